@@ -0,0 +1,54 @@
+// Command audit-verify checks the audit log hash chains and exits non-zero if
+// any organization's chain has been tampered with.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+)
+
+func main() {
+	orgID := flag.Uint("org", 0, "verify only this organization's chain")
+	flag.Parse()
+
+	database.LogLevel = logger.Warn
+	if err := database.InitializeDatabase(); err != nil {
+		log.Fatalf("failed to initialize database: %v", err)
+	}
+	db := database.GetDB()
+
+	var results []audit.Result
+	if *orgID != 0 {
+		result, err := audit.Verify(db, *orgID)
+		if err != nil {
+			log.Fatalf("verification failed: %v", err)
+		}
+		results = append(results, result)
+	} else {
+		var err error
+		if results, err = audit.VerifyAll(db); err != nil {
+			log.Fatalf("verification failed: %v", err)
+		}
+	}
+
+	broken := false
+	for _, r := range results {
+		if r.Valid() {
+			fmt.Printf("organization %d: ok, %d events, head %s\n", r.OrganizationID, r.Events, r.Head)
+		} else {
+			broken = true
+			fmt.Printf("organization %d: BROKEN at seq %d: %s\n", r.OrganizationID, r.BrokenSeq, r.Problem)
+		}
+	}
+
+	if broken {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,170 @@
+// Package audit appends tamper-evident records of changes. Each organization's
+// events form a hash chain: an event's hash covers its content and the hash of
+// the event before it, so editing, reordering or removing an event breaks
+// every hash after it.
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// ignoredFields change with every write or are loaded relations recorded as their own entities
+var ignoredFields = map[string]bool{
+	"updated_at":     true,
+	"version":        true,
+	"attachments":    true,
+	"ai_suggestions": true,
+	"organization":   true,
+	"user":           true,
+}
+
+// Change describes one mutation to record.
+type Change struct {
+	OrganizationID uint
+	ActorID        uint
+	RequestID      string
+	IP             string
+	Action         string
+	EntityType     string
+	EntityID       uint
+	// ExpenseID links changes to an expense or its attachments into the expense's history
+	ExpenseID uint
+	// Before and After are the entity's state around the change, nil when it did not exist
+	Before interface{}
+	After  interface{}
+}
+
+// Record appends the change to its organization's chain. It must run in the
+// transaction making the change so that both commit or neither does. Updates
+// that leave every recorded field unchanged are skipped.
+func Record(tx *gorm.DB, c Change) error {
+	before, after, err := Diff(c.Before, c.After)
+	if err != nil {
+		return err
+	}
+	if c.Before != nil && c.After != nil && before == "" && after == "" {
+		return nil
+	}
+
+	event := &models.AuditEvent{
+		OrganizationID: c.OrganizationID,
+		ActorID:        c.ActorID,
+		Action:         c.Action,
+		EntityType:     c.EntityType,
+		EntityID:       c.EntityID,
+		Before:         models.RawJSON(before),
+		After:          models.RawJSON(after),
+		RequestID:      c.RequestID,
+		IP:             c.IP,
+		// Stored times round-trip exactly at microsecond precision, keeping hashes reproducible
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if c.ExpenseID != 0 {
+		id := c.ExpenseID
+		event.ExpenseID = &id
+	}
+
+	db := database.WithTenant(tx, c.OrganizationID)
+	var last models.AuditEvent
+	if err := db.Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	event.Seq = last.Seq + 1
+	event.PrevHash = last.Hash
+	event.Hash = Hash(event)
+
+	// The unique (organization_id, seq) index turns a concurrent append into an error rather than a fork
+	return db.Create(event).Error
+}
+
+// Hash computes an event's chain hash from its content and PrevHash.
+func Hash(e *models.AuditEvent) string {
+	content := struct {
+		Seq            int64  `json:"seq"`
+		OrganizationID uint   `json:"organization_id"`
+		ActorID        uint   `json:"actor_id"`
+		Action         string `json:"action"`
+		EntityType     string `json:"entity_type"`
+		EntityID       uint   `json:"entity_id"`
+		ExpenseID      *uint  `json:"expense_id"`
+		Before         string `json:"before"`
+		After          string `json:"after"`
+		RequestID      string `json:"request_id"`
+		IP             string `json:"ip"`
+		CreatedAt      string `json:"created_at"`
+		PrevHash       string `json:"prev_hash"`
+	}{
+		e.Seq, e.OrganizationID, e.ActorID, e.Action, e.EntityType, e.EntityID, e.ExpenseID,
+		string(e.Before), string(e.After), e.RequestID, e.IP,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano), e.PrevHash,
+	}
+	payload, _ := json.Marshal(content)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff renders the fields that differ between two states of an entity as JSON
+// objects. A nil state yields an empty string and the other state in full.
+func Diff(before, after interface{}) (string, string, error) {
+	b, err := fields(before)
+	if err != nil {
+		return "", "", err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return "", "", err
+	}
+
+	if b != nil && a != nil {
+		for key, value := range b {
+			if other, ok := a[key]; ok && bytes.Equal(value, other) {
+				delete(b, key)
+				delete(a, key)
+			}
+		}
+	}
+	beforeJSON, err := encode(b)
+	if err != nil {
+		return "", "", err
+	}
+	afterJSON, err := encode(a)
+	if err != nil {
+		return "", "", err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func fields(state interface{}) (map[string]json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	for key := range ignoredFields {
+		delete(out, key)
+	}
+	return out, nil
+}
+
+func encode(state map[string]json.RawMessage) (string, error) {
+	if len(state) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(state)
+	return string(raw), err
+}
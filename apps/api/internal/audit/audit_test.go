@@ -0,0 +1,310 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+type tag struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// chains opens a database where organization 1 has five events and
+// organization 2, writing in between, has three
+func chains(t *testing.T) *gorm.DB {
+	t.Helper()
+	level := database.LogLevel
+	database.LogLevel = logger.Silent
+	t.Cleanup(func() { database.LogLevel = level })
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, org := range []uint{1, 2, 1, 1, 2, 1, 2, 1} {
+		record(t, db, org, fmt.Sprintf("tag %d", i))
+	}
+	return db
+}
+
+func record(t *testing.T, db *gorm.DB, org uint, name string) {
+	t.Helper()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Record(tx, Change{
+			OrganizationID: org, ActorID: 7, RequestID: "req-" + name, IP: "203.0.113.7",
+			Action: "tag.create", EntityType: "tag", EntityID: 1,
+			After: tag{ID: 1, Name: name, Color: "blue"},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func events(t *testing.T, db *gorm.DB, org uint) []models.AuditEvent {
+	t.Helper()
+	var out []models.AuditEvent
+	if err := database.WithTenant(db, org).Order("seq").Find(&out).Error; err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// tamper changes the stored events the way someone with access to the
+// database file could, past the triggers that normally refuse it
+func tamper(t *testing.T, db *gorm.DB, statements ...string) {
+	t.Helper()
+	for _, sql := range append([]string{
+		"DROP TRIGGER IF EXISTS audit_events_no_update",
+		"DROP TRIGGER IF EXISTS audit_events_no_delete",
+	}, statements...) {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+}
+
+func TestSeqIsContiguousPerOrganization(t *testing.T) {
+	db := chains(t)
+	for org, want := range map[uint]int{1: 5, 2: 3} {
+		list := events(t, db, org)
+		if len(list) != want {
+			t.Fatalf("organization %d has %d events, want %d", org, len(list), want)
+		}
+		prev := ""
+		for i, e := range list {
+			if e.Seq != int64(i+1) {
+				t.Errorf("organization %d event %d has seq %d", org, i, e.Seq)
+			}
+			if e.OrganizationID != org {
+				t.Errorf("organization %d lists an event of organization %d", org, e.OrganizationID)
+			}
+			if e.PrevHash != prev {
+				t.Errorf("organization %d seq %d does not chain to the event before it", org, e.Seq)
+			}
+			if e.Hash != Hash(&e) {
+				t.Errorf("organization %d seq %d has a hash that does not match its content", org, e.Seq)
+			}
+			prev = e.Hash
+		}
+	}
+}
+
+func TestEventsAreImmutable(t *testing.T) {
+	db := chains(t)
+	scoped := database.WithTenant(db, 1)
+	if err := scoped.Model(&models.AuditEvent{}).Where("seq = 2").Update("action", "tag.delete").Error; err == nil {
+		t.Error("updating an audit event succeeded")
+	}
+	if err := db.Exec("UPDATE audit_events SET action = 'tag.delete' WHERE seq = 2").Error; err == nil {
+		t.Error("updating an audit event with SQL succeeded")
+	}
+	if err := db.Exec("DELETE FROM audit_events WHERE seq = 2").Error; err == nil {
+		t.Error("deleting an audit event with SQL succeeded")
+	}
+	if result, err := Verify(db, 1); err != nil || !result.Valid() {
+		t.Errorf("Verify() = %+v, %v", result, err)
+	}
+}
+
+func TestRecordSkipsUnchangedUpdates(t *testing.T) {
+	db := chains(t)
+	state := tag{ID: 1, Name: "tag 0", Color: "blue"}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Record(tx, Change{OrganizationID: 1, Action: "tag.update", EntityType: "tag", EntityID: 1, Before: state, After: state})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(events(t, db, 1)); n != 5 {
+		t.Errorf("organization 1 has %d events after an unchanged update, want 5", n)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  []string
+		// broken is the seq Verify reports for organization 1, zero when it passes
+		broken  int64
+		problem string
+		events  int64
+		// others is how many events organization 2 keeps, all of them unless moved
+		others int64
+	}{
+		{name: "intact", events: 5},
+		{
+			name:    "modified content",
+			sql:     []string{`UPDATE audit_events SET "after" = '{"name":"renamed"}' WHERE organization_id = 1 AND seq = 3`},
+			broken:  3,
+			problem: "content does not match its hash",
+		},
+		{
+			name:    "modified actor",
+			sql:     []string{"UPDATE audit_events SET actor_id = 8 WHERE organization_id = 1 AND seq = 1"},
+			broken:  1,
+			problem: "content does not match its hash",
+		},
+		{
+			name:    "modified time",
+			sql:     []string{"UPDATE audit_events SET created_at = '2020-01-01 00:00:00+00:00' WHERE organization_id = 1 AND seq = 5"},
+			broken:  5,
+			problem: "content does not match its hash",
+		},
+		{
+			name:    "changed hash",
+			sql:     []string{"UPDATE audit_events SET hash = prev_hash WHERE organization_id = 1 AND seq = 2"},
+			broken:  2,
+			problem: "content does not match its hash",
+		},
+		{
+			name:    "deleted in the middle",
+			sql:     []string{"DELETE FROM audit_events WHERE organization_id = 1 AND seq = 3"},
+			broken:  3,
+			problem: "event missing before seq 4",
+		},
+		{
+			name:    "deleted first",
+			sql:     []string{"DELETE FROM audit_events WHERE organization_id = 1 AND seq = 1"},
+			broken:  1,
+			problem: "event missing before seq 2",
+		},
+		{
+			name:    "deleted and renumbered",
+			sql:     []string{"DELETE FROM audit_events WHERE organization_id = 1 AND seq = 3", "UPDATE audit_events SET seq = seq - 1 WHERE organization_id = 1 AND seq > 3"},
+			broken:  3,
+			problem: "previous hash does not match the preceding event",
+		},
+		{
+			name: "reordered",
+			sql: []string{
+				"UPDATE audit_events SET seq = -1 WHERE organization_id = 1 AND seq = 2",
+				"UPDATE audit_events SET seq = 2 WHERE organization_id = 1 AND seq = 3",
+				"UPDATE audit_events SET seq = 3 WHERE organization_id = 1 AND seq = -1",
+			},
+			broken:  2,
+			problem: "previous hash does not match the preceding event",
+		},
+		{
+			name:    "moved from another organization",
+			sql:     []string{"UPDATE audit_events SET organization_id = 1, seq = 6 WHERE organization_id = 2 AND seq = 3"},
+			broken:  6,
+			problem: "previous hash does not match the preceding event",
+			others:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := chains(t)
+			tamper(t, db, tt.sql...)
+
+			result, err := Verify(db, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.BrokenSeq != tt.broken || result.Problem != tt.problem {
+				t.Errorf("Verify() = seq %d %q, want seq %d %q", result.BrokenSeq, result.Problem, tt.broken, tt.problem)
+			}
+			if tt.broken == 0 && result.Events != tt.events {
+				t.Errorf("Verify() checked %d events, want %d", result.Events, tt.events)
+			}
+
+			// The other organization's chain still verifies
+			others := tt.others
+			if others == 0 {
+				others = 3
+			}
+			if other, err := Verify(db, 2); err != nil || !other.Valid() || other.Events != others {
+				t.Errorf("Verify() of organization 2 = %+v, %v, want %d valid events", other, err, others)
+			}
+		})
+	}
+}
+
+func TestVerifyHeadExposesTruncation(t *testing.T) {
+	db := chains(t)
+	before, err := Verify(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := events(t, db, 1)
+	if before.Head != list[len(list)-1].Hash {
+		t.Fatalf("Head = %s, want the last event's hash", before.Head)
+	}
+
+	// Removing events from the end leaves a valid but shorter chain
+	tamper(t, db, "DELETE FROM audit_events WHERE organization_id = 1 AND seq >= 4")
+	after, err := Verify(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Valid() || after.Events != 3 {
+		t.Fatalf("Verify() = %+v", after)
+	}
+	if after.Head == before.Head {
+		t.Error("Head did not change when events were removed from the end")
+	}
+}
+
+func TestVerifyAcrossBatches(t *testing.T) {
+	db := chains(t)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < 2*verifyBatchSize; i++ {
+			if err := Record(tx, Change{
+				OrganizationID: 1, Action: "tag.create", EntityType: "tag", EntityID: uint(i),
+				After: tag{ID: uint(i), Name: fmt.Sprint(i)},
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := int64(5 + 2*verifyBatchSize)
+	if result, err := Verify(db, 1); err != nil || !result.Valid() || result.Events != total {
+		t.Fatalf("Verify() = %+v, %v, want %d valid events", result, err, total)
+	}
+
+	// Batches are fetched after the last seq seen, so a gap at a batch boundary is still found
+	broken := int64(verifyBatchSize + 1)
+	tamper(t, db, fmt.Sprintf("DELETE FROM audit_events WHERE organization_id = 1 AND seq = %d", broken))
+	result, err := Verify(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BrokenSeq != broken || !strings.HasPrefix(result.Problem, "event missing") {
+		t.Errorf("Verify() = seq %d %q, want seq %d", result.BrokenSeq, result.Problem, broken)
+	}
+}
+
+func TestVerifyAll(t *testing.T) {
+	db := chains(t)
+	tamper(t, db, "UPDATE audit_events SET ip = '198.51.100.1' WHERE organization_id = 2 AND seq = 2")
+
+	results, err := VerifyAll(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("VerifyAll() returned %d results, want 2", len(results))
+	}
+	if r := results[0]; r.OrganizationID != 1 || !r.Valid() || r.Events != 5 {
+		t.Errorf("organization 1: %+v", r)
+	}
+	if r := results[1]; r.OrganizationID != 2 || r.BrokenSeq != 2 {
+		t.Errorf("organization 2: %+v", r)
+	}
+}
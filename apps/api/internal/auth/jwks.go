@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// keySetRecheckInterval bounds how often the JWKS file is re-read when the
+// requested key is already known.
+const keySetRecheckInterval = time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// KeySet holds RSA public keys loaded from a local JWKS file. The file is
+// re-read when it changes on disk so keys can be rotated without a restart.
+type KeySet struct {
+	path string
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	modTime     time.Time
+	lastChecked time.Time
+}
+
+// LoadKeySet reads the JWKS document at path.
+func LoadKeySet(path string) (*KeySet, error) {
+	ks := &KeySet{path: path}
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Key returns the public key with the given key ID. An empty kid is accepted
+// only when the set contains exactly one key.
+func (ks *KeySet) Key(kid string) (*rsa.PublicKey, error) {
+	key, found, stale := ks.lookup(kid)
+	if !found || stale {
+		if err := ks.reloadIfChanged(); err != nil {
+			return nil, err
+		}
+		key, found, _ = ks.lookup(kid)
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (ks *KeySet) lookup(kid string) (*rsa.PublicKey, bool, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	stale := time.Since(ks.lastChecked) > keySetRecheckInterval
+	if kid == "" {
+		if len(ks.keys) != 1 {
+			return nil, false, stale
+		}
+		for _, key := range ks.keys {
+			return key, true, stale
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok, stale
+}
+
+func (ks *KeySet) reloadIfChanged() error {
+	info, err := os.Stat(ks.path)
+	if err != nil {
+		return fmt.Errorf("stat jwks file: %w", err)
+	}
+
+	ks.mu.RLock()
+	unchanged := info.ModTime().Equal(ks.modTime)
+	ks.mu.RUnlock()
+
+	if unchanged {
+		ks.mu.Lock()
+		ks.lastChecked = time.Now()
+		ks.mu.Unlock()
+		return nil
+	}
+	return ks.reload()
+}
+
+func (ks *KeySet) reload() error {
+	info, err := os.Stat(ks.path)
+	if err != nil {
+		return fmt.Errorf("stat jwks file: %w", err)
+	}
+	raw, err := os.ReadFile(ks.path)
+	if err != nil {
+		return fmt.Errorf("read jwks file: %w", err)
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return fmt.Errorf("parse jwks file: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		key, err := parseRSAKey(k)
+		if err != nil {
+			return fmt.Errorf("jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks file contains no RSA signing keys")
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.modTime = info.ModTime()
+	ks.lastChecked = time.Now()
+	ks.mu.Unlock()
+	return nil
+}
+
+func parseRSAKey(k jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("decode modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("decode exponent: %w", err)
+	}
+	if len(nBytes) == 0 || len(eBytes) == 0 {
+		return nil, errors.New("missing modulus or exponent")
+	}
+
+	e := new(big.Int).SetBytes(eBytes)
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent out of range")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(e.Int64()),
+	}, nil
+}
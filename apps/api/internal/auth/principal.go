@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// ErrNotMember is returned when a caller asks to act within an organization
+// they do not belong to.
+var ErrNotMember = errors.New("not a member of the requested organization")
+
+// Principal identifies the authenticated caller of a request and the
+// organization they are acting in.
+type Principal struct {
+	UserID         uint
+	Subject        string
+	Email          string
+	OrganizationID uint
+	Role           models.Role
+	// RequestID and ClientIP identify the request for the audit log
+	RequestID string
+	ClientIP  string
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the given principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored on ctx, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
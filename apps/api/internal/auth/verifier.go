@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config controls which tokens the Verifier accepts.
+type Config struct {
+	// HMACSecret enables HS256 tokens signed with a shared secret.
+	HMACSecret string
+	// JWKSFile enables RS256 tokens verified against keys in a local JWKS file.
+	JWKSFile string
+	// Issuer and Audience, when set, must match the token's iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew when validating exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// Claims are the JWT claims understood by the API.
+type Claims struct {
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier validates bearer tokens.
+type Verifier struct {
+	cfg     Config
+	secret  []byte
+	keys    *KeySet
+	methods []string
+}
+
+// NewVerifier builds a verifier from cfg. At least one of HMACSecret or
+// JWKSFile must be configured.
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{cfg: cfg}
+
+	if cfg.HMACSecret != "" {
+		if len(cfg.HMACSecret) < 32 {
+			return nil, errors.New("HMAC secret must be at least 32 characters")
+		}
+		v.secret = []byte(cfg.HMACSecret)
+		v.methods = append(v.methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := LoadKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		v.methods = append(v.methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	if len(v.methods) == 0 {
+		return nil, errors.New("no token signing keys configured")
+	}
+
+	return v, nil
+}
+
+// Verify parses token, checks its signature and standard claims and returns
+// the claims on success.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(v.methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.cfg.Leeway),
+	}
+	if v.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.cfg.Issuer))
+	}
+	if v.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.cfg.Audience))
+	}
+
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.keyFunc, opts...); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	switch t.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+}
@@ -0,0 +1,233 @@
+// Package authz decides what a principal may do based on their organization role.
+package authz
+
+import (
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// Action is an operation subject to access control.
+type Action string
+
+const (
+	ExpenseCreate Action = "expense:create"
+	ExpenseRead   Action = "expense:read"
+	ExpenseUpdate Action = "expense:update"
+	ExpenseDelete Action = "expense:delete"
+
+	// TrashRead lists soft-deleted expenses and attachments; restoring one
+	// needs the matching delete permission.
+	TrashRead Action = "trash:read"
+
+	ExpenseSubmit    Action = "expense:submit"
+	ExpenseApprove   Action = "expense:approve"
+	ExpenseReimburse Action = "expense:reimburse"
+
+	// ReimbursementRead lists reimbursement batches and what is due;
+	// managing batches needs ExpenseReimburse.
+	ReimbursementRead Action = "reimbursement:read"
+	// BankAccountManage sets the bank account a member is reimbursed to.
+	BankAccountManage Action = "bank-account:manage"
+
+	ApprovalRuleRead   Action = "approval-rule:read"
+	ApprovalRuleManage Action = "approval-rule:manage"
+
+	PolicyRead   Action = "policy:read"
+	PolicyManage Action = "policy:manage"
+
+	CategoryRead   Action = "category:read"
+	CategoryManage Action = "category:manage"
+
+	ProjectRead      Action = "project:read"
+	ProjectManage    Action = "project:manage"
+	CostCenterRead   Action = "cost-center:read"
+	CostCenterManage Action = "cost-center:manage"
+	TagRead          Action = "tag:read"
+	TagManage        Action = "tag:manage"
+	MerchantRead     Action = "merchant:read"
+	MerchantManage   Action = "merchant:manage"
+
+	AllowanceRateRead   Action = "allowance-rate:read"
+	AllowanceRateManage Action = "allowance-rate:manage"
+
+	TaxRateRead   Action = "tax-rate:read"
+	TaxRateManage Action = "tax-rate:manage"
+	// TaxReportRead produces the tax reclaim report over the organization's expenses.
+	TaxReportRead Action = "tax-report:read"
+
+	AttachmentUpload Action = "attachment:upload"
+	AttachmentRead   Action = "attachment:read"
+	AttachmentDelete Action = "attachment:delete"
+
+	MemberRead   Action = "member:read"
+	MemberInvite Action = "member:invite"
+	MemberManage Action = "member:manage"
+
+	OrganizationManage Action = "organization:manage"
+
+	ExchangeRateRead   Action = "exchange-rate:read"
+	ExchangeRateManage Action = "exchange-rate:manage"
+
+	AuditRead Action = "audit:read"
+)
+
+// Scope is how far an allowed action reaches.
+type Scope int
+
+const (
+	// None denies the action.
+	None Scope = iota
+	// Own allows the action on resources the principal owns.
+	Own
+	// Organization allows the action on every resource in the organization.
+	Organization
+)
+
+var memberPolicy = map[Action]Scope{
+	ExpenseCreate:     Own,
+	ExpenseRead:       Own,
+	ExpenseUpdate:     Own,
+	ExpenseDelete:     Own,
+	TrashRead:         Own,
+	ExpenseSubmit:     Own,
+	ApprovalRuleRead:  Organization,
+	PolicyRead:        Organization,
+	CategoryRead:      Organization,
+	ProjectRead:       Organization,
+	CostCenterRead:    Organization,
+	TagRead:           Organization,
+	MerchantRead:      Organization,
+	AttachmentUpload:  Own,
+	AttachmentRead:    Own,
+	AttachmentDelete:  Own,
+	MemberRead:        Organization,
+	ExchangeRateRead:  Organization,
+	AllowanceRateRead: Organization,
+	TaxRateRead:       Organization,
+	BankAccountManage: Own,
+}
+
+var approverPolicy = merge(memberPolicy, map[Action]Scope{
+	ExpenseRead:    Organization,
+	ExpenseApprove: Organization,
+	AttachmentRead: Organization,
+})
+
+var adminPolicy = map[Action]Scope{
+	ExpenseCreate:       Organization,
+	ExpenseRead:         Organization,
+	ExpenseUpdate:       Organization,
+	ExpenseDelete:       Organization,
+	TrashRead:           Organization,
+	ExpenseSubmit:       Organization,
+	ExpenseApprove:      Organization,
+	ExpenseReimburse:    Organization,
+	ReimbursementRead:   Organization,
+	BankAccountManage:   Own,
+	ApprovalRuleRead:    Organization,
+	ApprovalRuleManage:  Organization,
+	PolicyRead:          Organization,
+	PolicyManage:        Organization,
+	CategoryRead:        Organization,
+	CategoryManage:      Organization,
+	ProjectRead:         Organization,
+	ProjectManage:       Organization,
+	CostCenterRead:      Organization,
+	CostCenterManage:    Organization,
+	TagRead:             Organization,
+	TagManage:           Organization,
+	MerchantRead:        Organization,
+	MerchantManage:      Organization,
+	AttachmentUpload:    Organization,
+	AttachmentRead:      Organization,
+	AttachmentDelete:    Organization,
+	MemberRead:          Organization,
+	MemberInvite:        Organization,
+	MemberManage:        Organization,
+	OrganizationManage:  Organization,
+	ExchangeRateRead:    Organization,
+	ExchangeRateManage:  Organization,
+	AllowanceRateRead:   Organization,
+	AllowanceRateManage: Organization,
+	TaxRateRead:         Organization,
+	TaxRateManage:       Organization,
+	TaxReportRead:       Organization,
+	AuditRead:           Organization,
+}
+
+var auditorPolicy = map[Action]Scope{
+	ExpenseRead:       Organization,
+	ReimbursementRead: Organization,
+	TrashRead:         Organization,
+	ApprovalRuleRead:  Organization,
+	PolicyRead:        Organization,
+	CategoryRead:      Organization,
+	ProjectRead:       Organization,
+	CostCenterRead:    Organization,
+	TagRead:           Organization,
+	MerchantRead:      Organization,
+	AttachmentRead:    Organization,
+	MemberRead:        Organization,
+	ExchangeRateRead:  Organization,
+	AllowanceRateRead: Organization,
+	TaxRateRead:       Organization,
+	TaxReportRead:     Organization,
+	AuditRead:         Organization,
+}
+
+var policies = map[models.Role]map[Action]Scope{
+	models.RoleOwner:    adminPolicy,
+	models.RoleAdmin:    adminPolicy,
+	models.RoleApprover: approverPolicy,
+	models.RoleMember:   memberPolicy,
+	models.RoleAuditor:  auditorPolicy,
+}
+
+// ScopeOf returns how far the principal's role lets them perform action.
+func ScopeOf(p *auth.Principal, action Action) Scope {
+	if p == nil {
+		return None
+	}
+	return policies[p.Role][action]
+}
+
+// Allowed reports whether the principal may perform action on at least their own resources.
+func Allowed(p *auth.Principal, action Action) bool {
+	return ScopeOf(p, action) != None
+}
+
+// AllowedOn reports whether the principal may perform action on a resource owned by ownerID.
+func AllowedOn(p *auth.Principal, action Action, ownerID uint) bool {
+	switch ScopeOf(p, action) {
+	case Organization:
+		return true
+	case Own:
+		return ownerID == p.UserID
+	default:
+		return false
+	}
+}
+
+// CanAssignRole reports whether the principal may grant role to a member or
+// change a member currently holding current.
+func CanAssignRole(p *auth.Principal, current, role models.Role) bool {
+	if ScopeOf(p, MemberManage) == None {
+		return false
+	}
+	if p.Role == models.RoleOwner {
+		return true
+	}
+	// Only owners may create or modify owners
+	return current != models.RoleOwner && role != models.RoleOwner
+}
+
+func merge(base, overrides map[Action]Scope) map[Action]Scope {
+	out := make(map[Action]Scope, len(base)+len(overrides))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range overrides {
+		out[k] = v
+	}
+	return out
+}
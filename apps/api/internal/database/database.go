@@ -1,7 +1,10 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -11,33 +14,254 @@ import (
 
 var DB *gorm.DB
 
+// LogLevel is the SQL log level used by InitializeDatabase
+var LogLevel = logger.Info
+
+// LegacyOwner is the token subject of the user given the expenses of a
+// database created before organizations existed
+var LegacyOwner string
+
 // InitializeDatabase initializes the SQLite database and runs migrations
 func InitializeDatabase() error {
-	var err error
-	
-	// Initialize SQLite database
-	DB, err = gorm.Open(sqlite.Open("expenses.db"), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	db, err := Open("expenses.db")
 	if err != nil {
 		return err
 	}
+	DB = db
+
+	log.Println("Database initialized successfully")
+	return nil
+}
+
+// Open opens the SQLite database at path and brings its schema and data up to date
+func Open(path string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+		Logger: logger.Default.LogMode(LogLevel),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tenant isolation must be in place before anything touches the data
+	if err := registerTenantCallbacks(db); err != nil {
+		return nil, err
+	}
 
 	// Auto-migrate the schemas
-	err = DB.AutoMigrate(
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.Organization{},
+		&models.Membership{},
+		&models.Invitation{},
 		&models.Expense{},
 		&models.Attachment{},
 		&models.AISuggestion{},
+		&models.ExpenseTransition{},
+		&models.ApprovalRule{},
+		&models.ExchangeRate{},
+		&models.AuditEvent{},
+		&models.IdempotencyRecord{},
+		&models.ReceiptMatch{},
+		&models.DuplicatePair{},
+		&models.PolicyRule{},
+		&models.PolicyViolation{},
+		&models.Category{},
+		&models.Project{},
+		&models.CostCenter{},
+		&models.Tag{},
+		&models.ExpenseAllocation{},
+		&models.MileageRate{},
+		&models.PerDiemRate{},
+		&models.TaxRate{},
+		&models.Merchant{},
+		&models.MerchantAlias{},
+		&models.RecurringExpense{},
+		&models.ExpenseReport{},
+		&models.ExpenseReportTransition{},
+		&models.BankAccount{},
+		&models.ReimbursementBatch{},
+		&models.ReimbursementPayment{},
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	log.Println("Database initialized successfully")
-	return nil
+	if err := migrateLegacyAmounts(db); err != nil {
+		return nil, err
+	}
+
+	if err := migrateLegacyOwnership(db); err != nil {
+		return nil, err
+	}
+
+	if err := seedMissingCategories(db); err != nil {
+		return nil, err
+	}
+
+	if err := protectAuditEvents(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
 }
 
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
-}
\ No newline at end of file
+}
+
+// SeedCategories gives an organization the default categories
+func SeedCategories(db *gorm.DB, organizationID uint) error {
+	categories := make([]models.Category, len(models.DefaultCategories))
+	for i, name := range models.DefaultCategories {
+		categories[i] = models.Category{OrganizationID: organizationID, Name: name}
+	}
+	return WithTenant(db, organizationID).Create(&categories).Error
+}
+
+// seedMissingCategories gives organizations created before categories were
+// stored per organization the default ones
+func seedMissingCategories(db *gorm.DB) error {
+	var ids []uint
+	err := db.Model(&models.Organization{}).
+		Where("NOT EXISTS (SELECT 1 FROM categories WHERE categories.organization_id = organizations.id)").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := SeedCategories(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protectAuditEvents makes the database itself refuse to change or remove
+// audit events, covering raw SQL that bypasses the model hooks
+func protectAuditEvents(db *gorm.DB) error {
+	for _, op := range []string{"UPDATE", "DELETE"} {
+		err := db.Exec(`CREATE TRIGGER IF NOT EXISTS audit_events_no_` + strings.ToLower(op) + `
+			BEFORE ` + op + ` ON audit_events
+			BEGIN SELECT RAISE(ABORT, 'audit events are immutable'); END`).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyAmounts moves amounts stored as floating point dollars into
+// integer cents and drops the old columns. Databases created before
+// multi-currency support only ever held USD.
+func migrateLegacyAmounts(db *gorm.DB) error {
+	migrator := db.Migrator()
+
+	if migrator.HasColumn(&models.Expense{}, "amount") {
+		err := db.Exec(`UPDATE expenses SET
+			amount_minor = CAST(ROUND(amount * 100) AS INTEGER),
+			currency = 'USD',
+			base_amount_minor = CAST(ROUND(amount * 100) AS INTEGER),
+			base_currency = 'USD',
+			exchange_rate = '1'
+			WHERE amount_minor = 0`).Error
+		if err != nil {
+			return err
+		}
+		if err := migrator.DropColumn(&models.Expense{}, "amount"); err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasColumn(&models.ApprovalRule{}, "min_amount") {
+		err := db.Exec(`UPDATE approval_rules SET
+			min_amount_minor = CAST(ROUND(min_amount * 100) AS INTEGER),
+			currency = 'USD'
+			WHERE min_amount_minor = 0`).Error
+		if err != nil {
+			return err
+		}
+		if err := migrator.DropColumn(&models.ApprovalRule{}, "min_amount"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateLegacyOwnership gives the expenses of a database created before
+// organizations existed, and their attachments and suggestions, to the
+// LegacyOwner user and the organization they own, created if they own none.
+// Without an owner those rows would be hidden from every organization, so
+// startup fails instead when LegacyOwner is not set.
+func migrateLegacyOwnership(db *gorm.DB) error {
+	db = AcrossTenants(db)
+
+	var orphans int64
+	err := db.Model(&models.Expense{}).
+		Where("organization_id IS NULL OR organization_id = 0 OR user_id IS NULL OR user_id = 0").
+		Count(&orphans).Error
+	if err != nil || orphans == 0 {
+		return err
+	}
+	subject := strings.TrimSpace(LegacyOwner)
+	if subject == "" {
+		return fmt.Errorf("%d expenses were created before organizations and have no owner; "+
+			"set LEGACY_OWNER_SUBJECT to the token subject of the user who should own them", orphans)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Where("subject = ?", subject).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = models.User{Subject: subject}
+			err = tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var membership models.Membership
+		err = tx.Where("user_id = ? AND role = ?", user.ID, models.RoleOwner).Order("id").First(&membership).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			org := models.Organization{Name: "Default"}
+			if err := tx.Create(&org).Error; err != nil {
+				return err
+			}
+			membership = models.Membership{OrganizationID: org.ID, UserID: user.ID, Role: models.RoleOwner}
+			err = tx.Create(&membership).Error
+		}
+		if err != nil {
+			return err
+		}
+		orgID := membership.OrganizationID
+
+		err = tx.Exec(`UPDATE expenses SET organization_id = ? WHERE organization_id IS NULL OR organization_id = 0`, orgID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`UPDATE expenses SET user_id = ? WHERE user_id IS NULL OR user_id = 0`, user.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// Attachments and suggestions follow their expense
+		for _, table := range []string{"attachments", "ai_suggestions"} {
+			err := tx.Exec(`UPDATE ` + table + ` SET organization_id =
+				(SELECT organization_id FROM expenses WHERE expenses.id = ` + table + `.expense_id)
+				WHERE organization_id IS NULL OR organization_id = 0`).Error
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.Exec(`UPDATE attachments SET user_id =
+			(SELECT user_id FROM expenses WHERE expenses.id = attachments.expense_id)
+			WHERE user_id IS NULL OR user_id = 0`).Error
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Gave %d expenses created before organizations to %s in organization %d", orphans, subject, orgID)
+		return nil
+	})
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// The schema of a database created before organizations and multi-currency amounts
+
+type legacyExpense struct {
+	ID          uint   `gorm:"primaryKey"`
+	Description string `gorm:"not null"`
+	Amount      float64
+	Date        time.Time
+	Category    string
+	ClientNotes string `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (legacyExpense) TableName() string { return "expenses" }
+
+type legacyAttachment struct {
+	ID          uint   `gorm:"primaryKey"`
+	ExpenseID   uint   `gorm:"not null"`
+	Filename    string `gorm:"not null"`
+	FilePath    string `gorm:"not null"`
+	ContentType string
+	FileSize    int64
+	UploadedAt  time.Time
+	StorageType string `gorm:"default:'local'"`
+}
+
+func (legacyAttachment) TableName() string { return "attachments" }
+
+type legacySuggestion struct {
+	ID                uint `gorm:"primaryKey"`
+	ExpenseID         uint `gorm:"not null"`
+	SuggestedCategory string
+	CreatedAt         time.Time
+}
+
+func (legacySuggestion) TableName() string { return "ai_suggestions" }
+
+func legacyDatabase(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "expenses.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&legacyExpense{}, &legacyAttachment{}, &legacySuggestion{}); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2023, 5, 2, 0, 0, 0, 0, time.UTC)
+	expenses := []legacyExpense{
+		{Description: "Taxi", Amount: 12.5, Date: date, Category: "Travel"},
+		{Description: "Lunch", Amount: 30.1, Date: date, Category: "Meals & Entertainment"},
+	}
+	if err := db.Create(&expenses).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&legacyAttachment{ExpenseID: expenses[0].ID, Filename: "taxi.pdf", FilePath: "uploads/taxi.pdf"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&legacySuggestion{ExpenseID: expenses[1].ID, SuggestedCategory: "Meals & Entertainment"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	return path
+}
+
+func openTest(t *testing.T, path, owner string) (*gorm.DB, error) {
+	t.Helper()
+	LogLevel, LegacyOwner = logger.Silent, owner
+	t.Cleanup(func() { LogLevel, LegacyOwner = logger.Info, "" })
+	db, err := Open(path)
+	if err == nil {
+		t.Cleanup(func() {
+			sqlDB, _ := db.DB()
+			sqlDB.Close()
+		})
+	}
+	return db, err
+}
+
+func TestUpgradeLegacyDatabase(t *testing.T) {
+	path := legacyDatabase(t)
+
+	// Without an owner the expenses would vanish from the API, so startup fails
+	_, err := openTest(t, path, "")
+	if err == nil || !strings.Contains(err.Error(), "LEGACY_OWNER_SUBJECT") {
+		t.Fatalf("got error %v, want one naming LEGACY_OWNER_SUBJECT", err)
+	}
+
+	db, err := openTest(t, path, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user models.User
+	if err := db.Where("subject = ?", "alice").First(&user).Error; err != nil {
+		t.Fatalf("owner was not created: %v", err)
+	}
+	var memberships []models.Membership
+	if err := db.Find(&memberships).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(memberships) != 1 || memberships[0].UserID != user.ID || memberships[0].Role != models.RoleOwner {
+		t.Fatalf("memberships = %+v, want alice as the only owner", memberships)
+	}
+	orgID := memberships[0].OrganizationID
+
+	var expenses []models.Expense
+	if err := WithTenant(db, orgID).Order("id").Find(&expenses).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(expenses) != 2 {
+		t.Fatalf("organization sees %d expenses, want 2", len(expenses))
+	}
+	for i, want := range []int64{1250, 3010} {
+		e := expenses[i]
+		if e.UserID != user.ID {
+			t.Errorf("expense %d belongs to user %d, want %d", e.ID, e.UserID, user.ID)
+		}
+		if e.AmountMinor != want || e.Currency != "USD" {
+			t.Errorf("expense %d amount = %d %s, want %d USD", e.ID, e.AmountMinor, e.Currency, want)
+		}
+	}
+
+	var attachments []models.Attachment
+	if err := WithTenant(db, orgID).Find(&attachments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 1 || attachments[0].UserID != user.ID {
+		t.Errorf("attachments = %+v, want one uploaded by user %d", attachments, user.ID)
+	}
+	var suggestions int64
+	if err := WithTenant(db, orgID).Model(&models.AISuggestion{}).Count(&suggestions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if suggestions != 1 {
+		t.Errorf("organization sees %d suggestions, want 1", suggestions)
+	}
+	var categories int64
+	if err := WithTenant(db, orgID).Model(&models.Category{}).Count(&categories).Error; err != nil {
+		t.Fatal(err)
+	}
+	if categories != int64(len(models.DefaultCategories)) {
+		t.Errorf("organization has %d categories, want the %d defaults", categories, len(models.DefaultCategories))
+	}
+}
+
+func TestUpgradeIsDoneOnce(t *testing.T) {
+	path := legacyDatabase(t)
+	db, err := openTest(t, path, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	// Once upgraded, the database opens without an owner and gains no organization
+	db, err = openTest(t, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var organizations int64
+	if err := db.Model(&models.Organization{}).Count(&organizations).Error; err != nil {
+		t.Fatal(err)
+	}
+	if organizations != 1 {
+		t.Errorf("got %d organizations, want 1", organizations)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	db, err := openTest(t, filepath.Join(t.TempDir(), "expenses.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WithTenant(db, 1).Create(&models.Tag{Name: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := WithTenant(db, 2).Create(&models.Tag{Name: "two"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var tags []models.Tag
+	if err := WithTenant(db, 1).Find(&tags).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "one" {
+		t.Errorf("organization 1 sees %+v", tags)
+	}
+	if err := db.Find(&tags).Error; err != ErrMissingTenant {
+		t.Errorf("unscoped query error = %v, want ErrMissingTenant", err)
+	}
+	if err := WithTenant(db, 1).Create(&models.Tag{OrganizationID: 2, Name: "three"}).Error; err != ErrCrossTenantWrite {
+		t.Errorf("cross-tenant create error = %v, want ErrCrossTenantWrite", err)
+	}
+	var all int64
+	if err := AcrossTenants(db).Model(&models.Tag{}).Count(&all).Error; err != nil || all != 2 {
+		t.Errorf("across tenants counted %d tags (%v), want 2", all, err)
+	}
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// ErrMissingTenant is returned when a tenant-owned model is accessed without
+// first choosing an organization via WithTenant or AcrossTenants.
+var ErrMissingTenant = errors.New("database: tenant scope required for tenant-owned model")
+
+// ErrCrossTenantWrite is returned when a row is created for an organization
+// other than the session's tenant.
+var ErrCrossTenantWrite = errors.New("database: row belongs to a different tenant")
+
+type tenantContextKey struct{}
+
+type tenantScope struct {
+	organizationID uint
+	all            bool
+}
+
+// WithTenant returns a session whose statements on tenant-owned models are
+// restricted to, and create rows in, the given organization.
+func WithTenant(db *gorm.DB, organizationID uint) *gorm.DB {
+	return withScope(db, tenantScope{organizationID: organizationID})
+}
+
+// AcrossTenants returns a session that may read and write every
+// organization's rows. It is meant for background jobs, not request handling.
+func AcrossTenants(db *gorm.DB) *gorm.DB {
+	return withScope(db, tenantScope{all: true})
+}
+
+func withScope(db *gorm.DB, scope tenantScope) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The scope travels on the context because gorm copies it into every
+	// derived statement, including preloads and association writes.
+	return db.WithContext(context.WithValue(ctx, tenantContextKey{}, scope))
+}
+
+func registerTenantCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("tenant:create", assignTenant); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("tenant:query", filterTenant); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:update", filterTenant); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:delete", filterTenant); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("tenant:row", filterTenant)
+}
+
+func tenantOwned(stmt *gorm.Statement) bool {
+	if stmt.Schema == nil || stmt.Schema.LookUpField("OrganizationID") == nil {
+		return false
+	}
+	_, ok := reflect.New(stmt.Schema.ModelType).Interface().(models.TenantOwned)
+	return ok
+}
+
+func scopeOf(db *gorm.DB) (tenantScope, bool) {
+	if db.Statement.Context == nil {
+		return tenantScope{}, false
+	}
+	scope, ok := db.Statement.Context.Value(tenantContextKey{}).(tenantScope)
+	return scope, ok
+}
+
+func filterTenant(db *gorm.DB) {
+	if db.Error != nil || !tenantOwned(db.Statement) {
+		return
+	}
+
+	scope, ok := scopeOf(db)
+	if !ok {
+		db.AddError(ErrMissingTenant)
+		return
+	}
+	if scope.all {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{
+			Column: clause.Column{Table: db.Statement.Table, Name: "organization_id"},
+			Value:  scope.organizationID,
+		},
+	}})
+}
+
+func assignTenant(db *gorm.DB) {
+	if db.Error != nil || !tenantOwned(db.Statement) {
+		return
+	}
+
+	scope, ok := scopeOf(db)
+	if !ok {
+		db.AddError(ErrMissingTenant)
+		return
+	}
+	if scope.all {
+		return
+	}
+
+	field := db.Statement.Schema.LookUpField("OrganizationID")
+	ctx := db.Statement.Context
+	assign := func(rv reflect.Value) {
+		current, zero := field.ValueOf(ctx, rv)
+		if zero {
+			if err := field.Set(ctx, rv, scope.organizationID); err != nil {
+				db.AddError(err)
+			}
+			return
+		}
+		if current != scope.organizationID {
+			db.AddError(ErrCrossTenantWrite)
+		}
+	}
+
+	switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			assign(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		assign(rv)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type AllowanceHandler struct {
+	allowanceService *services.AllowanceService
+}
+
+func NewAllowanceHandler() *AllowanceHandler {
+	return &AllowanceHandler{
+		allowanceService: services.NewAllowanceService(),
+	}
+}
+
+// ListMileageRates handles GET /api/mileage-rates
+func (h *AllowanceHandler) ListMileageRates(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AllowanceRateRead)
+	if !ok {
+		return
+	}
+
+	rates, err := h.allowanceService.ListMileageRates(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve mileage rates")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// CreateMileageRate handles POST /api/mileage-rates
+func (h *AllowanceHandler) CreateMileageRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AllowanceRateManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreateMileageRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rate, err := h.allowanceService.CreateMileageRate(p, req)
+	if err != nil {
+		if !writeRateTableError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to save mileage rate")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rate)
+}
+
+// DeleteMileageRate handles DELETE /api/mileage-rates/{rate_id}
+func (h *AllowanceHandler) DeleteMileageRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AllowanceRateManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["rate_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rate ID")
+		return
+	}
+
+	if err := h.allowanceService.DeleteMileageRate(p, uint(id)); err != nil {
+		if err.Error() == "rate not found" {
+			writeError(w, http.StatusNotFound, "Mileage rate not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to delete mileage rate")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPerDiemRates handles GET /api/per-diem-rates
+func (h *AllowanceHandler) ListPerDiemRates(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AllowanceRateRead)
+	if !ok {
+		return
+	}
+
+	rates, err := h.allowanceService.ListPerDiemRates(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve per-diem rates")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// CreatePerDiemRate handles POST /api/per-diem-rates
+func (h *AllowanceHandler) CreatePerDiemRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AllowanceRateManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePerDiemRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rate, err := h.allowanceService.CreatePerDiemRate(p, req)
+	if err != nil {
+		if !writeRateTableError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to save per-diem rate")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rate)
+}
+
+// DeletePerDiemRate handles DELETE /api/per-diem-rates/{rate_id}
+func (h *AllowanceHandler) DeletePerDiemRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AllowanceRateManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["rate_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rate ID")
+		return
+	}
+
+	if err := h.allowanceService.DeletePerDiemRate(p, uint(id)); err != nil {
+		if err.Error() == "rate not found" {
+			writeError(w, http.StatusNotFound, "Per-diem rate not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to delete per-diem rate")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeRateTableError reports mileage and per-diem rate validation failures.
+// It returns false if err is not one of them.
+func writeRateTableError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "invalid effective date":
+		writeError(w, http.StatusBadRequest, "effective_from must be a date, expected YYYY-MM-DD")
+	case "invalid country":
+		writeError(w, http.StatusBadRequest, "country must be a two-letter ISO 3166 code, or empty for the default rate")
+	case "invalid distance unit":
+		writeError(w, http.StatusBadRequest, "unit must be km or mi")
+	case "unsupported currency":
+		writeError(w, http.StatusBadRequest, "Unsupported currency")
+	case "invalid rate":
+		writeError(w, http.StatusBadRequest, "Rate must be a positive number")
+	case "invalid percent":
+		writeError(w, http.StatusBadRequest, "Percents must be between 0 and 100")
+	case "invalid minimum hours":
+		writeError(w, http.StatusBadRequest, "min_partial_day_hours must be between 0 and 24")
+	default:
+		return false
+	}
+	return true
+}
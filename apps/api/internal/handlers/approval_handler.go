@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type ApprovalHandler struct {
+	approvalService *services.ApprovalService
+}
+
+func NewApprovalHandler() *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: services.NewApprovalService(),
+	}
+}
+
+// SubmitExpense handles POST /api/expenses/{expense_id}/submit
+func (h *ApprovalHandler) SubmitExpense(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionSubmit)
+}
+
+// ApproveExpense handles POST /api/expenses/{expense_id}/approve
+func (h *ApprovalHandler) ApproveExpense(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionApprove)
+}
+
+// RejectExpense handles POST /api/expenses/{expense_id}/reject
+func (h *ApprovalHandler) RejectExpense(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionReject)
+}
+
+// ReimburseExpense handles POST /api/expenses/{expense_id}/reimburse
+func (h *ApprovalHandler) ReimburseExpense(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionReimburse)
+}
+
+// GetTransitions handles GET /api/expenses/{expense_id}/transitions
+func (h *ApprovalHandler) GetTransitions(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	expenseID, err := strconv.ParseUint(mux.Vars(r)["expense_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense ID")
+		return
+	}
+
+	transitions, err := h.approvalService.GetTransitions(p, uint(expenseID))
+	if err != nil {
+		if err.Error() == "expense not found" {
+			writeError(w, http.StatusNotFound, "Expense not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve transitions")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transitions)
+}
+
+// ListRules handles GET /api/approval-rules
+func (h *ApprovalHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ApprovalRuleRead)
+	if !ok {
+		return
+	}
+
+	rules, err := h.approvalService.ListRules(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve approval rules")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// CreateRule handles POST /api/approval-rules
+func (h *ApprovalHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ApprovalRuleManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreateApprovalRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.approvalService.CreateRule(p, req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid min_amount", "min_amount must not be negative", "required_approvals must be at least 1":
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to create approval rule")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// DeleteRule handles DELETE /api/approval-rules/{rule_id}
+func (h *ApprovalHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ApprovalRuleManage)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.ParseUint(mux.Vars(r)["rule_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	if err := h.approvalService.DeleteRule(p, uint(ruleID)); err != nil {
+		if err.Error() == "approval rule not found" {
+			writeError(w, http.StatusNotFound, "Approval rule not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to delete approval rule")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// transition runs a workflow action against the expense in the URL
+func (h *ApprovalHandler) transition(w http.ResponseWriter, r *http.Request, action models.ExpenseAction) {
+	permission, _ := services.WorkflowPermission(action)
+	p, ok := authorize(w, r, permission)
+	if !ok {
+		return
+	}
+
+	expenseID, err := strconv.ParseUint(mux.Vars(r)["expense_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense ID")
+		return
+	}
+
+	// The comment is optional, so an empty body is fine
+	var req models.TransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	expense, err := h.approvalService.Transition(p, uint(expenseID), action, req.Comment)
+	if err != nil {
+		if writePolicyError(w, err, expense) {
+			return
+		}
+		switch err.Error() {
+		case "expense not found":
+			writeError(w, http.StatusNotFound, "Expense not found")
+		case "permission denied", "cannot review own expense", "already approved by this user":
+			writeError(w, http.StatusForbidden, err.Error())
+		case "invalid transition", "expense was modified concurrently":
+			writeError(w, http.StatusConflict, err.Error())
+		case "expense is on a report":
+			writeError(w, http.StatusConflict, "Expense is on a report; take the workflow step on the report instead")
+		case "expense is in a reimbursement batch":
+			writeError(w, http.StatusConflict, "Expense is in a reimbursement batch and is reimbursed when the batch is reconciled")
+		case "a comment is required when rejecting":
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to "+string(action)+" expense")
+		}
+		return
+	}
+
+	writeExpense(w, http.StatusOK, expense)
+}
@@ -7,6 +7,7 @@ import (
     
     "github.com/gorilla/mux"
     
+    "github.com/example/next-go-monorepo/apps/api/internal/authz"
     "github.com/example/next-go-monorepo/apps/api/internal/services"
 )
 
@@ -22,6 +23,11 @@ func NewAttachmentHandler() *AttachmentHandler {
 
 // UploadAttachment handles POST /api/expenses/{expense_id}/attachments
 func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+    p, ok := authorize(w, r, authz.AttachmentUpload)
+    if !ok {
+        return
+    }
+    
     vars := mux.Vars(r)
     expenseID, err := strconv.ParseUint(vars["expense_id"], 10, 32)
     if err != nil {
@@ -48,11 +54,16 @@ func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Requ
         return
     }
     
-    attachment, err := h.attachmentService.UploadAttachment(uint(expenseID), header)
+    attachment, err := h.attachmentService.UploadAttachment(p, uint(expenseID), header)
     if err != nil {
-        if err.Error() == "expense not found" {
+        switch err.Error() {
+        case "expense not found":
             writeError(w, http.StatusNotFound, "Expense not found")
-        } else {
+        case "permission denied":
+            writeError(w, http.StatusForbidden, "You are not allowed to attach files to this expense")
+        case "expense is locked":
+            writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
+        default:
             writeError(w, http.StatusInternalServerError, "Failed to upload file")
         }
         return
@@ -63,6 +74,11 @@ func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Requ
 
 // GetAttachment handles GET /api/expenses/{expense_id}/attachments/{attachment_id}
 func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+    p, ok := authorize(w, r, authz.AttachmentRead)
+    if !ok {
+        return
+    }
+    
     vars := mux.Vars(r)
     
     expenseID, err := strconv.ParseUint(vars["expense_id"], 10, 32)
@@ -77,7 +93,7 @@ func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request
         return
     }
     
-    reader, contentType, size, err := h.attachmentService.GetAttachmentFile(uint(expenseID), uint(attachmentID))
+    reader, contentType, size, err := h.attachmentService.GetAttachmentFile(p, uint(expenseID), uint(attachmentID))
     if err != nil {
         switch {
         case err.Error() == "attachment not found":
@@ -106,6 +122,11 @@ func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request
 
 // DeleteAttachment handles DELETE /api/expenses/{expense_id}/attachments/{attachment_id}
 func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+    p, ok := authorize(w, r, authz.AttachmentDelete)
+    if !ok {
+        return
+    }
+    
     vars := mux.Vars(r)
     
     expenseID, err := strconv.ParseUint(vars["expense_id"], 10, 32)
@@ -120,11 +141,16 @@ func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Requ
         return
     }
     
-    err = h.attachmentService.DeleteAttachment(uint(expenseID), uint(attachmentID))
+    err = h.attachmentService.DeleteAttachment(p, uint(expenseID), uint(attachmentID))
     if err != nil {
-        if err.Error() == "attachment not found" {
+        switch err.Error() {
+        case "attachment not found":
             writeError(w, http.StatusNotFound, "Attachment not found")
-        } else {
+        case "permission denied":
+            writeError(w, http.StatusForbidden, "You are not allowed to delete this attachment")
+        case "expense is locked":
+            writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
+        default:
             writeError(w, http.StatusInternalServerError, "Failed to delete attachment")
         }
         return
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/pagination"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{
+		auditService: services.NewAuditService(),
+	}
+}
+
+// GetExpenseHistory handles GET /api/expenses/{expense_id}/history
+func (h *AuditHandler) GetExpenseHistory(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["expense_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense ID")
+		return
+	}
+
+	events, err := h.auditService.ExpenseHistory(p, uint(id))
+	if err != nil {
+		if err.Error() == "expense not found" {
+			writeError(w, http.StatusNotFound, "Expense not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve expense history")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// ListAuditEvents handles GET /api/audit-events
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AuditRead)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var filter services.AuditFilter
+	for name, target := range map[string]*uint{
+		"actor_id":   &filter.ActorID,
+		"entity_id":  &filter.EntityID,
+		"expense_id": &filter.ExpenseID,
+	} {
+		if v := query.Get(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	filter.Action = strings.TrimSpace(query.Get("action"))
+	filter.EntityType = strings.TrimSpace(query.Get("entity_type"))
+
+	if v := query.Get("from"); v != "" {
+		t, _, err := parseDateParam(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from date, expected YYYY-MM-DD or RFC3339")
+			return
+		}
+		filter.From = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to date, expected YYYY-MM-DD or RFC3339")
+			return
+		}
+		if dateOnly {
+			// A bare date includes the whole day
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		filter.To = &t
+	}
+
+	limit := 100 // Default limit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			if l > 1000 { // Max limit for safety
+				l = 1000
+			}
+			limit = l
+		}
+	}
+
+	events, err := h.auditService.ListEvents(p, filter, query.Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve audit events")
+		}
+		return
+	}
+
+	if link := pagination.LinkHeader(r.URL, events.NextCursor, events.PrevCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type BankAccountHandler struct {
+	bankAccountService *services.BankAccountService
+}
+
+func NewBankAccountHandler() *BankAccountHandler {
+	return &BankAccountHandler{
+		bankAccountService: services.NewBankAccountService(),
+	}
+}
+
+// GetMyBankAccount handles GET /api/bank-account
+func (h *BankAccountHandler) GetMyBankAccount(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.BankAccountManage)
+	if !ok {
+		return
+	}
+
+	account, err := h.bankAccountService.GetMyBankAccount(p)
+	if err != nil {
+		if !writeBankAccountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve bank account")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+// SetMyBankAccount handles PUT /api/bank-account
+func (h *BankAccountHandler) SetMyBankAccount(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.BankAccountManage)
+	if !ok {
+		return
+	}
+
+	var req models.BankDetails
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	account, err := h.bankAccountService.SetMyBankAccount(p, req)
+	if err != nil {
+		if !writeBankAccountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to save bank account")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+// DeleteMyBankAccount handles DELETE /api/bank-account
+func (h *BankAccountHandler) DeleteMyBankAccount(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.BankAccountManage)
+	if !ok {
+		return
+	}
+
+	if err := h.bankAccountService.DeleteMyBankAccount(p); err != nil {
+		if !writeBankAccountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to delete bank account")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetOrganizationBankAccount handles GET /api/organizations/{org_id}/bank-account
+func (h *BankAccountHandler) GetOrganizationBankAccount(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.OrganizationManage)
+	if !ok {
+		return
+	}
+
+	account, err := h.bankAccountService.GetOrganizationBankAccount(p)
+	if err != nil {
+		if !writeBankAccountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve bank account")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+// SetOrganizationBankAccount handles PUT /api/organizations/{org_id}/bank-account
+func (h *BankAccountHandler) SetOrganizationBankAccount(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.OrganizationManage)
+	if !ok {
+		return
+	}
+
+	var req models.BankDetails
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	account, err := h.bankAccountService.SetOrganizationBankAccount(p, req)
+	if err != nil {
+		if !writeBankAccountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to save bank account")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+// DeleteOrganizationBankAccount handles DELETE /api/organizations/{org_id}/bank-account
+func (h *BankAccountHandler) DeleteOrganizationBankAccount(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.OrganizationManage)
+	if !ok {
+		return
+	}
+
+	if err := h.bankAccountService.DeleteOrganizationBankAccount(p); err != nil {
+		if !writeBankAccountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to delete bank account")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeBankAccountError reports bank account lookup, validation and
+// encryption failures. It returns false if err is not one of them.
+func writeBankAccountError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "bank account not found":
+		writeError(w, http.StatusNotFound, "Bank account not found")
+	case "bank details encryption is not configured":
+		writeError(w, http.StatusServiceUnavailable, "Bank details cannot be stored or read until BANK_DETAILS_KEY is configured")
+	case "bank details cannot be decrypted":
+		writeError(w, http.StatusInternalServerError, "Stored bank details cannot be decrypted with the configured key")
+	case "holder name is required":
+		writeError(w, http.StatusBadRequest, "holder_name is required")
+	case "bank details need an IBAN or a routing and account number":
+		writeError(w, http.StatusBadRequest, "Give an iban, or a routing_number and account_number")
+	case "invalid IBAN":
+		writeError(w, http.StatusBadRequest, "iban is not a valid IBAN")
+	case "invalid BIC":
+		writeError(w, http.StatusBadRequest, "bic must be an 8 or 11 character BIC")
+	case "invalid routing number":
+		writeError(w, http.StatusBadRequest, "routing_number must be a valid nine-digit ABA routing number")
+	case "invalid account number":
+		writeError(w, http.StatusBadRequest, "account_number must be 1 to 17 letters, digits or hyphens")
+	case "invalid account type":
+		writeError(w, http.StatusBadRequest, "account_type must be checking or savings")
+	case "invalid company ID":
+		writeError(w, http.StatusBadRequest, "company_id must be at most 10 characters")
+	default:
+		return false
+	}
+	return true
+}
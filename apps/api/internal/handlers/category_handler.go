@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type CategoryHandler struct {
+	categoryService *services.CategoryService
+}
+
+func NewCategoryHandler() *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: services.NewCategoryService(),
+	}
+}
+
+// ListCategories handles GET /api/categories
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CategoryRead)
+	if !ok {
+		return
+	}
+
+	includeArchived := false
+	if v := r.URL.Query().Get("include_archived"); v != "" {
+		var err error
+		if includeArchived, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "include_archived must be true or false")
+			return
+		}
+	}
+
+	categories, err := h.categoryService.ListCategories(p, includeArchived)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve categories")
+		return
+	}
+
+	response := models.CategoriesResponse{Categories: []string{}, Items: categories}
+	for _, c := range categories {
+		if !c.Archived {
+			response.Categories = append(response.Categories, c.Name)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// CreateCategory handles POST /api/categories
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CategoryManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	category, err := h.categoryService.CreateCategory(p, req)
+	if err != nil {
+		if !writeCategoryChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create category")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, category)
+}
+
+// UpdateCategory handles PUT /api/categories/{category_id}
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CategoryManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["category_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var req models.UpdateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	category, err := h.categoryService.UpdateCategory(p, uint(id), req)
+	if err != nil {
+		if writeCategoryChangeError(w, err) {
+			return
+		}
+		if err.Error() == "category not found" {
+			writeError(w, http.StatusNotFound, "Category not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to update category")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, category)
+}
+
+// DeleteCategory handles DELETE /api/categories/{category_id}
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CategoryManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["category_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	if err := h.categoryService.DeleteCategory(p, uint(id)); err != nil {
+		switch err.Error() {
+		case "category not found":
+			writeError(w, http.StatusNotFound, "Category not found")
+		case "category is in use":
+			writeError(w, http.StatusConflict, "Category has expenses or subcategories; archive it instead")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to delete category")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCategoryChangeError reports category validation failures. It returns
+// false if err is not one of them.
+func writeCategoryChangeError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "category name is required":
+		writeError(w, http.StatusBadRequest, "Category name is required")
+	case "category already exists":
+		writeError(w, http.StatusConflict, "A category with this name already exists")
+	case "parent category not found":
+		writeError(w, http.StatusBadRequest, "Parent category not found")
+	case "parent category is archived":
+		writeError(w, http.StatusBadRequest, "Parent category is archived")
+	case "category cannot be nested under itself":
+		writeError(w, http.StatusBadRequest, "A category cannot be nested under itself or its subcategories")
+	case "invalid receipt threshold":
+		writeError(w, http.StatusBadRequest, "receipt_required_above must be a non-negative amount in the base currency")
+	default:
+		return false
+	}
+	return true
+}
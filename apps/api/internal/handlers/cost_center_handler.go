@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type CostCenterHandler struct {
+	costCenterService *services.CostCenterService
+}
+
+func NewCostCenterHandler() *CostCenterHandler {
+	return &CostCenterHandler{
+		costCenterService: services.NewCostCenterService(),
+	}
+}
+
+// ListCostCenters handles GET /api/cost-centers
+func (h *CostCenterHandler) ListCostCenters(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CostCenterRead)
+	if !ok {
+		return
+	}
+
+	includeArchived := false
+	if v := r.URL.Query().Get("include_archived"); v != "" {
+		var err error
+		if includeArchived, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "include_archived must be true or false")
+			return
+		}
+	}
+
+	costCenters, err := h.costCenterService.ListCostCenters(p, includeArchived)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve cost centers")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, costCenters)
+}
+
+// CreateCostCenter handles POST /api/cost-centers
+func (h *CostCenterHandler) CreateCostCenter(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CostCenterManage)
+	if !ok {
+		return
+	}
+
+	var req models.CostCenterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	costCenter, err := h.costCenterService.CreateCostCenter(p, req)
+	if err != nil {
+		if !writeCostCenterChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create cost center")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, costCenter)
+}
+
+// UpdateCostCenter handles PUT /api/cost-centers/{cost_center_id}
+func (h *CostCenterHandler) UpdateCostCenter(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CostCenterManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["cost_center_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cost center ID")
+		return
+	}
+
+	var req models.CostCenterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	costCenter, err := h.costCenterService.UpdateCostCenter(p, uint(id), req)
+	if err != nil {
+		if writeCostCenterChangeError(w, err) {
+			return
+		}
+		if err.Error() == "cost center not found" {
+			writeError(w, http.StatusNotFound, "Cost center not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to update cost center")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, costCenter)
+}
+
+// DeleteCostCenter handles DELETE /api/cost-centers/{cost_center_id}
+func (h *CostCenterHandler) DeleteCostCenter(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.CostCenterManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["cost_center_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cost center ID")
+		return
+	}
+
+	if err := h.costCenterService.DeleteCostCenter(p, uint(id)); err != nil {
+		switch err.Error() {
+		case "cost center not found":
+			writeError(w, http.StatusNotFound, "Cost center not found")
+		case "cost center is in use":
+			writeError(w, http.StatusConflict, "Cost center has expenses; archive it instead")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to delete cost center")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCostCenterChangeError reports cost center validation failures. It
+// returns false if err is not one of them.
+func writeCostCenterChangeError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "cost center code is required":
+		writeError(w, http.StatusBadRequest, "Cost center code is required")
+	case "cost center name is required":
+		writeError(w, http.StatusBadRequest, "Cost center name is required")
+	case "cost center already exists":
+		writeError(w, http.StatusConflict, "A cost center with this code already exists")
+	default:
+		return false
+	}
+	return true
+}
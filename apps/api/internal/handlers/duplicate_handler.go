@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type DuplicateHandler struct {
+	duplicateService *services.DuplicateService
+}
+
+func NewDuplicateHandler() *DuplicateHandler {
+	return &DuplicateHandler{
+		duplicateService: services.NewDuplicateService(),
+	}
+}
+
+// ListDuplicates handles GET /api/expenses/duplicates
+func (h *DuplicateHandler) ListDuplicates(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	limit := 100 // Default limit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			if l > 1000 { // Max limit for safety
+				l = 1000
+			}
+			limit = l
+		}
+	}
+
+	pairs, err := h.duplicateService.ListDuplicates(p, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve duplicates")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pairs)
+}
+
+// DismissDuplicate handles POST /api/expenses/duplicates/{pair_id}/dismiss
+func (h *DuplicateHandler) DismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["pair_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid duplicate ID")
+		return
+	}
+
+	pair, err := h.duplicateService.DismissDuplicate(p, uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "duplicate not found":
+			writeError(w, http.StatusNotFound, "Duplicate not found")
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to modify this expense")
+		case "duplicate already dismissed":
+			writeError(w, http.StatusConflict, "Duplicate already dismissed")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to dismiss duplicate")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pair)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+// maxRateFileSize bounds exchange rate uploads; the full ECB history is a few MB
+const maxRateFileSize = 32 << 20
+
+type ExchangeRateHandler struct {
+	exchangeRateService *services.ExchangeRateService
+}
+
+func NewExchangeRateHandler() *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		exchangeRateService: services.NewExchangeRateService(),
+	}
+}
+
+// ListRates handles GET /api/exchange-rates
+func (h *ExchangeRateHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExchangeRateRead)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := services.ExchangeRateFilter{
+		BaseCurrency:  query.Get("base"),
+		QuoteCurrency: query.Get("quote"),
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid "+param+" date, expected YYYY-MM-DD")
+			return
+		}
+		*target = &t
+	}
+
+	rates, err := h.exchangeRateService.ListRates(p, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve exchange rates")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// CreateRate handles POST /api/exchange-rates
+func (h *ExchangeRateHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExchangeRateManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreateExchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rate, err := h.exchangeRateService.CreateRate(p, req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid date", "unsupported currency", "base and quote currency must differ", "invalid exchange rate":
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to save exchange rate")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rate)
+}
+
+// ImportRates handles POST /api/exchange-rates/import
+// It accepts an ECB reference rate CSV either as the request body or as a multipart "file" field.
+func (h *ExchangeRateHandler) ImportRates(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExchangeRateManage)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateFileSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxRateFileSize); err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to parse form")
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "No file provided")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := h.exchangeRateService.ImportECB(p, body, r.URL.Query().Get("base"))
+	if err != nil {
+		switch {
+		case err.Error() == "unsupported currency", err.Error() == "no exchange rates found in file",
+			strings.HasPrefix(err.Error(), "invalid exchange rate file"):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to import exchange rates")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
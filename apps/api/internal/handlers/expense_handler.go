@@ -2,30 +2,50 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 	
 	"github.com/gorilla/mux"
 	
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
 	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/pagination"
 	"github.com/example/next-go-monorepo/apps/api/internal/services"
 )
 
+// maxImportFileSize bounds uploaded expense import files
+const maxImportFileSize = 10 << 20
+
 type ExpenseHandler struct {
 	expenseService *services.ExpenseService
 	aiService      *services.AIService
+	searchService  *services.SearchService
+	importService  *services.ImportService
 }
 
 func NewExpenseHandler() *ExpenseHandler {
 	return &ExpenseHandler{
 		expenseService: services.NewExpenseService(),
 		aiService:      services.NewAIService(),
+		searchService:  services.NewSearchService(),
+		importService:  services.NewImportService(),
 	}
 }
 
 // CreateExpense handles POST /api/expenses
 func (h *ExpenseHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseCreate)
+	if !ok {
+		return
+	}
+	
 	var req models.CreateExpenseRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -39,8 +59,14 @@ func (h *ExpenseHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	if req.Amount <= 0 {
-		writeError(w, http.StatusBadRequest, "Amount must be greater than 0")
+	// Mileage and per-diem amounts are computed from their details
+	if req.Amount == "" && (req.Type == "" || strings.EqualFold(string(req.Type), string(models.ExpenseStandard))) {
+		writeError(w, http.StatusBadRequest, "Amount is required")
+		return
+	}
+	
+	if req.Attendees < 0 {
+		writeError(w, http.StatusBadRequest, "Attendees must be at least 1")
 		return
 	}
 	
@@ -49,47 +75,388 @@ func (h *ExpenseHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 		req.Date = time.Now()
 	}
 	
-	expense, err := h.expenseService.CreateExpense(req)
+	expense, err := h.expenseService.CreateExpense(p, req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to create expense")
+		if !writeAmountError(w, err) && !writeCategoryError(w, err) && !writeDimensionError(w, err) && !writeAllocationError(w, err) && !writeAllowanceError(w, err) && !writeTaxError(w, err) && !writePolicyError(w, err, expense) && !writeDuplicateError(w, err, expense) {
+			writeError(w, http.StatusInternalServerError, "Failed to create expense")
+		}
 		return
 	}
 	
-	writeJSON(w, http.StatusCreated, expense)
+	writeExpense(w, http.StatusCreated, expense)
 }
 
 // GetExpenses handles GET /api/expenses
 func (h *ExpenseHandler) GetExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+	
+	q, err := parseExpenseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	expenses, err := h.expenseService.GetExpenses(p, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, pagination.ErrInvalidCursor):
+			writeError(w, http.StatusBadRequest, "Invalid cursor; cursors are only valid for the sort order they were issued with")
+		case strings.HasPrefix(err.Error(), "invalid sort field"), err.Error() == "invalid min_amount",
+			err.Error() == "invalid max_amount", err.Error() == "unsupported currency":
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve expenses")
+		}
+		return
+	}
+	
+	if q.Cursor == "" && q.Skip > 0 {
+		// Offset paging drifts when rows are added between requests
+		w.Header().Set("Deprecation", "true")
+	}
+	if link := pagination.LinkHeader(r.URL, expenses.NextCursor, expenses.PrevCursor); link != "" {
+		w.Header().Set("Link", link)
+	}
+	
+	writeJSON(w, http.StatusOK, expenses)
+}
+
+// SummarizeExpenses handles GET /api/expenses/summary
+func (h *ExpenseHandler) SummarizeExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+	
+	q, err := parseExpenseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	summary, err := h.expenseService.SummarizeExpenses(p, q.Filter, strings.ToLower(r.URL.Query().Get("group_by")))
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid group_by"):
+			writeError(w, http.StatusBadRequest, "group_by must be category, project, cost_center, merchant or tag")
+		case err.Error() == "invalid min_amount", err.Error() == "invalid max_amount", err.Error() == "unsupported currency":
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to summarize expenses")
+		}
+		return
+	}
+	
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// SearchExpenses handles GET /api/expenses/search
+func (h *ExpenseHandler) SearchExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+	
 	query := r.URL.Query()
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
 	
-	skip := 0
+	skip, limit := 0, 20
 	if skipStr := query.Get("skip"); skipStr != "" {
 		if s, err := strconv.Atoi(skipStr); err == nil && s >= 0 {
 			skip = s
 		}
 	}
-	
-	limit := 100 // Default limit
 	if limitStr := query.Get("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			if l > 1000 { // Max limit for safety
-				l = 1000
+			if l > 100 { // Max limit for safety
+				l = 100
 			}
 			limit = l
 		}
 	}
 	
-	expenses, err := h.expenseService.GetExpenses(skip, limit)
+	results, err := h.searchService.SearchExpenses(p, text, skip, limit)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to retrieve expenses")
+		writeError(w, http.StatusInternalServerError, "Failed to search expenses")
 		return
 	}
 	
-	writeJSON(w, http.StatusOK, expenses)
+	writeJSON(w, http.StatusOK, results)
+}
+
+// ImportExpenses handles POST /api/expenses/import
+// The CSV or XLSX file comes in a multipart "file" field; options may be form fields or query parameters.
+func (h *ExpenseHandler) ImportExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseCreate)
+	if !ok {
+		return
+	}
+	
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "No file provided")
+		return
+	}
+	defer file.Close()
+	
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	
+	opts := models.ExpenseImportOptions{
+		Mode:         r.FormValue("mode"),
+		DryRun:       r.FormValue("dry_run") == "true",
+		Categorize:   r.FormValue("categorize") == "true",
+		DateFormat:   r.FormValue("date_format"),
+		DecimalComma: r.FormValue("decimal_separator") == ",",
+		Currency:     r.FormValue("currency"),
+		Sheet:        r.FormValue("sheet"),
+	}
+	if mapping := r.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			writeError(w, http.StatusBadRequest, "mapping must be a JSON object of field to column name")
+			return
+		}
+	}
+	
+	result, err := h.importService.ImportExpenses(p, header.Filename, data, opts)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid import mode":
+			writeError(w, http.StatusBadRequest, "mode must be atomic or best_effort")
+		case err.Error() == "unsupported currency", err.Error() == "file is empty",
+			strings.HasPrefix(err.Error(), "invalid date format"), strings.HasPrefix(err.Error(), "invalid mapping"),
+			strings.HasPrefix(err.Error(), "invalid CSV file"), strings.HasPrefix(err.Error(), "invalid XLSX file"),
+			strings.HasPrefix(err.Error(), "sheet "), strings.HasPrefix(err.Error(), "too many rows"):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to import expenses")
+		}
+		return
+	}
+	
+	switch {
+	case result.DryRun:
+		writeJSON(w, http.StatusOK, result)
+	case result.Imported > 0:
+		writeJSON(w, http.StatusCreated, result)
+	default:
+		// Nothing was imported; the response lists the rows that need fixing
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+	}
+}
+
+// ImportStatement handles POST /api/expenses/import/statement
+// The OFX, QFX, CAMT.053 or MT940 file comes in a multipart "file" field; options may be form fields or query parameters.
+func (h *ExpenseHandler) ImportStatement(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseCreate)
+	if !ok {
+		return
+	}
+	
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "No file provided")
+		return
+	}
+	defer file.Close()
+	
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+	
+	opts := models.StatementImportOptions{
+		Format: r.FormValue("format"),
+		DryRun: r.FormValue("dry_run") == "true",
+	}
+	
+	result, err := h.importService.ImportStatement(p, header.Filename, data, opts)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid statement format":
+			writeError(w, http.StatusBadRequest, "format must be ofx, qfx, camt053 or mt940")
+		case err.Error() == "no transactions found in statement",
+			strings.HasPrefix(err.Error(), "unrecognized statement format"), strings.HasPrefix(err.Error(), "invalid OFX file"),
+			strings.HasPrefix(err.Error(), "invalid CAMT.053 file"), strings.HasPrefix(err.Error(), "invalid MT940 file"),
+			strings.HasPrefix(err.Error(), "too many transactions"):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to import statement")
+		}
+		return
+	}
+	
+	if result.Imported > 0 {
+		writeJSON(w, http.StatusCreated, result)
+	} else {
+		// Dry runs, and statements whose debits were all imported before, create nothing
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// parseExpenseQuery reads listing filters, sort order and paging from query parameters.
+// Multi-valued filters may be repeated or comma separated.
+func parseExpenseQuery(query url.Values) (models.ExpenseQuery, error) {
+	q := models.ExpenseQuery{Limit: 100} // Default limit
+	
+	q.Cursor = query.Get("cursor")
+	
+	if skipStr := query.Get("skip"); skipStr != "" {
+		if s, err := strconv.Atoi(skipStr); err == nil && s >= 0 {
+			q.Skip = s
+		}
+	}
+	
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			if l > 1000 { // Max limit for safety
+				l = 1000
+			}
+			q.Limit = l
+		}
+	}
+	
+	f := &q.Filter
+	if v := query.Get("from"); v != "" {
+		t, _, err := parseDateParam(v)
+		if err != nil {
+			return q, errors.New("invalid from date, expected YYYY-MM-DD or RFC3339")
+		}
+		f.From = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			return q, errors.New("invalid to date, expected YYYY-MM-DD or RFC3339")
+		}
+		if dateOnly {
+			// A bare date includes the whole day
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		f.To = &t
+	}
+	
+	f.MinAmount = money.Decimal(strings.TrimSpace(query.Get("min_amount")))
+	f.MaxAmount = money.Decimal(strings.TrimSpace(query.Get("max_amount")))
+	f.Currency = money.Normalize(query.Get("currency"))
+	f.Categories = listParam(query["category"])
+	f.Text = strings.TrimSpace(query.Get("q"))
+	f.Tags = listParam(query["tag"])
+	
+	var err error
+	if f.ProjectIDs, err = idListParam(query["project_id"]); err != nil {
+		return q, errors.New("invalid project_id, expected numeric IDs")
+	}
+	if f.CostCenterIDs, err = idListParam(query["cost_center_id"]); err != nil {
+		return q, errors.New("invalid cost_center_id, expected numeric IDs")
+	}
+	if f.MerchantIDs, err = idListParam(query["merchant_id"]); err != nil {
+		return q, errors.New("invalid merchant_id, expected numeric IDs")
+	}
+	
+	for _, status := range listParam(query["status"]) {
+		f.Statuses = append(f.Statuses, models.ExpenseStatus(strings.ToLower(status)))
+	}
+	
+	if v := query.Get("has_attachments"); v != "" {
+		has, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("invalid has_attachments, expected true or false")
+		}
+		f.HasAttachments = &has
+	}
+	
+	if v := query.Get("missing_receipt"); v != "" {
+		missing, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, errors.New("invalid missing_receipt, expected true or false")
+		}
+		f.MissingReceipt = missing
+	}
+	
+	for _, field := range listParam(query["sort"]) {
+		key := models.SortKey{Field: field}
+		if strings.HasPrefix(field, "-") {
+			key = models.SortKey{Field: field[1:], Desc: true}
+		} else if name, direction, ok := strings.Cut(field, ":"); ok {
+			switch strings.ToLower(direction) {
+			case "asc":
+				key = models.SortKey{Field: name}
+			case "desc":
+				key = models.SortKey{Field: name, Desc: true}
+			default:
+				return q, errors.New("invalid sort direction, expected asc or desc")
+			}
+		}
+		q.Sort = append(q.Sort, key)
+	}
+	
+	return q, nil
+}
+
+// parseDateParam accepts YYYY-MM-DD or RFC3339 and reports whether only a date was given
+func parseDateParam(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// listParam flattens repeated and comma-separated query values, dropping empty entries
+func listParam(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// idListParam parses a multi-valued query parameter of IDs
+func idListParam(values []string) ([]uint, error) {
+	var ids []uint
+	for _, item := range listParam(values) {
+		id, err := strconv.ParseUint(item, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
 }
 
 // GetExpenseByID handles GET /api/expenses/{expense_id}
 func (h *ExpenseHandler) GetExpenseByID(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+	
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["expense_id"], 10, 32)
 	if err != nil {
@@ -97,7 +464,7 @@ func (h *ExpenseHandler) GetExpenseByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	expense, err := h.expenseService.GetExpenseByID(uint(id))
+	expense, err := h.expenseService.GetExpenseByID(p, uint(id))
 	if err != nil {
 		if err.Error() == "expense not found" {
 			writeError(w, http.StatusNotFound, "Expense not found")
@@ -107,11 +474,23 @@ func (h *ExpenseHandler) GetExpenseByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	writeJSON(w, http.StatusOK, expense)
+	etag := expenseETag(expense)
+	if noneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	
+	writeExpense(w, http.StatusOK, expense)
 }
 
 // UpdateExpense handles PUT /api/expenses/{expense_id}
 func (h *ExpenseHandler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+	
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["expense_id"], 10, 32)
 	if err != nil {
@@ -119,33 +498,49 @@ func (h *ExpenseHandler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	var req models.UpdateExpenseRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+	ifMatch, ok := ifMatchVersions(w, r)
+	if !ok {
 		return
 	}
 	
-	// Validate amount if provided
-	if req.Amount != nil && *req.Amount <= 0 {
-		writeError(w, http.StatusBadRequest, "Amount must be greater than 0")
+	var req models.UpdateExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 	
-	expense, err := h.expenseService.UpdateExpense(uint(id), req)
+	expense, err := h.expenseService.UpdateExpense(p, uint(id), req, ifMatch)
 	if err != nil {
-		if err.Error() == "expense not found" {
+		if writeAmountError(w, err) || writeCategoryError(w, err) || writeDimensionError(w, err) || writeAllocationError(w, err) || writeAllowanceError(w, err) || writeTaxError(w, err) || writeVersionError(w, err, ifMatch) || writePolicyError(w, err, expense) || writeDuplicateError(w, err, expense) {
+			return
+		}
+		switch err.Error() {
+		case "expense not found":
 			writeError(w, http.StatusNotFound, "Expense not found")
-		} else {
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to modify this expense")
+		case "expense is locked":
+			writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
+		case "attendees must be at least 1":
+			writeError(w, http.StatusBadRequest, "Attendees must be at least 1")
+		case "expense date is outside the report period":
+			writeError(w, http.StatusBadRequest, "The date is outside the period of the expense's report")
+		default:
 			writeError(w, http.StatusInternalServerError, "Failed to update expense")
 		}
 		return
 	}
 	
-	writeJSON(w, http.StatusOK, expense)
+	writeExpense(w, http.StatusOK, expense)
 }
 
 // DeleteExpense handles DELETE /api/expenses/{expense_id}
 func (h *ExpenseHandler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseDelete)
+	if !ok {
+		return
+	}
+	
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["expense_id"], 10, 32)
 	if err != nil {
@@ -153,11 +548,24 @@ func (h *ExpenseHandler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	err = h.expenseService.DeleteExpense(uint(id))
+	ifMatch, ok := ifMatchVersions(w, r)
+	if !ok {
+		return
+	}
+	
+	err = h.expenseService.DeleteExpense(p, uint(id), ifMatch)
 	if err != nil {
-		if err.Error() == "expense not found" {
+		if writeVersionError(w, err, ifMatch) {
+			return
+		}
+		switch err.Error() {
+		case "expense not found":
 			writeError(w, http.StatusNotFound, "Expense not found")
-		} else {
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to delete this expense")
+		case "expense is locked":
+			writeError(w, http.StatusConflict, "Expense can no longer be deleted in its current status")
+		default:
 			writeError(w, http.StatusInternalServerError, "Failed to delete expense")
 		}
 		return
@@ -168,6 +576,11 @@ func (h *ExpenseHandler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 
 // GetAISuggestion handles POST /api/expenses/ai-suggest
 func (h *ExpenseHandler) GetAISuggestion(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseCreate)
+	if !ok {
+		return
+	}
+	
 	var req models.AISuggestRequest
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -185,9 +598,13 @@ func (h *ExpenseHandler) GetAISuggestion(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	suggestion, err := h.aiService.GetAISuggestion(req)
+	suggestion, err := h.aiService.GetAISuggestion(p, req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to generate AI suggestion")
+		if err.Error() == "merchant not found" {
+			writeError(w, http.StatusBadRequest, "Merchant not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to generate AI suggestion")
+		}
 		return
 	}
 	
@@ -196,6 +613,11 @@ func (h *ExpenseHandler) GetAISuggestion(w http.ResponseWriter, r *http.Request)
 
 // ApproveSuggestion handles POST /api/expenses/{expense_id}/ai-suggestions/{suggestion_id}/approve
 func (h *ExpenseHandler) ApproveSuggestion(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+	
 	vars := mux.Vars(r)
 	expenseID, err := strconv.ParseUint(vars["expense_id"], 10, 32)
 	if err != nil {
@@ -209,20 +631,116 @@ func (h *ExpenseHandler) ApproveSuggestion(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
-	expense, err := h.aiService.ApproveSuggestion(uint(expenseID), req)
+	expense, err := h.aiService.ApproveSuggestion(p, uint(expenseID), req)
 	if err != nil {
+		if writeCategoryError(w, err) || writeTaxError(w, err) || writePolicyError(w, err, expense) {
+			return
+		}
 		switch err.Error() {
 		case "expense not found", "suggestion not found":
 			writeError(w, http.StatusNotFound, err.Error())
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to modify this expense")
+		case "expense is locked":
+			writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
 		case "suggestion does not belong to this expense":
 			writeError(w, http.StatusBadRequest, err.Error())
+		case "expense was modified concurrently":
+			writeError(w, http.StatusConflict, err.Error())
 		default:
 			writeError(w, http.StatusInternalServerError, "Failed to approve suggestion")
 		}
 		return
 	}
 	
-	writeJSON(w, http.StatusOK, expense)
+	writeExpense(w, http.StatusOK, expense)
+}
+
+// requireIfMatch makes If-Match mandatory on expense updates and deletes
+var requireIfMatch bool
+
+// SetRequireIfMatch makes expense updates and deletes without an If-Match
+// header fail with 428. It must be called before serving requests.
+func SetRequireIfMatch(required bool) {
+	requireIfMatch = required
+}
+
+// expenseETag is the entity tag of an expense at its current version
+func expenseETag(expense *models.Expense) string {
+	return `"` + strconv.FormatInt(expense.Version, 10) + `"`
+}
+
+// writeExpense writes an expense along with its ETag
+func writeExpense(w http.ResponseWriter, status int, expense *models.Expense) {
+	w.Header().Set("ETag", expenseETag(expense))
+	writeJSON(w, status, expense)
+}
+
+// ifMatchVersions reads the If-Match header as the expense versions the client
+// accepts; none means any version will do. It writes 428 or 412 and returns
+// false when the request cannot proceed.
+func ifMatchVersions(w http.ResponseWriter, r *http.Request) ([]int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if requireIfMatch {
+			writeError(w, http.StatusPreconditionRequired, "If-Match header with the expense's ETag is required")
+			return nil, false
+		}
+		return nil, true
+	}
+	if header == "*" {
+		return nil, true
+	}
+	
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		// If-Match compares strongly, so weak tags never match
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		writeError(w, http.StatusPreconditionFailed, "Expense has been modified since it was fetched")
+		return nil, false
+	}
+	return versions, true
+}
+
+// noneMatch reports whether an If-None-Match header matches etag, comparing weakly
+func noneMatch(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeVersionError reports a failed version check. A change racing a
+// conditional request fails its precondition; without one it is a conflict.
+// It returns false if err is not a version error.
+func writeVersionError(w http.ResponseWriter, err error, ifMatch []int64) bool {
+	switch err.Error() {
+	case "version mismatch":
+		writeError(w, http.StatusPreconditionFailed, "Expense has been modified since it was fetched")
+	case "expense was modified concurrently":
+		if len(ifMatch) > 0 {
+			writeError(w, http.StatusPreconditionFailed, "Expense has been modified since it was fetched")
+		} else {
+			writeError(w, http.StatusConflict, err.Error())
+		}
+	default:
+		return false
+	}
+	return true
 }
 
 // Helper functions
@@ -234,4 +752,175 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 
 func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, models.ErrorResponse{Detail: message})
-}
\ No newline at end of file
+}
+
+// currentPrincipal returns the authenticated caller; routes are only reachable behind the auth middleware
+func currentPrincipal(r *http.Request) *auth.Principal {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		panic("handlers: request reached handler without an authenticated principal")
+	}
+	return p
+}
+
+// authorize checks the caller's role against action, writing a 403 when it is not permitted
+func authorize(w http.ResponseWriter, r *http.Request, action authz.Action) (*auth.Principal, bool) {
+	p := currentPrincipal(r)
+	if !authz.Allowed(p, action) {
+		writeError(w, http.StatusForbidden, "Your role does not permit this action")
+		return nil, false
+	}
+	return p, true
+}
+
+// writePolicyError answers 422 with the violations when a blocking policy rule
+// stopped an expense. It returns false for other errors.
+func writePolicyError(w http.ResponseWriter, err error, expense *models.Expense) bool {
+	if err.Error() != "policy violation" || expense == nil {
+		return false
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, models.PolicyBlockedResponse{
+		Detail:     "This expense breaks the organization's expense policy",
+		Violations: expense.PolicyViolations,
+	})
+	return true
+}
+
+// writeDuplicateError answers 409 with the lookalikes when the organization
+// blocked an expense as a likely duplicate. It returns false for other errors.
+func writeDuplicateError(w http.ResponseWriter, err error, expense *models.Expense) bool {
+	if err.Error() != "possible duplicate" || expense == nil {
+		return false
+	}
+	writeJSON(w, http.StatusConflict, models.DuplicateBlockedResponse{
+		Detail:     "This expense looks like a duplicate of one already recorded",
+		Duplicates: expense.PossibleDuplicates,
+	})
+	return true
+}
+
+// writeCategoryError reports a category that is not one of the organization's
+// active categories. It returns false for any other error.
+func writeCategoryError(w http.ResponseWriter, err error) bool {
+	if err.Error() != "unknown category" {
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "Category must be one of the organization's active categories")
+	return true
+}
+
+// writeDimensionError reports a project, cost center, merchant or tag an
+// expense cannot be given. It returns false if err is not one of them.
+func writeDimensionError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "project not found":
+		writeError(w, http.StatusBadRequest, "Project not found")
+	case "project is closed":
+		writeError(w, http.StatusConflict, "Project is closed to new expenses")
+	case "cost center not found":
+		writeError(w, http.StatusBadRequest, "Cost center not found")
+	case "cost center is archived":
+		writeError(w, http.StatusConflict, "Cost center is archived")
+	case "merchant not found":
+		writeError(w, http.StatusBadRequest, "Merchant not found")
+	case "merchant name is required":
+		writeError(w, http.StatusBadRequest, "Merchant name must contain letters other than company suffixes")
+	case "invalid tag":
+		writeError(w, http.StatusBadRequest, "Tags must be between 1 and 50 characters")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeAllocationError reports lines of a split expense that do not fit it.
+// It returns false if err is not one of them.
+func writeAllocationError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "allocation needs an amount or a percent":
+		writeError(w, http.StatusBadRequest, "Each allocation needs either an amount or a percent")
+	case "allocations mix amounts and percents":
+		writeError(w, http.StatusBadRequest, "Allocations must all give amounts or all give percents")
+	case "invalid allocation amount":
+		writeError(w, http.StatusBadRequest, "Allocation amounts must be greater than 0 with no more decimals than the currency allows")
+	case "invalid allocation percent":
+		writeError(w, http.StatusBadRequest, "Allocation percents must be above 0 and at most 100, with up to six decimals")
+	case "allocations do not add up to the amount":
+		writeError(w, http.StatusBadRequest, "Allocations must add up to the expense's amount, or percents to 100")
+	case "allocation user not found":
+		writeError(w, http.StatusBadRequest, "Allocation user is not a member of the organization")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeAllowanceError reports mileage and per-diem details an expense cannot
+// be priced from. It returns false if err is not one of them.
+func writeAllowanceError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "invalid expense type":
+		writeError(w, http.StatusBadRequest, "type must be standard, mileage or per_diem")
+	case "amount is computed":
+		writeError(w, http.StatusBadRequest, "The amount, currency and per-diem date of a computed expense come from its details")
+	case "details do not match the expense type":
+		writeError(w, http.StatusBadRequest, "Mileage expenses need mileage details, per_diem expenses need per_diem details, and standard expenses neither")
+	case "invalid distance":
+		writeError(w, http.StatusBadRequest, "Distance must be a positive number")
+	case "invalid distance unit":
+		writeError(w, http.StatusBadRequest, "unit must be km or mi")
+	case "invalid country":
+		writeError(w, http.StatusBadRequest, "country must be a two-letter ISO 3166 code")
+	case "invalid trip dates":
+		writeError(w, http.StatusBadRequest, "Trip end must be after its start")
+	case "invalid meals provided":
+		writeError(w, http.StatusBadRequest, "Meals provided must be between 0 and the number of days of the trip")
+	case "no mileage rate":
+		writeError(w, http.StatusUnprocessableEntity, "No mileage rate for this vehicle type and country on the expense date")
+	case "no per diem rate":
+		writeError(w, http.StatusUnprocessableEntity, "No per-diem rate for this country on the trip's start date")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeTaxError reports tax details an expense's tax cannot be worked out
+// from. It returns false if err is not one of them.
+func writeTaxError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "invalid tax jurisdiction":
+		writeError(w, http.StatusBadRequest, "tax_jurisdiction must be a two-letter ISO 3166 code")
+	case "tax jurisdiction is required":
+		writeError(w, http.StatusBadRequest, "A tax_rate or tax_amount needs a tax_jurisdiction")
+	case "invalid tax rate":
+		writeError(w, http.StatusBadRequest, "tax_rate must be a percent between 0 and 100")
+	case "invalid tax amount":
+		writeError(w, http.StatusBadRequest, "tax_amount must be at least 0 and less than the amount, with no more decimals than the currency allows")
+	case "invalid VAT number":
+		writeError(w, http.StatusBadRequest, "merchant_vat_number must be at most 32 letters and digits")
+	case "no tax rate":
+		writeError(w, http.StatusUnprocessableEntity, "No tax rate for this tax code in the jurisdiction on the expense date")
+	default:
+		return false
+	}
+	return true
+}
+
+// writeAmountError reports amount and currency validation failures from the
+// expense services. It returns false if err is not one of them.
+func writeAmountError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err.Error() == "invalid amount":
+		writeError(w, http.StatusBadRequest, "Amount must be a decimal number with no more decimals than the currency allows")
+	case err.Error() == "amount must be greater than 0":
+		writeError(w, http.StatusBadRequest, "Amount must be greater than 0")
+	case err.Error() == "unsupported currency":
+		writeError(w, http.StatusBadRequest, "Unsupported currency")
+	case strings.HasPrefix(err.Error(), "no exchange rate"):
+		writeError(w, http.StatusUnprocessableEntity, strings.ToUpper(err.Error()[:1])+err.Error()[1:])
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type ExpenseReportHandler struct {
+	reportService *services.ExpenseReportService
+}
+
+func NewExpenseReportHandler() *ExpenseReportHandler {
+	return &ExpenseReportHandler{
+		reportService: services.NewExpenseReportService(),
+	}
+}
+
+// ListExpenseReports handles GET /api/expense-reports
+func (h *ExpenseReportHandler) ListExpenseReports(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	status := models.ExpenseStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.StatusDraft, models.StatusSubmitted, models.StatusApproved, models.StatusRejected, models.StatusReimbursed:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	reports, err := h.reportService.ListExpenseReports(p, status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve expense reports")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reports)
+}
+
+// GetExpenseReport handles GET /api/expense-reports/{report_id}
+func (h *ExpenseReportHandler) GetExpenseReport(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	report, err := h.reportService.GetExpenseReport(p, uint(id))
+	if err != nil {
+		if err.Error() == "expense report not found" {
+			writeError(w, http.StatusNotFound, "Expense report not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve expense report")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// CreateExpenseReport handles POST /api/expense-reports
+func (h *ExpenseReportHandler) CreateExpenseReport(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseCreate)
+	if !ok {
+		return
+	}
+
+	var req models.CreateExpenseReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, err := h.reportService.CreateExpenseReport(p, req)
+	if err != nil {
+		if !writeReportChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create expense report")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, report)
+}
+
+// UpdateExpenseReport handles PUT /api/expense-reports/{report_id}
+func (h *ExpenseReportHandler) UpdateExpenseReport(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	var req models.UpdateExpenseReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, err := h.reportService.UpdateExpenseReport(p, uint(id), req)
+	if err != nil {
+		if !writeReportChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to update expense report")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// DeleteExpenseReport handles DELETE /api/expense-reports/{report_id}
+func (h *ExpenseReportHandler) DeleteExpenseReport(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseDelete)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	if err := h.reportService.DeleteExpenseReport(p, uint(id)); err != nil {
+		if !writeReportChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to delete expense report")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddExpenses handles POST /api/expense-reports/{report_id}/expenses
+func (h *ExpenseReportHandler) AddExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	var req models.ReportExpensesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, err := h.reportService.AddExpenses(p, uint(id), req.ExpenseIDs)
+	if err != nil {
+		if !writeReportChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to add expenses to expense report")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// RemoveExpense handles DELETE /api/expense-reports/{report_id}/expenses/{expense_id}
+func (h *ExpenseReportHandler) RemoveExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+	expenseID, err := strconv.ParseUint(mux.Vars(r)["expense_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense ID")
+		return
+	}
+
+	report, err := h.reportService.RemoveExpense(p, uint(id), uint(expenseID))
+	if err != nil {
+		if !writeReportChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to remove expense from expense report")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// SubmitExpenseReport handles POST /api/expense-reports/{report_id}/submit
+func (h *ExpenseReportHandler) SubmitExpenseReport(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionSubmit)
+}
+
+// ApproveExpenseReport handles POST /api/expense-reports/{report_id}/approve
+func (h *ExpenseReportHandler) ApproveExpenseReport(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionApprove)
+}
+
+// RejectExpenseReport handles POST /api/expense-reports/{report_id}/reject
+func (h *ExpenseReportHandler) RejectExpenseReport(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionReject)
+}
+
+// ReimburseExpenseReport handles POST /api/expense-reports/{report_id}/reimburse
+func (h *ExpenseReportHandler) ReimburseExpenseReport(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, models.ActionReimburse)
+}
+
+// GetTransitions handles GET /api/expense-reports/{report_id}/transitions
+func (h *ExpenseReportHandler) GetTransitions(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	transitions, err := h.reportService.GetTransitions(p, uint(id))
+	if err != nil {
+		if err.Error() == "expense report not found" {
+			writeError(w, http.StatusNotFound, "Expense report not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve transitions")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transitions)
+}
+
+// GetExpenseReportPDF handles GET /api/expense-reports/{report_id}/pdf
+func (h *ExpenseReportHandler) GetExpenseReportPDF(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	// Rendered in full first so a failure can still be answered with an error status
+	var buf bytes.Buffer
+	if err := h.reportService.WriteExpenseReportPDF(p, uint(id), &buf); err != nil {
+		if err.Error() == "expense report not found" {
+			writeError(w, http.StatusNotFound, "Expense report not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to generate expense report PDF")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"expense-report-%d.pdf\"", id))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// transition runs a workflow action against the expense report in the URL
+func (h *ExpenseReportHandler) transition(w http.ResponseWriter, r *http.Request, action models.ExpenseAction) {
+	permission, _ := services.WorkflowPermission(action)
+	p, ok := authorize(w, r, permission)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["report_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense report ID")
+		return
+	}
+
+	// The comment is optional, so an empty body is fine
+	var req models.TransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	report, err := h.reportService.Transition(p, uint(id), action, req.Comment)
+	if err != nil {
+		if err.Error() == "policy violation" && report != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, models.PolicyBlockedResponse{
+				Detail:     "Expenses on this report break the organization's expense policy",
+				Violations: report.PolicyViolations,
+			})
+			return
+		}
+		switch err.Error() {
+		case "expense report not found":
+			writeError(w, http.StatusNotFound, "Expense report not found")
+		case "permission denied", "cannot review own expense report", "already approved by this user":
+			writeError(w, http.StatusForbidden, err.Error())
+		case "invalid transition", "expense report was modified concurrently", "expense was modified concurrently":
+			writeError(w, http.StatusConflict, err.Error())
+		case "expense report is empty":
+			writeError(w, http.StatusConflict, "Expense report has no expenses to submit")
+		case "expense is in a reimbursement batch":
+			writeError(w, http.StatusConflict, "An expense on the report is in a reimbursement batch and is reimbursed when the batch is reconciled")
+		case "expense date is outside the report period":
+			writeError(w, http.StatusConflict, "An expense on the report is dated outside its period")
+		case "a comment is required when rejecting":
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to "+string(action)+" expense report")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// writeReportChangeError reports failures to change an expense report or the
+// expenses on it. It returns false if err is not one of them.
+func writeReportChangeError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "expense report not found":
+		writeError(w, http.StatusNotFound, "Expense report not found")
+	case "expense not found":
+		writeError(w, http.StatusNotFound, "Expense not found")
+	case "permission denied":
+		writeError(w, http.StatusForbidden, "You are not allowed to modify this expense report")
+	case "expense report is locked":
+		writeError(w, http.StatusConflict, "Expense report can no longer be changed in its current status")
+	case "expense is locked":
+		writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
+	case "expense is on another report":
+		writeError(w, http.StatusConflict, "Expense is already on another report")
+	case "expense belongs to another user":
+		writeError(w, http.StatusBadRequest, "Only expenses of the report's owner can be added to it")
+	case "expense date is outside the report period":
+		writeError(w, http.StatusBadRequest, "Expense is dated outside the report's period")
+	case "title is required", "expense_ids is required", "period end is before period start":
+		writeError(w, http.StatusBadRequest, err.Error())
+	case "invalid period start":
+		writeError(w, http.StatusBadRequest, "period_start must be a date, expected YYYY-MM-DD")
+	case "invalid period end":
+		writeError(w, http.StatusBadRequest, "period_end must be a date, expected YYYY-MM-DD")
+	default:
+		return false
+	}
+	return true
+}
@@ -4,7 +4,6 @@ import (
     "net/http"
     "time"
     
-    "github.com/example/next-go-monorepo/apps/api/internal/models"
     "github.com/example/next-go-monorepo/apps/api/internal/services"
 )
 
@@ -25,14 +24,6 @@ func (h *GeneralHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
     writeJSON(w, http.StatusOK, response)
 }
 
-// GetCategories handles GET /api/categories
-func (h *GeneralHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
-    response := models.CategoriesResponse{
-        Categories: models.DefaultCategories,
-    }
-    writeJSON(w, http.StatusOK, response)
-}
-
 // GetSystemInfo handles GET /api/system/info
 func (h *GeneralHandler) GetSystemInfo(w http.ResponseWriter, r *http.Request) {
     attachmentService := services.NewAttachmentService()
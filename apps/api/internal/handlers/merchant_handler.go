@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type MerchantHandler struct {
+	merchantService *services.MerchantService
+}
+
+func NewMerchantHandler() *MerchantHandler {
+	return &MerchantHandler{
+		merchantService: services.NewMerchantService(),
+	}
+}
+
+// ListMerchants handles GET /api/merchants
+func (h *MerchantHandler) ListMerchants(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MerchantRead)
+	if !ok {
+		return
+	}
+
+	merchants, err := h.merchantService.ListMerchants(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve merchants")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merchants)
+}
+
+// GetMerchant handles GET /api/merchants/{merchant_id}
+func (h *MerchantHandler) GetMerchant(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MerchantRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["merchant_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid merchant ID")
+		return
+	}
+
+	merchant, err := h.merchantService.GetMerchant(p, uint(id))
+	if err != nil {
+		if err.Error() == "merchant not found" {
+			writeError(w, http.StatusNotFound, "Merchant not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve merchant")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merchant)
+}
+
+// CreateMerchant handles POST /api/merchants
+func (h *MerchantHandler) CreateMerchant(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MerchantManage)
+	if !ok {
+		return
+	}
+
+	var req models.MerchantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	merchant, err := h.merchantService.CreateMerchant(p, req)
+	if err != nil {
+		if !writeMerchantChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create merchant")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, merchant)
+}
+
+// UpdateMerchant handles PUT /api/merchants/{merchant_id}
+func (h *MerchantHandler) UpdateMerchant(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MerchantManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["merchant_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid merchant ID")
+		return
+	}
+
+	var req models.MerchantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	merchant, err := h.merchantService.UpdateMerchant(p, uint(id), req)
+	if err != nil {
+		if writeMerchantChangeError(w, err) {
+			return
+		}
+		if err.Error() == "merchant not found" {
+			writeError(w, http.StatusNotFound, "Merchant not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to update merchant")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merchant)
+}
+
+// DeleteMerchant handles DELETE /api/merchants/{merchant_id}
+func (h *MerchantHandler) DeleteMerchant(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MerchantManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["merchant_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid merchant ID")
+		return
+	}
+
+	if err := h.merchantService.DeleteMerchant(p, uint(id)); err != nil {
+		switch err.Error() {
+		case "merchant not found":
+			writeError(w, http.StatusNotFound, "Merchant not found")
+		case "merchant is in use":
+			writeError(w, http.StatusConflict, "Merchant has expenses; merge it into another instead")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to delete merchant")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeMerchants handles POST /api/merchants/{merchant_id}/merge
+func (h *MerchantHandler) MergeMerchants(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MerchantManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["merchant_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid merchant ID")
+		return
+	}
+
+	var req models.MergeMerchantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	merchant, err := h.merchantService.MergeMerchants(p, uint(id), req)
+	if err != nil {
+		switch err.Error() {
+		case "no merchants to merge":
+			writeError(w, http.StatusBadRequest, "merchant_ids must name at least one merchant")
+		case "cannot merge a merchant into itself":
+			writeError(w, http.StatusBadRequest, "A merchant cannot be merged into itself")
+		case "merchant not found":
+			writeError(w, http.StatusNotFound, "Merchant not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to merge merchants")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merchant)
+}
+
+// writeMerchantChangeError reports merchant validation failures. It returns
+// false if err is not one of them.
+func writeMerchantChangeError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "merchant name is required":
+		writeError(w, http.StatusBadRequest, "Merchant name must contain letters other than company suffixes")
+	case "invalid merchant alias":
+		writeError(w, http.StatusBadRequest, "Aliases must contain letters other than company suffixes")
+	case "invalid tax ID":
+		writeError(w, http.StatusBadRequest, "tax_id must be at most 32 letters and digits")
+	case "merchant already exists":
+		writeError(w, http.StatusConflict, "Another merchant already has this name or alias")
+	default:
+		return writeCategoryError(w, err)
+	}
+	return true
+}
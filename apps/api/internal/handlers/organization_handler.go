@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+}
+
+func NewOrganizationHandler() *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: services.NewOrganizationService(),
+	}
+}
+
+// ListOrganizations handles GET /api/organizations
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	memberships, err := h.organizationService.ListMemberships(currentPrincipal(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve organizations")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, memberships)
+}
+
+// CreateOrganization handles POST /api/organizations
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	membership, err := h.organizationService.CreateOrganization(currentPrincipal(r), req)
+	if err != nil {
+		switch err.Error() {
+		case "organization name is required":
+			writeError(w, http.StatusBadRequest, "Organization name is required")
+		case "unsupported currency":
+			writeError(w, http.StatusBadRequest, "Unsupported currency")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to create organization")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, membership)
+}
+
+// UpdateOrganization handles PUT /api/organizations/{org_id}
+func (h *OrganizationHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.OrganizationManage)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	org, err := h.organizationService.UpdateOrganization(p, req)
+	if err != nil {
+		switch err.Error() {
+		case "organization name is required":
+			writeError(w, http.StatusBadRequest, "Organization name is required")
+		case "unsupported currency":
+			writeError(w, http.StatusBadRequest, "Unsupported currency")
+		case "base currency cannot change once expenses or approval rules exist":
+			writeError(w, http.StatusConflict, "Base currency cannot change once expenses or approval rules exist")
+		case "invalid duplicate mode":
+			writeError(w, http.StatusBadRequest, "duplicate_mode must be warn, block or ignore")
+		case "invalid receipt threshold":
+			writeError(w, http.StatusBadRequest, "receipt_required_above must be a non-negative amount in the base currency")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to update organization")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, org)
+}
+
+// ListMembers handles GET /api/organizations/{org_id}/members
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MemberRead)
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationService.ListMembers(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve members")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, members)
+}
+
+// UpdateMember handles PUT /api/organizations/{org_id}/members/{membership_id}
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MemberManage)
+	if !ok {
+		return
+	}
+
+	membershipID, err := strconv.ParseUint(mux.Vars(r)["membership_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid membership ID")
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	membership, err := h.organizationService.UpdateMemberRole(p, uint(membershipID), req.Role)
+	if err != nil {
+		writeMembershipError(w, err, "Failed to update member")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, membership)
+}
+
+// RemoveMember handles DELETE /api/organizations/{org_id}/members/{membership_id}
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MemberManage)
+	if !ok {
+		return
+	}
+
+	membershipID, err := strconv.ParseUint(mux.Vars(r)["membership_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid membership ID")
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(p, uint(membershipID)); err != nil {
+		writeMembershipError(w, err, "Failed to remove member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InviteMember handles POST /api/organizations/{org_id}/invitations
+func (h *OrganizationHandler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MemberInvite)
+	if !ok {
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	invitation, err := h.organizationService.InviteMember(p, req)
+	if err != nil {
+		writeMembershipError(w, err, "Failed to create invitation")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, invitation)
+}
+
+// ListInvitations handles GET /api/organizations/{org_id}/invitations
+func (h *OrganizationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MemberInvite)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.organizationService.ListInvitations(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve invitations")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, invitations)
+}
+
+// RevokeInvitation handles DELETE /api/organizations/{org_id}/invitations/{invitation_id}
+func (h *OrganizationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.MemberInvite)
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.ParseUint(mux.Vars(r)["invitation_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	if err := h.organizationService.RevokeInvitation(p, uint(invitationID)); err != nil {
+		writeMembershipError(w, err, "Failed to revoke invitation")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMyInvitations handles GET /api/invitations
+func (h *OrganizationHandler) ListMyInvitations(w http.ResponseWriter, r *http.Request) {
+	invitations, err := h.organizationService.PendingInvitations(currentPrincipal(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve invitations")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, invitations)
+}
+
+// AcceptInvitation handles POST /api/invitations/{invitation_id}/accept
+func (h *OrganizationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	invitationID, err := strconv.ParseUint(mux.Vars(r)["invitation_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	membership, err := h.organizationService.AcceptInvitation(currentPrincipal(r), uint(invitationID))
+	if err != nil {
+		writeMembershipError(w, err, "Failed to accept invitation")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, membership)
+}
+
+// writeMembershipError maps organization service errors to HTTP responses
+func writeMembershipError(w http.ResponseWriter, err error, fallback string) {
+	switch err.Error() {
+	case "member not found", "invitation not found":
+		writeError(w, http.StatusNotFound, err.Error())
+	case "permission denied":
+		writeError(w, http.StatusForbidden, "Only owners can grant or modify the owner role")
+	case "invalid role", "invalid email address":
+		writeError(w, http.StatusBadRequest, err.Error())
+	case "user is already a member", "organization must keep at least one owner":
+		writeError(w, http.StatusConflict, err.Error())
+	case "invitation has expired":
+		writeError(w, http.StatusGone, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type PolicyHandler struct {
+	policyService *services.PolicyService
+}
+
+func NewPolicyHandler() *PolicyHandler {
+	return &PolicyHandler{
+		policyService: services.NewPolicyService(),
+	}
+}
+
+// ListRules handles GET /api/policies
+func (h *PolicyHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.PolicyRead)
+	if !ok {
+		return
+	}
+
+	rules, err := h.policyService.ListRules(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve policy rules")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// CreateRule handles POST /api/policies
+func (h *PolicyHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.PolicyManage)
+	if !ok {
+		return
+	}
+
+	var req models.PolicyRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.policyService.CreateRule(p, req)
+	if err != nil {
+		if !writeRuleError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create policy rule")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// UpdateRule handles PUT /api/policies/{policy_id}
+func (h *PolicyHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.PolicyManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["policy_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid policy ID")
+		return
+	}
+
+	var req models.PolicyRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.policyService.UpdateRule(p, uint(id), req)
+	if err != nil {
+		if writeRuleError(w, err) {
+			return
+		}
+		if err.Error() == "policy rule not found" {
+			writeError(w, http.StatusNotFound, "Policy rule not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to update policy rule")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule handles DELETE /api/policies/{policy_id}
+func (h *PolicyHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.PolicyManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["policy_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid policy ID")
+		return
+	}
+
+	if err := h.policyService.DeleteRule(p, uint(id)); err != nil {
+		if err.Error() == "policy rule not found" {
+			writeError(w, http.StatusNotFound, "Policy rule not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to delete policy rule")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Evaluate handles POST /api/policies/evaluate
+func (h *PolicyHandler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.PolicyRead)
+	if !ok {
+		return
+	}
+
+	var req models.EvaluatePolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.policyService.Evaluate(p, req)
+	if err != nil {
+		if writeRuleError(w, err) || writeAmountError(w, err) {
+			return
+		}
+		switch err.Error() {
+		case "expense not found":
+			writeError(w, http.StatusNotFound, "Expense not found")
+		case "expense or expense_id is required":
+			writeError(w, http.StatusBadRequest, "Either expense or expense_id is required")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to evaluate policies")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeRuleError reports policy rule validation failures. It returns false if
+// err is not one of them.
+func writeRuleError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err.Error() == "policy name is required":
+		writeError(w, http.StatusBadRequest, "Policy name is required")
+	case err.Error() == "invalid severity":
+		writeError(w, http.StatusBadRequest, "severity must be warn or block")
+	case strings.HasPrefix(err.Error(), "invalid condition"):
+		writeError(w, http.StatusBadRequest, strings.ToUpper(err.Error()[:1])+err.Error()[1:])
+	default:
+		return false
+	}
+	return true
+}
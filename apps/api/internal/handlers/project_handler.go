@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type ProjectHandler struct {
+	projectService *services.ProjectService
+}
+
+func NewProjectHandler() *ProjectHandler {
+	return &ProjectHandler{
+		projectService: services.NewProjectService(),
+	}
+}
+
+// ListProjects handles GET /api/projects
+func (h *ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ProjectRead)
+	if !ok {
+		return
+	}
+
+	status := models.ProjectStatus(strings.ToLower(r.URL.Query().Get("status")))
+	if status != "" && status != models.ProjectActive && status != models.ProjectClosed {
+		writeError(w, http.StatusBadRequest, "status must be active or closed")
+		return
+	}
+
+	projects, err := h.projectService.ListProjects(p, status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve projects")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// GetProject handles GET /api/projects/{project_id}
+func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ProjectRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["project_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid project ID")
+		return
+	}
+
+	project, err := h.projectService.GetProject(p, uint(id))
+	if err != nil {
+		if err.Error() == "project not found" {
+			writeError(w, http.StatusNotFound, "Project not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve project")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, project)
+}
+
+// CreateProject handles POST /api/projects
+func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ProjectManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	project, err := h.projectService.CreateProject(p, req)
+	if err != nil {
+		if !writeProjectChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create project")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, project)
+}
+
+// UpdateProject handles PUT /api/projects/{project_id}
+func (h *ProjectHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ProjectManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["project_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid project ID")
+		return
+	}
+
+	var req models.UpdateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	project, err := h.projectService.UpdateProject(p, uint(id), req)
+	if err != nil {
+		if writeProjectChangeError(w, err) {
+			return
+		}
+		if err.Error() == "project not found" {
+			writeError(w, http.StatusNotFound, "Project not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to update project")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, project)
+}
+
+// DeleteProject handles DELETE /api/projects/{project_id}
+func (h *ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ProjectManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["project_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid project ID")
+		return
+	}
+
+	if err := h.projectService.DeleteProject(p, uint(id)); err != nil {
+		switch err.Error() {
+		case "project not found":
+			writeError(w, http.StatusNotFound, "Project not found")
+		case "project is in use":
+			writeError(w, http.StatusConflict, "Project has expenses; close it instead")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to delete project")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeProjectChangeError reports project validation failures. It returns
+// false if err is not one of them.
+func writeProjectChangeError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "project name is required":
+		writeError(w, http.StatusBadRequest, "Project name is required")
+	case "project already exists":
+		writeError(w, http.StatusConflict, "A project with this name already exists")
+	case "invalid budget":
+		writeError(w, http.StatusBadRequest, "budget must be a non-negative amount in the base currency")
+	case "invalid project status":
+		writeError(w, http.StatusBadRequest, "status must be active or closed")
+	default:
+		return false
+	}
+	return true
+}
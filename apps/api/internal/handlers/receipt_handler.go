@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type ReceiptHandler struct {
+	receiptService *services.ReceiptService
+}
+
+func NewReceiptHandler() *ReceiptHandler {
+	return &ReceiptHandler{
+		receiptService: services.NewReceiptService(),
+	}
+}
+
+// UploadReceipt handles POST /api/receipts
+// The file comes in a multipart "file" field; merchant, amount, currency and
+// date (YYYY-MM-DD) are optional form fields that improve matching.
+func (h *ReceiptHandler) UploadReceipt(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AttachmentUpload)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB max
+		writeError(w, http.StatusBadRequest, "Failed to parse form data")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > 10<<20 {
+		writeError(w, http.StatusBadRequest, "File too large (max 10MB)")
+		return
+	}
+
+	details := models.ReceiptDetails{
+		Merchant: r.FormValue("merchant"),
+		Amount:   money.Decimal(strings.TrimSpace(r.FormValue("amount"))),
+		Currency: r.FormValue("currency"),
+	}
+	if date := r.FormValue("date"); date != "" {
+		d, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid date, expected YYYY-MM-DD")
+			return
+		}
+		details.Date = &d
+	}
+
+	result, err := h.receiptService.UploadReceipt(p, header, details)
+	if err != nil {
+		if !writeAmountError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to upload receipt")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, result)
+}
+
+// ListReceipts handles GET /api/receipts
+func (h *ReceiptHandler) ListReceipts(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AttachmentRead)
+	if !ok {
+		return
+	}
+
+	receipts, err := h.receiptService.ListReceipts(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve receipts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, receipts)
+}
+
+// GetReceipt handles GET /api/receipts/{receipt_id}
+func (h *ReceiptHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AttachmentRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["receipt_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid receipt ID")
+		return
+	}
+
+	reader, contentType, size, err := h.receiptService.GetReceiptFile(p, uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "receipt not found":
+			writeError(w, http.StatusNotFound, "Receipt not found")
+		case "file not found on filesystem":
+			writeError(w, http.StatusNotFound, "File not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve file")
+		}
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	io.Copy(w, reader)
+}
+
+// MatchReceipts handles POST /api/receipts/match
+func (h *ReceiptHandler) MatchReceipts(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AttachmentUpload)
+	if !ok {
+		return
+	}
+
+	result, err := h.receiptService.MatchReceipts(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to match receipts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ReviewQueue handles GET /api/receipts/matches
+func (h *ReceiptHandler) ReviewQueue(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AttachmentRead)
+	if !ok {
+		return
+	}
+
+	queue, err := h.receiptService.ReviewQueue(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve review queue")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, queue)
+}
+
+// ConfirmMatch handles POST /api/receipts/matches/{match_id}/confirm
+func (h *ReceiptHandler) ConfirmMatch(w http.ResponseWriter, r *http.Request) {
+	h.resolveMatch(w, r, h.receiptService.ConfirmMatch)
+}
+
+// RejectMatch handles POST /api/receipts/matches/{match_id}/reject
+func (h *ReceiptHandler) RejectMatch(w http.ResponseWriter, r *http.Request) {
+	h.resolveMatch(w, r, h.receiptService.RejectMatch)
+}
+
+func (h *ReceiptHandler) resolveMatch(w http.ResponseWriter, r *http.Request, resolve func(*auth.Principal, uint) (*models.ReceiptMatch, error)) {
+	p, ok := authorize(w, r, authz.AttachmentUpload)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["match_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid match ID")
+		return
+	}
+
+	match, err := resolve(p, uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "match not found":
+			writeError(w, http.StatusNotFound, "Match not found")
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to attach files to this expense")
+		case "expense is locked":
+			writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
+		case "match already resolved", "receipt already matched":
+			writeError(w, http.StatusConflict, strings.ToUpper(err.Error()[:1])+err.Error()[1:])
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to resolve match")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, match)
+}
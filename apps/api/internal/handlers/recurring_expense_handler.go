@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+// defaultUpcomingDays is how far ahead upcoming occurrences are listed unless asked otherwise
+const defaultUpcomingDays = 30
+
+type RecurringExpenseHandler struct {
+	recurringService *services.RecurringExpenseService
+}
+
+func NewRecurringExpenseHandler() *RecurringExpenseHandler {
+	return &RecurringExpenseHandler{
+		recurringService: services.NewRecurringExpenseService(),
+	}
+}
+
+// ListRecurringExpenses handles GET /api/recurring-expenses
+func (h *RecurringExpenseHandler) ListRecurringExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	templates, err := h.recurringService.ListRecurringExpenses(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve recurring expenses")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// GetRecurringExpense handles GET /api/recurring-expenses/{recurring_id}
+func (h *RecurringExpenseHandler) GetRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["recurring_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid recurring expense ID")
+		return
+	}
+
+	template, err := h.recurringService.GetRecurringExpense(p, uint(id))
+	if err != nil {
+		if err.Error() == "recurring expense not found" {
+			writeError(w, http.StatusNotFound, "Recurring expense not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve recurring expense")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, template)
+}
+
+// CreateRecurringExpense handles POST /api/recurring-expenses
+func (h *RecurringExpenseHandler) CreateRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseCreate)
+	if !ok {
+		return
+	}
+
+	var req models.CreateRecurringExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	template, err := h.recurringService.CreateRecurringExpense(p, req)
+	if err != nil {
+		if !writeRecurringChangeError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to create recurring expense")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, template)
+}
+
+// UpdateRecurringExpense handles PUT /api/recurring-expenses/{recurring_id}
+func (h *RecurringExpenseHandler) UpdateRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseUpdate)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["recurring_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid recurring expense ID")
+		return
+	}
+
+	var req models.UpdateRecurringExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	template, err := h.recurringService.UpdateRecurringExpense(p, uint(id), req)
+	if err != nil {
+		if writeRecurringChangeError(w, err) {
+			return
+		}
+		switch err.Error() {
+		case "recurring expense not found":
+			writeError(w, http.StatusNotFound, "Recurring expense not found")
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to modify this recurring expense")
+		case "invalid recurring status":
+			writeError(w, http.StatusBadRequest, "status must be active or paused")
+		case "recurring expense has ended":
+			writeError(w, http.StatusConflict, "Recurring expense has ended; change its schedule to restart it")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to update recurring expense")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, template)
+}
+
+// DeleteRecurringExpense handles DELETE /api/recurring-expenses/{recurring_id}
+func (h *RecurringExpenseHandler) DeleteRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseDelete)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["recurring_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid recurring expense ID")
+		return
+	}
+
+	if err := h.recurringService.DeleteRecurringExpense(p, uint(id)); err != nil {
+		switch err.Error() {
+		case "recurring expense not found":
+			writeError(w, http.StatusNotFound, "Recurring expense not found")
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to delete this recurring expense")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to delete recurring expense")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpcomingOccurrences handles GET /api/recurring-expenses/upcoming
+func (h *RecurringExpenseHandler) UpcomingOccurrences(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	days := defaultUpcomingDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			writeError(w, http.StatusBadRequest, "days must be between 1 and 366")
+			return
+		}
+		days = n
+	}
+
+	upcoming, err := h.recurringService.UpcomingOccurrences(p, days)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list upcoming recurring expenses")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, upcoming)
+}
+
+// SuggestRecurringExpenses handles GET /api/recurring-expenses/suggestions
+func (h *RecurringExpenseHandler) SuggestRecurringExpenses(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseRead)
+	if !ok {
+		return
+	}
+
+	suggestions, err := h.recurringService.SuggestRecurringExpenses(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to detect recurring expenses")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+// writeRecurringChangeError reports recurring expense validation failures.
+// It returns false if err is not one of them.
+func writeRecurringChangeError(w http.ResponseWriter, err error) bool {
+	if writeAmountError(w, err) || writeCategoryError(w, err) || writeDimensionError(w, err) {
+		return true
+	}
+	switch {
+	case err.Error() == "description is required":
+		writeError(w, http.StatusBadRequest, "Description is required")
+	case strings.HasPrefix(err.Error(), "invalid recurrence rule"):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err.Error() == "invalid start date":
+		writeError(w, http.StatusBadRequest, "start_date must be a date, expected YYYY-MM-DD")
+	case err.Error() == "invalid end date":
+		writeError(w, http.StatusBadRequest, "end_date must be a date, expected YYYY-MM-DD")
+	case err.Error() == "end date is before start date":
+		writeError(w, http.StatusBadRequest, "end_date must not be before start_date")
+	case err.Error() == "schedule has no dates":
+		writeError(w, http.StatusBadRequest, "The rule has no dates between start_date and end_date")
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/payout"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type ReimbursementHandler struct {
+	reimbursementService *services.ReimbursementService
+}
+
+func NewReimbursementHandler() *ReimbursementHandler {
+	return &ReimbursementHandler{
+		reimbursementService: services.NewReimbursementService(),
+	}
+}
+
+// ListDueReimbursements handles GET /api/reimbursements/due
+func (h *ReimbursementHandler) ListDueReimbursements(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ReimbursementRead)
+	if !ok {
+		return
+	}
+
+	due, err := h.reimbursementService.ListDue(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve reimbursements due")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, due)
+}
+
+// ListReimbursementBatches handles GET /api/reimbursement-batches
+func (h *ReimbursementHandler) ListReimbursementBatches(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ReimbursementRead)
+	if !ok {
+		return
+	}
+
+	status := models.BatchStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.BatchDraft, models.BatchPaid, models.BatchReconciled:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	batches, err := h.reimbursementService.ListBatches(p, status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve reimbursement batches")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, batches)
+}
+
+// GetReimbursementBatch handles GET /api/reimbursement-batches/{batch_id}
+func (h *ReimbursementHandler) GetReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ReimbursementRead)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["batch_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reimbursement batch ID")
+		return
+	}
+
+	batch, err := h.reimbursementService.GetBatch(p, uint(id))
+	if err != nil {
+		if err.Error() == "reimbursement batch not found" {
+			writeError(w, http.StatusNotFound, "Reimbursement batch not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to retrieve reimbursement batch")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// CreateReimbursementBatch handles POST /api/reimbursement-batches
+func (h *ReimbursementHandler) CreateReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseReimburse)
+	if !ok {
+		return
+	}
+
+	// Every field is optional, so an empty body is fine
+	var req models.CreateReimbursementBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	batch, err := h.reimbursementService.CreateBatch(p, req)
+	if err != nil {
+		switch err.Error() {
+		case "reference is too long":
+			writeError(w, http.StatusBadRequest, "reference must be at most 35 characters")
+		case "invalid execution date":
+			writeError(w, http.StatusBadRequest, "execution_date must be a date, expected YYYY-MM-DD")
+		case "execution date is in the past":
+			writeError(w, http.StatusBadRequest, "execution_date must not be in the past")
+		case "no reimbursements are due":
+			writeError(w, http.StatusConflict, "No approved, reimbursable expenses are waiting to be paid")
+		case "no employee with reimbursements due has a bank account":
+			writeError(w, http.StatusConflict, "No employee with reimbursements due has a bank account on file")
+		case "expense was modified concurrently":
+			writeError(w, http.StatusConflict, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to create reimbursement batch")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, batch)
+}
+
+// DeleteReimbursementBatch handles DELETE /api/reimbursement-batches/{batch_id}
+func (h *ReimbursementHandler) DeleteReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseReimburse)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["batch_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reimbursement batch ID")
+		return
+	}
+
+	if err := h.reimbursementService.CancelBatch(p, uint(id)); err != nil {
+		if !writeBatchStepError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to delete reimbursement batch")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PayReimbursementBatch handles POST /api/reimbursement-batches/{batch_id}/pay
+func (h *ReimbursementHandler) PayReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseReimburse)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["batch_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reimbursement batch ID")
+		return
+	}
+
+	batch, err := h.reimbursementService.MarkBatchPaid(p, uint(id))
+	if err != nil {
+		if !writeBatchStepError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to mark reimbursement batch paid")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// ReconcileReimbursementBatch handles POST /api/reimbursement-batches/{batch_id}/reconcile
+func (h *ReimbursementHandler) ReconcileReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseReimburse)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["batch_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reimbursement batch ID")
+		return
+	}
+
+	batch, err := h.reimbursementService.ReconcileBatch(p, uint(id))
+	if err != nil {
+		if !writeBatchStepError(w, err) {
+			writeError(w, http.StatusInternalServerError, "Failed to reconcile reimbursement batch")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// ExportReimbursementBatch handles GET /api/reimbursement-batches/{batch_id}/export?format=sepa|nacha
+func (h *ReimbursementHandler) ExportReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseReimburse)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["batch_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reimbursement batch ID")
+		return
+	}
+
+	format := payout.Format(strings.ToLower(r.URL.Query().Get("format")))
+	contentType, extension := "", ""
+	switch format {
+	case payout.FormatSEPA:
+		contentType, extension = "application/xml", "xml"
+	case payout.FormatNACHA:
+		contentType, extension = "text/plain; charset=us-ascii", "ach"
+	default:
+		writeError(w, http.StatusBadRequest, "format must be sepa or nacha")
+		return
+	}
+
+	// Written in full first so a failure can still be answered with an error status
+	var buf bytes.Buffer
+	if err := h.reimbursementService.WriteBatchFile(p, uint(id), format, &buf); err != nil {
+		switch {
+		case err.Error() == "reimbursement batch not found":
+			writeError(w, http.StatusNotFound, "Reimbursement batch not found")
+		case err.Error() == "organization bank account not found":
+			writeError(w, http.StatusConflict, "Set the organization's bank account before exporting payment files")
+		case strings.HasPrefix(err.Error(), "cannot export batch: "):
+			writeError(w, http.StatusUnprocessableEntity, strings.TrimPrefix(err.Error(), "cannot export batch: "))
+		case !writeBankAccountError(w, err):
+			writeError(w, http.StatusInternalServerError, "Failed to export reimbursement batch")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"reimbursement-batch-%d.%s\"", id, extension))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// writeBatchStepError reports why a batch could not be paid, reconciled or
+// cancelled. It returns false if err is not one of those reasons.
+func writeBatchStepError(w http.ResponseWriter, err error) bool {
+	switch err.Error() {
+	case "reimbursement batch not found":
+		writeError(w, http.StatusNotFound, "Reimbursement batch not found")
+	case "reimbursement batch is not a draft":
+		writeError(w, http.StatusConflict, "Only draft reimbursement batches can be changed this way")
+	case "reimbursement batch is not paid":
+		writeError(w, http.StatusConflict, "Only paid reimbursement batches can be reconciled")
+	case "reimbursement batch was modified concurrently", "expense was modified concurrently", "expense report was modified concurrently":
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type TagHandler struct {
+	tagService *services.TagService
+}
+
+func NewTagHandler() *TagHandler {
+	return &TagHandler{
+		tagService: services.NewTagService(),
+	}
+}
+
+// ListTags handles GET /api/tags
+func (h *TagHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TagRead)
+	if !ok {
+		return
+	}
+
+	tags, err := h.tagService.ListTags(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve tags")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// RenameTag handles PUT /api/tags/{tag_id}
+func (h *TagHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TagManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["tag_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var req models.RenameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tag, err := h.tagService.RenameTag(p, uint(id), req.Name)
+	if err != nil {
+		switch err.Error() {
+		case "tag not found":
+			writeError(w, http.StatusNotFound, "Tag not found")
+		case "invalid tag":
+			writeError(w, http.StatusBadRequest, "Tags must be between 1 and 50 characters")
+		case "tag already exists":
+			writeError(w, http.StatusConflict, "A tag with this name already exists")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to rename tag")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+// DeleteTag handles DELETE /api/tags/{tag_id}
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TagManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["tag_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	if err := h.tagService.DeleteTag(p, uint(id)); err != nil {
+		if err.Error() == "tag not found" {
+			writeError(w, http.StatusNotFound, "Tag not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to delete tag")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
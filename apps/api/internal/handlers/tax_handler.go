@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/reporting"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type TaxHandler struct {
+	taxService *services.TaxService
+}
+
+func NewTaxHandler() *TaxHandler {
+	return &TaxHandler{
+		taxService: services.NewTaxService(),
+	}
+}
+
+// ListTaxRates handles GET /api/tax-rates
+func (h *TaxHandler) ListTaxRates(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TaxRateRead)
+	if !ok {
+		return
+	}
+
+	rates, err := h.taxService.ListTaxRates(p)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve tax rates")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rates)
+}
+
+// CreateTaxRate handles POST /api/tax-rates
+func (h *TaxHandler) CreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TaxRateManage)
+	if !ok {
+		return
+	}
+
+	var req models.CreateTaxRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rate, err := h.taxService.CreateTaxRate(p, req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid effective date":
+			writeError(w, http.StatusBadRequest, "effective_from must be a date, expected YYYY-MM-DD")
+		case "invalid country":
+			writeError(w, http.StatusBadRequest, "country must be a two-letter ISO 3166 code")
+		case "invalid tax rate":
+			writeError(w, http.StatusBadRequest, "rate must be a percent between 0 and 100")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to save tax rate")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rate)
+}
+
+// DeleteTaxRate handles DELETE /api/tax-rates/{rate_id}
+func (h *TaxHandler) DeleteTaxRate(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TaxRateManage)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["rate_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rate ID")
+		return
+	}
+
+	if err := h.taxService.DeleteTaxRate(p, uint(id)); err != nil {
+		if err.Error() == "rate not found" {
+			writeError(w, http.StatusNotFound, "Tax rate not found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "Failed to delete tax rate")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetVATReclaimReport handles GET /api/reports/vat-reclaim?format=excel|pdf&from=&to=
+func (h *TaxHandler) GetVATReclaimReport(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TaxReportRead)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	format := reporting.ExportFormat(strings.ToLower(query.Get("format")))
+	contentType, extension := "", ""
+	switch format {
+	case "", reporting.FormatExcel:
+		format = reporting.FormatExcel
+		contentType, extension = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"
+	case reporting.FormatPDF:
+		contentType, extension = "application/pdf", "pdf"
+	default:
+		writeError(w, http.StatusBadRequest, "format must be excel or pdf")
+		return
+	}
+
+	var from, to *time.Time
+	if v := query.Get("from"); v != "" {
+		t, _, err := parseDateParam(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from date, expected YYYY-MM-DD or RFC3339")
+			return
+		}
+		from = &t
+	}
+	if v := query.Get("to"); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to date, expected YYYY-MM-DD or RFC3339")
+			return
+		}
+		if dateOnly {
+			// A bare date includes the whole day
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		to = &t
+	}
+
+	// Rendered in full first so a failure can still be answered with an error status
+	var buf bytes.Buffer
+	if err := h.taxService.WriteVATReclaimReport(p, from, to, format, &buf); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to generate VAT reclaim report")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"vat-reclaim.%s\"", extension))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/services"
+)
+
+type TrashHandler struct {
+	trashService *services.TrashService
+}
+
+func NewTrashHandler() *TrashHandler {
+	return &TrashHandler{
+		trashService: services.NewTrashService(),
+	}
+}
+
+// ListTrash handles GET /api/trash
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.TrashRead)
+	if !ok {
+		return
+	}
+
+	limit := 100 // Default limit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			if l > 1000 { // Max limit for safety
+				l = 1000
+			}
+			limit = l
+		}
+	}
+
+	trash, err := h.trashService.ListTrash(p, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to retrieve trash")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, trash)
+}
+
+// RestoreExpense handles POST /api/expenses/{expense_id}/restore
+func (h *TrashHandler) RestoreExpense(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.ExpenseDelete)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["expense_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense ID")
+		return
+	}
+
+	expense, err := h.trashService.RestoreExpense(p, uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "expense not found":
+			writeError(w, http.StatusNotFound, "Deleted expense not found")
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to restore this expense")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to restore expense")
+		}
+		return
+	}
+
+	writeExpense(w, http.StatusOK, expense)
+}
+
+// RestoreAttachment handles POST /api/expenses/{expense_id}/attachments/{attachment_id}/restore
+func (h *TrashHandler) RestoreAttachment(w http.ResponseWriter, r *http.Request) {
+	p, ok := authorize(w, r, authz.AttachmentDelete)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	expenseID, err := strconv.ParseUint(vars["expense_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid expense ID")
+		return
+	}
+	attachmentID, err := strconv.ParseUint(vars["attachment_id"], 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid attachment ID")
+		return
+	}
+
+	attachment, err := h.trashService.RestoreAttachment(p, uint(expenseID), uint(attachmentID))
+	if err != nil {
+		switch err.Error() {
+		case "attachment not found":
+			writeError(w, http.StatusNotFound, "Deleted attachment not found")
+		case "permission denied":
+			writeError(w, http.StatusForbidden, "You are not allowed to restore this attachment")
+		case "expense is locked":
+			writeError(w, http.StatusConflict, "Expense can no longer be edited in its current status")
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to restore attachment")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachment)
+}
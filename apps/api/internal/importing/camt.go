@@ -0,0 +1,204 @@
+package importing
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// camtDocument is the part of an ISO 20022 camt.053 bank-to-customer
+// statement that becomes transactions. Elements are matched by local name so
+// every message version (camt.053.001.02 onwards) reads the same way.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	IBAN     string      `xml:"Acct>Id>IBAN"`
+	Other    string      `xml:"Acct>Id>Othr>Id"`
+	Currency string      `xml:"Acct>Ccy"`
+	Entries  []camtEntry `xml:"Ntry"`
+}
+
+type camtEntry struct {
+	Ref         string        `xml:"NtryRef"`
+	Amount      camtAmount    `xml:"Amt"`
+	Indicator   string        `xml:"CdtDbtInd"`
+	Reversal    bool          `xml:"RvslInd"`
+	Status      camtStatus    `xml:"Sts"`
+	BookingDate camtDate      `xml:"BookgDt"`
+	ValueDate   camtDate      `xml:"ValDt"`
+	ServicerRef string        `xml:"AcctSvcrRef"`
+	Details     []camtDetails `xml:"NtryDtls>TxDtls"`
+	Info        string        `xml:"AddtlNtryInf"`
+}
+
+type camtDetails struct {
+	ServicerRef  string     `xml:"Refs>AcctSvcrRef"`
+	TxID         string     `xml:"Refs>TxId"`
+	EndToEndID   string     `xml:"Refs>EndToEndId"`
+	Amount       camtAmount `xml:"Amt"`
+	TxAmount     camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	Indicator    string     `xml:"CdtDbtInd"`
+	Creditor     string     `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty  string     `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Unstructured []string   `xml:"RmtInf>Ustrd"`
+	Info         string     `xml:"AddtlTxInf"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtStatus is a plain code in camt.053.001.02 and a <Cd> element from .001.08 on
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// parseCAMT reads the booked entries of camt.053 statements. Batched entries
+// whose transaction details carry their own amounts become one transaction
+// per detail.
+func parseCAMT(data []byte) ([]Transaction, error) {
+	var doc camtDocument
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Statements are UTF-8 in practice even when declared otherwise
+		return input, nil
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid CAMT.053 file: %v", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, errors.New("invalid CAMT.053 file: no statements")
+	}
+
+	var transactions []Transaction
+	for _, stmt := range doc.Statements {
+		account := strings.TrimSpace(stmt.IBAN)
+		if account == "" {
+			account = strings.TrimSpace(stmt.Other)
+		}
+
+		for _, entry := range stmt.Entries {
+			status := strings.ToUpper(strings.TrimSpace(entry.Status.Code + entry.Status.Value))
+			if status != "" && status != "BOOK" {
+				continue
+			}
+
+			date, err := entry.BookingDate.parse()
+			if err != nil {
+				if date, err = entry.ValueDate.parse(); err != nil {
+					return nil, fmt.Errorf("invalid CAMT.053 file: entry %q has no valid booking date", entry.Ref)
+				}
+			}
+
+			base := Transaction{
+				Account:     account,
+				Reference:   firstOf(entry.ServicerRef, entry.Ref),
+				Date:        date,
+				Amount:      money.Decimal(strings.TrimSpace(entry.Amount.Value)),
+				Currency:    money.Normalize(firstOf(entry.Amount.Currency, stmt.Currency)),
+				Debit:       debit(entry.Indicator, entry.Reversal),
+				Description: collapseSpace(entry.Info),
+			}
+
+			split := len(entry.Details) > 1
+			for _, d := range entry.Details {
+				if strings.TrimSpace(d.amount().Value) == "" {
+					split = false
+				}
+			}
+			if !split {
+				if len(entry.Details) > 0 {
+					d := entry.Details[0]
+					base.Reference = firstOf(d.ServicerRef, base.Reference, d.reference())
+					base.Description = firstOf(d.description(), base.Description)
+				}
+				transactions = append(transactions, base)
+				continue
+			}
+
+			for i, d := range entry.Details {
+				t := base
+				amount := d.amount()
+				t.Amount = money.Decimal(strings.TrimSpace(amount.Value))
+				t.Currency = money.Normalize(firstOf(amount.Currency, t.Currency))
+				if d.Indicator != "" {
+					t.Debit = debit(d.Indicator, entry.Reversal)
+				}
+				t.Reference = firstOf(d.ServicerRef, d.reference())
+				if t.Reference == "" && base.Reference != "" {
+					t.Reference = fmt.Sprintf("%s/%d", base.Reference, i+1)
+				}
+				t.Description = firstOf(d.description(), base.Description)
+				transactions = append(transactions, t)
+			}
+		}
+	}
+
+	return transactions, nil
+}
+
+func (d camtDate) parse() (time.Time, error) {
+	if value := strings.TrimSpace(d.Date); value != "" {
+		return time.Parse("2006-01-02", value)
+	}
+	if value := strings.TrimSpace(d.DateTime); len(value) >= 10 {
+		return time.Parse("2006-01-02", value[:10])
+	}
+	return time.Time{}, errors.New("missing date")
+}
+
+func (d camtDetails) amount() camtAmount {
+	if strings.TrimSpace(d.Amount.Value) != "" {
+		return d.Amount
+	}
+	return d.TxAmount
+}
+
+// reference returns the payment's own identifier, skipping the NOTPROVIDED placeholder
+func (d camtDetails) reference() string {
+	for _, ref := range []string{d.TxID, d.EndToEndID} {
+		if ref = strings.TrimSpace(ref); ref != "" && !strings.EqualFold(ref, "NOTPROVIDED") {
+			return ref
+		}
+	}
+	return ""
+}
+
+func (d camtDetails) description() string {
+	parts := []string{firstOf(d.Creditor, d.CreditorPty)}
+	parts = append(parts, d.Unstructured...)
+	if len(d.Unstructured) == 0 {
+		parts = append(parts, d.Info)
+	}
+	return collapseSpace(strings.Join(parts, " "))
+}
+
+// debit reports whether money left the account. A reversal carries the
+// indicator of the entry it reverses, so a reversed debit is money coming back.
+func debit(indicator string, reversal bool) bool {
+	return strings.EqualFold(strings.TrimSpace(indicator), "DBIT") != reversal
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package importing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Expense fields a column can be mapped to.
+const (
+	FieldDate        = "date"
+	FieldDescription = "description"
+	FieldAmount      = "amount"
+	FieldCurrency    = "currency"
+	FieldCategory    = "category"
+	FieldClientNotes = "client_notes"
+)
+
+// RequiredFields must be mapped for an import to run.
+var RequiredFields = []string{FieldDate, FieldDescription, FieldAmount}
+
+// headerAliases are the column names recognised for each field when no
+// mapping is given, compared case-insensitively.
+var headerAliases = map[string][]string{
+	FieldDate:        {"date", "expense date", "transaction date", "posting date", "booking date", "posted", "posted date", "value date"},
+	FieldDescription: {"description", "details", "memo", "merchant", "payee", "narrative", "item", "name"},
+	FieldAmount:      {"amount", "total", "value", "cost", "price", "sum", "gross"},
+	FieldCurrency:    {"currency", "ccy", "currency code"},
+	FieldCategory:    {"category", "expense type", "type"},
+	FieldClientNotes: {"client_notes", "client notes", "notes", "note", "comment", "comments"},
+}
+
+// Mapping maps expense fields to column indexes.
+type Mapping map[string]int
+
+// Names returns the mapping as field to column name.
+func (m Mapping) Names(t *Table) map[string]string {
+	names := make(map[string]string, len(m))
+	for field, column := range m {
+		names[field] = t.Header[column]
+	}
+	return names
+}
+
+// Map resolves the requested field to column name mapping against the
+// table's header. Fields left out are matched by common column names.
+func (t *Table) Map(requested map[string]string) (Mapping, error) {
+	columns := make(map[string]int, len(t.Header))
+	for i, name := range t.Header {
+		key := strings.ToLower(name)
+		if _, seen := columns[key]; !seen && key != "" {
+			columns[key] = i
+		}
+	}
+
+	mapping := Mapping{}
+	for field, name := range requested {
+		if _, ok := headerAliases[field]; !ok {
+			return nil, fmt.Errorf("invalid mapping: unknown field %q", field)
+		}
+		column, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid mapping: column %q not found", name)
+		}
+		mapping[field] = column
+	}
+
+	used := make(map[int]bool, len(mapping))
+	for _, column := range mapping {
+		used[column] = true
+	}
+	fields := make([]string, 0, len(headerAliases))
+	for field := range headerAliases {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if _, ok := mapping[field]; ok {
+			continue
+		}
+		for _, alias := range headerAliases[field] {
+			if column, ok := columns[alias]; ok && !used[column] {
+				mapping[field] = column
+				used[column] = true
+				break
+			}
+		}
+	}
+
+	for _, field := range RequiredFields {
+		if _, ok := mapping[field]; !ok {
+			return nil, fmt.Errorf("invalid mapping: no column for required field %q", field)
+		}
+	}
+	return mapping, nil
+}
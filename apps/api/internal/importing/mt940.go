@@ -0,0 +1,200 @@
+package importing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// mt940Line matches a :61: statement line: value date, optional entry date,
+// debit/credit mark, optional funds code, amount, transaction type, then the
+// customer reference and, after //, the bank's reference.
+var mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)([A-Z]\w{3})(.*)$`)
+
+// mt940Balance matches an opening balance: mark, date, currency and amount
+var mt940Balance = regexp.MustCompile(`^[CD](\d{6})([A-Z]{3})`)
+
+// mt940Subfield matches the ?NN subfield markers of structured :86: information
+var mt940Subfield = regexp.MustCompile(`\?(\d{2})`)
+
+// mt940Field is one :tag: field of an MT940 message with its continuation lines
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+// parseMT940 reads the statement lines of SWIFT MT940 files. A file may hold
+// several messages, with or without the SWIFT {1:...}{4: envelope.
+func parseMT940(data []byte) ([]Transaction, error) {
+	fields, err := mt940Fields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		transactions []Transaction
+		account      string
+		currency     string
+		current      *Transaction
+	)
+	flush := func() {
+		if current != nil {
+			transactions = append(transactions, *current)
+			current = nil
+		}
+	}
+
+	for _, f := range fields {
+		switch f.tag {
+		case "20":
+			flush()
+		case "25":
+			flush()
+			account = strings.TrimSpace(f.value)
+		case "60F", "60M":
+			flush()
+			m := mt940Balance.FindStringSubmatch(strings.TrimSpace(f.value))
+			if m == nil {
+				return nil, fmt.Errorf("invalid MT940 file: invalid opening balance %q", f.value)
+			}
+			currency = m[2]
+		case "61":
+			flush()
+			t, err := parseMT940Line(f.value)
+			if err != nil {
+				return nil, err
+			}
+			t.Account = account
+			t.Currency = currency
+			current = &t
+		case "86":
+			if current != nil {
+				if info := mt940Information(f.value); info != "" {
+					current.Description = info
+				}
+			}
+		case "62F", "62M", "64", "65":
+			flush()
+		}
+	}
+	flush()
+
+	return transactions, nil
+}
+
+// mt940Fields splits the message text into fields, dropping the SWIFT
+// envelope and joining continuation lines onto their field.
+func mt940Fields(data []byte) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "", trimmed == "-", trimmed == "-}":
+			continue
+		case strings.HasPrefix(trimmed, "{"):
+			// Envelope blocks; the text block may start on the same line
+			if i := strings.Index(trimmed, "{4:"); i >= 0 && strings.TrimSpace(trimmed[i+3:]) != "" {
+				line = trimmed[i+3:]
+			} else {
+				continue
+			}
+		}
+
+		if strings.HasPrefix(line, ":") {
+			if end := strings.Index(line[1:], ":"); end > 0 {
+				fields = append(fields, mt940Field{tag: line[1 : end+1], value: line[end+2:]})
+				continue
+			}
+		}
+		if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid MT940 file: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid MT940 file: no fields")
+	}
+	return fields, nil
+}
+
+// parseMT940Line reads a :61: statement line. Its second line, when present,
+// holds supplementary details used as a fallback description.
+func parseMT940Line(value string) (Transaction, error) {
+	first, rest, _ := strings.Cut(value, "\n")
+	m := mt940Line.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return Transaction{}, fmt.Errorf("invalid MT940 file: invalid statement line %q", first)
+	}
+
+	date, err := time.Parse("060102", m[1])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("invalid MT940 file: invalid date %q", m[1])
+	}
+	// The entry (booking) date only has month and day; it can fall in the next or previous year
+	if m[2] != "" {
+		if entry, err := time.Parse("0102", m[2]); err == nil {
+			booked := time.Date(date.Year(), entry.Month(), entry.Day(), 0, 0, 0, 0, time.UTC)
+			switch {
+			case booked.Sub(date) > 180*24*time.Hour:
+				booked = booked.AddDate(-1, 0, 0)
+			case date.Sub(booked) > 180*24*time.Hour:
+				booked = booked.AddDate(1, 0, 0)
+			}
+			date = booked
+		}
+	}
+
+	t := Transaction{
+		Date:   date,
+		Amount: money.Decimal(strings.Replace(m[5], ",", ".", 1)),
+		// RD reverses a debit and RC a credit
+		Debit:       m[3] == "D" || m[3] == "RC",
+		Description: collapseSpace(rest),
+	}
+	if strings.HasSuffix(string(t.Amount), ".") {
+		t.Amount += "0"
+	}
+
+	_, bankRef, _ := strings.Cut(m[7], "//")
+	if bankRef = strings.TrimSpace(bankRef); bankRef != "" && !strings.EqualFold(bankRef, "NONREF") {
+		t.Reference = bankRef
+	}
+	return t, nil
+}
+
+// mt940Information turns :86: information into a description. Structured
+// information (as used by German banks) starts with a three digit code and
+// splits into ?NN subfields; the counterparty name (?32, ?33) and the purpose
+// (?20 to ?29) are kept.
+func mt940Information(value string) string {
+	value = strings.ReplaceAll(value, "\n", "")
+	if len(value) < 4 || value[3] != '?' {
+		return collapseSpace(value)
+	}
+
+	var name, purpose []string
+	locs := mt940Subfield.FindAllStringSubmatchIndex(value, -1)
+	for i, loc := range locs {
+		end := len(value)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		text := strings.TrimSpace(value[loc[1]:end])
+		switch code := value[loc[2]:loc[3]]; {
+		case code == "32" || code == "33":
+			name = append(name, text)
+		case code >= "20" && code <= "29":
+			purpose = append(purpose, text)
+		}
+	}
+	return collapseSpace(strings.Join(name, "") + " " + strings.Join(purpose, " "))
+}
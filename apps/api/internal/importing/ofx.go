@@ -0,0 +1,167 @@
+package importing
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// ofxDateLayouts cover the DTPOSTED forms in use; any fraction or [offset:TZ]
+// suffix is cut off first.
+var ofxDateLayouts = []string{"20060102150405", "200601021504", "20060102"}
+
+// parseOFX reads bank and card statements from OFX 1.x (SGML) and 2.x (XML)
+// files. QFX files are OFX with Intuit extensions and read the same way.
+// Leaf elements need no closing tag in OFX 1.x, so the file is read as a flat
+// stream of tags rather than as XML.
+func parseOFX(data []byte) ([]Transaction, error) {
+	body := string(data)
+	start := strings.Index(strings.ToUpper(body), "<OFX>")
+	if start < 0 {
+		return nil, errors.New("invalid OFX file: no <OFX> element")
+	}
+	body = body[start:]
+
+	var (
+		transactions []Transaction
+		account      string
+		currency     string
+		current      *Transaction
+		amount       string
+		payee        string
+		name         string
+		memo         string
+		inPayee      bool
+		inCurrency   bool
+		stmtStart    int
+	)
+
+	for pos := 0; pos < len(body); {
+		open := strings.IndexByte(body[pos:], '<')
+		if open < 0 {
+			break
+		}
+		open += pos
+		end := strings.IndexByte(body[open:], '>')
+		if end < 0 {
+			return nil, errors.New("invalid OFX file: unterminated tag")
+		}
+		end += open
+		tag := strings.ToUpper(strings.TrimSpace(body[open+1 : end]))
+		next := strings.IndexByte(body[end+1:], '<')
+		if next < 0 {
+			next = len(body)
+		} else {
+			next += end + 1
+		}
+		value := strings.TrimSpace(html.UnescapeString(body[end+1 : next]))
+		pos = next
+
+		switch tag {
+		case "STMTRS", "CCSTMTRS":
+			// A file may hold several statements, each with its own account and currency
+			account, currency = "", ""
+			stmtStart = len(transactions)
+		case "/STMTRS", "/CCSTMTRS":
+			for i := stmtStart; i < len(transactions); i++ {
+				if transactions[i].Account == "" {
+					transactions[i].Account = account
+				}
+				if transactions[i].Currency == "" {
+					transactions[i].Currency = currency
+				}
+			}
+		case "CURDEF":
+			currency = money.Normalize(value)
+		case "ACCTID":
+			if account == "" {
+				account = value
+			}
+		case "STMTTRN":
+			current = &Transaction{}
+			amount, payee, name, memo = "", "", "", ""
+			inPayee, inCurrency = false, false
+		case "/STMTTRN":
+			if current == nil {
+				continue
+			}
+			if amount == "" {
+				return nil, fmt.Errorf("invalid OFX file: transaction %q has no amount", current.Reference)
+			}
+			current.Amount, current.Debit = unsigned(amount)
+			if name == "" {
+				name = payee
+			}
+			current.Description = collapseSpace(name)
+			if memo != "" && !strings.EqualFold(memo, name) {
+				current.Description = collapseSpace(strings.TrimSpace(current.Description + " " + memo))
+			}
+			if current.Date.IsZero() {
+				return nil, fmt.Errorf("invalid OFX file: transaction %q has no date", current.Reference)
+			}
+			transactions = append(transactions, *current)
+			current = nil
+		case "PAYEE":
+			inPayee = true
+		case "/PAYEE":
+			inPayee = false
+		case "CURRENCY":
+			inCurrency = true
+		case "/CURRENCY":
+			inCurrency = false
+		}
+
+		if current == nil || value == "" {
+			continue
+		}
+		switch tag {
+		case "DTPOSTED":
+			date, err := parseOFXDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OFX file: invalid date %q", value)
+			}
+			current.Date = date
+		case "TRNAMT":
+			amount = strings.ReplaceAll(value, ",", ".")
+		case "FITID":
+			current.Reference = value
+		case "NAME":
+			// A NAME inside a PAYEE aggregate names the payee in full
+			if inPayee {
+				payee = value
+			} else {
+				name = value
+			}
+		case "MEMO":
+			memo = value
+		case "CURSYM":
+			// Amounts of a line with a CURRENCY aggregate are in that currency
+			if inCurrency {
+				current.Currency = money.Normalize(value)
+			}
+		}
+	}
+
+	return transactions, nil
+}
+
+// parseOFXDate reads an OFX datetime such as 20240315, 20240315120000 or
+// 20240315120000.000[-5:EST]. Only the calendar date is kept.
+func parseOFXDate(value string) (time.Time, error) {
+	if i := strings.IndexAny(value, ".["); i >= 0 {
+		value = value[:i]
+	}
+	for _, layout := range ofxDateLayouts {
+		if len(value) != len(layout) {
+			continue
+		}
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date")
+}
@@ -0,0 +1,129 @@
+package importing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// Bank and card statement formats.
+const (
+	FormatOFX   Format = "ofx"
+	FormatCAMT  Format = "camt053"
+	FormatMT940 Format = "mt940"
+)
+
+// Transaction is one booked line of a bank or card statement.
+type Transaction struct {
+	// Account identifies the statement's account: an IBAN, card or account number
+	Account string
+	// Reference is the bank's own identifier for the line, empty when the file has none
+	Reference string
+	Date      time.Time
+	// Amount is unsigned; Debit tells which way the money moved
+	Amount      money.Decimal
+	Currency    string
+	Debit       bool
+	Description string
+}
+
+// ExternalID returns an identifier for the transaction that stays the same
+// when an overlapping statement is imported again. It derives from the bank's
+// reference, or from the line's content when the bank gives none; occurrence
+// tells apart identical lines within one statement.
+func (t Transaction) ExternalID(occurrence int) string {
+	key := t.Account + "\x00ref\x00" + t.Reference
+	if t.Reference == "" {
+		key = fmt.Sprintf("%s\x00line\x00%s\x00%s\x00%s\x00%t\x00%s\x00%d",
+			t.Account, t.Date.Format("2006-01-02"), t.Amount, t.Currency, t.Debit, t.Description, occurrence)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExternalIDs returns the ExternalID of each transaction of a statement.
+// Identical lines without a bank reference are numbered in the order they
+// appear, so each keeps its own ID.
+func ExternalIDs(transactions []Transaction) []string {
+	ids := make([]string, len(transactions))
+	occurrences := make(map[string]int)
+	for i, t := range transactions {
+		if t.Reference == "" {
+			first := t.ExternalID(0)
+			ids[i] = t.ExternalID(occurrences[first])
+			occurrences[first]++
+		} else {
+			ids[i] = t.ExternalID(0)
+		}
+	}
+	return ids
+}
+
+// DetectStatementFormat picks the statement format from the file name,
+// falling back to the content.
+func DetectStatementFormat(filename string, data []byte) (Format, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ofx", ".qfx":
+		return FormatOFX, nil
+	case ".sta", ".mt940", ".940":
+		return FormatMT940, nil
+	}
+
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	switch {
+	case bytes.Contains(head, []byte("OFXHEADER")), bytes.Contains(bytes.ToUpper(head), []byte("<OFX>")):
+		return FormatOFX, nil
+	case bytes.Contains(head, []byte("camt.053")), bytes.Contains(head, []byte("BkToCstmrStmt")):
+		return FormatCAMT, nil
+	case bytes.Contains(head, []byte(":20:")) && bytes.Contains(head, []byte(":25:")):
+		return FormatMT940, nil
+	}
+	return "", errors.New("unrecognized statement format, expected OFX, QFX, CAMT.053 or MT940")
+}
+
+// ParseStatement reads the booked transactions from a statement file.
+func ParseStatement(format Format, data []byte) ([]Transaction, error) {
+	var transactions []Transaction
+	var err error
+	switch format {
+	case FormatOFX:
+		transactions, err = parseOFX(data)
+	case FormatCAMT:
+		transactions, err = parseCAMT(data)
+	case FormatMT940:
+		transactions, err = parseMT940(data)
+	default:
+		return nil, fmt.Errorf("unsupported statement format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, errors.New("no transactions found in statement")
+	}
+	return transactions, nil
+}
+
+// unsigned strips a leading sign from an amount and reports whether it was negative
+func unsigned(amount string) (money.Decimal, bool) {
+	amount = strings.TrimSpace(amount)
+	if strings.HasPrefix(amount, "-") {
+		return money.Decimal(strings.TrimSpace(amount[1:])), true
+	}
+	return money.Decimal(strings.TrimPrefix(amount, "+")), false
+}
+
+// collapseSpace joins lines and runs of whitespace into single spaces
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
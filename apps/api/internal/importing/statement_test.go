@@ -0,0 +1,181 @@
+package importing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func readStatement(t *testing.T, name string) []Transaction {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	format, err := DetectStatementFormat(name, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := ParseStatement(format, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transactions
+}
+
+func TestParseStatement(t *testing.T) {
+	tests := []struct {
+		file   string
+		format Format
+		want   []Transaction
+	}{
+		{
+			file:   "checking.ofx",
+			format: FormatOFX,
+			want: []Transaction{
+				{Account: "123456789", Reference: "202403050001", Date: date(2024, 3, 5), Amount: "42.50", Currency: "USD", Debit: true, Description: "UBER *TRIP 8841 San Francisco CA"},
+				{Account: "123456789", Reference: "202403100002", Date: date(2024, 3, 10), Amount: "1250.00", Currency: "USD", Description: "Payroll"},
+				{Account: "123456789", Reference: "202403120003", Date: date(2024, 3, 12), Amount: "3.75", Currency: "USD", Debit: true, Description: "Blue Bottle Coffee"},
+			},
+		},
+		{
+			file:   "card.qfx",
+			format: FormatOFX,
+			want: []Transaction{
+				{Account: "4111111111111111", Reference: "CC0001", Date: date(2024, 3, 2), Amount: "89.99", Currency: "USD", Debit: true, Description: "AT&T WIRELESS"},
+				{Account: "4111111111111111", Reference: "CC0002", Date: date(2024, 3, 8), Amount: "120.00", Currency: "EUR", Debit: true, Description: "Hotel Adlon Kempinski"},
+				{Account: "4111111111111111", Reference: "CC0003", Date: date(2024, 3, 15), Amount: "15.00", Currency: "USD", Description: "REFUND Returned charger"},
+			},
+		},
+		{
+			file:   "statement.camt053.xml",
+			format: FormatCAMT,
+			want: []Transaction{
+				{Account: "DE89370400440532013000", Reference: "2024030400123", Date: date(2024, 3, 4), Amount: "58.40", Currency: "EUR", Debit: true, Description: "Deutsche Bahn AG Ticket Berlin Hamburg"},
+				{Account: "DE89370400440532013000", Reference: "N3", Date: date(2024, 3, 6), Amount: "12.00", Currency: "EUR", Description: "Refund Lufthansa"},
+				{Account: "DE89370400440532013000", Reference: "TX-A", Date: date(2024, 3, 15), Amount: "100.00", Currency: "EUR", Debit: true, Description: "Hotel Atlantic Invoice 4471"},
+				{Account: "DE89370400440532013000", Reference: "2024031500777/2", Date: date(2024, 3, 15), Amount: "25.50", Currency: "EUR", Debit: true, Description: "Taxi Zentrale"},
+				{Account: "DE89370400440532013000", Date: date(2024, 3, 20), Amount: "2.50", Currency: "EUR", Debit: true, Description: "Parking meter"},
+				{Account: "DE89370400440532013000", Date: date(2024, 3, 20), Amount: "2.50", Currency: "EUR", Debit: true, Description: "Parking meter"},
+			},
+		},
+		{
+			file:   "statement.sta",
+			format: FormatMT940,
+			want: []Transaction{
+				{Account: "37040044/0532013000", Date: date(2024, 3, 20), Amount: "12.50", Currency: "EUR", Debit: true, Description: "PARKHAUS GMBH Parkhaus Mitte"},
+				{Account: "37040044/0532013000", Date: date(2024, 3, 20), Amount: "12.50", Currency: "EUR", Debit: true, Description: "PARKHAUS GMBH Parkhaus Mitte"},
+				{Account: "37040044/0532013000", Reference: "BANKREF77", Date: date(2024, 3, 21), Amount: "250.0", Currency: "EUR", Description: "ACME GMBH Erstattung Reisekosten Maerz"},
+				// Booked on 29 December for a 2 January value date
+				{Account: "DE89370400440532013000", Date: date(2023, 12, 29), Amount: "99.99", Currency: "USD", Debit: true, Description: "Kontofuehrung"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			format, err := DetectStatementFormat(tt.file, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.format {
+				t.Errorf("detected format %q, want %q", format, tt.format)
+			}
+			// Detection from content alone, as for a file uploaded without a known extension
+			if format, err := DetectStatementFormat("statement.txt", data); err != nil || format != tt.format {
+				t.Errorf("detected format %q from content (%v), want %q", format, err, tt.format)
+			}
+
+			got, err := ParseStatement(format, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d transactions, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !got[i].Date.Equal(tt.want[i].Date) {
+					t.Errorf("transaction %d date = %s, want %s", i+1, got[i].Date.Format("2006-01-02"), tt.want[i].Date.Format("2006-01-02"))
+				}
+				got[i].Date = tt.want[i].Date
+				if got[i] != tt.want[i] {
+					t.Errorf("transaction %d = %+v, want %+v", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExternalIDsAreStable(t *testing.T) {
+	for _, file := range []string{"checking.ofx", "card.qfx", "statement.camt053.xml", "statement.sta"} {
+		t.Run(file, func(t *testing.T) {
+			first := ExternalIDs(readStatement(t, file))
+			second := ExternalIDs(readStatement(t, file))
+			seen := make(map[string]bool)
+			for i := range first {
+				if first[i] != second[i] {
+					t.Errorf("transaction %d has ID %s, then %s", i+1, first[i], second[i])
+				}
+				if seen[first[i]] {
+					t.Errorf("transaction %d repeats ID %s", i+1, first[i])
+				}
+				seen[first[i]] = true
+			}
+		})
+	}
+}
+
+func TestExternalIDsNumberIdenticalLines(t *testing.T) {
+	line := Transaction{Account: "DE89370400440532013000", Date: date(2024, 3, 20), Amount: "2.50", Currency: "EUR", Debit: true, Description: "Parking meter"}
+	other := line
+	other.Amount = money.Decimal("3.00")
+	referenced := line
+	referenced.Reference = "REF1"
+
+	ids := ExternalIDs([]Transaction{line, other, line, referenced, referenced, line})
+	if want := line.ExternalID(0); ids[0] != want {
+		t.Errorf("first line has ID %s, want %s", ids[0], want)
+	}
+	if want := line.ExternalID(1); ids[2] != want {
+		t.Errorf("second identical line has ID %s, want %s", ids[2], want)
+	}
+	if want := line.ExternalID(2); ids[5] != want {
+		t.Errorf("third identical line has ID %s, want %s", ids[5], want)
+	}
+	if want := other.ExternalID(0); ids[1] != want {
+		t.Errorf("different line has ID %s, want %s", ids[1], want)
+	}
+	// Lines with a bank reference are identified by it alone
+	if ids[3] != referenced.ExternalID(0) || ids[4] != ids[3] {
+		t.Errorf("referenced lines have IDs %s and %s", ids[3], ids[4])
+	}
+
+	// A later statement repeating the first line alone gives it the same ID
+	if again := ExternalIDs([]Transaction{line}); again[0] != ids[0] {
+		t.Errorf("repeated line has ID %s, want %s", again[0], ids[0])
+	}
+}
+
+func TestExternalIDIgnoresContentWhenReferenced(t *testing.T) {
+	a := Transaction{Account: "123456789", Reference: "202403050001", Date: date(2024, 3, 5), Amount: "42.50", Currency: "USD", Debit: true, Description: "UBER *TRIP"}
+	b := a
+	b.Description = "UBER *TRIP 8841 San Francisco CA"
+	if a.ExternalID(0) != b.ExternalID(0) {
+		t.Error("a bank-referenced line changed ID with its description")
+	}
+	c := a
+	c.Account = "987654321"
+	if a.ExternalID(0) == c.ExternalID(0) {
+		t.Error("the same reference on another account gave the same ID")
+	}
+}
@@ -0,0 +1,148 @@
+// Package importing reads expense rows from uploaded CSV and Excel files and
+// transactions from bank and card statements (OFX, QFX, CAMT.053, MT940), and
+// parses the loosely formatted values found in them.
+package importing
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format is an uploaded file's format.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// Table is one sheet of cells with its header row split off.
+type Table struct {
+	Format Format
+	Header []string
+	// Rows are the data rows; Rows[i] is row i+2 of the file
+	Rows [][]string
+}
+
+// Cell returns the trimmed value of a row's column, empty when the row is short.
+func (t *Table) Cell(row []string, column int) string {
+	if column < 0 || column >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[column])
+}
+
+// DetectFormat picks the format from the file name, falling back to the
+// content: XLSX files are zip archives.
+func DetectFormat(filename string, data []byte) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx", ".xlsm":
+		return FormatXLSX
+	case ".csv", ".txt":
+		return FormatCSV
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// ReadTable reads a file whose first row holds the column names. For XLSX
+// files sheet selects the worksheet, defaulting to the first; cells are read
+// unformatted, so dates arrive as serial numbers.
+func ReadTable(format Format, data []byte, sheet string) (*Table, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case FormatXLSX:
+		rows, err = readXLSX(data, sheet)
+	default:
+		format = FormatCSV
+		rows, err = readCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Trailing blank rows are common in spreadsheets
+	for len(rows) > 0 && Blank(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	header := make([]string, len(rows[0]))
+	for i, name := range rows[0] {
+		header[i] = strings.TrimSpace(name)
+	}
+	return &Table{Format: format, Header: header, Rows: rows[1:]}, nil
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = sniffDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file: %v", err)
+	}
+	return rows, nil
+}
+
+// sniffDelimiter picks the most frequent of the usual delimiters in the
+// first line; spreadsheets in many European locales export with semicolons.
+func sniffDelimiter(data []byte) rune {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+
+	best, count := ',', 0
+	for _, d := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(line, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+func readXLSX(data []byte, sheet string) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %v", err)
+	}
+	defer f.Close()
+
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	} else if index, err := f.GetSheetIndex(sheet); err != nil || index < 0 {
+		return nil, fmt.Errorf("sheet %q not found", sheet)
+	}
+
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %v", err)
+	}
+	return rows, nil
+}
+
+// Blank reports whether every cell of the row is empty.
+func Blank(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package importing
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// defaultDateLayouts are tried in order when no date format is given
+var defaultDateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02",
+}
+
+// DateLayout turns a pattern such as "DD/MM/YYYY" into a time layout.
+// YYYY, YY, MM and DD are replaced; everything else is kept literally.
+func DateLayout(pattern string) (string, error) {
+	layout := strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(strings.ToUpper(pattern))
+	if !strings.Contains(layout, "01") || !strings.Contains(layout, "02") || !strings.Contains(layout, "06") {
+		return "", errors.New("invalid date format, expected a pattern such as DD/MM/YYYY")
+	}
+	return layout, nil
+}
+
+// ParseDate reads a date cell with layout, or the ISO formats when layout is
+// empty. Numeric cells from XLSX files are spreadsheet serial dates.
+func (t *Table) ParseDate(value, layout string) (time.Time, error) {
+	if t.Format == FormatXLSX {
+		if serial, err := strconv.ParseFloat(value, 64); err == nil {
+			date, err := excelize.ExcelDateToTime(serial, false)
+			if err != nil {
+				return time.Time{}, errors.New("invalid date")
+			}
+			return date, nil
+		}
+	}
+
+	layouts := defaultDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if date, err := time.Parse(l, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date")
+}
+
+// ParseAmount reads an amount cell, ignoring currency symbols, spaces and
+// thousands separators. With decimalComma the comma separates decimals, as in
+// "1.234,56". Amounts in parentheses are negative.
+func ParseAmount(value string, decimalComma bool) money.Decimal {
+	value = strings.Map(func(r rune) rune {
+		switch r {
+		case '$', '€', '£', '¥', ' ', ' ', '\'':
+			return -1
+		}
+		return r
+	}, value)
+
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		value = "-" + value[1:len(value)-1]
+	}
+	if decimalComma {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	} else {
+		value = strings.ReplaceAll(value, ",", "")
+	}
+	return money.Decimal(value)
+}
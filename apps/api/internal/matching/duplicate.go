@@ -0,0 +1,61 @@
+package matching
+
+import (
+	"math"
+	"time"
+)
+
+// DuplicateScore is the score at which two expenses are suspected of
+// recording the same purchase.
+const DuplicateScore = 0.8
+
+// DuplicateWindow is how far apart two expenses may be dated and still be
+// compared; a purchase entered twice usually carries the same date or one
+// a day off.
+const DuplicateWindow = 3 * 24 * time.Hour
+
+// Weights of the duplicate score components; they add up to one.
+const (
+	duplicateAmountWeight = 0.45
+	duplicateDateWeight   = 0.2
+	duplicateTextWeight   = 0.35
+)
+
+// duplicateTolerance is the relative amount difference at which expenses stop
+// matching. Both are entered by the same person, so it is far tighter than
+// the tolerance between a receipt and its card transaction.
+const duplicateTolerance = 0.02
+
+// Duplicate scores how likely two expenses are the same purchase entered
+// twice. Amounts only count in the same currency. Expenses carrying the same
+// attachment file are duplicates whatever their other fields say.
+func Duplicate(a, b Candidate, sameFile bool) Score {
+	s := Score{
+		Date: duplicateDateScore(a.Date, b.Date),
+		Text: Similarity(a.Text, b.Text),
+	}
+	if a.Currency == b.Currency && a.AmountMinor > 0 && b.AmountMinor > 0 {
+		diff := math.Abs(float64(a.AmountMinor-b.AmountMinor)) / math.Max(float64(a.AmountMinor), float64(b.AmountMinor))
+		s.Amount = round(math.Max(0, 1-diff/duplicateTolerance))
+	}
+	s.Total = round(duplicateAmountWeight*s.Amount + duplicateDateWeight*s.Date + duplicateTextWeight*s.Text)
+	if sameFile {
+		s.Total = 1
+	}
+	return s
+}
+
+// duplicateDateScore compares calendar days: the same day is a full match,
+// falling to nothing at DuplicateWindow.
+func duplicateDateScore(a, b time.Time) float64 {
+	if a.IsZero() || b.IsZero() {
+		return 0
+	}
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	diff := dayA.Sub(dayB)
+	if diff < 0 {
+		diff = -diff
+	}
+	return round(math.Max(0, 1-float64(diff)/float64(DuplicateWindow)))
+}
@@ -0,0 +1,101 @@
+package matching
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDuplicateWeightsAddUpToOne(t *testing.T) {
+	if sum := duplicateAmountWeight + duplicateDateWeight + duplicateTextWeight; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("duplicate weights add up to %v", sum)
+	}
+}
+
+func TestDuplicateDateScore(t *testing.T) {
+	morning := time.Date(2024, 3, 1, 0, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		a, b time.Time
+		want float64
+	}{
+		{"same day", morning, morning.Add(23 * time.Hour), 1},
+		{"across midnight", morning.Add(23 * time.Hour), morning.Add(25 * time.Hour), 0.667},
+		{"two days", morning, morning.AddDate(0, 0, -2), 0.333},
+		{"at the window", morning, morning.AddDate(0, 0, 3), 0},
+		{"past the window", morning, morning.AddDate(0, 0, 10), 0},
+		{"unknown date", morning, time.Time{}, 0},
+	}
+	for _, tt := range tests {
+		if got := duplicateDateScore(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: duplicateDateScore() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateThreshold(t *testing.T) {
+	lunch := Candidate{AmountMinor: 1850, Currency: "EUR", Date: day, Text: "Lunch at Pret A Manger"}
+	tests := []struct {
+		name     string
+		other    Candidate
+		sameFile bool
+		want     float64
+		flagged  bool
+	}{
+		{
+			name:  "entered twice",
+			other: lunch,
+			want:  1, flagged: true,
+		},
+		{
+			name:  "a day apart, reworded",
+			other: Candidate{AmountMinor: 1850, Currency: "EUR", Date: day.AddDate(0, 0, 1), Text: "Pret lunch"},
+			want:  0.898, flagged: true,
+		},
+		{
+			name:  "at the edge of the window",
+			other: Candidate{AmountMinor: 1850, Currency: "EUR", Date: day.AddDate(0, 0, 3), Text: "Lunch at Pret A Manger"},
+			want:  0.8, flagged: true,
+		},
+		{
+			name:  "amount one percent off",
+			other: Candidate{AmountMinor: 1869, Currency: "EUR", Date: day, Text: "Lunch at Pret A Manger"},
+			want:  0.771,
+		},
+		{
+			name:  "same figure in another currency",
+			other: Candidate{AmountMinor: 1850, Currency: "GBP", Date: day, Text: "Lunch at Pret A Manger"},
+			want:  0.55,
+		},
+		{
+			name:  "same amount and day, other merchant",
+			other: Candidate{AmountMinor: 1850, Currency: "EUR", Date: day, Text: "Taxi to the office"},
+			want:  0.65,
+		},
+		{
+			name:     "same receipt file",
+			other:    Candidate{AmountMinor: 9900, Currency: "USD", Date: day.AddDate(0, 0, 2), Text: "Taxi"},
+			sameFile: true,
+			want:     1, flagged: true,
+		},
+		{
+			name:  "no amount",
+			other: Candidate{Currency: "EUR", Date: day, Text: "Lunch at Pret A Manger"},
+			want:  0.55,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Duplicate(lunch, tt.other, tt.sameFile)
+			if got.Total != tt.want {
+				t.Errorf("Duplicate() = %+v, want a total of %v", got, tt.want)
+			}
+			if (got.Total >= DuplicateScore) != tt.flagged {
+				t.Errorf("score %v is flagged: %v, want %v", got.Total, got.Total >= DuplicateScore, tt.flagged)
+			}
+			if back := Duplicate(tt.other, lunch, tt.sameFile); back != got {
+				t.Errorf("Duplicate is not symmetric: %+v and %+v", got, back)
+			}
+		})
+	}
+}
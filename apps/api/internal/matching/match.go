@@ -0,0 +1,89 @@
+// Package matching scores how likely two records describe the same purchase,
+// from their amounts, dates and merchant text: a receipt and a card
+// transaction, or two expenses entered twice.
+package matching
+
+import (
+	"math"
+	"time"
+)
+
+// Score thresholds. Pairs at or above AutoLinkScore are linked without review
+// when nothing else comes close; pairs at or above ReviewScore are queued for
+// a person to decide.
+const (
+	AutoLinkScore = 0.85
+	ReviewScore   = 0.5
+	// AutoLinkMargin is how far a pair must lead the runner-up to be linked automatically
+	AutoLinkMargin = 0.1
+)
+
+// Weights of the score components; they add up to one.
+const (
+	amountWeight = 0.5
+	dateWeight   = 0.25
+	textWeight   = 0.25
+)
+
+// amountTolerance is the relative difference at which amounts stop matching
+// at all, leaving room for tips and card fees.
+const amountTolerance = 0.1
+
+// dateWindow is how far apart a purchase and its posting may be; card
+// transactions usually post a day or two after the receipt is printed.
+const dateWindow = 7 * 24 * time.Hour
+
+// Candidate is one side of a pair. AmountMinor is zero when unknown.
+type Candidate struct {
+	AmountMinor int64
+	Currency    string
+	Date        time.Time
+	Text        string
+}
+
+// Score is a pair's overall score with its components, each from 0 to 1.
+type Score struct {
+	Total  float64 `json:"score"`
+	Amount float64 `json:"amount_score"`
+	Date   float64 `json:"date_score"`
+	Text   float64 `json:"text_score"`
+}
+
+// Compare scores a receipt against a transaction. The amounts must be in the
+// same currency; callers convert them first when they are not.
+func Compare(receipt, transaction Candidate) Score {
+	s := Score{
+		Amount: amountScore(receipt.AmountMinor, transaction.AmountMinor),
+		Date:   dateScore(receipt.Date, transaction.Date),
+		Text:   Similarity(receipt.Text, transaction.Text),
+	}
+	s.Total = round(amountWeight*s.Amount + dateWeight*s.Date + textWeight*s.Text)
+	return s
+}
+
+func amountScore(a, b int64) float64 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	diff := math.Abs(float64(a-b)) / math.Max(float64(a), float64(b))
+	return round(math.Max(0, 1-diff/amountTolerance))
+}
+
+func dateScore(a, b time.Time) float64 {
+	if a.IsZero() || b.IsZero() {
+		return 0
+	}
+	diff := a.Sub(b)
+	if diff < 0 {
+		diff = -diff
+	}
+	// Same or next day counts as a full match
+	if diff <= 24*time.Hour {
+		return 1
+	}
+	return round(math.Max(0, 1-float64(diff-24*time.Hour)/float64(dateWindow-24*time.Hour)))
+}
+
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
@@ -0,0 +1,121 @@
+package matching
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestWeightsAddUpToOne(t *testing.T) {
+	if sum := amountWeight + dateWeight + textWeight; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("match weights add up to %v", sum)
+	}
+}
+
+func TestAmountScore(t *testing.T) {
+	tests := []struct {
+		a, b int64
+		want float64
+	}{
+		{1000, 1000, 1},
+		{1000, 1050, 0.524},
+		{1050, 1000, 0.524},
+		// A 10% tip on the card
+		{1000, 1100, 0.091},
+		{1000, 1112, 0},
+		{1000, 5000, 0},
+		{0, 1000, 0},
+		{1000, 0, 0},
+		{-1000, -1000, 0},
+	}
+	for _, tt := range tests {
+		if got := amountScore(tt.a, tt.b); got != tt.want {
+			t.Errorf("amountScore(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDateScore(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b time.Time
+		want float64
+	}{
+		{"same time", day, day, 1},
+		{"posted next day", day, day.Add(24 * time.Hour), 1},
+		{"posted before", day, day.Add(-24 * time.Hour), 1},
+		{"two days", day, day.Add(48 * time.Hour), 0.833},
+		{"four days", day, day.Add(96 * time.Hour), 0.5},
+		{"a week", day, day.Add(7 * 24 * time.Hour), 0},
+		{"a month", day, day.AddDate(0, 1, 0), 0},
+		{"unknown date", time.Time{}, day, 0},
+	}
+	for _, tt := range tests {
+		if got := dateScore(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: dateScore() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompareThresholds(t *testing.T) {
+	receipt := Candidate{AmountMinor: 2350, Currency: "USD", Date: day, Text: "Uber"}
+	tests := []struct {
+		name        string
+		transaction Candidate
+		want        float64
+		autoLink    bool
+		review      bool
+	}{
+		{
+			name:        "same amount, day and merchant",
+			transaction: Candidate{AmountMinor: 2350, Date: day, Text: "Uber"},
+			want:        1, autoLink: true, review: true,
+		},
+		{
+			name:        "card descriptor posted two days later",
+			transaction: Candidate{AmountMinor: 2350, Date: day.Add(48 * time.Hour), Text: "UBER *TRIP 8841 HELP.UBER.COM"},
+			want:        0.933, autoLink: true, review: true,
+		},
+		{
+			name:        "tip added on the card",
+			transaction: Candidate{AmountMinor: 2585, Date: day, Text: "UBER *TRIP"},
+			want:        0.521, review: true,
+		},
+		{
+			name:        "same amount at an unknown merchant a week later",
+			transaction: Candidate{AmountMinor: 2350, Date: day.Add(7 * 24 * time.Hour), Text: "DELTA AIR LINES"},
+			want:        0.5, review: true,
+		},
+		{
+			name:        "same merchant and day, other amount",
+			transaction: Candidate{AmountMinor: 4100, Date: day, Text: "UBER *EATS"},
+			want:        0.475,
+		},
+		{
+			name:        "unrelated",
+			transaction: Candidate{AmountMinor: 9900, Date: day.Add(5 * 24 * time.Hour), Text: "AMAZON MKTPLACE"},
+			want:        0.083,
+		},
+		{
+			name:        "receipt without amount",
+			transaction: Candidate{Date: day, Text: "Uber"},
+			want:        0.5, review: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(receipt, tt.transaction)
+			if got.Total != tt.want {
+				t.Errorf("Compare() = %+v, want a total of %v", got, tt.want)
+			}
+			if (got.Total >= AutoLinkScore) != tt.autoLink {
+				t.Errorf("score %v links automatically: %v, want %v", got.Total, got.Total >= AutoLinkScore, tt.autoLink)
+			}
+			if (got.Total >= ReviewScore) != tt.review {
+				t.Errorf("score %v is reviewed: %v, want %v", got.Total, got.Total >= ReviewScore, tt.review)
+			}
+		})
+	}
+}
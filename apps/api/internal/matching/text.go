@@ -0,0 +1,131 @@
+package matching
+
+import (
+	"strings"
+	"unicode"
+)
+
+// noiseWords appear in card descriptors and receipts regardless of merchant
+var noiseWords = map[string]bool{
+	"pos": true, "card": true, "purchase": true, "payment": true, "debit": true, "credit": true,
+	"visa": true, "mastercard": true, "amex": true, "sq": true, "paypal": true,
+	"www": true, "com": true, "net": true, "org": true,
+	"inc": true, "ltd": true, "llc": true, "gmbh": true, "bv": true, "sa": true, "plc": true, "co": true,
+	"the": true, "and": true, "receipt": true, "invoice": true,
+}
+
+// Normalize lower-cases text and reduces it to its merchant words: digits,
+// punctuation and the noise found in card descriptors are dropped, so
+// "UBER *TRIP 8841" and "Uber trip" normalize alike.
+func Normalize(text string) string {
+	return strings.Join(words(text), " ")
+}
+
+// MerchantName guesses the merchant a card descriptor names, title-cased:
+// "UBER *TRIP 8841" gives "Uber" and "SQ *BLUE BOTTLE #12" gives "Blue
+// Bottle". What follows a "*" is taken only when a payment processor's
+// prefix comes before it. It returns "" when no merchant words remain.
+func MerchantName(descriptor string) string {
+	parts := strings.SplitN(descriptor, "*", 2)
+	name := words(parts[0])
+	if len(name) == 0 && len(parts) > 1 {
+		name = words(parts[1])
+	}
+	for i, w := range name {
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		name[i] = string(runes)
+	}
+	return strings.Join(name, " ")
+}
+
+// Similarity compares two texts after normalizing them, from 0 for nothing
+// in common to 1 for the same words. A short text whose words all appear in
+// a longer one, such as a merchant name within a description, scores highly.
+func Similarity(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+
+	dice := trigramDice(strings.Join(wa, " "), strings.Join(wb, " "))
+
+	set := make(map[string]bool, len(wb))
+	for _, w := range wb {
+		set[w] = true
+	}
+	shared := 0
+	for _, w := range unique(wa) {
+		if set[w] {
+			shared++
+		}
+	}
+	shorter := len(unique(wa))
+	if n := len(unique(wb)); n < shorter {
+		shorter = n
+	}
+	overlap := 0.9 * float64(shared) / float64(shorter)
+
+	if overlap > dice {
+		return round(overlap)
+	}
+	return round(dice)
+}
+
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len(f) > 1 && !noiseWords[f] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func unique(words []string) []string {
+	seen := make(map[string]bool, len(words))
+	var out []string
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// trigramDice is the Dice coefficient of the texts' character trigrams
+func trigramDice(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t, n := range ta {
+		if m := tb[t]; m < n {
+			shared += m
+		} else {
+			shared += n
+		}
+	}
+	total := 0
+	for _, n := range ta {
+		total += n
+	}
+	for _, n := range tb {
+		total += n
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+func trigrams(s string) map[string]int {
+	runes := []rune("  " + s + " ")
+	out := make(map[string]int)
+	for i := 0; i+3 <= len(runes); i++ {
+		out[string(runes[i:i+3])]++
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package matching
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"UBER *TRIP 8841", "uber trip"},
+		{"Uber trip", "uber trip"},
+		{"SQ *BLUE BOTTLE #12", "blue bottle"},
+		{"POS PURCHASE VISA 1234 STARBUCKS STORE 00981", "starbucks store"},
+		{"Amazon.com", "amazon"},
+		{"WWW.BOOKING.COM", "booking"},
+		{"Pret A Manger Ltd", "pret manger"},
+		{"Müller GmbH & Co. KG", "müller kg"},
+		{"McDonald's", "mcdonald"},
+		{"  lots   of\tspace\n", "lots of space"},
+		{"1234 5678", ""},
+		{"the card payment", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMerchantName(t *testing.T) {
+	tests := []struct {
+		descriptor string
+		want       string
+	}{
+		{"UBER *TRIP 8841", "Uber"},
+		{"SQ *BLUE BOTTLE #12", "Blue Bottle"},
+		{"PAYPAL *SPOTIFY", "Spotify"},
+		{"AMZN Mktp US*2K4", "Amzn Mktp Us"},
+		{"DELTA AIR LINES 0062", "Delta Air Lines"},
+		{"éclair café", "Éclair Café"},
+		{"SQ *", ""},
+		{"#1234", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MerchantName(tt.descriptor); got != tt.want {
+			t.Errorf("MerchantName(%q) = %q, want %q", tt.descriptor, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		min, max float64
+	}{
+		{"identical", "Blue Bottle Coffee", "Blue Bottle Coffee", 1, 1},
+		{"descriptor and receipt", "UBER *TRIP 8841", "Uber trip", 1, 1},
+		{"case and punctuation", "blue-bottle coffee!", "BLUE BOTTLE COFFEE", 1, 1},
+		{"repeated words", "uber uber", "uber", 0.9, 0.9},
+		{"merchant within a description", "Uber", "Uber ride to the airport", 0.9, 0.9},
+		{"half the words", "Blue Bottle", "Blue Ocean", 0.45, 0.6},
+		{"misspelling", "Starbucks", "Starbuck", 0.7, 0.9},
+		{"nothing in common", "Delta Air Lines", "Starbucks", 0, 0.1},
+		{"only noise", "POS CARD PURCHASE 1234", "POS CARD PURCHASE 1234", 0, 0},
+		{"empty", "", "Uber", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(tt.a, tt.b)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Similarity(%q, %q) = %v, want %v to %v", tt.a, tt.b, got, tt.min, tt.max)
+			}
+			if back := Similarity(tt.b, tt.a); back != got {
+				t.Errorf("Similarity is not symmetric: %v and %v", got, back)
+			}
+		})
+	}
+}
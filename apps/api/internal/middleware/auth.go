@@ -0,0 +1,103 @@
+package middleware
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gorilla/mux"
+
+    "github.com/example/next-go-monorepo/apps/api/internal/auth"
+)
+
+// OrganizationHeader selects the organization a request acts in when the route has no org_id.
+const OrganizationHeader = "X-Organization-ID"
+
+// PrincipalResolver maps verified token claims and the requested organization
+// (zero for the caller's default) to the principal stored on the request context.
+type PrincipalResolver func(claims *auth.Claims, organizationID uint) (*auth.Principal, error)
+
+// Authenticate rejects requests without a valid bearer token and stores the
+// resolved principal on the request context. It must run after route matching
+// so that an {org_id} path variable can select the organization.
+func Authenticate(verifier *auth.Verifier, resolve PrincipalResolver) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            token := bearerToken(r)
+            if token == "" {
+                unauthorized(w, "Missing bearer token")
+                return
+            }
+
+            claims, err := verifier.Verify(token)
+            if err != nil {
+                unauthorized(w, "Invalid or expired token")
+                return
+            }
+
+            organizationID, ok := requestedOrganization(r)
+            if !ok {
+                w.Header().Set("Content-Type", "application/json")
+                w.WriteHeader(http.StatusBadRequest)
+                w.Write([]byte(`{"detail": "Invalid organization ID"}`))
+                return
+            }
+
+            principal, err := resolve(claims, organizationID)
+            if errors.Is(err, auth.ErrNotMember) {
+                w.Header().Set("Content-Type", "application/json")
+                w.WriteHeader(http.StatusForbidden)
+                w.Write([]byte(`{"detail": "Not a member of this organization"}`))
+                return
+            }
+            if err != nil {
+                log.Printf("failed to resolve principal for subject %s: %v", claims.Subject, err)
+                w.Header().Set("Content-Type", "application/json")
+                w.WriteHeader(http.StatusInternalServerError)
+                w.Write([]byte(`{"detail": "Failed to authenticate request"}`))
+                return
+            }
+
+            principal.RequestID = RequestIDFromContext(r.Context())
+            principal.ClientIP = getClientIP(r)
+
+            next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+        })
+    }
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) string {
+    header := r.Header.Get("Authorization")
+    scheme, token, found := strings.Cut(header, " ")
+    if !found || !strings.EqualFold(scheme, "Bearer") {
+        return ""
+    }
+    return strings.TrimSpace(token)
+}
+
+// requestedOrganization reads the organization from the route or the organization header
+func requestedOrganization(r *http.Request) (uint, bool) {
+    value := mux.Vars(r)["org_id"]
+    if value == "" {
+        value = strings.TrimSpace(r.Header.Get(OrganizationHeader))
+    }
+    if value == "" {
+        return 0, true
+    }
+
+    id, err := strconv.ParseUint(value, 10, 32)
+    if err != nil || id == 0 {
+        return 0, false
+    }
+    return uint(id), true
+}
+
+func unauthorized(w http.ResponseWriter, detail string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+    w.WriteHeader(http.StatusUnauthorized)
+    w.Write([]byte(`{"detail": "` + detail + `"}`))
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "peer without port", remoteAddr: "203.0.113.7:51234", want: "203.0.113.7"},
+		{name: "ipv6 peer", remoteAddr: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "forged headers from untrusted peer", remoteAddr: "203.0.113.7:51234",
+			forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.7"},
+		{name: "forged headers when proxy is elsewhere", proxies: []string{"10.0.0.1"},
+			remoteAddr: "203.0.113.7:51234", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "trusted proxy", proxies: []string{"10.0.0.1"}, remoteAddr: "10.0.0.1:8080",
+			forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "client prepends a forged hop", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:8080",
+			forwarded: []string{"192.0.2.99, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:8080",
+			forwarded: []string{"198.51.100.1, 10.1.2.3", "10.4.5.6"}, want: "198.51.100.1"},
+		{name: "only trusted hops", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:8080",
+			forwarded: []string{"10.1.2.3"}, want: "10.1.2.3"},
+		{name: "malformed hop", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.1:8080",
+			forwarded: []string{"nonsense"}, want: "10.0.0.1"},
+		{name: "real ip from trusted proxy", proxies: []string{"10.0.0.1"}, remoteAddr: "10.0.0.1:8080",
+			realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "invalid real ip", proxies: []string{"10.0.0.1"}, remoteAddr: "10.0.0.1:8080",
+			realIP: "198.51.100.2:99", want: "10.0.0.1"},
+	}
+	t.Cleanup(func() { trustedProxies = nil })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := getClientIP(r); got != tt.want {
+				t.Errorf("getClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxiesRejectsInvalidEntries(t *testing.T) {
+	t.Cleanup(func() { trustedProxies = nil })
+	for _, proxy := range []string{"proxy.internal", "10.0.0.0/33", "10.0.0.1:80"} {
+		if err := SetTrustedProxies([]string{proxy}); err == nil {
+			t.Errorf("SetTrustedProxies(%q) succeeded, want an error", proxy)
+		}
+	}
+	if err := SetTrustedProxies([]string{" 10.0.0.1 ", "", "2001:db8::/32"}); err != nil {
+		t.Errorf("SetTrustedProxies() = %v", err)
+	}
+}
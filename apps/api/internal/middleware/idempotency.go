@@ -0,0 +1,207 @@
+package middleware
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "mime"
+    "mime/multipart"
+    "net/http"
+    "strings"
+
+    "github.com/example/next-go-monorepo/apps/api/internal/auth"
+    "github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// IdempotencyKeyHeader lets clients retry a POST without repeating its effect
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks responses replayed from an earlier request
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodySize bounds request bodies buffered for fingerprinting
+const maxIdempotentBodySize = 32 << 20
+
+// IdempotencyStore keeps the responses to requests sent with an idempotency
+// key, scoped to the principal's user and organization.
+type IdempotencyStore interface {
+    // Begin claims key for a request with the given fingerprint. It returns
+    // nil when the request should be handled and the stored record when the
+    // key already completed an identical request. It fails with
+    // "idempotency key reused" for a different request and
+    // "idempotency key in progress" while the first request is running.
+    Begin(p *auth.Principal, key, fingerprint string) (*models.IdempotencyRecord, error)
+    Complete(p *auth.Principal, key string, status int, header http.Header, body []byte) error
+    Release(p *auth.Principal, key string) error
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key safe to retry:
+// the first response is stored and replayed for identical retries. Server
+// errors are not stored, so those retries run again. It must run after
+// Authenticate.
+func Idempotency(store IdempotencyStore) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            key := r.Header.Get(IdempotencyKeyHeader)
+            principal, ok := auth.PrincipalFromContext(r.Context())
+            if r.Method != http.MethodPost || key == "" || !ok {
+                next.ServeHTTP(w, r)
+                return
+            }
+            if !validIdempotencyKey(key) {
+                writeDetail(w, http.StatusBadRequest, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+                return
+            }
+
+            body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+            if err != nil {
+                writeDetail(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+                return
+            }
+            r.Body = io.NopCloser(bytes.NewReader(body))
+
+            stored, err := store.Begin(principal, key, fingerprint(r, body))
+            if err != nil {
+                switch err.Error() {
+                case "idempotency key reused":
+                    writeDetail(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+                case "idempotency key in progress":
+                    writeDetail(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+                default:
+                    log.Printf("failed to claim idempotency key: %v", err)
+                    writeDetail(w, http.StatusInternalServerError, "Failed to process request")
+                }
+                return
+            }
+            if stored != nil {
+                replay(w, stored)
+                return
+            }
+
+            // A handler that panics or fails on our side must not pin the key
+            completed := false
+            defer func() {
+                if !completed {
+                    if err := store.Release(principal, key); err != nil {
+                        log.Printf("failed to release idempotency key: %v", err)
+                    }
+                }
+            }()
+
+            inherited := w.Header().Clone()
+            recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+            next.ServeHTTP(recorder, r)
+            if recorder.status >= http.StatusInternalServerError {
+                return
+            }
+
+            // Only headers set by the handler are stored; the rest belong to this request
+            header := http.Header{}
+            for name, values := range w.Header() {
+                if strings.Join(inherited[name], ",") != strings.Join(values, ",") {
+                    header[name] = values
+                }
+            }
+            if err := store.Complete(principal, key, recorder.status, header, recorder.body.Bytes()); err != nil {
+                log.Printf("failed to store idempotent response: %v", err)
+                return
+            }
+            completed = true
+        })
+    }
+}
+
+// responseRecorder passes a response through while keeping a copy
+type responseRecorder struct {
+    http.ResponseWriter
+    status      int
+    wroteHeader bool
+    body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+    if !rec.wroteHeader {
+        rec.status = status
+        rec.wroteHeader = true
+    }
+    rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+    rec.wroteHeader = true
+    rec.body.Write(b)
+    return rec.ResponseWriter.Write(b)
+}
+
+// replay writes a stored response
+func replay(w http.ResponseWriter, record *models.IdempotencyRecord) {
+    var header http.Header
+    if record.Header != "" {
+        if err := json.Unmarshal([]byte(record.Header), &header); err != nil {
+            log.Printf("failed to decode stored response headers: %v", err)
+        }
+    }
+    for name, values := range header {
+        w.Header()[name] = values
+    }
+    w.Header().Set(IdempotentReplayHeader, "true")
+    w.WriteHeader(record.StatusCode)
+    w.Write(record.Body)
+}
+
+// fingerprint identifies a request by method, target and content. Multipart
+// bodies are hashed part by part because clients pick a new boundary for
+// every attempt.
+func fingerprint(r *http.Request, body []byte) string {
+    h := sha256.New()
+    fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+
+    mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+        parts := sha256.New()
+        reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+        for {
+            part, err := reader.NextPart()
+            if err == io.EOF {
+                fmt.Fprintf(h, "%s\n", mediaType)
+                h.Write(parts.Sum(nil))
+                return hex.EncodeToString(h.Sum(nil))
+            }
+            if err != nil {
+                // Malformed bodies fall back to their raw bytes; the handler will reject them
+                break
+            }
+            content, _ := io.ReadAll(part)
+            fmt.Fprintf(parts, "%q %q %q %d\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), len(content))
+            parts.Write(content)
+        }
+    }
+
+    fmt.Fprintf(h, "%s\n", mediaType)
+    h.Write(body)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+func validIdempotencyKey(key string) bool {
+    if len(key) > maxIdempotencyKeyLength {
+        return false
+    }
+    for _, c := range key {
+        if c < ' ' || c > '~' {
+            return false
+        }
+    }
+    return true
+}
+
+func writeDetail(w http.ResponseWriter, status int, detail string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    w.Write([]byte(`{"detail": "` + detail + `"}`))
+}
@@ -36,9 +36,6 @@ func TollboothRateLimit(config RateLimitConfig) func(http.Handler) http.Handler
     // Set burst size
     lmt.SetBurst(config.BurstSize)
     
-    // Configure IP lookup methods
-    lmt.SetIPLookups([]string{"X-Forwarded-For", "X-Real-IP", "RemoteAddr"})
-    
     return func(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
             // Skip rate limiting for certain paths
@@ -49,8 +46,8 @@ func TollboothRateLimit(config RateLimitConfig) func(http.Handler) http.Handler
                 }
             }
             
-            // Apply rate limiting
-            httpError := tollbooth.LimitByRequest(lmt, w, r)
+            // Apply rate limiting, keyed the same way as SimpleRateLimit so forwarding headers are only trusted from known proxies
+            httpError := tollbooth.LimitByKeys(lmt, []string{getClientIP(r)})
             if httpError != nil {
                 w.Header().Set("Content-Type", "application/json")
                 w.WriteHeader(httpError.StatusCode)
@@ -112,21 +109,3 @@ func SimpleRateLimit(requestsPerSecond rate.Limit, burst int) func(http.Handler)
         })
     }
 }
-
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-    // Check X-Forwarded-For header first
-    forwarded := r.Header.Get("X-Forwarded-For")
-    if forwarded != "" {
-        return forwarded
-    }
-    
-    // Check X-Real-IP header
-    realIP := r.Header.Get("X-Real-IP")
-    if realIP != "" {
-        return realIP
-    }
-    
-    // Fall back to RemoteAddr
-    return r.RemoteAddr
-}
\ No newline at end of file
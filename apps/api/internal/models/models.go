@@ -1,39 +1,1053 @@
 package models
 
 import (
+    "encoding/json"
+    "errors"
     "time"
+
+    "gorm.io/gorm"
+
+    "github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// Role is a member's role within an organization
+type Role string
+
+const (
+    RoleOwner    Role = "owner"
+    RoleAdmin    Role = "admin"
+    RoleApprover Role = "approver"
+    RoleMember   Role = "member"
+    RoleAuditor  Role = "auditor"
+)
+
+// Roles lists every valid role
+var Roles = []Role{RoleOwner, RoleAdmin, RoleApprover, RoleMember, RoleAuditor}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+    for _, role := range Roles {
+        if r == role {
+            return true
+        }
+    }
+    return false
+}
+
+// TenantOwned is implemented by models whose rows belong to a single organization.
+// The database layer filters queries on these models to the caller's organization.
+type TenantOwned interface {
+    TenantOwned()
+}
+
+// Organization is a tenant that owns expenses and has members
+type Organization struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    Name      string    `json:"name" gorm:"not null"`
+    BaseCurrency string `json:"base_currency" gorm:"size:3;not null;default:'USD'"`
+    // ReceiptRequiredAbove flags expenses above this base currency amount that have no receipt; zero disables it
+    ReceiptRequiredAbove      money.Decimal `json:"receipt_required_above" gorm:"-"`
+    ReceiptRequiredAboveMinor int64         `json:"receipt_required_above_minor" gorm:"not null;default:0"`
+    DuplicateMode DuplicateMode `json:"duplicate_mode" gorm:"size:16;not null;default:'warn'"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AfterFind fills the decimal receipt threshold from the stored minor units
+func (o *Organization) AfterFind(tx *gorm.DB) error {
+    o.ReceiptRequiredAbove = money.FromMinor(o.ReceiptRequiredAboveMinor, o.BaseCurrency)
+    return nil
+}
+
+// AfterSave keeps the decimal receipt threshold in step with the stored minor units
+func (o *Organization) AfterSave(tx *gorm.DB) error {
+    o.ReceiptRequiredAbove = money.FromMinor(o.ReceiptRequiredAboveMinor, o.BaseCurrency)
+    return nil
+}
+
+// DuplicateMode is how an organization treats new or edited expenses that look like duplicates
+type DuplicateMode string
+
+const (
+    // DuplicateWarn saves the expense, returns the lookalikes and queues them for review
+    DuplicateWarn   DuplicateMode = "warn"
+    // DuplicateBlock rejects the expense
+    DuplicateBlock  DuplicateMode = "block"
+    // DuplicateIgnore skips the check
+    DuplicateIgnore DuplicateMode = "ignore"
+)
+
+// Valid reports whether m is a known duplicate mode
+func (m DuplicateMode) Valid() bool {
+    return m == DuplicateWarn || m == DuplicateBlock || m == DuplicateIgnore
+}
+
+// Membership grants a user a role within an organization
+type Membership struct {
+    ID             uint         `json:"id" gorm:"primaryKey"`
+    OrganizationID uint         `json:"organization_id" gorm:"not null;uniqueIndex:idx_membership_org_user"`
+    UserID         uint         `json:"user_id" gorm:"not null;uniqueIndex:idx_membership_org_user"`
+    Role           Role         `json:"role" gorm:"not null"`
+    CreatedAt      time.Time    `json:"created_at"`
+    UpdatedAt      time.Time    `json:"updated_at"`
+    User           *User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
+    Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// Invitation offers membership in an organization to an email address
+type Invitation struct {
+    ID             uint       `json:"id" gorm:"primaryKey"`
+    OrganizationID uint       `json:"organization_id" gorm:"not null;index"`
+    Email          string     `json:"email" gorm:"not null;index"`
+    Role           Role       `json:"role" gorm:"not null"`
+    InvitedByID    uint       `json:"invited_by_id"`
+    ExpiresAt      time.Time  `json:"expires_at"`
+    AcceptedAt     *time.Time `json:"accepted_at"`
+    CreatedAt      time.Time  `json:"created_at"`
+    Organization   *Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+// User represents an authenticated API user, keyed by the token subject
+type User struct {
+    ID        uint      `json:"id" gorm:"primaryKey"`
+    Subject   string    `json:"subject" gorm:"uniqueIndex;not null"`
+    Email     string    `json:"email"`
+    Name      string    `json:"name"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExpenseStatus is the approval state of an expense
+type ExpenseStatus string
+
+const (
+    StatusDraft      ExpenseStatus = "draft"
+    StatusSubmitted  ExpenseStatus = "submitted"
+    StatusApproved   ExpenseStatus = "approved"
+    StatusRejected   ExpenseStatus = "rejected"
+    StatusReimbursed ExpenseStatus = "reimbursed"
+)
+
+// ExpenseAction is a workflow step that moves an expense between statuses
+type ExpenseAction string
+
+const (
+    ActionSubmit    ExpenseAction = "submit"
+    ActionApprove   ExpenseAction = "approve"
+    ActionReject    ExpenseAction = "reject"
+    ActionReimburse ExpenseAction = "reimburse"
 )
 
 // Expense represents an expense record
 type Expense struct {
     ID           uint                   `json:"id" gorm:"primaryKey"`
+    OrganizationID uint                 `json:"organization_id" gorm:"index;uniqueIndex:idx_expense_external_id"`
+    UserID       uint                   `json:"user_id" gorm:"index"`
+    // ExternalID identifies the statement transaction an imported expense came from, so re-imports skip it
+    ExternalID   *string                `json:"external_id,omitempty" gorm:"size:64;uniqueIndex:idx_expense_external_id"`
+    // RecurringExpenseID is the template a scheduled expense was created from
+    RecurringExpenseID *uint            `json:"recurring_expense_id,omitempty" gorm:"index"`
+    // ReportID is the expense report the expense is claimed on
+    ReportID     *uint                  `json:"report_id,omitempty" gorm:"index"`
     Description  string                 `json:"description" gorm:"not null"`
-    Amount       float64               `json:"amount" gorm:"not null"`
-    Date         time.Time             `json:"date"`
-    Category     string                `json:"category"`
+    Amount       money.Decimal         `json:"amount" gorm:"-"`
+    AmountMinor  int64                 `json:"amount_minor" gorm:"not null;default:0"`
+    Currency     string                `json:"currency" gorm:"size:3;not null;default:'USD'"`
+    BaseAmount   money.Decimal         `json:"base_amount" gorm:"-"`
+    BaseAmountMinor int64              `json:"base_amount_minor" gorm:"not null;default:0;index"`
+    BaseCurrency string                `json:"base_currency" gorm:"size:3"`
+    ExchangeRate string                `json:"exchange_rate"`
+    ExchangeRateDate *time.Time        `json:"exchange_rate_date"`
+    Date         time.Time             `json:"date" gorm:"index"`
+    Category     string                `json:"category" gorm:"index"`
     ClientNotes  string                `json:"client_notes" gorm:"type:text"`
-    CreatedAt    time.Time             `json:"created_at"`
-    UpdatedAt    time.Time             `json:"updated_at"`
+    ProjectID    *uint                 `json:"project_id" gorm:"index"`
+    CostCenterID *uint                 `json:"cost_center_id" gorm:"index"`
+    // MerchantID is the vendor the expense was bought from, matched from its description
+    MerchantID   *uint                 `json:"merchant_id" gorm:"index"`
+    // Attendees is how many people the expense covers, for per-person policy limits
+    Attendees    int                   `json:"attendees" gorm:"not null;default:1"`
+    // Type tells how the amount was arrived at; mileage and per-diem amounts are computed
+    Type         ExpenseType           `json:"type" gorm:"size:16;not null;default:'standard';index"`
+    // Mileage and PerDiem hold the inputs and the rate a computed amount was frozen with
+    Mileage      *MileageDetails       `json:"mileage,omitempty" gorm:"serializer:json;type:text"`
+    PerDiem      *PerDiemDetails       `json:"per_diem,omitempty" gorm:"serializer:json;type:text"`
+    // TaxJurisdiction is the country whose value-added tax the amount includes, and
+    // TaxCode the kind of rate charged there, such as "standard" or "reduced"
+    TaxJurisdiction string             `json:"tax_jurisdiction" gorm:"size:2;index"`
+    TaxCode      string                `json:"tax_code"`
+    // TaxRate is a percent of the net amount; TaxRateID is the table rate it was
+    // looked up from, nil when it was entered
+    TaxRate      string                `json:"tax_rate"`
+    TaxRateID    *uint                 `json:"tax_rate_id,omitempty"`
+    // Amount is gross: TaxAmount is the tax it includes and NetAmount the rest
+    TaxAmount    money.Decimal         `json:"tax_amount" gorm:"-"`
+    TaxAmountMinor int64               `json:"tax_amount_minor" gorm:"not null;default:0"`
+    NetAmount    money.Decimal         `json:"net_amount" gorm:"-"`
+    NetAmountMinor int64               `json:"net_amount_minor" gorm:"-"`
+    // MerchantVATNumber is the seller's tax registration, needed to reclaim the tax
+    MerchantVATNumber string           `json:"merchant_vat_number" gorm:"size:32"`
+    Status       ExpenseStatus         `json:"status" gorm:"not null;default:'draft';index"`
+    ApprovalCount     int              `json:"approval_count" gorm:"not null;default:0"`
+    RequiredApprovals int              `json:"required_approvals" gorm:"not null;default:0"`
+    SubmittedAt  *time.Time            `json:"submitted_at"`
+    // Reimbursement is whether the owner is owed the expense and whether it has been paid out
+    Reimbursement ReimbursementStatus  `json:"reimbursement" gorm:"size:16;not null;default:'reimbursable';index"`
+    // ReimbursementBatchID is the payout batch the owner is paid the expense in
+    ReimbursementBatchID *uint         `json:"reimbursement_batch_id,omitempty" gorm:"index"`
+    PaidAt       *time.Time            `json:"paid_at,omitempty"`
+    // Version increases with every change to the expense or its attachments and is served as its ETag
+    Version      int64                 `json:"version" gorm:"not null;default:1"`
+    CreatedAt    time.Time             `json:"created_at" gorm:"index"`
+    UpdatedAt    time.Time             `json:"updated_at" gorm:"index"`
+    DeletedAt    gorm.DeletedAt        `json:"deleted_at" gorm:"index"`
     Attachments  []Attachment          `json:"attachments" gorm:"foreignKey:ExpenseID"`
     AISuggestions []AISuggestion       `json:"ai_suggestions" gorm:"foreignKey:ExpenseID"`
+    PolicyViolations []PolicyViolation `json:"policy_violations" gorm:"foreignKey:ExpenseID"`
+    Project      *Project              `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+    CostCenter   *CostCenter           `json:"cost_center,omitempty" gorm:"foreignKey:CostCenterID"`
+    Merchant     *Merchant             `json:"merchant,omitempty" gorm:"foreignKey:MerchantID"`
+    Tags         []Tag                 `json:"tags" gorm:"many2many:expense_tags"`
+    // Allocations split the expense into lines that add up to its amount; empty when it is not split
+    Allocations  []ExpenseAllocation   `json:"allocations" gorm:"foreignKey:ExpenseID"`
+    // PossibleDuplicates is filled on create and update responses only
+    PossibleDuplicates []PossibleDuplicate `json:"possible_duplicates,omitempty" gorm:"-"`
+}
+
+// ReimbursementStatus is what an expense's owner is owed for it
+type ReimbursementStatus string
+
+const (
+    // ReimbursementNone marks expenses the company paid, such as on a corporate card
+    ReimbursementNone ReimbursementStatus = "none"
+    // ReimbursementDue marks expenses the owner paid and is paid back once they are approved
+    ReimbursementDue ReimbursementStatus = "reimbursable"
+    // ReimbursementPaid marks expenses paid out in a reimbursement batch
+    ReimbursementPaid ReimbursementStatus = "paid"
+)
+
+// ExpenseType tells how an expense's amount is arrived at
+type ExpenseType string
+
+const (
+    // ExpenseStandard is a receipt-backed expense whose amount is entered
+    ExpenseStandard ExpenseType = "standard"
+    // ExpenseMileage is computed from a distance driven and a mileage rate
+    ExpenseMileage ExpenseType = "mileage"
+    // ExpensePerDiem is computed from a trip's days and a daily allowance
+    ExpensePerDiem ExpenseType = "per_diem"
+)
+
+// DistanceUnit is the unit distances and mileage rates are given in
+type DistanceUnit string
+
+const (
+    Kilometers DistanceUnit = "km"
+    Miles      DistanceUnit = "mi"
+)
+
+// MileageDetails are the inputs of a mileage claim and the rate its amount
+// was computed with. They are frozen with the amount, so later rate changes
+// do not affect the claim.
+type MileageDetails struct {
+    Distance          money.Decimal `json:"distance"`
+    Unit              DistanceUnit  `json:"unit"`
+    VehicleType       string        `json:"vehicle_type"`
+    Country           string        `json:"country,omitempty"`
+    RateID            uint          `json:"rate_id"`
+    // Rate is paid per RateUnit, in the expense's currency
+    Rate              string        `json:"rate"`
+    RateUnit          DistanceUnit  `json:"rate_unit"`
+    RateEffectiveFrom time.Time     `json:"rate_effective_from"`
+}
+
+// PerDiemDetails are the inputs of a per-diem claim and the rate its amount
+// was computed with, frozen with the amount
+type PerDiemDetails struct {
+    Country            string        `json:"country"`
+    Start              time.Time     `json:"start"`
+    End                time.Time     `json:"end"`
+    FullDays           int           `json:"full_days"`
+    PartialDays        int           `json:"partial_days"`
+    BreakfastsProvided int           `json:"breakfasts_provided"`
+    LunchesProvided    int           `json:"lunches_provided"`
+    DinnersProvided    int           `json:"dinners_provided"`
+    RateID             uint          `json:"rate_id"`
+    DailyRate          money.Decimal `json:"daily_rate"`
+    PartialDayPercent  int           `json:"partial_day_percent"`
+    BreakfastPercent   int           `json:"breakfast_percent"`
+    LunchPercent       int           `json:"lunch_percent"`
+    DinnerPercent      int           `json:"dinner_percent"`
+    RateEffectiveFrom  time.Time     `json:"rate_effective_from"`
+    // Allowance is due for the days before Deductions for provided meals are taken off
+    Allowance          money.Decimal `json:"allowance"`
+    Deductions         money.Decimal `json:"deductions"`
+}
+
+// MileageRate is what the organization pays per unit of distance driven in a
+// vehicle type from a date on. A rate without a country applies wherever no
+// rate for the trip's country exists.
+type MileageRate struct {
+    ID             uint         `json:"id" gorm:"primaryKey"`
+    OrganizationID uint         `json:"organization_id" gorm:"not null;uniqueIndex:idx_mileage_rate"`
+    VehicleType    string       `json:"vehicle_type" gorm:"not null;uniqueIndex:idx_mileage_rate"`
+    Country        string       `json:"country" gorm:"size:2;not null;uniqueIndex:idx_mileage_rate"`
+    EffectiveFrom  time.Time    `json:"effective_from" gorm:"not null;uniqueIndex:idx_mileage_rate"`
+    Unit           DistanceUnit `json:"unit" gorm:"size:2;not null"`
+    // Rate is in Currency per Unit and may be finer than the currency's minor unit
+    Rate           string       `json:"rate" gorm:"not null"`
+    Currency       string       `json:"currency" gorm:"size:3;not null"`
+    CreatedAt      time.Time    `json:"created_at"`
+    UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// PerDiemRate is the daily allowance for travel to a country from a date on.
+// A rate without a country applies wherever no rate for the destination
+// exists. The days a trip starts and ends, and a trip within one day lasting
+// at least MinPartialDayHours, earn PartialDayPercent of the daily rate. Each
+// meal provided takes its percent of the full daily rate off the allowance.
+type PerDiemRate struct {
+    ID                 uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID     uint          `json:"organization_id" gorm:"not null;uniqueIndex:idx_per_diem_rate"`
+    Country            string        `json:"country" gorm:"size:2;not null;uniqueIndex:idx_per_diem_rate"`
+    EffectiveFrom      time.Time     `json:"effective_from" gorm:"not null;uniqueIndex:idx_per_diem_rate"`
+    DailyRate          money.Decimal `json:"daily_rate" gorm:"-"`
+    DailyRateMinor     int64         `json:"daily_rate_minor" gorm:"not null"`
+    Currency           string        `json:"currency" gorm:"size:3;not null"`
+    PartialDayPercent  int           `json:"partial_day_percent" gorm:"not null"`
+    MinPartialDayHours int           `json:"min_partial_day_hours" gorm:"not null"`
+    BreakfastPercent   int           `json:"breakfast_percent" gorm:"not null"`
+    LunchPercent       int           `json:"lunch_percent" gorm:"not null"`
+    DinnerPercent      int           `json:"dinner_percent" gorm:"not null"`
+    CreatedAt          time.Time     `json:"created_at"`
+    UpdatedAt          time.Time     `json:"updated_at"`
+}
+
+// AfterFind fills the decimal daily rate from the stored minor units
+func (r *PerDiemRate) AfterFind(tx *gorm.DB) error {
+    r.DailyRate = money.FromMinor(r.DailyRateMinor, r.Currency)
+    return nil
+}
+
+// AfterSave keeps the decimal daily rate in step with the stored minor units
+func (r *PerDiemRate) AfterSave(tx *gorm.DB) error {
+    r.DailyRate = money.FromMinor(r.DailyRateMinor, r.Currency)
+    return nil
+}
+
+// TaxRate is the value-added tax rate of a tax code in a country from a date on.
+// Codes are free-form, such as "standard", "reduced" or "zero", and are what
+// categories' tax codes refer to.
+type TaxRate struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_tax_rate"`
+    Country        string    `json:"country" gorm:"size:2;not null;uniqueIndex:idx_tax_rate"`
+    Code           string    `json:"code" gorm:"not null;uniqueIndex:idx_tax_rate"`
+    EffectiveFrom  time.Time `json:"effective_from" gorm:"not null;uniqueIndex:idx_tax_rate"`
+    // Rate is a percent of the net amount, such as "19" or "5.5"
+    Rate           string    `json:"rate" gorm:"not null"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// RecurringStatus is whether a recurring expense is still creating expenses
+type RecurringStatus string
+
+const (
+    RecurringActive RecurringStatus = "active"
+    // RecurringPaused skips the dates that pass while paused
+    RecurringPaused RecurringStatus = "paused"
+    // RecurringEnded is set once the schedule has no dates left
+    RecurringEnded RecurringStatus = "ended"
+)
+
+// RecurringExpense is a template the scheduler creates draft expenses from on
+// the dates of its recurrence rule, such as a monthly subscription. Rule is an
+// RFC 5545 RRULE whose first date is StartDate; EndDate, when set, is the
+// last day an expense may be dated.
+type RecurringExpense struct {
+    ID             uint            `json:"id" gorm:"primaryKey"`
+    OrganizationID uint            `json:"organization_id" gorm:"not null;index"`
+    UserID         uint            `json:"user_id" gorm:"not null;index"`
+    Description    string          `json:"description" gorm:"not null"`
+    Amount         money.Decimal   `json:"amount" gorm:"-"`
+    AmountMinor    int64           `json:"amount_minor" gorm:"not null"`
+    Currency       string          `json:"currency" gorm:"size:3;not null"`
+    Category       string          `json:"category"`
+    ClientNotes    string          `json:"client_notes" gorm:"type:text"`
+    ProjectID      *uint           `json:"project_id"`
+    CostCenterID   *uint           `json:"cost_center_id"`
+    Rule           string          `json:"rule" gorm:"not null"`
+    StartDate      time.Time       `json:"start_date" gorm:"not null"`
+    EndDate        *time.Time      `json:"end_date"`
+    Status         RecurringStatus `json:"status" gorm:"size:16;not null;default:'active';index"`
+    // NextOccurrence is the next date an expense is due; nil once the schedule has ended
+    NextOccurrence *time.Time      `json:"next_occurrence" gorm:"index"`
+    LastOccurrence *time.Time      `json:"last_occurrence"`
+    // Occurrences counts the dates expenses have been created for
+    Occurrences    int             `json:"occurrences" gorm:"not null;default:0"`
+    CreatedAt      time.Time       `json:"created_at"`
+    UpdatedAt      time.Time       `json:"updated_at"`
+    // DeletedAt keeps deleted templates' IDs, which their expenses' external IDs are made from, from being reused
+    DeletedAt      gorm.DeletedAt  `json:"-" gorm:"index"`
+}
+
+// AfterFind fills the decimal amount from the stored minor units
+func (r *RecurringExpense) AfterFind(tx *gorm.DB) error {
+    r.Amount = money.FromMinor(r.AmountMinor, r.Currency)
+    return nil
+}
+
+// AfterSave keeps the decimal amount in step with the stored minor units
+func (r *RecurringExpense) AfterSave(tx *gorm.DB) error {
+    r.Amount = money.FromMinor(r.AmountMinor, r.Currency)
+    return nil
+}
+
+// UpcomingOccurrence is a date a recurring expense will create an expense on
+type UpcomingOccurrence struct {
+    RecurringExpenseID uint          `json:"recurring_expense_id"`
+    UserID             uint          `json:"user_id"`
+    Date               time.Time     `json:"date"`
+    Description        string        `json:"description"`
+    Amount             money.Decimal `json:"amount"`
+    AmountMinor        int64         `json:"amount_minor"`
+    Currency           string        `json:"currency"`
+    Category           string        `json:"category"`
+}
+
+// RecurringSuggestion proposes a recurring expense for a charge a user has
+// entered repeatedly on a regular schedule. Its fields can be posted back
+// to create the template.
+type RecurringSuggestion struct {
+    UserID       uint          `json:"user_id"`
+    Description  string        `json:"description"`
+    // Amount is the latest charge's; AmountVaries tells whether earlier ones differed
+    Amount       money.Decimal `json:"amount"`
+    AmountMinor  int64         `json:"amount_minor"`
+    AmountVaries bool          `json:"amount_varies"`
+    Currency     string        `json:"currency"`
+    Category     string        `json:"category"`
+    Rule         string        `json:"rule"`
+    // StartDate is the schedule's first date after the latest charge
+    StartDate    string        `json:"start_date"`
+    LastDate     time.Time     `json:"last_date"`
+    Confidence   float64       `json:"confidence"`
+    ExpenseIDs   []uint        `json:"expense_ids"`
+}
+
+// ExpenseReport bundles a user's expenses, such as those of a trip, into one
+// claim that is submitted and reviewed as a whole. It moves through the same
+// workflow as an expense and its expenses move with it, so they are locked
+// while it is under review or past approval. PeriodStart and PeriodEnd, when
+// set, bound the dates of its expenses.
+type ExpenseReport struct {
+    ID                uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID    uint          `json:"organization_id" gorm:"not null;index"`
+    UserID            uint          `json:"user_id" gorm:"not null;index"`
+    Title             string        `json:"title" gorm:"not null"`
+    Purpose           string        `json:"purpose" gorm:"type:text"`
+    PeriodStart       *time.Time    `json:"period_start"`
+    PeriodEnd         *time.Time    `json:"period_end"`
+    Status            ExpenseStatus `json:"status" gorm:"size:16;not null;default:'draft';index"`
+    ApprovalCount     int           `json:"approval_count" gorm:"not null;default:0"`
+    RequiredApprovals int           `json:"required_approvals" gorm:"not null;default:0"`
+    SubmittedAt       *time.Time    `json:"submitted_at"`
+    CreatedAt         time.Time     `json:"created_at"`
+    UpdatedAt         time.Time     `json:"updated_at"`
+    Expenses          []Expense     `json:"expenses" gorm:"foreignKey:ReportID"`
+    // Totals are per currency the expenses were paid in, CategoryTotals per
+    // category and currency; BaseTotal is in the organization's base currency
+    Totals            []ExpenseSummaryGroup `json:"totals" gorm:"-"`
+    CategoryTotals    []ExpenseSummaryGroup `json:"category_totals" gorm:"-"`
+    BaseTotal         money.Decimal         `json:"base_total" gorm:"-"`
+    BaseTotalMinor    int64                 `json:"base_total_minor" gorm:"-"`
+    BaseCurrency      string                `json:"base_currency" gorm:"-"`
+    // PolicyViolations is filled only when a blocking rule stops a submission
+    PolicyViolations  []PolicyViolation     `json:"policy_violations,omitempty" gorm:"-"`
+}
+
+// Editable reports whether expenses may still be added to or removed from the report
+func (r ExpenseReport) Editable() bool {
+    return r.Status == "" || r.Status == StatusDraft || r.Status == StatusRejected
+}
+
+// Covers reports whether an expense dated date falls within the report's period
+func (r ExpenseReport) Covers(date time.Time) bool {
+    day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+    return (r.PeriodStart == nil || !day.Before(*r.PeriodStart)) && (r.PeriodEnd == nil || !day.After(*r.PeriodEnd))
 }
 
-// Attachment represents a file attachment for an expense
+// ExpenseReportTransition records a workflow step taken on an expense report.
+// Each of the report's expenses gets a matching ExpenseTransition.
+type ExpenseReportTransition struct {
+    ID             uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID uint          `json:"organization_id" gorm:"index"`
+    ReportID       uint          `json:"report_id" gorm:"not null;index"`
+    Action         ExpenseAction `json:"action" gorm:"not null"`
+    FromStatus     ExpenseStatus `json:"from_status" gorm:"not null"`
+    ToStatus       ExpenseStatus `json:"to_status" gorm:"not null"`
+    ActorID        uint          `json:"actor_id" gorm:"not null"`
+    ApprovalLevel  int           `json:"approval_level"`
+    Comment        string        `json:"comment" gorm:"type:text"`
+    CreatedAt      time.Time     `json:"created_at"`
+}
+
+// BankAccount holds the bank details a member is reimbursed to or, without a
+// UserID, those of the account the organization pays from. The details are
+// stored encrypted; only the last digits of the account number are kept in
+// the clear so accounts can be told apart without the key.
+type BankAccount struct {
+    ID               uint        `json:"id" gorm:"primaryKey"`
+    OrganizationID   uint        `json:"organization_id" gorm:"not null;uniqueIndex:idx_bank_account_owner"`
+    UserID           *uint       `json:"user_id" gorm:"uniqueIndex:idx_bank_account_owner"`
+    AccountLast4     string      `json:"account_last4" gorm:"size:4"`
+    EncryptedDetails string      `json:"-" gorm:"type:text;not null"`
+    // Details are served with the account numbers masked
+    Details          BankDetails `json:"details" gorm:"-"`
+    CreatedAt        time.Time   `json:"created_at"`
+    UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// BankDetails are what a transfer to or from a bank account needs. SEPA
+// transfers use IBAN and BIC; ACH transfers use RoutingNumber, AccountNumber
+// and AccountType.
+type BankDetails struct {
+    HolderName    string `json:"holder_name"`
+    IBAN          string `json:"iban,omitempty"`
+    BIC           string `json:"bic,omitempty"`
+    RoutingNumber string `json:"routing_number,omitempty"`
+    AccountNumber string `json:"account_number,omitempty"`
+    // AccountType is checking or savings
+    AccountType   string `json:"account_type,omitempty"`
+    // CompanyID identifies the organization to its bank in ACH files; organization accounts only
+    CompanyID     string `json:"company_id,omitempty"`
+}
+
+// BatchStatus is how far a reimbursement batch has got in being paid out
+type BatchStatus string
+
+const (
+    // BatchDraft batches can be exported and cancelled
+    BatchDraft BatchStatus = "draft"
+    // BatchPaid batches have been sent to the bank
+    BatchPaid BatchStatus = "paid"
+    // BatchReconciled batches were found on the bank statement; their expenses are reimbursed
+    BatchReconciled BatchStatus = "reconciled"
+)
+
+// ReimbursementBatch pays approved, reimbursable expenses back to their
+// owners, with one payment per employee in the organization's base currency.
+// Its expenses cannot join another batch unless it is cancelled.
+type ReimbursementBatch struct {
+    ID             uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID uint          `json:"organization_id" gorm:"not null;index"`
+    // Reference identifies the batch's payment file to the bank
+    Reference      string        `json:"reference" gorm:"size:35;not null"`
+    Status         BatchStatus   `json:"status" gorm:"size:16;not null;default:'draft';index"`
+    Currency       string        `json:"currency" gorm:"size:3;not null"`
+    Total          money.Decimal `json:"total" gorm:"-"`
+    TotalMinor     int64         `json:"total_minor" gorm:"not null"`
+    // ExecutionDate is the day the bank is asked to make the transfers
+    ExecutionDate  time.Time     `json:"execution_date" gorm:"not null"`
+    CreatedByID    uint          `json:"created_by_id" gorm:"not null"`
+    PaidAt         *time.Time    `json:"paid_at"`
+    ReconciledAt   *time.Time    `json:"reconciled_at"`
+    CreatedAt      time.Time     `json:"created_at"`
+    UpdatedAt      time.Time     `json:"updated_at"`
+    Payments       []ReimbursementPayment `json:"payments,omitempty" gorm:"foreignKey:BatchID"`
+    // MissingBankDetails lists the owners of expenses due who were left out
+    // of a new batch because they have no bank account on file
+    MissingBankDetails []uint    `json:"missing_bank_details,omitempty" gorm:"-"`
+}
+
+// AfterFind fills the decimal total from the stored minor units
+func (b *ReimbursementBatch) AfterFind(tx *gorm.DB) error {
+    b.Total = money.FromMinor(b.TotalMinor, b.Currency)
+    return nil
+}
+
+// AfterSave keeps the decimal total in step with the stored minor units
+func (b *ReimbursementBatch) AfterSave(tx *gorm.DB) error {
+    b.Total = money.FromMinor(b.TotalMinor, b.Currency)
+    return nil
+}
+
+// ReimbursementPayment is the transfer to one employee in a reimbursement
+// batch. It keeps the employee's bank details as they were when the batch was
+// created, so later changes to the account do not alter a batch in flight.
+type ReimbursementPayment struct {
+    ID               uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID   uint          `json:"organization_id" gorm:"not null;index"`
+    BatchID          uint          `json:"batch_id" gorm:"not null;index"`
+    UserID           uint          `json:"user_id" gorm:"not null;index"`
+    Amount           money.Decimal `json:"amount" gorm:"-"`
+    AmountMinor      int64         `json:"amount_minor" gorm:"not null"`
+    Currency         string        `json:"currency" gorm:"size:3;not null"`
+    // EndToEndID identifies the transfer on the bank statements of both sides
+    EndToEndID       string        `json:"end_to_end_id" gorm:"size:35;not null"`
+    AccountLast4     string        `json:"account_last4" gorm:"size:4"`
+    EncryptedDetails string        `json:"-" gorm:"type:text;not null"`
+    ExpenseIDs       []uint        `json:"expense_ids" gorm:"-"`
+    CreatedAt        time.Time     `json:"created_at"`
+}
+
+// AfterFind fills the decimal amount from the stored minor units
+func (p *ReimbursementPayment) AfterFind(tx *gorm.DB) error {
+    p.Amount = money.FromMinor(p.AmountMinor, p.Currency)
+    return nil
+}
+
+// AfterSave keeps the decimal amount in step with the stored minor units
+func (p *ReimbursementPayment) AfterSave(tx *gorm.DB) error {
+    p.Amount = money.FromMinor(p.AmountMinor, p.Currency)
+    return nil
+}
+
+// DueReimbursement is what one employee is owed for approved, reimbursable
+// expenses that are not yet in a reimbursement batch
+type DueReimbursement struct {
+    UserID         uint          `json:"user_id"`
+    Amount         money.Decimal `json:"amount"`
+    AmountMinor    int64         `json:"amount_minor"`
+    Currency       string        `json:"currency"`
+    ExpenseCount   int           `json:"expense_count"`
+    HasBankAccount bool          `json:"has_bank_account"`
+}
+
+// ExpenseAllocation is the part of an expense booked to one category,
+// project, cost center or person. A line leaves a dimension empty to take the
+// expense's. The lines of an expense add up to its amount and base amount.
+type ExpenseAllocation struct {
+    ID              uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID  uint          `json:"organization_id" gorm:"index"`
+    ExpenseID       uint          `json:"expense_id" gorm:"not null;index"`
+    // Position orders the lines as they were given
+    Position        int           `json:"position" gorm:"not null"`
+    Description     string        `json:"description"`
+    Category        string        `json:"category" gorm:"index"`
+    ProjectID       *uint         `json:"project_id" gorm:"index"`
+    CostCenterID    *uint         `json:"cost_center_id" gorm:"index"`
+    // UserID is the member the line is charged to
+    UserID          *uint         `json:"user_id" gorm:"index"`
+    // Percent is the share of the expense when the line was given as a percentage, so it follows amount changes
+    Percent         money.Decimal `json:"percent,omitempty"`
+    Amount          money.Decimal `json:"amount" gorm:"-"`
+    AmountMinor     int64         `json:"amount_minor" gorm:"not null"`
+    Currency        string        `json:"currency" gorm:"size:3"`
+    BaseAmount      money.Decimal `json:"base_amount" gorm:"-"`
+    BaseAmountMinor int64         `json:"base_amount_minor" gorm:"not null"`
+    BaseCurrency    string        `json:"base_currency" gorm:"size:3"`
+    Project         *Project      `json:"project,omitempty" gorm:"foreignKey:ProjectID"`
+    CostCenter      *CostCenter   `json:"cost_center,omitempty" gorm:"foreignKey:CostCenterID"`
+    CreatedAt       time.Time     `json:"created_at"`
+}
+
+// AfterFind fills the decimal amounts from the stored minor units
+func (a *ExpenseAllocation) AfterFind(tx *gorm.DB) error {
+    a.fillAmounts()
+    return nil
+}
+
+// AfterSave keeps the decimal amounts in step with the stored minor units
+func (a *ExpenseAllocation) AfterSave(tx *gorm.DB) error {
+    a.fillAmounts()
+    return nil
+}
+
+func (a *ExpenseAllocation) fillAmounts() {
+    a.Amount = money.FromMinor(a.AmountMinor, a.Currency)
+    a.BaseAmount = money.FromMinor(a.BaseAmountMinor, a.BaseCurrency)
+}
+
+// PossibleDuplicate is an existing expense that resembles the one being saved
+type PossibleDuplicate struct {
+    // PairID identifies the suspected pair to dismiss; zero when the expense was not saved
+    PairID         uint    `json:"pair_id,omitempty"`
+    Expense        Expense `json:"expense"`
+    Score          float64 `json:"score"`
+    AmountScore    float64 `json:"amount_score"`
+    DateScore      float64 `json:"date_score"`
+    TextScore      float64 `json:"text_score"`
+    SameAttachment bool    `json:"same_attachment"`
+}
+
+// PolicyBlockedResponse is returned when an expense breaks a blocking policy rule
+type PolicyBlockedResponse struct {
+    Detail     string            `json:"detail"`
+    Violations []PolicyViolation `json:"violations"`
+}
+
+// DuplicateBlockedResponse is returned when the organization blocks an expense that looks like a duplicate
+type DuplicateBlockedResponse struct {
+    Detail     string              `json:"detail"`
+    Duplicates []PossibleDuplicate `json:"duplicates"`
+}
+
+// AfterFind fills the decimal amounts from the stored minor units
+func (e *Expense) AfterFind(tx *gorm.DB) error {
+    e.fillAmounts()
+    return nil
+}
+
+// AfterSave keeps the decimal amounts in step with the stored minor units
+func (e *Expense) AfterSave(tx *gorm.DB) error {
+    e.fillAmounts()
+    return nil
+}
+
+func (e *Expense) fillAmounts() {
+    e.Amount = money.FromMinor(e.AmountMinor, e.Currency)
+    e.BaseAmount = money.FromMinor(e.BaseAmountMinor, e.BaseCurrency)
+    e.TaxAmount = money.FromMinor(e.TaxAmountMinor, e.Currency)
+    e.NetAmountMinor = e.AmountMinor - e.TaxAmountMinor
+    e.NetAmount = money.FromMinor(e.NetAmountMinor, e.Currency)
+}
+
+// Editable reports whether the expense may still be changed by its owner.
+// Expenses under review or past approval are locked.
+func (e Expense) Editable() bool {
+    return e.Status == "" || e.Status == StatusDraft || e.Status == StatusRejected
+}
+
+// ExpenseTransition records a workflow step taken on an expense
+type ExpenseTransition struct {
+    ID             uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID uint          `json:"organization_id" gorm:"index"`
+    ExpenseID      uint          `json:"expense_id" gorm:"not null;index"`
+    Action         ExpenseAction `json:"action" gorm:"not null"`
+    FromStatus     ExpenseStatus `json:"from_status" gorm:"not null"`
+    ToStatus       ExpenseStatus `json:"to_status" gorm:"not null"`
+    ActorID        uint          `json:"actor_id" gorm:"not null"`
+    ApprovalLevel  int           `json:"approval_level"`
+    Comment        string        `json:"comment" gorm:"type:text"`
+    // ReportID is set when the step was taken on the expense's report as a whole
+    ReportID       *uint         `json:"report_id,omitempty" gorm:"index"`
+    CreatedAt      time.Time     `json:"created_at"`
+}
+
+// ApprovalRule requires a number of distinct approvals for expenses above an amount.
+// Thresholds are in the organization's base currency.
+type ApprovalRule struct {
+    ID                uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID    uint          `json:"organization_id" gorm:"index"`
+    MinAmount         money.Decimal `json:"min_amount" gorm:"-"`
+    MinAmountMinor    int64         `json:"min_amount_minor" gorm:"not null;default:0"`
+    Currency          string        `json:"currency" gorm:"size:3"`
+    RequiredApprovals int           `json:"required_approvals" gorm:"not null"`
+    CreatedAt         time.Time     `json:"created_at"`
+}
+
+// AfterFind fills the decimal threshold from the stored minor units
+func (r *ApprovalRule) AfterFind(tx *gorm.DB) error {
+    r.MinAmount = money.FromMinor(r.MinAmountMinor, r.Currency)
+    return nil
+}
+
+// AfterSave keeps the decimal threshold in step with the stored minor units
+func (r *ApprovalRule) AfterSave(tx *gorm.DB) error {
+    r.MinAmount = money.FromMinor(r.MinAmountMinor, r.Currency)
+    return nil
+}
+
+// ExchangeRate is the value of one unit of BaseCurrency in QuoteCurrency on a date.
+// Rates are kept as decimal text so conversions stay exact.
+type ExchangeRate struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_exchange_rate"`
+    Date           time.Time `json:"date" gorm:"uniqueIndex:idx_exchange_rate"`
+    BaseCurrency   string    `json:"base_currency" gorm:"size:3;not null;uniqueIndex:idx_exchange_rate"`
+    QuoteCurrency  string    `json:"quote_currency" gorm:"size:3;not null;uniqueIndex:idx_exchange_rate"`
+    Rate           string    `json:"rate" gorm:"not null"`
+    Source         string    `json:"source"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Attachment represents a file attachment for an expense. Receipts uploaded
+// on their own have no expense (ExpenseID is zero) until they are matched to one.
 type Attachment struct {
     ID          uint      `json:"id" gorm:"primaryKey"`
-    ExpenseID   uint      `json:"expense_id" gorm:"not null"`
+    OrganizationID uint   `json:"organization_id" gorm:"index"`
+    ExpenseID   uint      `json:"expense_id" gorm:"not null;index"`
+    // UserID is the uploader; it owns the receipt while it is unmatched
+    UserID      uint      `json:"user_id" gorm:"index"`
     Filename    string    `json:"filename" gorm:"not null"`
     FilePath    string    `json:"file_path" gorm:"not null"`
     ContentType string    `json:"content_type"`
     FileSize    int64     `json:"file_size"`
     UploadedAt  time.Time `json:"uploaded_at"`
     StorageType string    `json:"storage_type" gorm:"default:'local'"`
+    // ContentHash is the SHA-256 of the file, used to spot the same receipt on two expenses
+    ContentHash string    `json:"content_hash,omitempty" gorm:"size:64;index"`
+    // Receipt details used to match an unmatched receipt to a transaction
+    Merchant           string        `json:"merchant,omitempty"`
+    ReceiptAmount      money.Decimal `json:"receipt_amount,omitempty" gorm:"-"`
+    ReceiptAmountMinor int64         `json:"receipt_amount_minor,omitempty" gorm:"not null;default:0"`
+    ReceiptCurrency    string        `json:"receipt_currency,omitempty" gorm:"size:3"`
+    ReceiptDate        *time.Time    `json:"receipt_date,omitempty"`
+    DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// AfterFind fills the decimal receipt amount from the stored minor units
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+    a.fillAmounts()
+    return nil
+}
+
+// AfterSave keeps the decimal receipt amount in step with the stored minor units
+func (a *Attachment) AfterSave(tx *gorm.DB) error {
+    a.fillAmounts()
+    return nil
+}
+
+func (a *Attachment) fillAmounts() {
+    if a.ReceiptAmountMinor != 0 {
+        a.ReceiptAmount = money.FromMinor(a.ReceiptAmountMinor, a.ReceiptCurrency)
+    }
+}
+
+// ReceiptMatchStatus is where a suggested receipt and expense pairing stands
+type ReceiptMatchStatus string
+
+const (
+    // MatchPending awaits review
+    MatchPending   ReceiptMatchStatus = "pending"
+    // MatchAuto was linked automatically with high confidence
+    MatchAuto      ReceiptMatchStatus = "auto"
+    MatchConfirmed ReceiptMatchStatus = "confirmed"
+    // MatchRejected pairs are never suggested again
+    MatchRejected  ReceiptMatchStatus = "rejected"
+)
+
+// ReceiptMatch is a scored pairing of an unmatched receipt with an expense
+// lacking one. Scores run from 0 to 1.
+type ReceiptMatch struct {
+    ID             uint               `json:"id" gorm:"primaryKey"`
+    OrganizationID uint               `json:"organization_id" gorm:"index"`
+    AttachmentID   uint               `json:"attachment_id" gorm:"not null;uniqueIndex:idx_receipt_match_pair"`
+    ExpenseID      uint               `json:"expense_id" gorm:"not null;uniqueIndex:idx_receipt_match_pair;index"`
+    Score          float64            `json:"score"`
+    AmountScore    float64            `json:"amount_score"`
+    DateScore      float64            `json:"date_score"`
+    TextScore      float64            `json:"text_score"`
+    Status         ReceiptMatchStatus `json:"status" gorm:"not null;index"`
+    ResolvedByID   uint               `json:"resolved_by_id,omitempty"`
+    ResolvedAt     *time.Time         `json:"resolved_at,omitempty"`
+    CreatedAt      time.Time          `json:"created_at"`
+    Attachment     *Attachment        `json:"attachment,omitempty" gorm:"foreignKey:AttachmentID"`
+    Expense        *Expense           `json:"expense,omitempty" gorm:"foreignKey:ExpenseID"`
+}
+
+// DuplicateStatus is where a suspected duplicate pair stands
+type DuplicateStatus string
+
+const (
+    DuplicateSuspected DuplicateStatus = "suspected"
+    // DuplicateDismissed pairs were judged distinct and are not flagged again
+    DuplicateDismissed DuplicateStatus = "dismissed"
+)
+
+// DuplicatePair records two expenses of the same user that look like one
+// purchase entered twice. ExpenseID is the later expense and DuplicateOfID
+// the earlier one. Scores run from 0 to 1.
+type DuplicatePair struct {
+    ID             uint            `json:"id" gorm:"primaryKey"`
+    OrganizationID uint            `json:"organization_id" gorm:"index"`
+    ExpenseID      uint            `json:"expense_id" gorm:"not null;uniqueIndex:idx_duplicate_pair"`
+    DuplicateOfID  uint            `json:"duplicate_of_id" gorm:"not null;uniqueIndex:idx_duplicate_pair;index"`
+    Score          float64         `json:"score"`
+    AmountScore    float64         `json:"amount_score"`
+    DateScore      float64         `json:"date_score"`
+    TextScore      float64         `json:"text_score"`
+    // SameAttachment is set when both expenses carry the same file
+    SameAttachment bool            `json:"same_attachment"`
+    Status         DuplicateStatus `json:"status" gorm:"not null;index"`
+    DismissedByID  uint            `json:"dismissed_by_id,omitempty"`
+    DismissedAt    *time.Time      `json:"dismissed_at,omitempty"`
+    CreatedAt      time.Time       `json:"created_at"`
+    UpdatedAt      time.Time       `json:"updated_at"`
+    Expense        *Expense        `json:"expense,omitempty" gorm:"foreignKey:ExpenseID"`
+    DuplicateOf    *Expense        `json:"duplicate_of,omitempty" gorm:"foreignKey:DuplicateOfID"`
+}
+
+// ProjectStatus tells whether a project takes new expenses
+type ProjectStatus string
+
+const (
+    ProjectActive ProjectStatus = "active"
+    // ProjectClosed keeps the project's expenses but refuses new ones
+    ProjectClosed ProjectStatus = "closed"
+)
+
+// Project is a piece of work, typically for a client, that expenses are billed back to.
+// The budget is in the organization's base currency; zero means no budget.
+type Project struct {
+    ID             uint          `json:"id" gorm:"primaryKey"`
+    OrganizationID uint          `json:"organization_id" gorm:"not null;uniqueIndex:idx_project_name"`
+    Name           string        `json:"name" gorm:"not null;uniqueIndex:idx_project_name"`
+    Code           string        `json:"code"`
+    Client         string        `json:"client"`
+    Status         ProjectStatus `json:"status" gorm:"size:16;not null;default:'active'"`
+    Budget         money.Decimal `json:"budget" gorm:"-"`
+    BudgetMinor    int64         `json:"budget_minor" gorm:"not null;default:0"`
+    Currency       string        `json:"currency" gorm:"size:3"`
+    // Spent totals the base amounts of the project's live expenses; it is filled when projects are read
+    Spent          money.Decimal `json:"spent,omitempty" gorm:"-"`
+    CreatedAt      time.Time     `json:"created_at"`
+    UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// AfterFind fills the decimal budget from the stored minor units
+func (p *Project) AfterFind(tx *gorm.DB) error {
+    p.Budget = money.FromMinor(p.BudgetMinor, p.Currency)
+    return nil
+}
+
+// AfterSave keeps the decimal budget in step with the stored minor units
+func (p *Project) AfterSave(tx *gorm.DB) error {
+    p.Budget = money.FromMinor(p.BudgetMinor, p.Currency)
+    return nil
+}
+
+// CostCenter is an organizational unit expenses are allocated to. Archived
+// cost centers stay on their expenses but cannot be chosen again.
+type CostCenter struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_cost_center_code"`
+    Code           string    `json:"code" gorm:"not null;uniqueIndex:idx_cost_center_code"`
+    Name           string    `json:"name" gorm:"not null"`
+    Archived       bool      `json:"archived" gorm:"not null"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Tag is a free-form label on expenses. Tags are created the first time an
+// expense uses them.
+type Tag struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_tag_name"`
+    Name           string    `json:"name" gorm:"not null;uniqueIndex:idx_tag_name"`
+    CreatedAt      time.Time `json:"created_at"`
+}
+
+// Merchant is a vendor the organization's expenses are bought from. Key is the
+// normalized name; descriptions mentioning it or one of the aliases, such as
+// "UBER *TRIP" or "Uber BV" for Uber, are normalized to the merchant.
+type Merchant struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_merchant_key"`
+    Name           string    `json:"name" gorm:"not null"`
+    Key            string    `json:"key" gorm:"not null;uniqueIndex:idx_merchant_key"`
+    // DefaultCategory is given to the merchant's expenses when they name none
+    DefaultCategory string   `json:"default_category"`
+    // TaxID is the merchant's VAT registration, copied to its expenses
+    TaxID          string    `json:"tax_id" gorm:"size:32"`
+    Aliases        []MerchantAlias `json:"aliases,omitempty" gorm:"foreignKey:MerchantID"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// MerchantAlias is another name a merchant appears under. Keys are unique
+// across the organization's merchant names and aliases.
+type MerchantAlias struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_merchant_alias_key"`
+    MerchantID     uint      `json:"merchant_id" gorm:"not null;index"`
+    Alias          string    `json:"alias" gorm:"not null"`
+    Key            string    `json:"key" gorm:"not null;uniqueIndex:idx_merchant_alias_key"`
+    CreatedAt      time.Time `json:"created_at"`
+}
+
+// Category is one of an organization's expense categories. Categories nest
+// under a parent; expenses refer to them by name. Archived categories stay on
+// the expenses that use them but cannot be chosen again.
+type Category struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_category_name"`
+    ParentID       *uint     `json:"parent_id" gorm:"index"`
+    Name           string    `json:"name" gorm:"not null;uniqueIndex:idx_category_name"`
+    // GLCode is the general ledger account expenses in the category post to
+    GLCode         string    `json:"gl_code"`
+    TaxCode        string    `json:"tax_code"`
+    // ReceiptRequiredAbove replaces the organization's receipt threshold for the
+    // category; zero requires a receipt for every expense, nil keeps the organization's
+    ReceiptRequiredAbove      *money.Decimal `json:"receipt_required_above" gorm:"-"`
+    ReceiptRequiredAboveMinor *int64         `json:"receipt_required_above_minor"`
+    Currency       string    `json:"currency" gorm:"size:3"`
+    Archived       bool      `json:"archived" gorm:"not null"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AfterFind fills the decimal receipt threshold from the stored minor units
+func (c *Category) AfterFind(tx *gorm.DB) error {
+    c.fillReceiptThreshold()
+    return nil
+}
+
+// AfterSave keeps the decimal receipt threshold in step with the stored minor units
+func (c *Category) AfterSave(tx *gorm.DB) error {
+    c.fillReceiptThreshold()
+    return nil
+}
+
+func (c *Category) fillReceiptThreshold() {
+    c.ReceiptRequiredAbove = nil
+    if c.ReceiptRequiredAboveMinor != nil {
+        threshold := money.FromMinor(*c.ReceiptRequiredAboveMinor, c.Currency)
+        c.ReceiptRequiredAbove = &threshold
+    }
+}
+
+// PolicySeverity is what a policy rule does to an expense that breaks it
+type PolicySeverity string
+
+const (
+    // PolicyWarn records the violation on the expense
+    PolicyWarn  PolicySeverity = "warn"
+    // PolicyBlock rejects the create, update or submission
+    PolicyBlock PolicySeverity = "block"
+)
+
+// PolicyRule is an organization's expense rule, such as a per-person meal limit.
+// Condition is a policy language expression that holds when the rule is broken;
+// a rule with a Category applies only to expenses in that category.
+type PolicyRule struct {
+    ID             uint            `json:"id" gorm:"primaryKey"`
+    OrganizationID uint            `json:"organization_id" gorm:"index"`
+    Name           string          `json:"name" gorm:"not null"`
+    // Message explains the violation to the submitter; the name is used when empty
+    Message        string          `json:"message"`
+    Category       string          `json:"category" gorm:"index"`
+    Severity       PolicySeverity  `json:"severity" gorm:"not null;default:'warn'"`
+    Condition      json.RawMessage `json:"condition" gorm:"type:text;not null"`
+    Active         bool            `json:"active" gorm:"not null"`
+    CreatedAt      time.Time       `json:"created_at"`
+    UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// PolicyViolation is a policy rule broken by an expense when it was last
+// created, updated or submitted
+type PolicyViolation struct {
+    ID             uint           `json:"id" gorm:"primaryKey"`
+    OrganizationID uint           `json:"organization_id" gorm:"index"`
+    ExpenseID      uint           `json:"expense_id" gorm:"not null;index"`
+    // RuleID is zero for rules evaluated in a dry run without being saved
+    RuleID         uint           `json:"rule_id"`
+    RuleName       string         `json:"rule_name"`
+    Severity       PolicySeverity `json:"severity"`
+    Message        string         `json:"message"`
+    CreatedAt      time.Time      `json:"created_at"`
 }
 
 // AISuggestion represents AI-generated suggestions for an expense
 type AISuggestion struct {
     ID                uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID    uint      `json:"organization_id" gorm:"index"`
     ExpenseID         uint      `json:"expense_id" gorm:"not null"`
+    // AllocationID is set when the suggestion is for one line of a split expense
+    AllocationID      *uint     `json:"allocation_id,omitempty" gorm:"index"`
     SuggestedCategory string    `json:"suggested_category"`
     SuggestedNotes    string    `json:"suggested_notes" gorm:"type:text"`
     WasAccepted       bool      `json:"was_accepted" gorm:"default:false"`
@@ -44,35 +1058,331 @@ type AISuggestion struct {
     ModelUsed         string    `json:"model_used" gorm:"default:'gpt-3.5-turbo'"`
 }
 
+// AuditEvent is an append-only record of one change. Events form a hash chain per
+// organization: each hash covers the event and the previous event's hash.
+type AuditEvent struct {
+    ID             uint      `json:"id" gorm:"primaryKey"`
+    OrganizationID uint      `json:"organization_id" gorm:"not null;uniqueIndex:idx_audit_org_seq"`
+    Seq            int64     `json:"seq" gorm:"not null;uniqueIndex:idx_audit_org_seq"`
+    ActorID        uint      `json:"actor_id" gorm:"index"` // zero for background jobs
+    Action         string    `json:"action" gorm:"not null;index"`
+    EntityType     string    `json:"entity_type" gorm:"not null;index:idx_audit_entity"`
+    EntityID       uint      `json:"entity_id" gorm:"index:idx_audit_entity"`
+    ExpenseID      *uint     `json:"expense_id,omitempty" gorm:"index"`
+    Before         RawJSON   `json:"before" gorm:"type:text"`
+    After          RawJSON   `json:"after" gorm:"type:text"`
+    RequestID      string    `json:"request_id"`
+    IP             string    `json:"ip"`
+    CreatedAt      time.Time `json:"created_at" gorm:"index"`
+    PrevHash       string    `json:"prev_hash" gorm:"size:64"`
+    Hash           string    `json:"hash" gorm:"size:64;not null"`
+}
+
+// ErrAuditImmutable is returned for attempts to change recorded audit events
+var ErrAuditImmutable = errors.New("audit events are immutable")
+
+// BeforeUpdate keeps audit events append-only
+func (AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+    return ErrAuditImmutable
+}
+
+// BeforeDelete keeps audit events append-only
+func (AuditEvent) BeforeDelete(tx *gorm.DB) error {
+    return ErrAuditImmutable
+}
+
+// IdempotencyRecord remembers the response to a POST made with an Idempotency-Key
+// so that retries get the same response instead of repeating the request. Keys
+// are scoped to the user and organization that sent them.
+type IdempotencyRecord struct {
+    ID             uint       `json:"id" gorm:"primaryKey"`
+    UserID         uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_key"`
+    OrganizationID uint       `json:"organization_id" gorm:"not null;uniqueIndex:idx_idempotency_key"`
+    Key            string     `json:"key" gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_key"`
+    // Fingerprint hashes the method, path and body that first used the key
+    Fingerprint    string     `json:"fingerprint" gorm:"size:64;not null"`
+    // StatusCode is zero while the first request is still being handled
+    StatusCode     int        `json:"status_code"`
+    Header         string     `json:"-" gorm:"type:text"`
+    Body           []byte     `json:"-"`
+    CreatedAt      time.Time  `json:"created_at"`
+    ExpiresAt      time.Time  `json:"expires_at" gorm:"index"`
+}
+
+// RawJSON is JSON stored as text and embedded verbatim in responses
+type RawJSON string
+
+// MarshalJSON writes the stored JSON as is, or null when empty
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+    if j == "" {
+        return []byte("null"), nil
+    }
+    return []byte(j), nil
+}
+
+func (Expense) TenantOwned()                 {}
+func (Attachment) TenantOwned()              {}
+func (AISuggestion) TenantOwned()            {}
+func (ExpenseTransition) TenantOwned()       {}
+func (ApprovalRule) TenantOwned()            {}
+func (ExchangeRate) TenantOwned()            {}
+func (AuditEvent) TenantOwned()              {}
+func (ReceiptMatch) TenantOwned()            {}
+func (DuplicatePair) TenantOwned()           {}
+func (PolicyRule) TenantOwned()              {}
+func (PolicyViolation) TenantOwned()         {}
+func (Category) TenantOwned()                {}
+func (Project) TenantOwned()                 {}
+func (CostCenter) TenantOwned()              {}
+func (Tag) TenantOwned()                     {}
+func (Merchant) TenantOwned()                {}
+func (MerchantAlias) TenantOwned()           {}
+func (ExpenseAllocation) TenantOwned()       {}
+func (MileageRate) TenantOwned()             {}
+func (PerDiemRate) TenantOwned()             {}
+func (TaxRate) TenantOwned()                 {}
+func (RecurringExpense) TenantOwned()        {}
+func (ExpenseReport) TenantOwned()           {}
+func (ExpenseReportTransition) TenantOwned() {}
+func (BankAccount) TenantOwned()             {}
+func (ReimbursementBatch) TenantOwned()      {}
+func (ReimbursementPayment) TenantOwned()    {}
+
 // CreateExpenseRequest represents the request payload for creating an expense
 type CreateExpenseRequest struct {
-    Description         string    `json:"description" binding:"required"`
-    Amount              float64   `json:"amount" binding:"required,gt=0"`
+    Description         string        `json:"description" binding:"required"`
+    // Amount is required for standard expenses and must be left out of computed ones
+    Amount              money.Decimal `json:"amount"`
+    // Type defaults to standard; mileage and per_diem take Mileage or PerDiem instead of an amount
+    Type                ExpenseType     `json:"type"`
+    Mileage             *MileageRequest `json:"mileage"`
+    PerDiem             *PerDiemRequest `json:"per_diem"`
+    Currency            string        `json:"currency"`
     Date                time.Time `json:"date"`
     Category            string    `json:"category"`
     ClientNotes         string    `json:"client_notes"`
+    ProjectID           *uint     `json:"project_id"`
+    CostCenterID        *uint     `json:"cost_center_id"`
+    // MerchantID, else the Merchant named, which is created if unknown, is the
+    // vendor. Without either the description is matched to a known merchant,
+    // whose default category and tax ID fill in those left out.
+    MerchantID          *uint     `json:"merchant_id"`
+    Merchant            string    `json:"merchant"`
+    // Tags are names; unknown ones are created
+    Tags                []string  `json:"tags"`
+    // Attendees defaults to 1
+    Attendees           int       `json:"attendees"`
+    // Allocations split the expense; see AllocationRequest
+    Allocations         []AllocationRequest `json:"allocations"`
+    // Reimbursable defaults to true; false marks an expense the company paid
+    Reimbursable        *bool     `json:"reimbursable"`
+    // TaxJurisdiction makes the amount include that country's tax. Its rate is
+    // TaxRate if given, else looked up for TaxCode, which defaults to the
+    // category's tax code and then "standard". TaxAmount is computed from the
+    // rate unless given, e.g. as printed on the receipt.
+    TaxJurisdiction     string        `json:"tax_jurisdiction"`
+    TaxCode             string        `json:"tax_code"`
+    TaxRate             money.Decimal `json:"tax_rate"`
+    TaxAmount           money.Decimal `json:"tax_amount"`
+    MerchantVATNumber   string        `json:"merchant_vat_number"`
     RequestAISuggestion bool      `json:"request_ai_suggestion"`
 }
 
+// MileageRequest gives the inputs of a mileage claim. Unit defaults to the
+// rate's and VehicleType to "car".
+type MileageRequest struct {
+    Distance    money.Decimal `json:"distance"`
+    Unit        DistanceUnit  `json:"unit"`
+    VehicleType string        `json:"vehicle_type"`
+    Country     string        `json:"country"`
+}
+
+// PerDiemRequest gives the inputs of a per-diem claim: the destination, when
+// the trip started and ended, and how many meals were provided
+type PerDiemRequest struct {
+    Country            string    `json:"country"`
+    Start              time.Time `json:"start"`
+    End                time.Time `json:"end"`
+    BreakfastsProvided int       `json:"breakfasts_provided"`
+    LunchesProvided    int       `json:"lunches_provided"`
+    DinnersProvided    int       `json:"dinners_provided"`
+}
+
+// AllocationRequest is one line of a split expense. It gives either an amount
+// in the expense's currency or a percent of the expense, and every line of an
+// expense must do the same. Amounts must add up to the expense's amount and
+// percents to 100.
+type AllocationRequest struct {
+    Description  string        `json:"description"`
+    Category     string        `json:"category"`
+    ProjectID    *uint         `json:"project_id"`
+    CostCenterID *uint         `json:"cost_center_id"`
+    UserID       *uint         `json:"user_id"`
+    Amount       money.Decimal `json:"amount"`
+    Percent      money.Decimal `json:"percent"`
+}
+
 // UpdateExpenseRequest represents the request payload for updating an expense
 type UpdateExpenseRequest struct {
     Description *string  `json:"description"`
-    Amount      *float64 `json:"amount"`
+    Amount      *money.Decimal `json:"amount"`
+    Currency    *string  `json:"currency"`
     Date        *time.Time `json:"date"`
     Category    *string  `json:"category"`
     ClientNotes *string  `json:"client_notes"`
+    Attendees   *int     `json:"attendees"`
+    // ProjectID and CostCenterID of 0 clear them
+    ProjectID    *uint     `json:"project_id"`
+    CostCenterID *uint     `json:"cost_center_id"`
+    // MerchantID of 0 clears the merchant; Merchant names one as on create
+    MerchantID   *uint     `json:"merchant_id"`
+    Merchant     *string   `json:"merchant"`
+    // Tags replaces the expense's tags
+    Tags         *[]string `json:"tags"`
+    // Allocations replaces the expense's lines; an empty list stops splitting it
+    Allocations  *[]AllocationRequest `json:"allocations"`
+    // Mileage or PerDiem recompute a computed expense at the rates in effect on its date
+    Mileage      *MileageRequest `json:"mileage"`
+    PerDiem      *PerDiemRequest `json:"per_diem"`
+    Reimbursable *bool           `json:"reimbursable"`
+    // TaxJurisdiction of "" takes the tax off; TaxRate of "" goes back to the table rate.
+    // TaxAmount is computed again whenever the tax is, unless given.
+    TaxJurisdiction   *string        `json:"tax_jurisdiction"`
+    TaxCode           *string        `json:"tax_code"`
+    TaxRate           *money.Decimal `json:"tax_rate"`
+    TaxAmount         *money.Decimal `json:"tax_amount"`
+    MerchantVATNumber *string        `json:"merchant_vat_number"`
+}
+
+// ExpenseFilter narrows an expense listing. It is echoed back in list responses
+// so clients can see which filters were applied.
+type ExpenseFilter struct {
+    From           *time.Time      `json:"from,omitempty"`
+    To             *time.Time      `json:"to,omitempty"`
+    MinAmount      money.Decimal   `json:"min_amount,omitempty"`
+    MaxAmount      money.Decimal   `json:"max_amount,omitempty"`
+    Currency       string          `json:"currency,omitempty"`
+    Categories     []string        `json:"category,omitempty"`
+    ProjectIDs     []uint          `json:"project_id,omitempty"`
+    CostCenterIDs  []uint          `json:"cost_center_id,omitempty"`
+    MerchantIDs    []uint          `json:"merchant_id,omitempty"`
+    // Tags selects expenses carrying any of the named tags
+    Tags           []string        `json:"tag,omitempty"`
+    Statuses       []ExpenseStatus `json:"status,omitempty"`
+    Text           string          `json:"q,omitempty"`
+    HasAttachments *bool           `json:"has_attachments,omitempty"`
+    // MissingReceipt selects expenses above their category's or the organization's receipt threshold without attachments
+    MissingReceipt bool            `json:"missing_receipt,omitempty"`
+}
+
+// SortKey orders a listing by one field
+type SortKey struct {
+    Field string
+    Desc  bool
+}
+
+// ExpenseQuery describes a page of filtered, sorted expenses.
+// Cursor takes precedence over the deprecated Skip offset.
+type ExpenseQuery struct {
+    Filter ExpenseFilter
+    Sort   []SortKey
+    Cursor string
+    Skip   int
+    Limit  int
+}
+
+// ExpenseListResponse represents a page of expenses with the total number of matches
+type ExpenseListResponse struct {
+    Expenses   []Expense     `json:"expenses"`
+    Total      int64         `json:"total"`
+    Skip       int           `json:"skip"`
+    Limit      int           `json:"limit"`
+    Sort       string        `json:"sort"`
+    Filters    ExpenseFilter `json:"filters"`
+    NextCursor string        `json:"next_cursor,omitempty"`
+    PrevCursor string        `json:"prev_cursor,omitempty"`
+}
+
+// ExpenseSummaryGroup totals the expenses in one group, in the base currency
+type ExpenseSummaryGroup struct {
+    Key         string        `json:"key"`
+    Count       int           `json:"count"`
+    Amount      money.Decimal `json:"amount"`
+    AmountMinor int64         `json:"amount_minor"`
+    Currency    string        `json:"currency"`
+}
+
+// ExpenseSummaryResponse represents filtered expenses totalled by one dimension.
+// An expense with several tags counts toward each of them when grouping by tag.
+type ExpenseSummaryResponse struct {
+    GroupBy string                `json:"group_by"`
+    Groups  []ExpenseSummaryGroup `json:"groups"`
+    Totals  []ExpenseSummaryGroup `json:"totals"`
+    Filters ExpenseFilter         `json:"filters"`
+}
+
+// ExpenseSearchResult is one ranked expense search match
+type ExpenseSearchResult struct {
+    Expense Expense `json:"expense"`
+    Score   float64 `json:"score"`
+    Snippet string  `json:"snippet"`
+}
+
+// ExpenseSearchResponse represents a page of ranked search results
+type ExpenseSearchResponse struct {
+    Query   string                `json:"query"`
+    Backend string                `json:"backend"`
+    Results []ExpenseSearchResult `json:"results"`
+    Total   int64                 `json:"total"`
+    Skip    int                   `json:"skip"`
+    Limit   int                   `json:"limit"`
+}
+
+// TrashResponse lists soft-deleted expenses and attachments awaiting purge.
+// Attachments deleted together with their expense are listed on the expense.
+type TrashResponse struct {
+    Expenses      []Expense    `json:"expenses"`
+    Attachments   []Attachment `json:"attachments"`
+    RetentionDays int          `json:"retention_days"`
+}
+
+// AuditEventListResponse represents a page of audit events, newest first
+type AuditEventListResponse struct {
+    Events     []AuditEvent `json:"events"`
+    NextCursor string       `json:"next_cursor,omitempty"`
+    PrevCursor string       `json:"prev_cursor,omitempty"`
 }
 
 // AISuggestRequest represents the request payload for AI suggestions
 type AISuggestRequest struct {
     Description string  `json:"description" binding:"required"`
     Amount      float64 `json:"amount" binding:"required,gt=0"`
+    // MerchantID names the merchant; without it the description is matched to one
+    MerchantID  *uint   `json:"merchant_id,omitempty"`
+    // Lines asks for a category for each line of a split expense
+    Lines       []AISuggestLine `json:"lines,omitempty"`
+}
+
+// AISuggestLine is one line of a split expense to categorize
+type AISuggestLine struct {
+    Description string  `json:"description"`
+    Amount      float64 `json:"amount"`
 }
 
 // AISuggestResponse represents the AI suggestion response
 type AISuggestResponse struct {
     Category    string `json:"category"`
     ClientNotes string `json:"client_notes"`
+    // Merchant is the merchant the description was matched to, if any
+    Merchant    *Merchant `json:"merchant,omitempty"`
+    Lines       []AISuggestLineResponse `json:"lines,omitempty"`
+}
+
+// AISuggestLineResponse is the category suggested for one line
+type AISuggestLineResponse struct {
+    Description string  `json:"description"`
+    Amount      float64 `json:"amount"`
+    Category    string  `json:"category"`
 }
 
 // ApproveSuggestionRequest represents the request to approve/modify AI suggestions
@@ -84,17 +1394,376 @@ type ApproveSuggestionRequest struct {
     CustomNotes     *string `json:"custom_notes"`
 }
 
+// CreateOrganizationRequest represents the request payload for creating an organization
+type CreateOrganizationRequest struct {
+    Name         string `json:"name"`
+    BaseCurrency string `json:"base_currency"`
+}
+
+// UpdateOrganizationRequest represents the request payload for changing organization settings
+type UpdateOrganizationRequest struct {
+    Name                 *string        `json:"name"`
+    BaseCurrency         *string        `json:"base_currency"`
+    ReceiptRequiredAbove *money.Decimal `json:"receipt_required_above"`
+    DuplicateMode        *string        `json:"duplicate_mode"`
+}
+
+// InviteMemberRequest represents the request payload for inviting a member
+type InviteMemberRequest struct {
+    Email string `json:"email"`
+    Role  Role   `json:"role"`
+}
+
+// UpdateMemberRequest represents the request payload for changing a member's role
+type UpdateMemberRequest struct {
+    Role Role `json:"role"`
+}
+
+// TransitionRequest represents the request payload for a workflow step
+type TransitionRequest struct {
+    Comment string `json:"comment"`
+}
+
+// CreateApprovalRuleRequest represents the request payload for an approval threshold
+type CreateApprovalRuleRequest struct {
+    MinAmount         money.Decimal `json:"min_amount"`
+    RequiredApprovals int           `json:"required_approvals"`
+}
+
+// CreateCategoryRequest represents the request payload for creating a category
+type CreateCategoryRequest struct {
+    Name                 string         `json:"name"`
+    ParentID             *uint          `json:"parent_id"`
+    GLCode               string         `json:"gl_code"`
+    TaxCode              string         `json:"tax_code"`
+    ReceiptRequiredAbove *money.Decimal `json:"receipt_required_above"`
+}
+
+// UpdateCategoryRequest represents the request payload for changing a category.
+// A parent_id of 0 moves the category to the top level and an empty
+// receipt_required_above returns it to the organization's threshold.
+type UpdateCategoryRequest struct {
+    Name                 *string        `json:"name"`
+    ParentID             *uint          `json:"parent_id"`
+    GLCode               *string        `json:"gl_code"`
+    TaxCode              *string        `json:"tax_code"`
+    ReceiptRequiredAbove *money.Decimal `json:"receipt_required_above"`
+    Archived             *bool          `json:"archived"`
+}
+
+// CreateProjectRequest represents the request payload for creating a project
+type CreateProjectRequest struct {
+    Name   string        `json:"name"`
+    Code   string        `json:"code"`
+    Client string        `json:"client"`
+    Budget money.Decimal `json:"budget"`
+}
+
+// UpdateProjectRequest represents the request payload for changing a project
+type UpdateProjectRequest struct {
+    Name   *string        `json:"name"`
+    Code   *string        `json:"code"`
+    Client *string        `json:"client"`
+    Budget *money.Decimal `json:"budget"`
+    Status *ProjectStatus `json:"status"`
+}
+
+// CostCenterRequest represents the request payload for creating or changing a cost center
+type CostCenterRequest struct {
+    Code     *string `json:"code"`
+    Name     *string `json:"name"`
+    Archived *bool   `json:"archived"`
+}
+
+// MerchantRequest represents the request payload for creating or changing a
+// merchant. Aliases replaces the merchant's aliases and an empty
+// default_category or tax_id clears it.
+type MerchantRequest struct {
+    Name            *string   `json:"name"`
+    DefaultCategory *string   `json:"default_category"`
+    TaxID           *string   `json:"tax_id"`
+    Aliases         *[]string `json:"aliases"`
+}
+
+// MergeMerchantsRequest names the merchants folded into another
+type MergeMerchantsRequest struct {
+    MerchantIDs []uint `json:"merchant_ids"`
+}
+
+// CreateRecurringExpenseRequest represents the request payload for creating a
+// recurring expense. Dates are YYYY-MM-DD; Currency defaults to the base currency.
+type CreateRecurringExpenseRequest struct {
+    Description  string        `json:"description"`
+    Amount       money.Decimal `json:"amount"`
+    Currency     string        `json:"currency"`
+    Category     string        `json:"category"`
+    ClientNotes  string        `json:"client_notes"`
+    ProjectID    *uint         `json:"project_id"`
+    CostCenterID *uint         `json:"cost_center_id"`
+    Rule         string        `json:"rule"`
+    StartDate    string        `json:"start_date"`
+    EndDate      string        `json:"end_date"`
+}
+
+// UpdateRecurringExpenseRequest represents the request payload for changing a
+// recurring expense. Changes apply to expenses not yet created. An empty
+// EndDate removes it, and a ProjectID or CostCenterID of 0 clears them.
+type UpdateRecurringExpenseRequest struct {
+    Description  *string          `json:"description"`
+    Amount       *money.Decimal   `json:"amount"`
+    Currency     *string          `json:"currency"`
+    Category     *string          `json:"category"`
+    ClientNotes  *string          `json:"client_notes"`
+    ProjectID    *uint            `json:"project_id"`
+    CostCenterID *uint            `json:"cost_center_id"`
+    Rule         *string          `json:"rule"`
+    StartDate    *string          `json:"start_date"`
+    EndDate      *string          `json:"end_date"`
+    // Status pauses or resumes the template: active or paused
+    Status       *RecurringStatus `json:"status"`
+}
+
+// CreateExpenseReportRequest represents the request payload for creating an
+// expense report. Dates are YYYY-MM-DD; ExpenseIDs are added to the new report.
+type CreateExpenseReportRequest struct {
+    Title       string `json:"title"`
+    Purpose     string `json:"purpose"`
+    PeriodStart string `json:"period_start"`
+    PeriodEnd   string `json:"period_end"`
+    ExpenseIDs  []uint `json:"expense_ids"`
+}
+
+// UpdateExpenseReportRequest represents the request payload for changing an
+// expense report. An empty PeriodStart or PeriodEnd removes it.
+type UpdateExpenseReportRequest struct {
+    Title       *string `json:"title"`
+    Purpose     *string `json:"purpose"`
+    PeriodStart *string `json:"period_start"`
+    PeriodEnd   *string `json:"period_end"`
+}
+
+// ReportExpensesRequest represents the request payload for adding expenses to a report
+type ReportExpensesRequest struct {
+    ExpenseIDs []uint `json:"expense_ids"`
+}
+
+// CreateReimbursementBatchRequest represents the request payload for creating
+// a reimbursement batch. ExecutionDate is YYYY-MM-DD and defaults to today;
+// UserIDs, when given, limit the batch to those employees.
+type CreateReimbursementBatchRequest struct {
+    Reference     string `json:"reference"`
+    ExecutionDate string `json:"execution_date"`
+    UserIDs       []uint `json:"user_ids"`
+}
+
+// RenameTagRequest represents the request payload for renaming a tag
+type RenameTagRequest struct {
+    Name string `json:"name"`
+}
+
+// PolicyRuleRequest represents the request payload for creating or replacing a policy rule
+type PolicyRuleRequest struct {
+    Name      string          `json:"name"`
+    Message   string          `json:"message"`
+    Category  string          `json:"category"`
+    Severity  PolicySeverity  `json:"severity"`
+    Condition json.RawMessage `json:"condition"`
+    // Active defaults to true
+    Active    *bool           `json:"active"`
+}
+
+// PolicyExpense describes an expense to check against policy rules without saving it
+type PolicyExpense struct {
+    Description string        `json:"description"`
+    Amount      money.Decimal `json:"amount"`
+    Currency    string        `json:"currency"`
+    Date        time.Time     `json:"date"`
+    Category    string        `json:"category"`
+    ClientNotes string        `json:"client_notes"`
+    Attendees   int           `json:"attendees"`
+    // Attachments is the number of files the expense would carry
+    Attachments int           `json:"attachments"`
+}
+
+// EvaluatePolicyRequest represents a policy dry run: a saved expense (ExpenseID)
+// or a described one (Expense), checked against the organization's active rules
+// or, when given, the unsaved Rules
+type EvaluatePolicyRequest struct {
+    ExpenseID uint                `json:"expense_id"`
+    Expense   *PolicyExpense      `json:"expense"`
+    Rules     []PolicyRuleRequest `json:"rules"`
+}
+
+// PolicyEvaluation is the outcome of a policy dry run
+type PolicyEvaluation struct {
+    Violations []PolicyViolation `json:"violations"`
+    // Blocked tells whether a blocking rule would reject the expense
+    Blocked    bool              `json:"blocked"`
+}
+
+// CreateExchangeRateRequest represents the request payload for recording an exchange rate
+type CreateExchangeRateRequest struct {
+    Date          string `json:"date"`
+    BaseCurrency  string `json:"base_currency"`
+    QuoteCurrency string `json:"quote_currency"`
+    Rate          money.Decimal `json:"rate"`
+}
+
+// CreateMileageRateRequest represents the request payload for recording a mileage rate
+type CreateMileageRateRequest struct {
+    VehicleType   string        `json:"vehicle_type"`
+    Country       string        `json:"country"`
+    EffectiveFrom string        `json:"effective_from"`
+    Unit          DistanceUnit  `json:"unit"`
+    Rate          money.Decimal `json:"rate"`
+    Currency      string        `json:"currency"`
+}
+
+// CreatePerDiemRateRequest represents the request payload for recording a per-diem rate.
+// PartialDayPercent defaults to 100.
+type CreatePerDiemRateRequest struct {
+    Country            string        `json:"country"`
+    EffectiveFrom      string        `json:"effective_from"`
+    DailyRate          money.Decimal `json:"daily_rate"`
+    Currency           string        `json:"currency"`
+    PartialDayPercent  *int          `json:"partial_day_percent"`
+    MinPartialDayHours int           `json:"min_partial_day_hours"`
+    BreakfastPercent   int           `json:"breakfast_percent"`
+    LunchPercent       int           `json:"lunch_percent"`
+    DinnerPercent      int           `json:"dinner_percent"`
+}
+
+// CreateTaxRateRequest represents the request payload for recording a tax rate.
+// Code defaults to "standard".
+type CreateTaxRateRequest struct {
+    Country       string        `json:"country"`
+    Code          string        `json:"code"`
+    EffectiveFrom string        `json:"effective_from"`
+    Rate          money.Decimal `json:"rate"`
+}
+
+// ImportExchangeRatesResponse summarizes an exchange rate file import
+type ImportExchangeRatesResponse struct {
+    Imported     int      `json:"imported"`
+    BaseCurrency string   `json:"base_currency"`
+    Currencies   []string `json:"currencies"`
+    From         string   `json:"from,omitempty"`
+    To           string   `json:"to,omitempty"`
+}
+
+// Expense import modes
+const (
+    // ImportAtomic imports every row or, if any row is invalid, none
+    ImportAtomic = "atomic"
+    // ImportBestEffort imports the valid rows and reports the rest
+    ImportBestEffort = "best_effort"
+)
+
+// ExpenseImportOptions controls how a CSV or XLSX file is turned into expenses
+type ExpenseImportOptions struct {
+    // Mapping maps expense fields to column names; unmapped fields are matched by common names
+    Mapping      map[string]string
+    Mode         string
+    DryRun       bool
+    // Categorize fills in missing categories with the rule-based categorizer
+    Categorize   bool
+    // DateFormat is a pattern such as DD/MM/YYYY; ISO dates are read by default
+    DateFormat   string
+    DecimalComma bool
+    // Currency applies to rows without a currency column value; defaults to the base currency
+    Currency     string
+    Sheet        string
+}
+
+// ImportRowError reports why a row of an import file was rejected.
+// Row is the row number in the file, counting the header as row 1.
+type ImportRowError struct {
+    Row     int    `json:"row"`
+    Field   string `json:"field,omitempty"`
+    Message string `json:"message"`
+}
+
+// ExpenseImportResponse summarizes an expense import. In a dry run Expenses
+// holds the expenses that would be created, without IDs.
+type ExpenseImportResponse struct {
+    DryRun      bool              `json:"dry_run"`
+    Mode        string            `json:"mode"`
+    Columns     []string          `json:"columns"`
+    Mapping     map[string]string `json:"mapping"`
+    Rows        int               `json:"rows"`
+    Valid       int               `json:"valid"`
+    Imported    int               `json:"imported"`
+    Categorized int               `json:"categorized"`
+    Errors      []ImportRowError  `json:"errors"`
+    Expenses    []Expense         `json:"expenses"`
+}
+
+// StatementImportOptions controls how a bank or card statement is turned into expenses
+type StatementImportOptions struct {
+    // Format is ofx, camt053 or mt940; it is detected from the file when empty
+    Format string
+    DryRun bool
+}
+
+// StatementImportResponse summarizes a statement import. Only debits become
+// expenses; credits such as refunds and card payments are counted as skipped.
+// Transactions imported before, even if since deleted, count as duplicates.
+type StatementImportResponse struct {
+    DryRun       bool             `json:"dry_run"`
+    Format       string           `json:"format"`
+    Transactions int              `json:"transactions"`
+    Imported     int              `json:"imported"`
+    Duplicates   int              `json:"duplicates"`
+    Skipped      int              `json:"skipped"`
+    Categorized  int              `json:"categorized"`
+    // Errors report lines that could not become expenses; Row is the line's position in the statement
+    Errors       []ImportRowError `json:"errors"`
+    Expenses     []Expense        `json:"expenses"`
+    // ReceiptsLinked counts waiting receipts automatically attached to the new expenses
+    ReceiptsLinked int            `json:"receipts_linked"`
+}
+
+// ReceiptDetails describes an uploaded receipt for matching; every field is optional
+type ReceiptDetails struct {
+    Merchant string
+    Amount   money.Decimal
+    Currency string
+    Date     *time.Time
+}
+
+// ReceiptUploadResponse returns an uploaded receipt and the matching run it triggered
+type ReceiptUploadResponse struct {
+    Receipt Attachment         `json:"receipt"`
+    Match   ReceiptMatchResult `json:"match"`
+}
+
+// ReceiptMatchResult summarizes a matching run
+type ReceiptMatchResult struct {
+    Receipts   int `json:"receipts"`
+    AutoLinked int `json:"auto_linked"`
+    Queued     int `json:"queued"`
+}
+
+// ReceiptReviewResponse is the review queue: suggested pairings awaiting a
+// decision, best first, and the expenses flagged for lacking a receipt
+type ReceiptReviewResponse struct {
+    Matches         []ReceiptMatch `json:"matches"`
+    MissingReceipts []Expense      `json:"missing_receipts"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
     Detail string `json:"detail"`
 }
 
-// CategoriesResponse represents the categories response
+// CategoriesResponse represents the categories response: the names of the
+// categories expenses may use, and the category records themselves
 type CategoriesResponse struct {
-    Categories []string `json:"categories"`
+    Categories []string   `json:"categories"`
+    Items      []Category `json:"items"`
 }
 
-// DefaultCategories provides a list of default expense categories
+// DefaultCategories are the categories a new organization starts with
 var DefaultCategories = []string{
     "Travel",
     "Meals & Entertainment",
@@ -0,0 +1,52 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Decimal is a decimal amount carried as text so it never passes through a
+// float. It is written to JSON as a number and read from either a number or a
+// string.
+type Decimal string
+
+// MarshalJSON writes the decimal as a bare JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("null"), nil
+	}
+	return []byte(d), nil
+}
+
+// UnmarshalJSON accepts 12.34, "12.34" or null.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		*d = ""
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		text = strings.TrimSpace(text)
+	}
+	// Anything but a number, such as true or "{}", would be written back out verbatim
+	var number json.Number
+	if text != "" && (json.Unmarshal([]byte(text), &number) != nil || number.String() != text) {
+		return errors.New("amount must be a decimal number")
+	}
+	*d = Decimal(text)
+	return nil
+}
+
+// Minor parses the decimal into minor units of currency.
+func (d Decimal) Minor(currency string) (int64, error) {
+	return ParseMinor(string(d), currency)
+}
+
+// FromMinor returns the decimal representation of minor units of currency.
+func FromMinor(minor int64, currency string) Decimal {
+	return Decimal(Format(minor, currency))
+}
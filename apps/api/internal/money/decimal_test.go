@@ -0,0 +1,93 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimalUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want Decimal
+	}{
+		{`12.34`, "12.34"},
+		{`"12.34"`, "12.34"},
+		{`" 12.34 "`, "12.34"},
+		{`-0.5`, "-0.5"},
+		{`"-1500"`, "-1500"},
+		{`1e3`, "1e3"},
+		{`null`, ""},
+		{`""`, ""},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		if err := json.Unmarshal([]byte(tt.json), &d); err != nil || d != tt.want {
+			t.Errorf("Unmarshal(%s) = %q, %v, want %q", tt.json, d, err, tt.want)
+		}
+	}
+}
+
+func TestDecimalUnmarshalJSONRejects(t *testing.T) {
+	for _, input := range []string{
+		`"abc"`,
+		`"12,34"`,
+		`"12.34 USD"`,
+		`"$12"`,
+		`"1/2"`,
+		`"0x10"`,
+		`"012"`,
+		`"{}"`,
+		`"\"12\""`,
+		`true`,
+		`{}`,
+		`[12]`,
+	} {
+		var d Decimal
+		if err := json.Unmarshal([]byte(input), &d); err == nil {
+			t.Errorf("Unmarshal(%s) = %q, want an error", input, d)
+		}
+	}
+}
+
+func TestDecimalMarshalJSON(t *testing.T) {
+	out, err := json.Marshal(struct {
+		Amount Decimal `json:"amount"`
+		Rate   Decimal `json:"rate"`
+		Tax    Decimal `json:"tax"`
+	}{Amount: "-12.30", Rate: "1.0921"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"amount":-12.30,"rate":1.0921,"tax":null}`; string(out) != want {
+		t.Errorf("Marshal = %s, want %s", out, want)
+	}
+}
+
+func TestDecimalMinor(t *testing.T) {
+	tests := []struct {
+		amount   Decimal
+		currency string
+		want     int64
+		ok       bool
+	}{
+		{"12.34", "USD", 1234, true},
+		{"-0.5", "EUR", -50, true},
+		{"12", "JPY", 12, true},
+		{"1.234", "KWD", 1234, true},
+		{"1.5", "JPY", 0, false},
+		{"1e3", "USD", 0, false},
+		{"", "USD", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.amount.Minor(tt.currency)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Decimal(%q).Minor(%s) = %d, %v", tt.amount, tt.currency, got, err)
+		}
+	}
+
+	for _, minor := range []int64{1234, -5, 0} {
+		if back, err := FromMinor(minor, "USD").Minor("USD"); err != nil || back != minor {
+			t.Errorf("FromMinor(%d).Minor() = %d, %v", minor, back, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package money handles currency amounts as integer minor units so that sums
+// and conversions are exact.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// exponents maps ISO 4217 codes to the number of digits in their minor unit.
+var exponents = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2,
+	"CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"ISK": 0, "JOD": 3, "JPY": 0, "KES": 2, "KRW": 0, "KWD": 3, "MAD": 2,
+	"MXN": 2, "MYR": 2, "NGN": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PEN": 2,
+	"PHP": 2, "PKR": 2, "PLN": 2, "QAR": 2, "RON": 2, "RSD": 2, "SAR": 2,
+	"SEK": 2, "SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UAH": 2,
+	"USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// Normalize upper-cases and trims a currency code.
+func Normalize(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// Valid reports whether currency is a supported ISO 4217 code.
+func Valid(currency string) bool {
+	_, ok := exponents[Normalize(currency)]
+	return ok
+}
+
+// Exponent returns the number of minor-unit digits for currency, defaulting to 2.
+func Exponent(currency string) int {
+	if exp, ok := exponents[Normalize(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// ParseMinor converts a decimal string such as "12.34" into minor units of
+// currency. It rejects values with more fractional digits than the currency
+// supports rather than rounding them away.
+func ParseMinor(value, currency string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, errors.New("empty amount")
+	}
+
+	r, ok := new(big.Rat).SetString(value)
+	if !ok || strings.ContainsAny(value, "eE/") {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	r.Mul(r, scale(Exponent(currency)))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("amount %q has more decimals than %s allows", value, Normalize(currency))
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", value)
+	}
+
+	return r.Num().Int64(), nil
+}
+
+// Format renders minor units of currency as a plain decimal string, e.g. "-12.30".
+func Format(minor int64, currency string) string {
+	exp := Exponent(currency)
+	if exp == 0 {
+		return fmt.Sprintf("%d", minor)
+	}
+
+	sign := ""
+	abs := new(big.Int).SetInt64(minor)
+	if minor < 0 {
+		sign = "-"
+		abs.Neg(abs)
+	}
+
+	digits := abs.String()
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	split := len(digits) - exp
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+// Float returns minor units as a float64 in major units. It is only meant for
+// heuristics and display, never for arithmetic on stored amounts.
+func Float(minor int64, currency string) float64 {
+	r := new(big.Rat).SetInt64(minor)
+	f, _ := r.Quo(r, scale(Exponent(currency))).Float64()
+	return f
+}
+
+// ParseRate parses an exchange rate such as "1.0921". Rates must be positive.
+func ParseRate(value string) (*big.Rat, error) {
+	value = strings.TrimSpace(value)
+	r, ok := new(big.Rat).SetString(value)
+	if !ok || strings.ContainsAny(value, "eE/") || r.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q", value)
+	}
+	return r, nil
+}
+
+// FormatRate renders a rate as a decimal string with up to 10 fractional digits.
+func FormatRate(rate *big.Rat) string {
+	s := rate.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert turns minor units of from into minor units of to using rate, the
+// number of units of to per one unit of from. Results are rounded half away
+// from zero to the nearest minor unit of to.
+func Convert(minor int64, from, to string, rate *big.Rat) (int64, error) {
+	r := new(big.Rat).SetInt64(minor)
+	r.Mul(r, rate)
+	r.Mul(r, scale(Exponent(to)))
+	r.Quo(r, scale(Exponent(from)))
+
+	rounded := roundHalfAwayFromZero(r)
+	if !rounded.IsInt64() {
+		return 0, errors.New("converted amount is out of range")
+	}
+	return rounded.Int64(), nil
+}
+
+// Round converts an exact amount in major units of currency, such as a
+// distance times a rate per kilometer, into minor units rounded half away from
+// zero.
+func Round(amount *big.Rat, currency string) (int64, error) {
+	r := new(big.Rat).Mul(amount, scale(Exponent(currency)))
+	rounded := roundHalfAwayFromZero(r)
+	if !rounded.IsInt64() {
+		return 0, errors.New("amount is out of range")
+	}
+	return rounded.Int64(), nil
+}
+
+func scale(exp int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+}
+
+func roundHalfAwayFromZero(r *big.Rat) *big.Int {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	negative := num.Sign() < 0
+	num.Abs(num)
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if negative {
+		quo.Neg(quo)
+	}
+	return quo
+}
@@ -0,0 +1,254 @@
+package money
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("bad number " + s)
+	}
+	return r
+}
+
+func TestParseMinor(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+		// err is part of the error message when parsing fails
+		err string
+	}{
+		{value: "12.34", currency: "USD", want: 1234},
+		{value: "12.3", currency: "USD", want: 1230},
+		{value: "12", currency: "USD", want: 1200},
+		{value: " 7 ", currency: "usd", want: 700},
+		{value: "-12.34", currency: "USD", want: -1234},
+		{value: "-0.01", currency: "EUR", want: -1},
+		{value: "0", currency: "EUR", want: 0},
+		{value: "1500", currency: "JPY", want: 1500},
+		{value: "-3", currency: "CLP", want: -3},
+		{value: "1.5", currency: "JPY", err: "more decimals than JPY allows"},
+		{value: "1.234", currency: "KWD", want: 1234},
+		{value: "0.001", currency: "BHD", want: 1},
+		{value: "-1.5", currency: "OMR", want: -1500},
+		{value: "1.2345", currency: "KWD", err: "more decimals than KWD allows"},
+		{value: "12.345", currency: "USD", err: "more decimals than USD allows"},
+		{value: "12.340", currency: "USD", want: 1234},
+		// Unknown currencies fall back to two decimals
+		{value: "1.23", currency: "XYZ", want: 123},
+		{value: "92233720368547758.07", currency: "USD", want: math.MaxInt64},
+		{value: "-92233720368547758.08", currency: "USD", want: math.MinInt64},
+		{value: "92233720368547758.08", currency: "USD", err: "out of range"},
+		{value: "9223372036854775808", currency: "JPY", err: "out of range"},
+		{value: "", currency: "USD", err: "empty amount"},
+		{value: "  ", currency: "USD", err: "empty amount"},
+		{value: "abc", currency: "USD", err: "invalid amount"},
+		{value: "1,000.00", currency: "USD", err: "invalid amount"},
+		{value: "12.34.5", currency: "USD", err: "invalid amount"},
+		{value: "1e3", currency: "USD", err: "invalid amount"},
+		{value: "1E-2", currency: "USD", err: "invalid amount"},
+		{value: "1/3", currency: "USD", err: "invalid amount"},
+		{value: "$5", currency: "USD", err: "invalid amount"},
+	}
+	for _, tt := range tests {
+		got, err := ParseMinor(tt.value, tt.currency)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseMinor(%q, %s) = %d, %v, want error %q", tt.value, tt.currency, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMinor(%q, %s) = %d, %v, want %d", tt.value, tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     string
+	}{
+		{1234, "USD", "12.34"},
+		{1230, "EUR", "12.30"},
+		{5, "USD", "0.05"},
+		{0, "USD", "0.00"},
+		{-5, "USD", "-0.05"},
+		{-1234, "GBP", "-12.34"},
+		{1500, "JPY", "1500"},
+		{-7, "KRW", "-7"},
+		{0, "JPY", "0"},
+		{1, "KWD", "0.001"},
+		{1234, "BHD", "1.234"},
+		{-1234567, "TND", "-1234.567"},
+		{math.MaxInt64, "USD", "92233720368547758.07"},
+		{math.MinInt64, "USD", "-92233720368547758.08"},
+		{math.MinInt64, "KWD", "-9223372036854775.808"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.minor, tt.currency); got != tt.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tt.minor, tt.currency, got, tt.want)
+		}
+		// Formatted amounts parse back to the same minor units
+		if back, err := ParseMinor(tt.want, tt.currency); err != nil || back != tt.minor {
+			t.Errorf("ParseMinor(%q, %s) = %d, %v, want %d", tt.want, tt.currency, back, err, tt.minor)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+	}{
+		{"12.344", "USD", 1234},
+		{"12.345", "USD", 1235},
+		{"12.3449999", "USD", 1234},
+		{"-12.345", "USD", -1235},
+		{"-12.344", "USD", -1234},
+		{"0.005", "EUR", 1},
+		{"-0.005", "EUR", -1},
+		{"0.0049", "EUR", 0},
+		{"2.5", "JPY", 3},
+		{"-2.5", "JPY", -3},
+		{"3.49", "JPY", 3},
+		{"1.2345", "KWD", 1235},
+		{"-1.2345", "KWD", -1235},
+		{"1.23449", "KWD", 1234},
+		// 42.3 km at 0.3 per km
+		{"12.69", "GBP", 1269},
+		{"1/3", "USD", 33},
+		{"2/3", "USD", 67},
+		{"-2/3", "JPY", -1},
+	}
+	for _, tt := range tests {
+		got, err := Round(rat(tt.amount), tt.currency)
+		if err != nil || got != tt.want {
+			t.Errorf("Round(%s, %s) = %d, %v, want %d", tt.amount, tt.currency, got, err, tt.want)
+		}
+	}
+
+	if _, err := Round(rat("92233720368547758.075"), "USD"); err == nil {
+		t.Error("Round past the int64 range succeeded, want an error")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		minor    int64
+		from, to string
+		rate     string
+		want     int64
+	}{
+		{"same exponent", 1000, "EUR", "USD", "1.0921", 1092},
+		{"half way up", 1000, "EUR", "USD", "1.0925", 1093},
+		{"negative half way", -1000, "EUR", "USD", "1.0925", -1093},
+		{"refund", -1234, "USD", "EUR", "0.9", -1111},
+		{"to no decimals", 1000, "USD", "JPY", "149.5", 1495},
+		{"to no decimals half way", 1, "USD", "JPY", "150", 2},
+		{"from no decimals", 1000, "JPY", "USD", "0.00675", 675},
+		{"from no decimals half way", 100, "JPY", "USD", "0.00675", 68},
+		{"to three decimals", 1000, "USD", "KWD", "0.30705", 3071},
+		{"from three decimals", 1500, "KWD", "JPY", "491", 737},
+		{"three to two decimals", 1005, "BHD", "USD", "2.5", 251},
+		{"zero", 0, "USD", "EUR", "0.9", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.minor, tt.from, tt.to, rat(tt.rate))
+			if err != nil || got != tt.want {
+				t.Errorf("Convert(%d %s to %s at %s) = %d, %v, want %d", tt.minor, tt.from, tt.to, tt.rate, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Convert(math.MaxInt64, "USD", "JPY", rat("150")); err == nil {
+		t.Error("Convert past the int64 range succeeded, want an error")
+	}
+	if _, err := Convert(math.MinInt64, "JPY", "KWD", rat("1")); err == nil {
+		t.Error("Convert past the int64 range succeeded, want an error")
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for _, value := range []string{"1.0921", " 0.00675 ", "150", "1"} {
+		if _, err := ParseRate(value); err != nil {
+			t.Errorf("ParseRate(%q) = %v", value, err)
+		}
+	}
+	for _, value := range []string{"", "0", "0.0", "-1.2", "abc", "1e2", "1/3", "1,5"} {
+		if r, err := ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) = %s, want an error", value, r)
+		}
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want string
+	}{
+		{"1.0921", "1.0921"},
+		{"1.50", "1.5"},
+		{"150", "150"},
+		{"1/3", "0.3333333333"},
+		{"2/3", "0.6666666667"},
+		{"0.00000000001", "0"},
+	}
+	for _, tt := range tests {
+		if got := FormatRate(rat(tt.rate)); got != tt.want {
+			t.Errorf("FormatRate(%s) = %q, want %q", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestCurrencies(t *testing.T) {
+	tests := []struct {
+		currency string
+		valid    bool
+		exponent int
+	}{
+		{"USD", true, 2},
+		{" usd ", true, 2},
+		{"JPY", true, 0},
+		{"ISK", true, 0},
+		{"KWD", true, 3},
+		{"jod", true, 3},
+		{"XYZ", false, 2},
+		{"", false, 2},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.currency); got != tt.valid {
+			t.Errorf("Valid(%q) = %v, want %v", tt.currency, got, tt.valid)
+		}
+		if got := Exponent(tt.currency); got != tt.exponent {
+			t.Errorf("Exponent(%q) = %d, want %d", tt.currency, got, tt.exponent)
+		}
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     float64
+	}{
+		{1234, "USD", 12.34},
+		{-5, "EUR", -0.05},
+		{1500, "JPY", 1500},
+		{1234, "KWD", 1.234},
+	}
+	for _, tt := range tests {
+		if got := Float(tt.minor, tt.currency); got != tt.want {
+			t.Errorf("Float(%d, %s) = %v, want %v", tt.minor, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Key is one column of a listing's sort order. The last key must be unique
+// (usually the primary key) so that every row has a distinct position.
+type Key struct {
+	Column string
+	Desc   bool
+}
+
+// Page is one page of a keyset-paginated listing.
+type Page[T any] struct {
+	Items []T
+	// Next and Prev are cursors for the adjacent pages, empty when there is none.
+	Next string
+	Prev string
+}
+
+// Paginate loads up to limit rows of query ordered by keys, starting after (or,
+// for a Before cursor, ending before) the position in cursor. sort is the
+// canonical sort description the cursors are bound to and values returns a
+// row's sort key values in key order. With a nil cursor, offset rows are
+// skipped instead; this supports legacy offset paging.
+func Paginate[T any](query *gorm.DB, keys []Key, sort string, cursor *Cursor, offset, limit int, values func(T) []interface{}) (*Page[T], error) {
+	before := false
+	if cursor != nil {
+		if cursor.Sort != sort || len(cursor.Values) != len(keys) {
+			return nil, ErrInvalidCursor
+		}
+		seek, args, err := seekCondition(keys, cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(seek, args...)
+		before = cursor.Before
+	} else if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	items := []T{}
+	if err := query.Order(orderClause(keys, before)).Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if before {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	// Moving forward, the page before exists if we started past the beginning;
+	// moving backward, the page after is the one we came from.
+	hasNext := more
+	hasPrev := cursor != nil || offset > 0
+	if before {
+		hasNext, hasPrev = true, more
+	}
+
+	var err error
+	if hasNext {
+		if page.Next, err = encodeAt(sort, values(items[len(items)-1]), false); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrev {
+		if page.Prev, err = encodeAt(sort, values(items[0]), true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// LinkHeader builds an RFC 8288 Link header for the adjacent pages of a
+// listing served at u, keeping its other query parameters.
+func LinkHeader(u *url.URL, next, prev string) string {
+	var links []string
+	for _, link := range []struct{ rel, cursor string }{{"next", next}, {"prev", prev}} {
+		if link.cursor == "" {
+			continue
+		}
+		query := u.Query()
+		query.Del("skip")
+		query.Set("cursor", link.cursor)
+		target := url.URL{Path: u.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", target.String(), link.rel))
+	}
+	return strings.Join(links, ", ")
+}
+
+func encodeAt(sort string, raw []interface{}, before bool) (string, error) {
+	values := make([]Value, len(raw))
+	for i, v := range raw {
+		value, err := NewValue(v)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	return Encode(Cursor{Sort: sort, Values: values, Before: before})
+}
+
+// seekCondition selects rows strictly after (or before) the cursor position:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., with the comparison flipped for
+// descending keys and for Before cursors.
+func seekCondition(keys []Key, cursor *Cursor) (string, []interface{}, error) {
+	var terms []string
+	var args []interface{}
+
+	for i, key := range keys {
+		var parts []string
+		var termArgs []interface{}
+		for j := 0; j < i; j++ {
+			arg, err := cursor.Values[j].Arg()
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, keys[j].Column+" = ?")
+			termArgs = append(termArgs, arg)
+		}
+
+		arg, err := cursor.Values[i].Arg()
+		if err != nil {
+			return "", nil, err
+		}
+		op := ">"
+		if key.Desc != cursor.Before {
+			op = "<"
+		}
+		parts = append(parts, key.Column+" "+op+" ?")
+		termArgs = append(termArgs, arg)
+
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, termArgs...)
+	}
+
+	return "(" + strings.Join(terms, " OR ") + ")", args, nil
+}
+
+func orderClause(keys []Key, reverse bool) string {
+	clauses := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.Desc != reverse {
+			direction = "DESC"
+		}
+		clauses[i] = key.Column + " " + direction
+	}
+	return strings.Join(clauses, ", ")
+}
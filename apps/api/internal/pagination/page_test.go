@@ -0,0 +1,275 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSeekCondition(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	values := []Value{{Type: "time", Value: date.Format(time.RFC3339Nano)}, {Type: "int", Value: "42"}}
+	tests := []struct {
+		name   string
+		keys   []Key
+		before bool
+		want   string
+	}{
+		{
+			name: "ascending",
+			keys: []Key{{Column: "date"}, {Column: "id"}},
+			want: "((date > ?) OR (date = ? AND id > ?))",
+		},
+		{
+			name:   "ascending before",
+			keys:   []Key{{Column: "date"}, {Column: "id"}},
+			before: true,
+			want:   "((date < ?) OR (date = ? AND id < ?))",
+		},
+		{
+			name: "descending",
+			keys: []Key{{Column: "date", Desc: true}, {Column: "id", Desc: true}},
+			want: "((date < ?) OR (date = ? AND id < ?))",
+		},
+		{
+			name:   "descending before",
+			keys:   []Key{{Column: "date", Desc: true}, {Column: "id", Desc: true}},
+			before: true,
+			want:   "((date > ?) OR (date = ? AND id > ?))",
+		},
+		{
+			name: "mixed",
+			keys: []Key{{Column: "date", Desc: true}, {Column: "id"}},
+			want: "((date < ?) OR (date = ? AND id > ?))",
+		},
+		{
+			name:   "mixed before",
+			keys:   []Key{{Column: "date", Desc: true}, {Column: "id"}},
+			before: true,
+			want:   "((date > ?) OR (date = ? AND id < ?))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := seekCondition(tt.keys, &Cursor{Values: values, Before: tt.before})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("seekCondition() = %q, want %q", got, tt.want)
+			}
+			if want := []interface{}{date, date, int64(42)}; !reflect.DeepEqual(args, want) {
+				t.Errorf("seekCondition() args = %v, want %v", args, want)
+			}
+		})
+	}
+
+	bad := &Cursor{Values: []Value{{Type: "time", Value: "yesterday"}, {Type: "int", Value: "42"}}}
+	if _, _, err := seekCondition([]Key{{Column: "date"}, {Column: "id"}}, bad); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("seekCondition() with a bad value = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestOrderClause(t *testing.T) {
+	keys := []Key{{Column: "date", Desc: true}, {Column: "amount_minor"}, {Column: "id", Desc: true}}
+	if got, want := orderClause(keys, false), "date DESC, amount_minor ASC, id DESC"; got != want {
+		t.Errorf("orderClause() = %q, want %q", got, want)
+	}
+	if got, want := orderClause(keys, true), "date ASC, amount_minor DESC, id ASC"; got != want {
+		t.Errorf("orderClause(reverse) = %q, want %q", got, want)
+	}
+}
+
+func TestLinkHeader(t *testing.T) {
+	u, err := url.Parse("https://api.example.com/api/expenses?category=Travel&limit=2&skip=20&cursor=old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		next, prev string
+		want       string
+	}{
+		{"both", "n.1", "p.1", `</api/expenses?category=Travel&cursor=n.1&limit=2>; rel="next", </api/expenses?category=Travel&cursor=p.1&limit=2>; rel="prev"`},
+		{"first page", "n.1", "", `</api/expenses?category=Travel&cursor=n.1&limit=2>; rel="next"`},
+		{"last page", "", "p.1", `</api/expenses?category=Travel&cursor=p.1&limit=2>; rel="prev"`},
+		{"only page", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LinkHeader(u, tt.next, tt.prev); got != tt.want {
+				t.Errorf("LinkHeader() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+	if u.Query().Get("cursor") != "old" {
+		t.Error("LinkHeader changed the request URL")
+	}
+}
+
+type entry struct {
+	ID   uint
+	Date time.Time
+}
+
+var entryKeys = []Key{{Column: "date", Desc: true}, {Column: "id", Desc: true}}
+
+const entrySort = "date:desc,id:desc"
+
+func entryValues(e entry) []interface{} { return []interface{}{e.Date, e.ID} }
+
+// entries returns a database of nine entries on four days, in listing order
+// 9 8 7 6 5 4 3 2 1 with several entries sharing a day
+func entries(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "page.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&entry{}); err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	for i, d := range []int{1, 1, 2, 2, 2, 3, 3, 4, 4} {
+		if err := db.Create(&entry{ID: uint(i + 1), Date: day(d)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func page(t *testing.T, db *gorm.DB, token string, offset, limit int) *Page[entry] {
+	t.Helper()
+	var cursor *Cursor
+	if token != "" {
+		var err error
+		if cursor, err = Decode(token); err != nil {
+			t.Fatalf("Decode(%q): %v", token, err)
+		}
+	}
+	p, err := Paginate(db.Model(&entry{}), entryKeys, entrySort, cursor, offset, limit, entryValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func ids(p *Page[entry]) string {
+	s := ""
+	for _, e := range p.Items {
+		s += fmt.Sprint(e.ID)
+	}
+	return s
+}
+
+func TestPaginateBothWays(t *testing.T) {
+	useSecret(t, "cursor-test-secret")
+	db := entries(t)
+
+	var forward []string
+	var pages []*Page[entry]
+	token := ""
+	for {
+		p := page(t, db, token, 0, 2)
+		forward = append(forward, ids(p))
+		pages = append(pages, p)
+		if p.Next == "" {
+			break
+		}
+		token = p.Next
+	}
+	if want := []string{"98", "76", "54", "32", "1"}; !reflect.DeepEqual(forward, want) {
+		t.Fatalf("pages forward = %v, want %v", forward, want)
+	}
+	if pages[0].Prev != "" {
+		t.Error("first page has a previous page")
+	}
+
+	var backward []string
+	token = pages[len(pages)-1].Prev
+	for token != "" {
+		p := page(t, db, token, 0, 2)
+		backward = append(backward, ids(p))
+		if p.Next == "" {
+			t.Errorf("page %s going back has no next page", ids(p))
+		}
+		token = p.Prev
+	}
+	if want := []string{"32", "54", "76", "98"}; !reflect.DeepEqual(backward, want) {
+		t.Errorf("pages backward = %v, want %v", backward, want)
+	}
+}
+
+func TestPaginateKeepsPositionWhenRowsChange(t *testing.T) {
+	useSecret(t, "cursor-test-secret")
+	db := entries(t)
+	first := page(t, db, "", 0, 3)
+	if ids(first) != "987" {
+		t.Fatalf("first page = %s", ids(first))
+	}
+
+	// A newer entry and a removed one between requests neither repeat nor skip rows
+	if err := db.Create(&entry{ID: 10, Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&entry{}, 8).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(page(t, db, first.Next, 0, 3)); got != "654" {
+		t.Errorf("second page = %s, want 654", got)
+	}
+}
+
+func TestPaginateWithOffset(t *testing.T) {
+	useSecret(t, "cursor-test-secret")
+	db := entries(t)
+	p := page(t, db, "", 3, 2)
+	if ids(p) != "65" {
+		t.Fatalf("page at offset 3 = %s, want 65", ids(p))
+	}
+	if p.Prev == "" || p.Next == "" {
+		t.Fatalf("page at offset 3 has next %q and prev %q", p.Next, p.Prev)
+	}
+	if got := ids(page(t, db, p.Prev, 0, 2)); got != "87" {
+		t.Errorf("page before offset 3 = %s, want 87", got)
+	}
+	if got := ids(page(t, db, "", 20, 2)); got != "" {
+		t.Errorf("page past the end = %s", got)
+	}
+}
+
+func TestPaginateRejectsCursorForAnotherSort(t *testing.T) {
+	useSecret(t, "cursor-test-secret")
+	db := entries(t)
+	next := page(t, db, "", 0, 2).Next
+	cursor, err := Decode(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		keys []Key
+		sort string
+	}{
+		{"other order", []Key{{Column: "date"}, {Column: "id"}}, "date:asc,id:asc"},
+		{"other column", []Key{{Column: "id", Desc: true}, {Column: "date", Desc: true}}, "id:desc,date:desc"},
+		{"fewer keys", []Key{{Column: "id", Desc: true}}, entrySort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Paginate(db.Model(&entry{}), tt.keys, tt.sort, cursor, 0, 2, entryValues)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Paginate() = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package payout
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NACHA files are made of 94-character records, grouped in blocks of ten
+const (
+	nachaRecordLength   = 94
+	nachaBlockingFactor = 10
+)
+
+// ACH credit transaction codes
+const (
+	nachaCheckingCredit = "22"
+	nachaSavingsCredit  = "32"
+)
+
+// nachaPunctuation is the punctuation allowed in NACHA alphanumeric fields
+const nachaPunctuation = "-.,/&' "
+
+// WriteNACHA writes the batch as a NACHA ACH file holding one PPD batch of
+// credits, one entry per transfer. The payer's routing number is used as both
+// the immediate destination and the originating bank.
+func WriteNACHA(w io.Writer, b Batch) error {
+	if err := b.check(FormatNACHA); err != nil {
+		return err
+	}
+	if !ValidRoutingNumber(b.Payer.RoutingNumber) {
+		return errors.New("payer routing number is invalid")
+	}
+	companyID := nachaText(b.Payer.CompanyID)
+	if companyID == "" || len(companyID) > 10 {
+		return errors.New("payer company ID must be 1 to 10 characters")
+	}
+
+	odfi := b.Payer.RoutingNumber[:8]
+	var records []string
+
+	// File header
+	records = append(records, "1"+"01"+
+		" "+b.Payer.RoutingNumber+
+		rightJustify(companyID, 10)+
+		b.CreatedAt.Format("060102")+b.CreatedAt.Format("1504")+
+		"A"+"094"+"10"+"1"+
+		pad("", 23)+
+		pad(nachaText(b.Payer.Name), 23)+
+		pad(nachaText(b.MessageID), 8))
+
+	// Batch header: service class 220 is credits only, PPD is consumer payments
+	records = append(records, "5"+"220"+
+		pad(nachaText(b.Payer.Name), 16)+
+		pad("", 20)+
+		pad(companyID, 10)+
+		"PPD"+
+		pad("EXPENSES", 10)+
+		pad("", 6)+
+		b.ExecutionDate.Format("060102")+
+		pad("", 3)+
+		"1"+
+		odfi+
+		zeroPad(1, 7))
+
+	var hash, total int64
+	for i, t := range b.Transfers {
+		if !ValidRoutingNumber(t.Payee.RoutingNumber) {
+			return errors.New("transfer " + strconv.Itoa(i+1) + " has an invalid routing number")
+		}
+		account := strings.ToUpper(t.Payee.AccountNumber)
+		if !ValidAccountNumber(account) {
+			return errors.New("transfer " + strconv.Itoa(i+1) + " has an invalid account number")
+		}
+		if t.AmountMinor > 9999999999 {
+			return errors.New("transfer " + strconv.Itoa(i+1) + " is too large for an ACH entry")
+		}
+
+		code := nachaCheckingCredit
+		if t.Payee.Savings {
+			code = nachaSavingsCredit
+		}
+		rdfi, _ := strconv.ParseInt(t.Payee.RoutingNumber[:8], 10, 64)
+		hash += rdfi
+		total += t.AmountMinor
+
+		records = append(records, "6"+code+
+			t.Payee.RoutingNumber+
+			pad(account, 17)+
+			zeroPad(t.AmountMinor, 10)+
+			pad(nachaText(t.PayeeID), 15)+
+			pad(nachaText(t.Payee.Name), 22)+
+			pad("", 2)+
+			"0"+
+			odfi+zeroPad(int64(i+1), 7))
+	}
+	// The entry hash keeps the ten lowest digits of the sum
+	hash %= 10000000000
+	entries := int64(len(b.Transfers))
+
+	// Batch control
+	records = append(records, "8"+"220"+
+		zeroPad(entries, 6)+
+		zeroPad(hash, 10)+
+		zeroPad(0, 12)+
+		zeroPad(total, 12)+
+		pad(companyID, 10)+
+		pad("", 19)+
+		pad("", 6)+
+		odfi+
+		zeroPad(1, 7))
+
+	// File control counts the blocks including itself and the padding
+	blocks := (int64(len(records)) + 1 + nachaBlockingFactor - 1) / nachaBlockingFactor
+	records = append(records, "9"+
+		zeroPad(1, 6)+
+		zeroPad(blocks, 6)+
+		zeroPad(entries, 8)+
+		zeroPad(hash, 10)+
+		zeroPad(0, 12)+
+		zeroPad(total, 12)+
+		pad("", 39))
+
+	for len(records)%nachaBlockingFactor != 0 {
+		records = append(records, strings.Repeat("9", nachaRecordLength))
+	}
+
+	out := bufio.NewWriter(w)
+	for _, record := range records {
+		if len(record) != nachaRecordLength {
+			return fmt.Errorf("internal error: %d-character NACHA record", len(record))
+		}
+		if _, err := out.WriteString(record + "\n"); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// nachaText upper-cases s and restricts it to the characters NACHA allows
+func nachaText(s string) string {
+	return strings.ToUpper(asciiText(s, nachaPunctuation))
+}
+
+// pad left-justifies s in a field of width characters, cutting it if longer
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+// rightJustify right-justifies s in a field of width characters
+func rightJustify(s string, width int) string {
+	s = truncate(s, width)
+	return strings.Repeat(" ", width-len(s)) + s
+}
+
+// zeroPad writes n with leading zeros in a field of width digits
+func zeroPad(n int64, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
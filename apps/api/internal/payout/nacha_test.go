@@ -0,0 +1,185 @@
+package payout
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nachaBatch(transfers ...Transfer) Batch {
+	return Batch{
+		MessageID:     "PAY42",
+		CreatedAt:     time.Date(2024, 3, 14, 9, 30, 0, 0, time.UTC),
+		ExecutionDate: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		Currency:      "USD",
+		Payer: Account{
+			Name:          "Acme Corp",
+			RoutingNumber: "021000021",
+			CompanyID:     "1234567890",
+		},
+		Transfers: transfers,
+	}
+}
+
+func nachaTransfer(routing string, amount int64) Transfer {
+	return Transfer{
+		Payee:       Account{Name: "Jane Doe", RoutingNumber: routing, AccountNumber: "12345678"},
+		AmountMinor: amount,
+		PayeeID:     "EMP1",
+	}
+}
+
+func TestWriteNACHA(t *testing.T) {
+	tests := []struct {
+		name      string
+		transfers []Transfer
+		lines     int
+		hash      int64
+		total     int64
+	}{
+		{
+			name:      "one entry",
+			transfers: []Transfer{nachaTransfer("011000015", 12345)},
+			lines:     10,
+			hash:      1100001,
+			total:     12345,
+		},
+		{
+			name: "three entries",
+			transfers: []Transfer{
+				nachaTransfer("011000015", 100),
+				nachaTransfer("026009593", 250000),
+				nachaTransfer("121000358", 1),
+			},
+			lines: 10,
+			hash:  1100001 + 2600959 + 12100035,
+			total: 250101,
+		},
+		{
+			name: "seven entries fill a second block",
+			transfers: []Transfer{
+				nachaTransfer("011000015", 1), nachaTransfer("011000015", 2),
+				nachaTransfer("011000015", 3), nachaTransfer("011000015", 4),
+				nachaTransfer("011000015", 5), nachaTransfer("011000015", 6),
+				nachaTransfer("011000015", 7),
+			},
+			lines: 20,
+			hash:  7 * 1100001,
+			total: 28,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteNACHA(&buf, nachaBatch(tt.transfers...)); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(lines) != tt.lines {
+				t.Fatalf("got %d lines, want %d", len(lines), tt.lines)
+			}
+			for i, line := range lines {
+				if len(line) != nachaRecordLength {
+					t.Errorf("line %d is %d characters long", i+1, len(line))
+				}
+			}
+
+			entries := 0
+			var amounts int64
+			var batchControl, fileControl string
+			for _, line := range lines {
+				switch line[0] {
+				case '6':
+					entries++
+					amount, _ := strconv.ParseInt(line[29:39], 10, 64)
+					amounts += amount
+				case '8':
+					batchControl = line
+				case '9':
+					if fileControl == "" {
+						fileControl = line
+					}
+				}
+			}
+			if entries != len(tt.transfers) {
+				t.Errorf("got %d entries, want %d", entries, len(tt.transfers))
+			}
+			if amounts != tt.total {
+				t.Errorf("entries add up to %d, want %d", amounts, tt.total)
+			}
+
+			if got := batchControl[4:10]; got != zeroPad(int64(len(tt.transfers)), 6) {
+				t.Errorf("batch control entry count = %s", got)
+			}
+			if got := batchControl[10:20]; got != zeroPad(tt.hash, 10) {
+				t.Errorf("batch control entry hash = %s, want %010d", got, tt.hash)
+			}
+			if got := batchControl[32:44]; got != zeroPad(tt.total, 12) {
+				t.Errorf("batch control credit total = %s, want %012d", got, tt.total)
+			}
+
+			if got := fileControl[7:13]; got != zeroPad(int64(tt.lines/nachaBlockingFactor), 6) {
+				t.Errorf("file control block count = %s", got)
+			}
+			if got := fileControl[13:21]; got != zeroPad(int64(len(tt.transfers)), 8) {
+				t.Errorf("file control entry count = %s", got)
+			}
+			if got := fileControl[21:31]; got != zeroPad(tt.hash, 10) {
+				t.Errorf("file control entry hash = %s, want %010d", got, tt.hash)
+			}
+			if got := fileControl[43:55]; got != zeroPad(tt.total, 12) {
+				t.Errorf("file control credit total = %s, want %012d", got, tt.total)
+			}
+		})
+	}
+}
+
+func TestWriteNACHAEntryHashKeepsTenDigits(t *testing.T) {
+	// Enough large routing prefixes add up to more than ten digits
+	var transfers []Transfer
+	for i := 0; i < 1300; i++ {
+		transfers = append(transfers, nachaTransfer("999999992", 1))
+	}
+	var buf bytes.Buffer
+	if err := WriteNACHA(&buf, nachaBatch(transfers...)); err != nil {
+		t.Fatal(err)
+	}
+	want := zeroPad(1300*99999999%10000000000, 10)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "8") && line[10:20] != want {
+			t.Errorf("entry hash = %s, want %s", line[10:20], want)
+		}
+	}
+}
+
+func TestWriteNACHARejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch Batch
+		err   string
+	}{
+		{"wrong currency", func() Batch {
+			b := nachaBatch(nachaTransfer("011000015", 100))
+			b.Currency = "EUR"
+			return b
+		}(), "nacha files pay out in USD only"},
+		{"no transfers", nachaBatch(), "batch has no transfers"},
+		{"zero amount", nachaBatch(nachaTransfer("011000015", 0)), "transfer amounts must be positive"},
+		{"bad payee routing", nachaBatch(nachaTransfer("011000016", 100)), "transfer 1 has an invalid routing number"},
+		{"bad payer routing", func() Batch {
+			b := nachaBatch(nachaTransfer("011000015", 100))
+			b.Payer.RoutingNumber = "021000022"
+			return b
+		}(), "payer routing number is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WriteNACHA(&bytes.Buffer{}, tt.batch)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package payout writes the files a bank is sent to make a batch of credit
+// transfers: SEPA pain.001 XML for euro transfers within the SEPA area and
+// NACHA ACH files for dollar transfers within the United States.
+package payout
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Payout file formats.
+const (
+	FormatSEPA  Format = "sepa"
+	FormatNACHA Format = "nacha"
+)
+
+// Format is a payout file format.
+type Format string
+
+// Currency returns the only currency a format can pay out in.
+func (f Format) Currency() string {
+	switch f {
+	case FormatSEPA:
+		return "EUR"
+	case FormatNACHA:
+		return "USD"
+	}
+	return ""
+}
+
+// Account is the payer's or a payee's bank account. SEPA files use IBAN and
+// BIC; NACHA files use RoutingNumber, AccountNumber and AccountType.
+type Account struct {
+	Name          string
+	IBAN          string
+	BIC           string
+	RoutingNumber string
+	AccountNumber string
+	// Savings selects a savings rather than a checking account in NACHA files
+	Savings bool
+	// CompanyID identifies the payer to its bank in NACHA files
+	CompanyID string
+}
+
+// Transfer is one credit transfer of a batch.
+type Transfer struct {
+	Payee       Account
+	AmountMinor int64
+	// EndToEndID is passed on to the payee's bank and shows on both statements
+	EndToEndID string
+	// PayeeID is the payer's own identifier for the payee
+	PayeeID string
+	// Remittance is the free text shown to the payee
+	Remittance string
+}
+
+// Batch is the content of a payout file.
+type Batch struct {
+	// MessageID identifies the file to the payer's bank and must be unique per payer
+	MessageID     string
+	CreatedAt     time.Time
+	ExecutionDate time.Time
+	Currency      string
+	Payer         Account
+	Transfers     []Transfer
+}
+
+// Total returns the sum of the batch's transfers in minor units
+func (b Batch) Total() int64 {
+	var total int64
+	for _, t := range b.Transfers {
+		total += t.AmountMinor
+	}
+	return total
+}
+
+func (b Batch) check(f Format) error {
+	if b.Currency != f.Currency() {
+		return errors.New(string(f) + " files pay out in " + f.Currency() + " only")
+	}
+	if len(b.Transfers) == 0 {
+		return errors.New("batch has no transfers")
+	}
+	for _, t := range b.Transfers {
+		if t.AmountMinor <= 0 {
+			return errors.New("transfer amounts must be positive")
+		}
+	}
+	return nil
+}
+
+// NormalizeIBAN removes spaces from an IBAN and upper-cases it
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// ValidIBAN reports whether iban, in normalized form, is well-formed and
+// passes its ISO 13616 mod-97 check.
+func ValidIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	for i, r := range iban {
+		switch {
+		case i < 2 && (r < 'A' || r > 'Z'):
+			return false
+		case i >= 2 && i < 4 && (r < '0' || r > '9'):
+			return false
+		case (r < 'A' || r > 'Z') && (r < '0' || r > '9'):
+			return false
+		}
+	}
+
+	// Move the country code and check digits to the end and read letters as 10..35
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(big.NewInt(int64(r - 'A' + 10)).String())
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// ValidBIC reports whether bic is an 8 or 11 character ISO 9362 business identifier code
+func ValidBIC(bic string) bool {
+	if len(bic) != 8 && len(bic) != 11 {
+		return false
+	}
+	for i, r := range bic {
+		if i < 6 && (r < 'A' || r > 'Z') {
+			return false
+		}
+		if i >= 6 && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidRoutingNumber reports whether routing is a nine-digit ABA routing
+// number with a correct check digit.
+func ValidRoutingNumber(routing string) bool {
+	if len(routing) != 9 || !onlyDigits(routing) {
+		return false
+	}
+	weights := []int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i, r := range routing {
+		sum += int(r-'0') * weights[i]
+	}
+	return sum%10 == 0
+}
+
+// ValidAccountNumber reports whether account can be carried in a NACHA entry
+func ValidAccountNumber(account string) bool {
+	if account == "" || len(account) > 17 {
+		return false
+	}
+	for _, r := range account {
+		if !unicode.IsDigit(r) && (r < 'A' || r > 'Z') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func onlyDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// transliterations spell letters outside the basic Latin alphabet, which
+// neither format allows, the way banks expect them.
+var transliterations = map[rune]string{
+	'Ä': "AE", 'Ö': "OE", 'Ü': "UE", 'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss",
+	'Æ': "AE", 'æ': "ae", 'Ø': "OE", 'ø': "oe", 'Å': "AA", 'å': "aa",
+	'Œ': "OE", 'œ': "oe", 'Ł': "L", 'ł': "l", 'Đ': "D", 'đ': "d",
+}
+
+// asciiText spells s in basic Latin letters, digits and the punctuation in
+// allowed, dropping accents and replacing anything else with a space.
+func asciiText(s, allowed string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		r = stripAccent(r)
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case strings.ContainsRune(allowed, r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// stripAccent returns the unaccented letter of common accented Latin letters
+func stripAccent(r rune) rune {
+	const (
+		accented = "ÀÁÂÃÇÈÉÊËÌÍÎÏÑÒÓÔÕÙÚÛÝàáâãçèéêëìíîïñòóôõùúûýÿ"
+		plain    = "AAAACEEEEIIIINOOOOUUUYaaaaceeeeiiiinoooouuuyy"
+	)
+	if i := strings.IndexRune(accented, r); i >= 0 {
+		return rune(plain[len([]rune(accented[:i]))])
+	}
+	return r
+}
+
+// truncate cuts s to at most max characters
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
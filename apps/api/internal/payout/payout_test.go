@@ -0,0 +1,79 @@
+package payout
+
+import "testing"
+
+func TestValidIBAN(t *testing.T) {
+	tests := []struct {
+		iban string
+		want bool
+	}{
+		{"DE89370400440532013000", true},
+		{"GB82WEST12345698765432", true},
+		{"FR1420041010050500013M02606", true},
+		{"NL91ABNA0417164300", true},
+		{"DE88370400440532013000", false},
+		{"GB82WEST12345698765431", false},
+		{"de89370400440532013000", false},
+		{"DE8937040044", false},
+		{"D189370400440532013000", false},
+		{"DEX9370400440532013000", false},
+		{"DE89-370400440532013000", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidIBAN(tt.iban); got != tt.want {
+			t.Errorf("ValidIBAN(%q) = %v, want %v", tt.iban, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeIBAN(t *testing.T) {
+	got := NormalizeIBAN(" de89 3704 0044\t0532 0130 00 ")
+	if got != "DE89370400440532013000" {
+		t.Errorf("NormalizeIBAN = %q", got)
+	}
+	if !ValidIBAN(got) {
+		t.Errorf("normalized IBAN %q is not valid", got)
+	}
+}
+
+func TestValidRoutingNumber(t *testing.T) {
+	tests := []struct {
+		routing string
+		want    bool
+	}{
+		{"021000021", true},
+		{"011000015", true},
+		{"026009593", true},
+		{"121000358", true},
+		{"021000022", false},
+		{"011000016", false},
+		{"02100002", false},
+		{"0210000210", false},
+		{"02100002A", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRoutingNumber(tt.routing); got != tt.want {
+			t.Errorf("ValidRoutingNumber(%q) = %v, want %v", tt.routing, got, tt.want)
+		}
+	}
+}
+
+func TestValidBIC(t *testing.T) {
+	tests := []struct {
+		bic  string
+		want bool
+	}{
+		{"DEUTDEFF", true},
+		{"DEUTDEFF500", true},
+		{"DEUTDEF", false},
+		{"DEUT1EFF", false},
+		{"DEUTDEFF50", false},
+	}
+	for _, tt := range tests {
+		if got := ValidBIC(tt.bic); got != tt.want {
+			t.Errorf("ValidBIC(%q) = %v, want %v", tt.bic, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package payout
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// sepaNamespace is the ISO 20022 customer credit transfer initiation version
+// every SEPA bank accepts
+const sepaNamespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// sepaPunctuation is the punctuation of the SEPA character set
+const sepaPunctuation = "/-?:().,'+ "
+
+type sepaDocument struct {
+	XMLName  xml.Name        `xml:"Document"`
+	Xmlns    string          `xml:"xmlns,attr"`
+	Header   sepaGroupHeader `xml:"CstmrCdtTrfInitn>GrpHdr"`
+	Payments sepaPaymentInfo `xml:"CstmrCdtTrfInitn>PmtInf"`
+}
+
+type sepaGroupHeader struct {
+	MessageID      string `xml:"MsgId"`
+	CreatedAt      string `xml:"CreDtTm"`
+	Transactions   int    `xml:"NbOfTxs"`
+	ControlSum     string `xml:"CtrlSum"`
+	InitiatingName string `xml:"InitgPty>Nm"`
+}
+
+type sepaPaymentInfo struct {
+	ID            string         `xml:"PmtInfId"`
+	Method        string         `xml:"PmtMtd"`
+	BatchBooking  bool           `xml:"BtchBookg"`
+	Transactions  int            `xml:"NbOfTxs"`
+	ControlSum    string         `xml:"CtrlSum"`
+	ServiceLevel  string         `xml:"PmtTpInf>SvcLvl>Cd"`
+	ExecutionDate string         `xml:"ReqdExctnDt"`
+	DebtorName    string         `xml:"Dbtr>Nm"`
+	DebtorIBAN    string         `xml:"DbtrAcct>Id>IBAN"`
+	DebtorAgent   sepaAgent      `xml:"DbtrAgt>FinInstnId"`
+	ChargeBearer  string         `xml:"ChrgBr"`
+	Transfers     []sepaTransfer `xml:"CdtTrfTxInf"`
+}
+
+// sepaAgent names a bank by BIC, or says it is not provided as SEPA allows
+// for transfers within the EEA
+type sepaAgent struct {
+	BIC   string `xml:"BIC,omitempty"`
+	Other string `xml:"Othr>Id,omitempty"`
+}
+
+type sepaAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type sepaTransfer struct {
+	EndToEndID   string     `xml:"PmtId>EndToEndId"`
+	Amount       sepaAmount `xml:"Amt>InstdAmt"`
+	CreditorBIC  string     `xml:"CdtrAgt>FinInstnId>BIC,omitempty"`
+	CreditorName string     `xml:"Cdtr>Nm"`
+	CreditorIBAN string     `xml:"CdtrAcct>Id>IBAN"`
+	Remittance   string     `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+// WriteSEPA writes the batch as a SEPA credit transfer initiation
+// (pain.001.001.03) with a single payment information block.
+func WriteSEPA(w io.Writer, b Batch) error {
+	if err := b.check(FormatSEPA); err != nil {
+		return err
+	}
+	if !ValidIBAN(b.Payer.IBAN) {
+		return errors.New("payer IBAN is invalid")
+	}
+
+	total := money.Format(b.Total(), b.Currency)
+	doc := sepaDocument{
+		Xmlns: sepaNamespace,
+		Header: sepaGroupHeader{
+			MessageID:      sepaText(b.MessageID, 35),
+			CreatedAt:      b.CreatedAt.UTC().Format("2006-01-02T15:04:05"),
+			Transactions:   len(b.Transfers),
+			ControlSum:     total,
+			InitiatingName: sepaText(b.Payer.Name, 70),
+		},
+		Payments: sepaPaymentInfo{
+			ID:            sepaText(b.MessageID, 35),
+			Method:        "TRF",
+			BatchBooking:  true,
+			Transactions:  len(b.Transfers),
+			ControlSum:    total,
+			ServiceLevel:  "SEPA",
+			ExecutionDate: b.ExecutionDate.Format("2006-01-02"),
+			DebtorName:    sepaText(b.Payer.Name, 70),
+			DebtorIBAN:    b.Payer.IBAN,
+			DebtorAgent:   sepaAgent{BIC: b.Payer.BIC},
+			ChargeBearer:  "SLEV",
+		},
+	}
+	if b.Payer.BIC == "" {
+		doc.Payments.DebtorAgent = sepaAgent{Other: "NOTPROVIDED"}
+	}
+
+	for i, t := range b.Transfers {
+		if !ValidIBAN(t.Payee.IBAN) {
+			return errors.New("transfer " + strconv.Itoa(i+1) + " has an invalid IBAN")
+		}
+		doc.Payments.Transfers = append(doc.Payments.Transfers, sepaTransfer{
+			EndToEndID:   sepaText(t.EndToEndID, 35),
+			Amount:       sepaAmount{Currency: b.Currency, Value: money.Format(t.AmountMinor, b.Currency)},
+			CreditorBIC:  t.Payee.BIC,
+			CreditorName: sepaText(t.Payee.Name, 70),
+			CreditorIBAN: t.Payee.IBAN,
+			Remittance:   sepaText(t.Remittance, 140),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sepaText restricts s to the SEPA character set and max characters
+func sepaText(s string, max int) string {
+	return truncate(asciiText(s, sepaPunctuation), max)
+}
@@ -0,0 +1,133 @@
+package payout
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func sepaBatch(amounts ...int64) Batch {
+	b := Batch{
+		MessageID:     "PAY42",
+		CreatedAt:     time.Date(2024, 3, 14, 9, 30, 0, 0, time.UTC),
+		ExecutionDate: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		Currency:      "EUR",
+		Payer:         Account{Name: "Acme GmbH", IBAN: "DE89370400440532013000", BIC: "COBADEFF"},
+	}
+	for _, amount := range amounts {
+		b.Transfers = append(b.Transfers, Transfer{
+			Payee:       Account{Name: "Jörg Müller", IBAN: "NL91ABNA0417164300"},
+			AmountMinor: amount,
+			EndToEndID:  "EXP-1",
+			Remittance:  "Expenses March",
+		})
+	}
+	return b
+}
+
+func TestWriteSEPA(t *testing.T) {
+	tests := []struct {
+		name    string
+		amounts []int64
+		sum     string
+	}{
+		{"one transfer", []int64{12345}, "123.45"},
+		{"several transfers", []int64{100, 5, 250000}, "2501.05"},
+		{"whole euros", []int64{1000, 2000}, "30.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteSEPA(&buf, sepaBatch(tt.amounts...)); err != nil {
+				t.Fatal(err)
+			}
+			var doc sepaDocument
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			if doc.Xmlns != sepaNamespace {
+				t.Errorf("namespace = %q", doc.Xmlns)
+			}
+			if doc.Header.Transactions != len(tt.amounts) {
+				t.Errorf("group header NbOfTxs = %d, want %d", doc.Header.Transactions, len(tt.amounts))
+			}
+			if doc.Header.ControlSum != tt.sum {
+				t.Errorf("group header CtrlSum = %s, want %s", doc.Header.ControlSum, tt.sum)
+			}
+			if doc.Payments.Transactions != len(tt.amounts) {
+				t.Errorf("payment NbOfTxs = %d, want %d", doc.Payments.Transactions, len(tt.amounts))
+			}
+			if doc.Payments.ControlSum != tt.sum {
+				t.Errorf("payment CtrlSum = %s, want %s", doc.Payments.ControlSum, tt.sum)
+			}
+			if len(doc.Payments.Transfers) != len(tt.amounts) {
+				t.Fatalf("got %d transfers, want %d", len(doc.Payments.Transfers), len(tt.amounts))
+			}
+			for i, tr := range doc.Payments.Transfers {
+				if tr.Amount.Currency != "EUR" {
+					t.Errorf("transfer %d currency = %q", i+1, tr.Amount.Currency)
+				}
+				if tr.CreditorName != "Joerg Mueller" {
+					t.Errorf("transfer %d creditor = %q", i+1, tr.CreditorName)
+				}
+			}
+			if doc.Payments.ExecutionDate != "2024-03-15" {
+				t.Errorf("execution date = %q", doc.Payments.ExecutionDate)
+			}
+			if doc.Payments.DebtorAgent.BIC != "COBADEFF" {
+				t.Errorf("debtor agent = %+v", doc.Payments.DebtorAgent)
+			}
+		})
+	}
+}
+
+func TestWriteSEPAWithoutPayerBIC(t *testing.T) {
+	b := sepaBatch(100)
+	b.Payer.BIC = ""
+	var buf bytes.Buffer
+	if err := WriteSEPA(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	var doc sepaDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Payments.DebtorAgent.Other != "NOTPROVIDED" || doc.Payments.DebtorAgent.BIC != "" {
+		t.Errorf("debtor agent = %+v", doc.Payments.DebtorAgent)
+	}
+}
+
+func TestWriteSEPARejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch Batch
+		err   string
+	}{
+		{"wrong currency", func() Batch {
+			b := sepaBatch(100)
+			b.Currency = "USD"
+			return b
+		}(), "sepa files pay out in EUR only"},
+		{"negative amount", sepaBatch(100, -5), "transfer amounts must be positive"},
+		{"bad payer IBAN", func() Batch {
+			b := sepaBatch(100)
+			b.Payer.IBAN = "DE88370400440532013000"
+			return b
+		}(), "payer IBAN is invalid"},
+		{"bad payee IBAN", func() Batch {
+			b := sepaBatch(100, 200)
+			b.Transfers[1].Payee.IBAN = "NL91ABNA0417164301"
+			return b
+		}(), "transfer 2 has an invalid IBAN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WriteSEPA(&bytes.Buffer{}, tt.batch)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
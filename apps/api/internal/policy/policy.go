@@ -0,0 +1,304 @@
+// Package policy evaluates expense policy rules written in a small
+// declarative JSON language. A condition is either a comparison of one
+// expense field with a value or a combination of other conditions:
+//
+//	{"field": "amount_per_person", "op": "gt", "value": 75}
+//	{"all": [{"field": "category", "op": "eq", "value": "Travel"},
+//	         {"field": "days_booked_ahead", "op": "lt", "value": 7}]}
+//	{"any": [...]}, {"not": {...}}
+//
+// A rule is violated when its condition holds.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Kind is the type of a field's values
+type Kind int
+
+const (
+	// Number fields hold exact decimals, such as amounts in the base currency
+	Number Kind = iota
+	// Text fields hold strings and compare case-insensitively
+	Text
+)
+
+// Fields lists the expense fields conditions may refer to
+var Fields = map[string]Kind{
+	"amount":            Number,
+	"amount_per_person": Number,
+	"attendees":         Number,
+	"attachments":       Number,
+	"days_booked_ahead": Number,
+	"type":              Text,
+	"currency":          Text,
+	"category":          Text,
+	"description":       Text,
+	"client_notes":      Text,
+	"text":              Text,
+}
+
+// Op is a comparison operator
+type Op string
+
+const (
+	Eq          Op = "eq"
+	Ne          Op = "ne"
+	Gt          Op = "gt"
+	Gte         Op = "gte"
+	Lt          Op = "lt"
+	Lte         Op = "lte"
+	In          Op = "in"
+	NotIn       Op = "not_in"
+	Contains    Op = "contains"
+	ContainsAny Op = "contains_any"
+)
+
+// ops lists the operators each kind of field supports
+var ops = map[Kind]map[Op]bool{
+	Number: {Eq: true, Ne: true, Gt: true, Gte: true, Lt: true, Lte: true, In: true, NotIn: true},
+	Text:   {Eq: true, Ne: true, In: true, NotIn: true, Contains: true, ContainsAny: true},
+}
+
+// Condition is one node of a rule's condition. Exactly one of All, Any, Not
+// or a comparison of Field using Op with Value is set.
+type Condition struct {
+	All   []Condition     `json:"all,omitempty"`
+	Any   []Condition     `json:"any,omitempty"`
+	Not   *Condition      `json:"not,omitempty"`
+	Field string          `json:"field,omitempty"`
+	Op    Op              `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+
+	numbers []*big.Rat
+	texts   []string
+}
+
+// Facts are the values of an expense's fields. Number fields hold *big.Rat,
+// text fields hold string.
+type Facts map[string]interface{}
+
+// Parse reads and validates a condition
+func Parse(data []byte) (*Condition, error) {
+	var c Condition
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid condition: %v", err)
+	}
+	if err := c.compile("condition"); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// compile checks the condition and parses its values; path locates it in error messages
+func (c *Condition) compile(path string) error {
+	set := 0
+	if c.All != nil {
+		set++
+	}
+	if c.Any != nil {
+		set++
+	}
+	if c.Not != nil {
+		set++
+	}
+	if c.Field != "" || c.Op != "" || c.Value != nil {
+		set++
+	}
+	if set != 1 {
+		return fmt.Errorf("invalid condition: %s must have exactly one of all, any, not or field", path)
+	}
+
+	switch {
+	case c.All != nil, c.Any != nil:
+		list, name := c.All, "all"
+		if c.Any != nil {
+			list, name = c.Any, "any"
+		}
+		if len(list) == 0 {
+			return fmt.Errorf("invalid condition: %s.%s is empty", path, name)
+		}
+		for i := range list {
+			if err := list[i].compile(fmt.Sprintf("%s.%s[%d]", path, name, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case c.Not != nil:
+		return c.Not.compile(path + ".not")
+	}
+
+	kind, ok := Fields[c.Field]
+	if !ok {
+		return fmt.Errorf("invalid condition: %s has unknown field %q, expected one of %s", path, c.Field, strings.Join(fieldNames(), ", "))
+	}
+	if !ops[kind][c.Op] {
+		return fmt.Errorf("invalid condition: %s cannot use operator %q on %s", path, c.Op, c.Field)
+	}
+
+	var raw []json.RawMessage
+	if c.Op == In || c.Op == NotIn || c.Op == ContainsAny {
+		if err := json.Unmarshal(c.Value, &raw); err != nil || len(raw) == 0 {
+			return fmt.Errorf("invalid condition: %s needs a non-empty list value for %q", path, c.Op)
+		}
+	} else {
+		raw = []json.RawMessage{c.Value}
+	}
+
+	for _, r := range raw {
+		if kind == Number {
+			n, ok := parseNumber(r)
+			if !ok {
+				return fmt.Errorf("invalid condition: %s compares %s with a value that is not a number", path, c.Field)
+			}
+			c.numbers = append(c.numbers, n)
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(r, &s); err != nil {
+			return fmt.Errorf("invalid condition: %s compares %s with a value that is not a string", path, c.Field)
+		}
+		c.texts = append(c.texts, strings.ToLower(strings.TrimSpace(s)))
+	}
+	return nil
+}
+
+// Matches reports whether the condition holds for the facts. The condition
+// must come from Parse.
+func (c *Condition) Matches(facts Facts) bool {
+	switch {
+	case c.All != nil:
+		for i := range c.All {
+			if !c.All[i].Matches(facts) {
+				return false
+			}
+		}
+		return true
+	case c.Any != nil:
+		for i := range c.Any {
+			if c.Any[i].Matches(facts) {
+				return true
+			}
+		}
+		return false
+	case c.Not != nil:
+		return !c.Not.Matches(facts)
+	}
+
+	if Fields[c.Field] == Number {
+		value, ok := facts[c.Field].(*big.Rat)
+		if !ok {
+			return false
+		}
+		return compareNumber(c.Op, value, c.numbers)
+	}
+	value, ok := facts[c.Field].(string)
+	if !ok {
+		return false
+	}
+	return compareText(c.Op, strings.ToLower(value), c.texts)
+}
+
+// Uses reports whether the condition refers to the field anywhere
+func (c *Condition) Uses(field string) bool {
+	for i := range c.All {
+		if c.All[i].Uses(field) {
+			return true
+		}
+	}
+	for i := range c.Any {
+		if c.Any[i].Uses(field) {
+			return true
+		}
+	}
+	if c.Not != nil {
+		return c.Not.Uses(field)
+	}
+	return c.Field == field
+}
+
+func compareNumber(op Op, value *big.Rat, operands []*big.Rat) bool {
+	cmp := value.Cmp(operands[0])
+	switch op {
+	case Eq:
+		return cmp == 0
+	case Ne:
+		return cmp != 0
+	case Gt:
+		return cmp > 0
+	case Gte:
+		return cmp >= 0
+	case Lt:
+		return cmp < 0
+	case Lte:
+		return cmp <= 0
+	case In, NotIn:
+		found := false
+		for _, o := range operands {
+			if value.Cmp(o) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (op == In)
+	}
+	return false
+}
+
+func compareText(op Op, value string, operands []string) bool {
+	switch op {
+	case Eq:
+		return value == operands[0]
+	case Ne:
+		return value != operands[0]
+	case Contains:
+		return strings.Contains(value, operands[0])
+	case In, NotIn:
+		found := false
+		for _, o := range operands {
+			if value == o {
+				found = true
+				break
+			}
+		}
+		return found == (op == In)
+	case ContainsAny:
+		for _, o := range operands {
+			if strings.Contains(value, o) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseNumber accepts a JSON number or a decimal string
+func parseNumber(raw json.RawMessage) (*big.Rat, bool) {
+	text := string(bytes.TrimSpace(raw))
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		text = strings.TrimSpace(s)
+	}
+	if text == "" {
+		return nil, false
+	}
+	n, ok := new(big.Rat).SetString(text)
+	return n, ok
+}
+
+func fieldNames() []string {
+	names := make([]string, 0, len(Fields))
+	for name := range Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func rat(s string) *big.Rat {
+	n, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("bad number " + s)
+	}
+	return n
+}
+
+// dinner is a team dinner for three booked as travel three days ahead
+func dinner() Facts {
+	return Facts{
+		"amount":            rat("120.50"),
+		"amount_per_person": new(big.Rat).Quo(rat("120.50"), rat("3")),
+		"attendees":         rat("3"),
+		"attachments":       rat("0"),
+		"days_booked_ahead": rat("3"),
+		"type":              "standard",
+		"currency":          "USD",
+		"category":          "Travel",
+		"description":       "Team dinner with Wine",
+		"client_notes":      "",
+		"text":              "Team dinner with Wine\n",
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+	}{
+		{"eq number", `{"field": "amount", "op": "eq", "value": 120.5}`, true},
+		{"eq decimal string", `{"field": "amount", "op": "eq", "value": "120.50"}`, true},
+		{"eq exact fraction", `{"field": "amount_per_person", "op": "eq", "value": "40.1666666667"}`, false},
+		{"ne number", `{"field": "amount", "op": "ne", "value": 120.5}`, false},
+		{"gt", `{"field": "amount", "op": "gt", "value": "120.49"}`, true},
+		{"gt at the limit", `{"field": "amount", "op": "gt", "value": "120.50"}`, false},
+		{"gte at the limit", `{"field": "amount", "op": "gte", "value": "120.50"}`, true},
+		{"lt", `{"field": "attendees", "op": "lt", "value": 3}`, false},
+		{"lte", `{"field": "attendees", "op": "lte", "value": 3}`, true},
+		{"in numbers", `{"field": "attendees", "op": "in", "value": [1, 2, 3]}`, true},
+		{"not_in numbers", `{"field": "attendees", "op": "not_in", "value": [1, 2, 3]}`, false},
+		{"eq text ignores case", `{"field": "category", "op": "eq", "value": "travel"}`, true},
+		{"eq text trims the value", `{"field": "currency", "op": "eq", "value": " usd "}`, true},
+		{"ne text", `{"field": "type", "op": "ne", "value": "mileage"}`, true},
+		{"in text", `{"field": "currency", "op": "in", "value": ["EUR", "GBP"]}`, false},
+		{"not_in text", `{"field": "currency", "op": "not_in", "value": ["EUR", "GBP"]}`, true},
+		{"contains", `{"field": "description", "op": "contains", "value": "WINE"}`, true},
+		{"contains_any", `{"field": "text", "op": "contains_any", "value": ["beer", "wine"]}`, true},
+		{"contains_any without a hit", `{"field": "text", "op": "contains_any", "value": ["beer", "liquor"]}`, false},
+		{"empty text", `{"field": "client_notes", "op": "eq", "value": ""}`, true},
+
+		{"all", `{"all": [{"field": "category", "op": "eq", "value": "Travel"}, {"field": "amount", "op": "gt", "value": 100}]}`, true},
+		{"all with one false", `{"all": [{"field": "category", "op": "eq", "value": "Travel"}, {"field": "amount", "op": "gt", "value": 500}]}`, false},
+		{"any", `{"any": [{"field": "currency", "op": "eq", "value": "EUR"}, {"field": "attachments", "op": "eq", "value": 0}]}`, true},
+		{"any all false", `{"any": [{"field": "currency", "op": "eq", "value": "EUR"}, {"field": "attachments", "op": "gt", "value": 0}]}`, false},
+		{"not", `{"not": {"field": "currency", "op": "eq", "value": "USD"}}`, false},
+		{"not not", `{"not": {"not": {"field": "currency", "op": "eq", "value": "USD"}}}`, true},
+		{"nested", `{"all": [
+			{"any": [{"field": "category", "op": "eq", "value": "Meals"}, {"field": "text", "op": "contains", "value": "dinner"}]},
+			{"not": {"field": "amount_per_person", "op": "lte", "value": 40}}
+		]}`, true},
+
+		{"booked less than a week ahead", `{"all": [{"field": "category", "op": "eq", "value": "Travel"}, {"field": "days_booked_ahead", "op": "lt", "value": 7}]}`, true},
+		{"booked at least a week ahead", `{"field": "days_booked_ahead", "op": "gte", "value": 7}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse([]byte(tt.condition))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := c.Matches(dinner()); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesDaysBookedAhead(t *testing.T) {
+	c, err := Parse([]byte(`{"field": "days_booked_ahead", "op": "lt", "value": 14}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		days string
+		want bool
+	}{
+		{"0", true},
+		{"13", true},
+		{"14", false},
+		{"30", false},
+		// Recorded after the date it is for, e.g. a claim for last week's taxi
+		{"-5", true},
+	} {
+		facts := dinner()
+		facts["days_booked_ahead"] = rat(tt.days)
+		if got := c.Matches(facts); got != tt.want {
+			t.Errorf("days_booked_ahead %s: Matches() = %v, want %v", tt.days, got, tt.want)
+		}
+	}
+}
+
+// A fact of the wrong type or a missing fact never breaks a rule
+func TestMatchesWithMismatchedFacts(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		field     string
+		value     interface{}
+	}{
+		{"number field holding text", `{"field": "amount", "op": "gt", "value": 0}`, "amount", "120.50"},
+		{"number field holding a float", `{"field": "amount", "op": "gt", "value": 0}`, "amount", 120.5},
+		{"text field holding a number", `{"field": "currency", "op": "ne", "value": "EUR"}`, "currency", rat("1")},
+		{"missing number", `{"field": "attendees", "op": "lt", "value": 100}`, "attendees", nil},
+		{"missing text", `{"field": "category", "op": "ne", "value": "Meals"}`, "category", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse([]byte(tt.condition))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			facts := dinner()
+			if tt.value == nil {
+				delete(facts, tt.field)
+			} else {
+				facts[tt.field] = tt.value
+			}
+			if c.Matches(facts) {
+				t.Error("Matches() = true, want false")
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		// want is part of the error message, which points at the offending node
+		want string
+	}{
+		{"not JSON", `{"field": `, "invalid condition"},
+		{"unknown key", `{"field": "amount", "op": "gt", "value": 1, "unit": "USD"}`, `unknown field "unit"`},
+		{"unknown field", `{"field": "merchant", "op": "eq", "value": "Uber"}`, `condition has unknown field "merchant"`},
+		{"field in the wrong case", `{"field": "Amount", "op": "gt", "value": 1}`, `unknown field "Amount"`},
+		{"unknown operator", `{"field": "amount", "op": "between", "value": 1}`, `cannot use operator "between" on amount`},
+		{"text operator on a number", `{"field": "amount", "op": "contains", "value": "1"}`, `cannot use operator "contains" on amount`},
+		{"number operator on text", `{"field": "category", "op": "gt", "value": "A"}`, `cannot use operator "gt" on category`},
+		{"text compared with a number", `{"field": "currency", "op": "eq", "value": 840}`, "currency with a value that is not a string"},
+		{"number compared with text", `{"field": "amount", "op": "gt", "value": "lots"}`, "amount with a value that is not a number"},
+		{"number compared with a bool", `{"field": "attendees", "op": "eq", "value": true}`, "not a number"},
+		{"number compared with an empty string", `{"field": "amount", "op": "gt", "value": ""}`, "not a number"},
+		{"missing value", `{"field": "amount", "op": "gt"}`, "not a number"},
+		{"list operator with one value", `{"field": "currency", "op": "in", "value": "USD"}`, `non-empty list value for "in"`},
+		{"empty list", `{"field": "text", "op": "contains_any", "value": []}`, `non-empty list value for "contains_any"`},
+		{"mixed list", `{"field": "attendees", "op": "in", "value": [1, "two"]}`, "not a number"},
+		{"empty condition", `{}`, "condition must have exactly one of"},
+		{"all and field", `{"all": [{"field": "amount", "op": "gt", "value": 1}], "field": "amount"}`, "condition must have exactly one of"},
+		{"empty all", `{"all": []}`, "condition.all is empty"},
+		{"empty any", `{"any": []}`, "condition.any is empty"},
+		{"nested error", `{"all": [{"field": "amount", "op": "gt", "value": 1}, {"not": {"field": "nope", "op": "eq", "value": 1}}]}`, `condition.all[1].not has unknown field "nope"`},
+		{"nested in any", `{"any": [{"any": [{"field": "amount", "op": "lt"}]}]}`, "condition.any[0].any[0] compares amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.condition))
+			if err == nil {
+				t.Fatal("Parse succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUses(t *testing.T) {
+	c, err := Parse([]byte(`{"all": [
+		{"field": "amount", "op": "gt", "value": 100},
+		{"any": [{"field": "category", "op": "eq", "value": "Meals"}, {"not": {"field": "attachments", "op": "gt", "value": 0}}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]bool{"amount": true, "category": true, "attachments": true, "currency": false} {
+		if got := c.Uses(field); got != want {
+			t.Errorf("Uses(%q) = %v, want %v", field, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package recurrence
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// MinOccurrences is how many times a charge must have been seen before
+// Detect proposes a schedule for it
+const MinOccurrences = 3
+
+// regularShare is the share of gaps between charges that must fit a cadence;
+// one late or skipped charge in five still counts as regular
+const regularShare = 0.8
+
+// cadence is a schedule Detect recognizes: charges about days apart, give or
+// take tolerance days
+type cadence struct {
+	days      float64
+	tolerance float64
+	freq      Frequency
+	interval  int
+}
+
+var cadences = []cadence{
+	{days: 7, tolerance: 1, freq: Weekly, interval: 1},
+	{days: 14, tolerance: 2, freq: Weekly, interval: 2},
+	{days: 30.44, tolerance: 4, freq: Monthly, interval: 1},
+	{days: 91.31, tolerance: 7, freq: Monthly, interval: 3},
+	{days: 365.25, tolerance: 10, freq: Yearly, interval: 1},
+}
+
+// Pattern is a schedule found in past dates
+type Pattern struct {
+	Rule *Rule
+	// Next is the first date of the schedule after the last one seen
+	Next time.Time
+	// Confidence is the share of gaps between the dates that fit the schedule
+	Confidence float64
+}
+
+// Detect looks for a weekly, fortnightly, monthly, quarterly or yearly
+// schedule in the dates of repeated charges. It reports false when there are
+// too few dates, they follow no schedule, or the schedule has lapsed by now.
+func Detect(dates []time.Time, now time.Time) (*Pattern, bool) {
+	days := make([]time.Time, 0, len(dates))
+	for _, d := range dates {
+		days = append(days, Day(d))
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	days = unique(days)
+	if len(days) < MinOccurrences {
+		return nil, false
+	}
+
+	gaps := make([]float64, len(days)-1)
+	for i := range gaps {
+		gaps[i] = days[i+1].Sub(days[i]).Hours() / 24
+	}
+
+	var best *cadence
+	bestShare := 0.0
+	for i := range cadences {
+		c := &cadences[i]
+		fits := 0
+		for _, gap := range gaps {
+			if math.Abs(gap-c.days) <= c.tolerance {
+				fits++
+			}
+		}
+		if share := float64(fits) / float64(len(gaps)); share > bestShare {
+			best, bestShare = c, share
+		}
+	}
+	if best == nil || bestShare < regularShare {
+		return nil, false
+	}
+
+	last := days[len(days)-1]
+	// A charge missing for more than one and a half periods has probably been cancelled
+	if Day(now).Sub(last).Hours()/24 > 1.5*best.days+best.tolerance {
+		return nil, false
+	}
+
+	rule := &Rule{Freq: best.freq, Interval: best.interval}
+	switch best.freq {
+	case Weekly:
+		rule.ByDay = []Weekday{{Day: last.Weekday()}}
+	case Monthly:
+		rule.ByMonthDay = []int{typicalMonthDay(days)}
+	case Yearly:
+		rule.ByMonth = []time.Month{last.Month()}
+		rule.ByMonthDay = []int{typicalMonthDay(days)}
+	}
+
+	it := rule.Iterate(last)
+	next, ok := it.Next()
+	for ok && !next.After(last) {
+		next, ok = it.Next()
+	}
+	if !ok {
+		return nil, false
+	}
+	return &Pattern{Rule: rule, Next: next, Confidence: math.Round(bestShare*100) / 100}, true
+}
+
+// typicalMonthDay is the median day of the month of the dates. Charges that
+// land on the last days of the month are taken to be month-end charges.
+func typicalMonthDay(days []time.Time) int {
+	values := make([]int, len(days))
+	for i, d := range days {
+		values[i] = d.Day()
+	}
+	sort.Ints(values)
+	day := values[len(values)/2]
+	if day > 28 {
+		return -1
+	}
+	return day
+}
@@ -0,0 +1,444 @@
+// Package recurrence parses the subset of RFC 5545 recurrence rules that
+// recurring expenses are scheduled with, enumerates their dates and spots
+// schedules in past dates.
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a rule's periods repeat
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxEmptyPeriods bounds the search for a rule's next date, so rules that
+// can never match, such as the 30th of February, end instead of looping
+const maxEmptyPeriods = 5000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Weekday is a BYDAY entry. N picks the Nth such weekday of the month,
+// counting from the end when negative; 0 means every one.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed recurrence rule. Its dates are calendar days; the first
+// comes from the start date it is iterated from, as DTSTART would.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// Parse reads a rule such as "FREQ=MONTHLY;BYMONTHDAY=15", with or without an
+// "RRULE:" prefix. FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH
+// and WKST=MO are understood; anything else is rejected rather than ignored.
+func Parse(text string) (*Rule, error) {
+	text = strings.TrimSpace(text)
+	if len(text) >= 6 && strings.EqualFold(text[:6], "RRULE:") {
+		text = text[6:]
+	}
+	if text == "" {
+		return nil, invalid("rule is empty")
+	}
+
+	r := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(text, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, invalid("malformed part %q", part)
+		}
+		if seen[name] {
+			return nil, invalid("%s is given twice", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = f
+			default:
+				return nil, invalid("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, invalid("INTERVAL must be between 1 and 1000")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, invalid("COUNT must be at least 1")
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(value, ",") {
+				day, err := parseWeekday(item)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(item))
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, invalid("BYMONTHDAY values must be between 1 and 31 or -31 and -1")
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, item := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(item))
+				if err != nil || n < 1 || n > 12 {
+					return nil, invalid("BYMONTH values must be between 1 and 12")
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, invalid("only WKST=MO is supported")
+			}
+		default:
+			return nil, invalid("unsupported part %s", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, invalid("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, invalid("COUNT and UNTIL cannot be combined")
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, invalid("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	if r.Freq == Yearly && len(r.ByDay) > 0 && len(r.ByMonth) == 0 {
+		return nil, invalid("BYDAY with FREQ=YEARLY needs BYMONTH")
+	}
+	if r.Freq == Daily || r.Freq == Weekly {
+		for _, day := range r.ByDay {
+			if day.N != 0 {
+				return nil, invalid("numbered BYDAY values need FREQ=MONTHLY or YEARLY")
+			}
+		}
+	}
+	return r, nil
+}
+
+// String renders the rule in canonical form, e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if len(r.ByDay) > 0 {
+		items := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			items[i] = strings.ToUpper(day.Day.String()[:2])
+			if day.N != 0 {
+				items[i] = strconv.Itoa(day.N) + items[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(items, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		items := make([]string, len(r.ByMonthDay))
+		for i, n := range r.ByMonthDay {
+			items[i] = strconv.Itoa(n)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(items, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		items := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			items[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(items, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Iterator walks a rule's dates in order
+type Iterator struct {
+	rule    *Rule
+	start   time.Time
+	period  int
+	pending []time.Time
+	emitted int
+	done    bool
+}
+
+// Iterate returns the rule's dates from start on. Start is the first date
+// when it matches the rule; a monthly rule without BYMONTHDAY or BYDAY
+// repeats on its day of the month and skips months too short for it.
+func (r *Rule) Iterate(start time.Time) *Iterator {
+	return &Iterator{rule: r, start: Day(start)}
+}
+
+// Next returns the next date, or false once the rule has ended
+func (it *Iterator) Next() (time.Time, bool) {
+	for empty := 0; len(it.pending) == 0; empty++ {
+		if it.done || empty > maxEmptyPeriods {
+			it.done = true
+			return time.Time{}, false
+		}
+		periodStart, dates := it.rule.period(it.start, it.period)
+		it.period++
+		if it.rule.Until != nil && periodStart.After(*it.rule.Until) {
+			it.done = true
+			return time.Time{}, false
+		}
+		for _, d := range dates {
+			if !d.Before(it.start) {
+				it.pending = append(it.pending, d)
+			}
+		}
+	}
+
+	next := it.pending[0]
+	it.pending = it.pending[1:]
+	if (it.rule.Count > 0 && it.emitted >= it.rule.Count) || (it.rule.Until != nil && next.After(*it.rule.Until)) {
+		it.done = true
+		it.pending = nil
+		return time.Time{}, false
+	}
+	it.emitted++
+	return next, true
+}
+
+// Between returns the rule's dates from start that fall within from and to,
+// both inclusive, at most limit of them
+func (r *Rule) Between(start, from, to time.Time, limit int) []time.Time {
+	from, to = Day(from), Day(to)
+	var dates []time.Time
+	it := r.Iterate(start)
+	for len(dates) < limit {
+		d, ok := it.Next()
+		if !ok || d.After(to) {
+			break
+		}
+		if !d.Before(from) {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+// Day reduces a timestamp to the calendar day it falls on, at midnight UTC
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// period returns the first day of the nth period after start's and the
+// rule's dates within it, in order
+func (r *Rule) period(start time.Time, n int) (time.Time, []time.Time) {
+	step := n * r.Interval
+	var first time.Time
+	var dates []time.Time
+	switch r.Freq {
+	case Daily:
+		first = start.AddDate(0, 0, step)
+		if r.matchesMonth(first) && r.matchesMonthDay(first) && r.matchesWeekday(first) {
+			dates = []time.Time{first}
+		}
+	case Weekly:
+		// Weeks start on Monday
+		first = start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []Weekday{{Day: start.Weekday()}}
+		}
+		for _, day := range days {
+			d := first.AddDate(0, 0, (int(day.Day)+6)%7)
+			if r.matchesMonth(d) {
+				dates = append(dates, d)
+			}
+		}
+	case Monthly:
+		first = time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(first) {
+			dates = r.monthDates(start, first)
+		}
+	case Yearly:
+		first = time.Date(start.Year()+step, time.January, 1, 0, 0, 0, 0, time.UTC)
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		for _, m := range months {
+			dates = append(dates, r.monthDates(start, time.Date(first.Year(), m, 1, 0, 0, 0, 0, time.UTC))...)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return first, unique(dates)
+}
+
+// monthDates returns the rule's days in the month beginning at first
+func (r *Rule) monthDates(start, first time.Time) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if start.Day() > length {
+			return nil
+		}
+		return []time.Time{first.AddDate(0, 0, start.Day()-1)}
+	}
+
+	var byMonthDay, byDay map[int]bool
+	if len(r.ByMonthDay) > 0 {
+		byMonthDay = make(map[int]bool)
+		for _, n := range r.ByMonthDay {
+			if n < 0 {
+				n += length + 1
+			}
+			if n >= 1 && n <= length {
+				byMonthDay[n] = true
+			}
+		}
+	}
+	if len(r.ByDay) > 0 {
+		byDay = make(map[int]bool)
+		for _, day := range r.ByDay {
+			var matches []int
+			for d := 1; d <= length; d++ {
+				if first.AddDate(0, 0, d-1).Weekday() == day.Day {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case day.N == 0:
+				for _, d := range matches {
+					byDay[d] = true
+				}
+			case day.N > 0 && day.N <= len(matches):
+				byDay[matches[day.N-1]] = true
+			case day.N < 0 && -day.N <= len(matches):
+				byDay[matches[len(matches)+day.N]] = true
+			}
+		}
+	}
+
+	var dates []time.Time
+	for d := 1; d <= length; d++ {
+		if (byMonthDay == nil || byMonthDay[d]) && (byDay == nil || byDay[d]) {
+			dates = append(dates, first.AddDate(0, 0, d-1))
+		}
+	}
+	return dates
+}
+
+func (r *Rule) matchesMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if d.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, n := range r.ByMonthDay {
+		if n == d.Day() || n+length+1 == d.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if d.Weekday() == day.Day {
+			return true
+		}
+	}
+	return false
+}
+
+func parseWeekday(item string) (Weekday, error) {
+	item = strings.TrimSpace(item)
+	if len(item) < 2 {
+		return Weekday{}, invalid("invalid BYDAY value %q", item)
+	}
+	day, ok := weekdays[item[len(item)-2:]]
+	if !ok {
+		return Weekday{}, invalid("invalid BYDAY value %q", item)
+	}
+	w := Weekday{Day: day}
+	if prefix := item[:len(item)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Weekday{}, invalid("invalid BYDAY value %q", item)
+		}
+		w.N = n
+	}
+	return w, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return Day(t), nil
+		}
+	}
+	return time.Time{}, invalid("UNTIL must be a date such as 20251231")
+}
+
+func unique(dates []time.Time) []time.Time {
+	out := dates[:0]
+	for i, d := range dates {
+		if i == 0 || !d.Equal(dates[i-1]) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid recurrence rule: "+format, args...)
+}
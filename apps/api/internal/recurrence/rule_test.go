@@ -0,0 +1,245 @@
+package recurrence
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// take returns up to n dates of the rule from start, as YYYY-MM-DD
+func take(t *testing.T, text, start string, n int) []string {
+	t.Helper()
+	r, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", text, err)
+	}
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	it := r.Iterate(from)
+	for len(out) < n {
+		d, ok := it.Next()
+		if !ok {
+			break
+		}
+		out = append(out, d.Format("2006-01-02"))
+	}
+	return out
+}
+
+func TestIterate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		want  []string
+		// ends is set when the rule has no dates beyond want
+		ends bool
+	}{
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: "2024-01-15",
+			want:  []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"},
+		},
+		{
+			name:  "last day of February outside leap years",
+			rule:  "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1",
+			start: "2023-01-01",
+			want:  []string{"2023-02-28", "2024-02-29", "2025-02-28"},
+		},
+		{
+			name:  "the 31st skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: "2024-01-31",
+			want:  []string{"2024-01-31", "2024-03-31", "2024-05-31", "2024-07-31", "2024-08-31", "2024-10-31"},
+		},
+		{
+			name:  "BYMONTHDAY=31 skips short months",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31",
+			start: "2024-02-01",
+			want:  []string{"2024-03-31", "2024-05-31", "2024-07-31"},
+		},
+		{
+			name:  "start is skipped when it does not match",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=15",
+			start: "2024-01-20",
+			want:  []string{"2024-02-15", "2024-03-15"},
+		},
+		{
+			name:  "every other week on two days",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			start: "2024-03-06",
+			want:  []string{"2024-03-07", "2024-03-18", "2024-03-21", "2024-04-01"},
+		},
+		{
+			name:  "last Friday of the month",
+			rule:  "RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+			start: "2024-01-01",
+			want:  []string{"2024-01-26", "2024-02-23", "2024-03-29"},
+		},
+		{
+			name:  "weekdays only",
+			rule:  "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			start: "2024-03-08",
+			want:  []string{"2024-03-08", "2024-03-11", "2024-03-12"},
+		},
+		{
+			name:  "COUNT stops after that many dates",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: "2024-01-10",
+			want:  []string{"2024-01-10", "2024-02-10", "2024-03-10"},
+			ends:  true,
+		},
+		{
+			name:  "COUNT counts only matching dates",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=2",
+			start: "2024-04-01",
+			want:  []string{"2024-05-31", "2024-07-31"},
+			ends:  true,
+		},
+		{
+			name:  "UNTIL is inclusive",
+			rule:  "FREQ=WEEKLY;UNTIL=20240318",
+			start: "2024-03-04",
+			want:  []string{"2024-03-04", "2024-03-11", "2024-03-18"},
+			ends:  true,
+		},
+		{
+			name:  "UNTIL with a time keeps its day",
+			rule:  "FREQ=DAILY;UNTIL=20240305T235959Z",
+			start: "2024-03-03",
+			want:  []string{"2024-03-03", "2024-03-04", "2024-03-05"},
+			ends:  true,
+		},
+		{
+			name:  "UNTIL before the start",
+			rule:  "FREQ=DAILY;UNTIL=20240101",
+			start: "2024-03-03",
+			want:  nil,
+			ends:  true,
+		},
+		{
+			name:  "the 30th of February never occurs",
+			rule:  "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			start: "2024-01-01",
+			want:  nil,
+			ends:  true,
+		},
+		{
+			name:  "a weekday that is never in the month",
+			rule:  "FREQ=MONTHLY;BYMONTH=2;BYDAY=5MO;BYMONTHDAY=1",
+			start: "2024-01-01",
+			want:  nil,
+			ends:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := take(t, tt.rule, tt.start, len(tt.want)+1)
+			if !tt.ends && len(got) > len(tt.want) {
+				got = got[:len(tt.want)]
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIterateImpossibleRuleEnds(t *testing.T) {
+	r, err := Parse("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := r.Iterate(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	for i := 0; i < 3; i++ {
+		if d, ok := it.Next(); ok {
+			t.Fatalf("got date %s from a rule that never matches", d.Format("2006-01-02"))
+		}
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if dates := r.Between(from, from, from.AddDate(100, 0, 0), 10); len(dates) != 0 {
+		t.Errorf("Between returned %v", dates)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	r, err := Parse("FREQ=MONTHLY;BYMONTHDAY=-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	from := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 30, 23, 59, 0, 0, time.UTC)
+
+	var got []string
+	for _, d := range r.Between(start, from, to, 10) {
+		got = append(got, d.Format("2006-01-02"))
+	}
+	want := "2024-02-29 2024-03-31 2024-04-30 2024-05-31 2024-06-30"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	if dates := r.Between(start, from, to, 2); len(dates) != 2 {
+		t.Errorf("limit 2 returned %d dates", len(dates))
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"FREQ=MONTHLY;BYMONTHDAY=15", "FREQ=MONTHLY;BYMONTHDAY=15"},
+		{"rrule:freq=weekly;byday=mo,th;interval=2", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=12", "FREQ=MONTHLY;COUNT=12;BYMONTHDAY=-1"},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", "FREQ=YEARLY;BYMONTHDAY=30;BYMONTH=2"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;WKST=MO", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=DAILY;INTERVAL=1;UNTIL=20251231T120000Z", "FREQ=DAILY;UNTIL=20251231"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.text)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.text, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"", "rule is empty"},
+		{"BYMONTHDAY=1", "FREQ is required"},
+		{"FREQ=HOURLY", "unsupported FREQ HOURLY"},
+		{"FREQ=DAILY;FREQ=WEEKLY", "FREQ is given twice"},
+		{"FREQ=DAILY;BYSETPOS=1", "unsupported part BYSETPOS"},
+		{"FREQ=DAILY;COUNT", `malformed part "COUNT"`},
+		{"FREQ=DAILY;COUNT=0", "COUNT must be at least 1"},
+		{"FREQ=DAILY;INTERVAL=0", "INTERVAL must be between 1 and 1000"},
+		{"FREQ=MONTHLY;BYMONTHDAY=0", "BYMONTHDAY values must be between 1 and 31 or -31 and -1"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-32", "BYMONTHDAY values must be between 1 and 31 or -31 and -1"},
+		{"FREQ=YEARLY;BYMONTH=13", "BYMONTH values must be between 1 and 12"},
+		{"FREQ=MONTHLY;BYDAY=6MO", `invalid BYDAY value "6MO"`},
+		{"FREQ=DAILY;UNTIL=2025-12-31", "UNTIL must be a date such as 20251231"},
+		{"FREQ=DAILY;COUNT=3;UNTIL=20251231", "COUNT and UNTIL cannot be combined"},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", "BYMONTHDAY cannot be used with FREQ=WEEKLY"},
+		{"FREQ=YEARLY;BYDAY=MO", "BYDAY with FREQ=YEARLY needs BYMONTH"},
+		{"FREQ=WEEKLY;BYDAY=1MO", "numbered BYDAY values need FREQ=MONTHLY or YEARLY"},
+		{"FREQ=WEEKLY;WKST=SU", "only WKST=MO is supported"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.text)
+		if want := "invalid recurrence rule: " + tt.err; err == nil || err.Error() != want {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.text, err, want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // receipts may be GIFs
+	"image/jpeg"
+	_ "image/png" // receipts may be PNGs
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// thumbnailPixels bounds the longer side of a receipt image embedded in a summary
+const thumbnailPixels = 600
+
+// Receipt thumbnails are laid out in a grid of cells this many millimetres wide and high
+const (
+	thumbnailWidth  = 65
+	thumbnailHeight = 70
+	thumbnailGap    = 4
+)
+
+// Receipt is a file attached to an expense on an expense report. Images are
+// shown as thumbnails; other files are listed by Caption only.
+type Receipt struct {
+	Caption     string
+	ContentType string
+	Data        []byte
+}
+
+// ExpenseReportSummary is the content of an expense report's PDF summary.
+// Records are the report's expense lines in the currency each was paid in.
+type ExpenseReportSummary struct {
+	Title     string
+	Owner     string
+	Purpose   string
+	Status    string
+	StartDate time.Time
+	EndDate   time.Time
+	Records   []Record
+	// BaseTotalMinor is the report's total in BaseCurrency
+	BaseTotalMinor int64
+	BaseCurrency   string
+	Receipts       []Receipt
+}
+
+// WriteExpenseReportPDF writes the summary of an expense report in the style
+// of WritePDFReport: its expenses, totals per currency and per category, and
+// thumbnails of the receipts attached to them.
+func WriteExpenseReportPDF(w io.Writer, summary ExpenseReportSummary) error {
+	subtitle := []string{"Status: " + summary.Status}
+	if summary.Owner != "" {
+		subtitle = append(subtitle, "Submitted by: "+summary.Owner)
+	}
+	if !summary.StartDate.IsZero() || !summary.EndDate.IsZero() {
+		subtitle = append(subtitle, fmt.Sprintf("Period: %s - %s", formatDay(summary.StartDate), formatDay(summary.EndDate)))
+	}
+
+	pdf := newPDF(nonEmpty(summary.Title, "Expense Report"), strings.Join(subtitle, "    "))
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	if summary.Purpose != "" {
+		pdf.SetFont("Arial", "", 10)
+		pdf.MultiCell(0, 5, tr("Purpose: "+summary.Purpose), "", "L", false)
+		pdf.Ln(2)
+	}
+
+	// Expense lines
+	headers := []string{"Date", "Category", "Description", "Project", "Cost Center", "Amount"}
+	widths := []float64{25, 45, 87, 45, 35, 40}
+	align := []string{"L", "L", "L", "L", "L", "R"}
+	tableHeader(pdf, headers, widths)
+
+	alt := false
+	for _, r := range summary.Records {
+		rowFill(pdf, alt)
+		alt = !alt
+
+		row := []string{
+			r.Date.Format("2006-01-02"),
+			r.Category,
+			r.Item,
+			r.Project,
+			r.CostCenter,
+			formatMoney(r.Revenue(), r.Currency),
+		}
+		for i, cell := range row {
+			pdf.CellFormat(widths[i], 7, fitText(pdf, tr(cell), widths[i]), "1", 0, align[i], true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// Totals rows, one per currency, then the total in the base currency
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(255, 255, 255)
+	labelWidth := widths[0] + widths[1] + widths[2] + widths[3] + widths[4]
+	for _, t := range totalsByCurrency(summary.Records) {
+		pdf.CellFormat(labelWidth, 8, "Totals:", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 8, formatMoney(t.Revenue, t.Currency), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	if summary.BaseCurrency != "" {
+		pdf.CellFormat(labelWidth, 8, "Total in "+summary.BaseCurrency+":", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 8, formatMoney(summary.BaseTotalMinor, summary.BaseCurrency), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	pdf.Ln(6)
+
+	// Totals per category
+	headers = []string{"Category", "Currency", "Expenses", "Amount"}
+	widths = []float64{87, 30, 30, 40}
+	tableHeader(pdf, headers, widths)
+	alt = false
+	for _, t := range aggregateBy(summary.Records, "category") {
+		rowFill(pdf, alt)
+		alt = !alt
+		pdf.CellFormat(widths[0], 7, fitText(pdf, tr(nonEmpty(t.Key, "Uncategorized")), widths[0]), "1", 0, "L", true, 0, "")
+		pdf.CellFormat(widths[1], 7, t.Currency, "1", 0, "L", true, 0, "")
+		pdf.CellFormat(widths[2], 7, fmt.Sprintf("%d", t.Quantity), "1", 0, "R", true, 0, "")
+		pdf.CellFormat(widths[3], 7, formatMoney(t.Revenue, t.Currency), "1", 0, "R", true, 0, "")
+		pdf.Ln(-1)
+	}
+
+	if len(summary.Receipts) > 0 {
+		writeReceipts(pdf, tr, summary.Receipts)
+	}
+
+	return pdf.Output(w)
+}
+
+// writeReceipts lays the receipts out in a grid on pages of their own.
+// Files that are not images, or cannot be read as one, get their caption only.
+func writeReceipts(pdf *fpdf.Fpdf, tr func(string) string, receipts []Receipt) {
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(0, 8, "Receipts", "", 1, "L", false, 0, "")
+
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	perRow := int((pageWidth - left - right + thumbnailGap) / (thumbnailWidth + thumbnailGap))
+	if perRow < 1 {
+		perRow = 1
+	}
+
+	x, y := left, pdf.GetY()
+	for i, receipt := range receipts {
+		if i > 0 && i%perRow == 0 {
+			x, y = left, y+thumbnailHeight+thumbnailGap
+		}
+		if y+thumbnailHeight > pageHeight-bottom {
+			pdf.AddPage()
+			x, y = left, pdf.GetY()
+		}
+
+		imageHeight := float64(thumbnailHeight - 8)
+		pdf.SetDrawColor(217, 217, 217)
+		pdf.Rect(x, y, thumbnailWidth, thumbnailHeight, "D")
+		if name, width, height, ok := registerThumbnail(pdf, fmt.Sprintf("receipt-%d", i), receipt); ok {
+			// Fit the image inside its cell, keeping its proportions
+			scale := (thumbnailWidth - 4) / width
+			if (imageHeight-2)/height < scale {
+				scale = (imageHeight - 2) / height
+			}
+			w, h := width*scale, height*scale
+			pdf.ImageOptions(name, x+(thumbnailWidth-w)/2, y+1+(imageHeight-h)/2, w, h, false, fpdf.ImageOptions{ImageType: "JPG"}, 0, "")
+		} else {
+			pdf.SetFont("Arial", "I", 8)
+			pdf.SetXY(x, y+imageHeight/2-3)
+			pdf.CellFormat(thumbnailWidth, 6, "No preview", "", 0, "C", false, 0, "")
+		}
+
+		pdf.SetFont("Arial", "", 7)
+		pdf.SetXY(x+1, y+imageHeight)
+		pdf.CellFormat(thumbnailWidth-2, 7, fitText(pdf, tr(receipt.Caption), thumbnailWidth-2), "", 0, "C", false, 0, "")
+		x += thumbnailWidth + thumbnailGap
+	}
+}
+
+// registerThumbnail adds a scaled-down JPEG copy of an image receipt to the
+// document under name. Images are re-encoded because the PDF writer does not
+// read every variant of the formats, such as interlaced PNGs.
+func registerThumbnail(pdf *fpdf.Fpdf, name string, receipt Receipt) (string, float64, float64, bool) {
+	if !strings.HasPrefix(strings.ToLower(receipt.ContentType), "image/") || len(receipt.Data) == 0 {
+		return "", 0, 0, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(receipt.Data))
+	if err != nil {
+		return "", 0, 0, false
+	}
+	img = shrink(img, thumbnailPixels)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return "", 0, 0, false
+	}
+	info := pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "JPG"}, &buf)
+	if info == nil || pdf.Err() {
+		pdf.ClearError()
+		return "", 0, 0, false
+	}
+	bounds := img.Bounds()
+	return name, float64(bounds.Dx()), float64(bounds.Dy()), true
+}
+
+// shrink scales an image down, by sampling, so that neither side exceeds max pixels
+func shrink(img image.Image, max int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= max && height <= max {
+		return img
+	}
+	scale := float64(max) / float64(width)
+	if height > width {
+		scale = float64(max) / float64(height)
+	}
+	w, h := int(float64(width)*scale), int(float64(height)*scale)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := bounds.Min.Y + int(float64(y)/scale)
+		for x := 0; x < w; x++ {
+			out.Set(x, y, img.At(bounds.Min.X+int(float64(x)/scale), sy))
+		}
+	}
+	return out
+}
+
+// fitText shortens text, already translated to the font's single-byte
+// encoding, with an ellipsis until it fits in a cell of the given width
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	const padding = 2
+	if pdf.GetStringWidth(text) <= width-padding {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width-padding {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
+
+func formatDay(t time.Time) string {
+	if t.IsZero() {
+		return "..."
+	}
+	return t.Format("2006-01-02")
+}
@@ -12,6 +12,8 @@ import (
 
     "github.com/go-pdf/fpdf"
     "github.com/xuri/excelize/v2"
+
+    "github.com/example/next-go-monorepo/apps/api/internal/money"
 )
 
 // ExportFormat represents the output format for a report.
@@ -27,21 +29,29 @@ type ExportOptions struct {
     Title     string
     StartDate time.Time
     EndDate   time.Time
-    GroupBy   string // optional: category | region | salesperson
+    GroupBy   string // optional: category | region | salesperson | project | cost_center | tag
 }
 
 // Record is a single row in the report dataset.
+// Prices are integer minor units of Currency so that totals are exact.
 type Record struct {
-    Date        time.Time
-    Category    string
-    Item        string
-    Region      string
-    Salesperson string
-    Quantity    int
-    UnitPrice   float64
+    Date           time.Time
+    Category       string
+    Item           string
+    Region         string
+    Salesperson    string
+    Quantity       int
+    UnitPriceMinor int64
+    Currency       string
+    // Project, CostCenter, Merchant and Tags are the expense dimensions a record was booked to
+    Project        string
+    CostCenter     string
+    Merchant       string
+    Tags           []string
 }
 
-func (r Record) Revenue() float64 { return float64(r.Quantity) * r.UnitPrice }
+// Revenue returns the row total in minor units of the record's currency.
+func (r Record) Revenue() int64 { return int64(r.Quantity) * r.UnitPriceMinor }
 
 var categories = []string{"Software", "Hardware", "Services", "Subscriptions"}
 var regions = []string{"North", "South", "East", "West"}
@@ -76,8 +86,9 @@ func GenerateSampleData(n int, start, end time.Time) []Record {
             Item:        items[rng.Intn(len(items))],
             Region:      regions[rng.Intn(len(regions))],
             Salesperson: people[rng.Intn(len(people))],
-            Quantity:    1 + rng.Intn(50),
-            UnitPrice:   20 + rng.Float64()*480, // $20 - $500
+            Quantity:       1 + rng.Intn(50),
+            UnitPriceMinor: 2000 + rng.Int63n(48001), // $20 - $500
+            Currency:       "USD",
         }
         data = append(data, rec)
     }
@@ -93,29 +104,18 @@ func WriteExcelReport(w io.Writer, records []Record, opts ExportOptions) error {
     // Data sheet
     const dataSheet = "Data"
     f.SetSheetName("Sheet1", dataSheet)
-    headers := []string{"Date", "Category", "Item", "Region", "Salesperson", "Quantity", "Unit Price", "Revenue"}
+    headers := []string{"Date", "Category", "Item", "Region", "Salesperson", "Quantity", "Currency", "Unit Price", "Revenue"}
     // Write headers
     for colIdx, h := range headers {
         cell, _ := excelize.CoordinatesToCellName(colIdx+1, 1)
         _ = f.SetCellStr(dataSheet, cell, h)
     }
     // Header style
-    headStyle, _ := f.NewStyle(&excelize.Style{
-        Font:      &excelize.Font{Bold: true, Color: "#FFFFFF"},
-        Fill:      excelize.Fill{Type: "pattern", Color: []string{"#1F497D"}, Pattern: 1},
-        Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
-        Border: []excelize.Border{
-            {Type: "left", Color: "#D9D9D9", Style: 1},
-            {Type: "right", Color: "#D9D9D9", Style: 1},
-            {Type: "top", Color: "#D9D9D9", Style: 1},
-            {Type: "bottom", Color: "#D9D9D9", Style: 1},
-        },
-    })
-    _ = f.SetCellStyle(dataSheet, "A1", "H1", headStyle)
+    headStyle := headerStyle(f)
+    _ = f.SetCellStyle(dataSheet, "A1", "I1", headStyle)
     _ = f.SetRowHeight(dataSheet, 1, 22)
 
     dateStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 14})         // mm-dd-yy
-    currencyStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 44})     // _-"$"* #,##0.00_
     numberStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 3})        // #,##0
     altFillStyle, _ := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Color: []string{"#F2F2F2"}, Pattern: 1}})
 
@@ -128,37 +128,40 @@ func WriteExcelReport(w io.Writer, records []Record, opts ExportOptions) error {
         _ = f.SetCellValue(dataSheet, fmt.Sprintf("D%d", row), r.Region)
         _ = f.SetCellValue(dataSheet, fmt.Sprintf("E%d", row), r.Salesperson)
         _ = f.SetCellValue(dataSheet, fmt.Sprintf("F%d", row), r.Quantity)
-        _ = f.SetCellValue(dataSheet, fmt.Sprintf("G%d", row), r.UnitPrice)
-        _ = f.SetCellValue(dataSheet, fmt.Sprintf("H%d", row), r.Revenue())
+        _ = f.SetCellStr(dataSheet, fmt.Sprintf("G%d", row), r.Currency)
+        setMoney(f, dataSheet, fmt.Sprintf("H%d", row), r.UnitPriceMinor, r.Currency)
+        setMoney(f, dataSheet, fmt.Sprintf("I%d", row), r.Revenue(), r.Currency)
 
         // Apply styles per column
         _ = f.SetCellStyle(dataSheet, fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), dateStyle)
         _ = f.SetCellStyle(dataSheet, fmt.Sprintf("F%d", row), fmt.Sprintf("F%d", row), numberStyle)
-        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("G%d", row), fmt.Sprintf("H%d", row), currencyStyle)
+        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("H%d", row), fmt.Sprintf("I%d", row), moneyStyle(f, r.Currency, false))
         if i%2 == 1 {
-            _ = f.SetCellStyle(dataSheet, fmt.Sprintf("A%d", row), fmt.Sprintf("H%d", row), altFillStyle)
+            _ = f.SetCellStyle(dataSheet, fmt.Sprintf("A%d", row), fmt.Sprintf("I%d", row), altFillStyle)
         }
     }
 
-    // Totals row
+    // Totals rows, one per currency. They are summed here in integer minor
+    // units rather than with a SUM formula, which Excel evaluates in floating point.
+    boldStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
     lastRow := len(records) + 2
-    if len(records) > 0 {
+    for _, t := range totalsByCurrency(records) {
         _ = f.SetCellStr(dataSheet, fmt.Sprintf("E%d", lastRow), "Totals:")
-        _ = f.SetCellFormula(dataSheet, fmt.Sprintf("F%d", lastRow), fmt.Sprintf("SUM(F2:F%d)", lastRow-1))
-        _ = f.SetCellFormula(dataSheet, fmt.Sprintf("H%d", lastRow), fmt.Sprintf("SUM(H2:H%d)", lastRow-1))
-        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("F%d", lastRow), fmt.Sprintf("F%d", lastRow), numberStyle)
-        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("H%d", lastRow), fmt.Sprintf("H%d", lastRow), currencyStyle)
-        boldStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
-        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("E%d", lastRow), fmt.Sprintf("H%d", lastRow), boldStyle)
+        _ = f.SetCellInt(dataSheet, fmt.Sprintf("F%d", lastRow), t.Quantity)
+        _ = f.SetCellStr(dataSheet, fmt.Sprintf("G%d", lastRow), t.Currency)
+        setMoney(f, dataSheet, fmt.Sprintf("I%d", lastRow), t.Revenue, t.Currency)
+        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("E%d", lastRow), fmt.Sprintf("I%d", lastRow), boldStyle)
+        _ = f.SetCellStyle(dataSheet, fmt.Sprintf("I%d", lastRow), fmt.Sprintf("I%d", lastRow), moneyStyle(f, t.Currency, true))
+        lastRow++
     }
 
     // Sizing and filters
-    _ = f.AutoFilter(dataSheet, "A1", "H1", "")
+    _ = f.AutoFilter(dataSheet, "A1:I1", nil)
     _ = f.SetPanes(dataSheet, &excelize.Panes{Freeze: true, Split: true, XSplit: 0, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
     _ = f.SetColWidth(dataSheet, "A", "A", 12)
     _ = f.SetColWidth(dataSheet, "B", "E", 16)
-    _ = f.SetColWidth(dataSheet, "F", "G", 12)
-    _ = f.SetColWidth(dataSheet, "H", "H", 14)
+    _ = f.SetColWidth(dataSheet, "F", "G", 10)
+    _ = f.SetColWidth(dataSheet, "H", "I", 14)
 
     // Summary sheet grouped by GroupBy (default Category)
     groupBy := strings.ToLower(strings.TrimSpace(opts.GroupBy))
@@ -167,36 +170,45 @@ func WriteExcelReport(w io.Writer, records []Record, opts ExportOptions) error {
     }
     summarySheet := "Summary"
     _, _ = f.NewSheet(summarySheet)
-    _ = f.SetCellStr(summarySheet, "A1", strings.Title(groupBy))
-    _ = f.SetCellStr(summarySheet, "B1", "Quantity")
-    _ = f.SetCellStr(summarySheet, "C1", "Revenue")
-    _ = f.SetCellStyle(summarySheet, "A1", "C1", headStyle)
+    _ = f.SetCellStr(summarySheet, "A1", strings.Title(strings.ReplaceAll(groupBy, "_", " ")))
+    _ = f.SetCellStr(summarySheet, "B1", "Currency")
+    _ = f.SetCellStr(summarySheet, "C1", "Quantity")
+    _ = f.SetCellStr(summarySheet, "D1", "Revenue")
+    _ = f.SetCellStyle(summarySheet, "A1", "D1", headStyle)
     _ = f.SetRowHeight(summarySheet, 1, 22)
 
-    aggregates := aggregateBy(records, groupBy)
     row := 2
-    for key, agg := range aggregates {
-        _ = f.SetCellStr(summarySheet, fmt.Sprintf("A%d", row), key)
-        _ = f.SetCellInt(summarySheet, fmt.Sprintf("B%d", row), agg.Quantity)
-        _ = f.SetCellFloat(summarySheet, fmt.Sprintf("C%d", row), agg.Revenue, 2, 64)
-        _ = f.SetCellStyle(summarySheet, fmt.Sprintf("B%d", row), fmt.Sprintf("B%d", row), numberStyle)
-        _ = f.SetCellStyle(summarySheet, fmt.Sprintf("C%d", row), fmt.Sprintf("C%d", row), currencyStyle)
+    for _, agg := range aggregateBy(records, groupBy) {
+        _ = f.SetCellStr(summarySheet, fmt.Sprintf("A%d", row), agg.Key)
+        _ = f.SetCellStr(summarySheet, fmt.Sprintf("B%d", row), agg.Currency)
+        _ = f.SetCellInt(summarySheet, fmt.Sprintf("C%d", row), agg.Quantity)
+        setMoney(f, summarySheet, fmt.Sprintf("D%d", row), agg.Revenue, agg.Currency)
+        _ = f.SetCellStyle(summarySheet, fmt.Sprintf("C%d", row), fmt.Sprintf("C%d", row), numberStyle)
+        _ = f.SetCellStyle(summarySheet, fmt.Sprintf("D%d", row), fmt.Sprintf("D%d", row), moneyStyle(f, agg.Currency, false))
+        row++
+    }
+    // Totals on summary, per currency
+    for _, t := range totalsByCurrency(records) {
+        _ = f.SetCellStr(summarySheet, fmt.Sprintf("A%d", row), "Totals:")
+        _ = f.SetCellStr(summarySheet, fmt.Sprintf("B%d", row), t.Currency)
+        _ = f.SetCellInt(summarySheet, fmt.Sprintf("C%d", row), t.Quantity)
+        setMoney(f, summarySheet, fmt.Sprintf("D%d", row), t.Revenue, t.Currency)
+        _ = f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), boldStyle)
+        _ = f.SetCellStyle(summarySheet, fmt.Sprintf("D%d", row), fmt.Sprintf("D%d", row), moneyStyle(f, t.Currency, true))
         row++
     }
-    // Totals on summary
-    _ = f.SetCellStr(summarySheet, fmt.Sprintf("A%d", row), "Totals:")
-    _ = f.SetCellFormula(summarySheet, fmt.Sprintf("B%d", row), fmt.Sprintf("SUM(B2:B%d)", row-1))
-    _ = f.SetCellFormula(summarySheet, fmt.Sprintf("C%d", row), fmt.Sprintf("SUM(C2:C%d)", row-1))
-    boldStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
-    _ = f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("C%d", row), boldStyle)
     _ = f.SetColWidth(summarySheet, "A", "A", 20)
-    _ = f.SetColWidth(summarySheet, "B", "C", 14)
+    _ = f.SetColWidth(summarySheet, "B", "B", 10)
+    _ = f.SetColWidth(summarySheet, "C", "D", 14)
 
-    f.SetActiveSheet(f.GetSheetIndex(summarySheet))
+    if idx, err := f.GetSheetIndex(summarySheet); err == nil {
+        f.SetActiveSheet(idx)
+    }
 
     // Metadata sheet title
     if opts.Title != "" {
-        _ = f.SetSheetPrOptions(dataSheet, excelize.ShowGridLines(false))
+        showGridLines := false
+        _ = f.SetSheetView(dataSheet, 0, &excelize.ViewOptions{ShowGridLines: &showGridLines})
         meta := "Meta"
         _, _ = f.NewSheet(meta)
         _ = f.SetCellStr(meta, "A1", opts.Title)
@@ -215,44 +227,188 @@ func WriteExcelReport(w io.Writer, records []Record, opts ExportOptions) error {
     return err
 }
 
-type agg struct {
+// Total is a group total. Revenue is in minor units of Currency; amounts in
+// different currencies are never added together.
+type Total struct {
+    Key      string
+    Currency string
     Quantity int
-    Revenue  float64
+    Revenue  int64
 }
 
-func aggregateBy(records []Record, by string) map[string]agg {
-    m := make(map[string]agg)
-    for _, r := range records {
-        key := ""
+// GroupBy lists the dimensions records can be grouped by
+var GroupBy = []string{"category", "region", "salesperson", "project", "cost_center", "merchant", "tag"}
+
+// Summarize totals records per group of the dimension and currency, sorted by
+// group then currency. A record with several tags counts toward each of them.
+func Summarize(records []Record, by string) []Total {
+    return aggregateBy(records, by)
+}
+
+// Totals totals all records per currency.
+func Totals(records []Record) []Total {
+    return totalsByCurrency(records)
+}
+
+// aggregateBy totals records per group and currency, sorted by group then currency.
+func aggregateBy(records []Record, by string) []Total {
+    return aggregate(records, func(r Record) []string {
         switch by {
         case "region":
-            key = r.Region
+            return []string{r.Region}
         case "salesperson":
-            key = r.Salesperson
+            return []string{r.Salesperson}
+        case "project":
+            return []string{r.Project}
+        case "cost_center":
+            return []string{r.CostCenter}
+        case "merchant":
+            return []string{r.Merchant}
+        case "tag":
+            if len(r.Tags) == 0 {
+                return []string{""}
+            }
+            return r.Tags
         default:
-            key = r.Category
+            return []string{r.Category}
+        }
+    })
+}
+
+// totalsByCurrency totals all records per currency.
+func totalsByCurrency(records []Record) []Total {
+    return aggregate(records, func(Record) []string { return []string{""} })
+}
+
+func aggregate(records []Record, keysOf func(Record) []string) []Total {
+    type groupKey struct{ key, currency string }
+    m := make(map[groupKey]*Total)
+    var out []*Total
+    for _, r := range records {
+        for _, key := range keysOf(r) {
+            k := groupKey{key, r.Currency}
+            a, ok := m[k]
+            if !ok {
+                a = &Total{Key: k.key, Currency: k.currency}
+                m[k] = a
+                out = append(out, a)
+            }
+            a.Quantity += r.Quantity
+            a.Revenue += r.Revenue()
+        }
+    }
+
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Key != out[j].Key {
+            return out[i].Key < out[j].Key
         }
-        a := m[key]
-        a.Quantity += r.Quantity
-        a.Revenue += r.Revenue()
-        m[key] = a
+        return out[i].Currency < out[j].Currency
+    })
+
+    result := make([]Total, len(out))
+    for i, a := range out {
+        result[i] = *a
+    }
+    return result
+}
+
+// headerStyle returns the white-on-blue style of column headings
+func headerStyle(f *excelize.File) int {
+    style, _ := f.NewStyle(&excelize.Style{
+        Font:      &excelize.Font{Bold: true, Color: "#FFFFFF"},
+        Fill:      excelize.Fill{Type: "pattern", Color: []string{"#1F497D"}, Pattern: 1},
+        Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+        Border: []excelize.Border{
+            {Type: "left", Color: "#D9D9D9", Style: 1},
+            {Type: "right", Color: "#D9D9D9", Style: 1},
+            {Type: "top", Color: "#D9D9D9", Style: 1},
+            {Type: "bottom", Color: "#D9D9D9", Style: 1},
+        },
+    })
+    return style
+}
+
+// setMoney writes minor units as a number with exactly the currency's decimals.
+func setMoney(f *excelize.File, sheet, cell string, minor int64, currency string) {
+    _ = f.SetCellFloat(sheet, cell, money.Float(minor, currency), money.Exponent(currency), 64)
+}
+
+// moneyStyle returns a number style showing the currency's minor-unit digits.
+func moneyStyle(f *excelize.File, currency string, bold bool) int {
+    format := "#,##0"
+    if exp := money.Exponent(currency); exp > 0 {
+        format += "." + strings.Repeat("0", exp)
     }
-    return m
+    style, _ := f.NewStyle(&excelize.Style{CustomNumFmt: &format, Font: &excelize.Font{Bold: bold}})
+    return style
 }
 
 // WritePDFReport writes a PDF file containing the provided records.
 func WritePDFReport(w io.Writer, records []Record, opts ExportOptions) error {
+    rangeText := fmt.Sprintf("Date Range: %s - %s", opts.StartDate.Format("2006-01-02"), opts.EndDate.Format("2006-01-02"))
+    pdf := newPDF(nonEmpty(opts.Title, "Sales Report"), rangeText)
+    pdf.AddPage()
+
+    // Table headers
+    headers := []string{"Date", "Category", "Item", "Region", "Salesperson", "Qty", "Unit Price", "Revenue"}
+    widths := []float64{25, 40, 35, 30, 40, 15, 35, 40}
+    align := []string{"L", "L", "L", "L", "L", "R", "R", "R"}
+    tableHeader(pdf, headers, widths)
+
+    alt := false
+    for _, r := range records {
+        rowFill(pdf, alt)
+        alt = !alt
+
+        row := []string{
+            r.Date.Format("2006-01-02"),
+            r.Category,
+            r.Item,
+            r.Region,
+            r.Salesperson,
+            fmt.Sprintf("%d", r.Quantity),
+            formatMoney(r.UnitPriceMinor, r.Currency),
+            formatMoney(r.Revenue(), r.Currency),
+        }
+
+        for i, cell := range row {
+            pdf.CellFormat(widths[i], 7, cell, "1", 0, align[i], true, 0, "")
+        }
+        pdf.Ln(-1)
+    }
+
+    // Totals rows, one per currency
+    pdf.SetFont("Arial", "B", 10)
+    pdf.SetFillColor(255, 255, 255)
+    // Merge first five columns for label
+    labelWidth := widths[0] + widths[1] + widths[2] + widths[3] + widths[4]
+    for _, t := range totalsByCurrency(records) {
+        pdf.CellFormat(labelWidth, 8, "Totals:", "1", 0, "R", false, 0, "")
+        pdf.CellFormat(widths[5], 8, fmt.Sprintf("%d", t.Quantity), "1", 0, "R", false, 0, "")
+        pdf.CellFormat(widths[6], 8, "", "1", 0, "R", false, 0, "")
+        pdf.CellFormat(widths[7], 8, formatMoney(t.Revenue, t.Currency), "1", 0, "R", false, 0, "")
+        pdf.Ln(-1)
+    }
+
+    return pdf.Output(w)
+}
+
+// newPDF starts a landscape A4 document whose pages carry the title, a line
+// of subtitle and a page number
+func newPDF(title, subtitle string) *fpdf.Fpdf {
     pdf := fpdf.New("L", "mm", "A4", "")
     pdf.SetMargins(10, 15, 10)
     pdf.SetAutoPageBreak(true, 12)
+    // The core fonts are Windows-1252 encoded
+    tr := pdf.UnicodeTranslatorFromDescriptor("")
+    title, subtitle = tr(title), tr(subtitle)
 
     // Header for each page
     pdf.SetHeaderFuncMode(func() {
         pdf.SetFont("Arial", "B", 14)
-        pdf.CellFormat(0, 8, nonEmpty(opts.Title, "Sales Report"), "", 1, "L", false, 0, "")
+        pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
         pdf.SetFont("Arial", "", 10)
-        rangeText := fmt.Sprintf("Date Range: %s - %s", opts.StartDate.Format("2006-01-02"), opts.EndDate.Format("2006-01-02"))
-        pdf.CellFormat(0, 6, rangeText, "", 1, "L", false, 0, "")
+        pdf.CellFormat(0, 6, subtitle, "", 1, "L", false, 0, "")
         pdf.Ln(2)
     }, true)
     pdf.SetFooterFunc(func() {
@@ -260,14 +416,12 @@ func WritePDFReport(w io.Writer, records []Record, opts ExportOptions) error {
         pdf.SetFont("Arial", "I", 8)
         pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
     })
+    return pdf
+}
 
-    pdf.AddPage()
-
-    // Table headers
-    headers := []string{"Date", "Category", "Item", "Region", "Salesperson", "Qty", "Unit Price", "Revenue"}
-    widths := []float64{25, 40, 35, 30, 40, 15, 30, 30}
-    align := []string{"L", "L", "L", "L", "L", "R", "R", "R"}
-
+// tableHeader writes a row of white-on-blue column headings and leaves the
+// font set for the rows that follow
+func tableHeader(pdf *fpdf.Fpdf, headers []string, widths []float64) {
     pdf.SetFillColor(31, 73, 125)
     pdf.SetTextColor(255, 255, 255)
     pdf.SetDrawColor(217, 217, 217)
@@ -281,49 +435,20 @@ func WritePDFReport(w io.Writer, records []Record, opts ExportOptions) error {
     // Reset text color for rows
     pdf.SetTextColor(0, 0, 0)
     pdf.SetFont("Arial", "", 9)
+}
 
-    alt := false
-    var totalQty int
-    var totalRev float64
-    for _, r := range records {
-        if alt {
-            pdf.SetFillColor(242, 242, 242)
-        } else {
-            pdf.SetFillColor(255, 255, 255)
-        }
-        alt = !alt
-
-        row := []string{
-            r.Date.Format("2006-01-02"),
-            r.Category,
-            r.Item,
-            r.Region,
-            r.Salesperson,
-            fmt.Sprintf("%d", r.Quantity),
-            fmt.Sprintf("$%.2f", r.UnitPrice),
-            fmt.Sprintf("$%.2f", r.Revenue()),
-        }
-
-        for i, cell := range row {
-            pdf.CellFormat(widths[i], 7, cell, "1", 0, align[i], true, 0, "")
-        }
-        pdf.Ln(-1)
-
-        totalQty += r.Quantity
-        totalRev += r.Revenue()
+// rowFill shades every other table row
+func rowFill(pdf *fpdf.Fpdf, alt bool) {
+    if alt {
+        pdf.SetFillColor(242, 242, 242)
+    } else {
+        pdf.SetFillColor(255, 255, 255)
     }
+}
 
-    // Totals row
-    pdf.SetFont("Arial", "B", 10)
-    pdf.SetFillColor(255, 255, 255)
-    // Merge first five columns for label
-    labelWidth := 25 + 40 + 35 + 30 + 40
-    pdf.CellFormat(labelWidth, 8, "Totals:", "1", 0, "R", false, 0, "")
-    pdf.CellFormat(15, 8, fmt.Sprintf("%d", totalQty), "1", 0, "R", false, 0, "")
-    pdf.CellFormat(30, 8, "", "1", 0, "R", false, 0, "")
-    pdf.CellFormat(30, 8, fmt.Sprintf("$%.2f", totalRev), "1", 0, "R", false, 0, "")
-
-    return pdf.Output(w)
+// formatMoney renders minor units with the currency code, e.g. "USD 1234.50".
+func formatMoney(minor int64, currency string) string {
+    return currency + " " + money.Format(minor, currency)
 }
 
 func nonEmpty(s, fallback string) string {
@@ -341,6 +466,6 @@ func hexToRGB(hex string) (r, g, b int) {
         _, _ = fmt.Sscanf(hx, "%02x%02x%02x", &rr, &gg, &bb)
         return int(rr), int(gg), int(bb)
     }
-    c := color.White
-    return int(c.R), int(c.G), int(c.B)
+    rr, gg, bb, _ := color.White.RGBA()
+    return int(rr >> 8), int(gg >> 8), int(bb >> 8)
 }
@@ -0,0 +1,337 @@
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// notReclaimableNote explains why some lines are left out of the totals
+const notReclaimableNote = "Lines without a merchant VAT number cannot be reclaimed and are left out of the totals."
+
+// VATLine is an expense whose amount includes value-added tax. Amounts are in
+// minor units of Currency and Rate is a percent of the net amount.
+type VATLine struct {
+	Date        time.Time
+	ExpenseID   uint
+	Description string
+	Category    string
+	Country     string
+	Rate        string
+	Currency    string
+	NetMinor    int64
+	TaxMinor    int64
+	VATNumber   string
+}
+
+// GrossMinor returns the amount paid including the tax.
+func (l VATLine) GrossMinor() int64 { return l.NetMinor + l.TaxMinor }
+
+// Reclaimable reports whether the tax can be claimed back, which takes the
+// merchant's VAT number.
+func (l VATLine) Reclaimable() bool { return strings.TrimSpace(l.VATNumber) != "" }
+
+// VATReclaimReport is the content of a VAT reclaim report: the taxed expenses
+// of a period, in the currency each was paid in.
+type VATReclaimReport struct {
+	Title     string
+	StartDate time.Time
+	EndDate   time.Time
+	Lines     []VATLine
+}
+
+// VATTotal totals reclaimable lines. Country and Rate are empty on totals per
+// currency only; amounts in different currencies are never added together.
+type VATTotal struct {
+	Country    string
+	Rate       string
+	Currency   string
+	Count      int
+	NetMinor   int64
+	TaxMinor   int64
+	GrossMinor int64
+}
+
+// SummarizeVAT totals the reclaimable lines per country, rate and currency,
+// sorted by country, then from the highest rate, then by currency.
+func SummarizeVAT(lines []VATLine) []VATTotal {
+	return aggregateVAT(lines, func(l VATLine) (string, string) { return l.Country, l.Rate })
+}
+
+// VATTotals totals the reclaimable lines per currency.
+func VATTotals(lines []VATLine) []VATTotal {
+	return aggregateVAT(lines, func(VATLine) (string, string) { return "", "" })
+}
+
+func aggregateVAT(lines []VATLine, keyOf func(VATLine) (string, string)) []VATTotal {
+	type groupKey struct{ country, rate, currency string }
+	m := make(map[groupKey]*VATTotal)
+	var out []*VATTotal
+	for _, l := range lines {
+		if !l.Reclaimable() {
+			continue
+		}
+		country, rate := keyOf(l)
+		k := groupKey{country, rate, l.Currency}
+		t, ok := m[k]
+		if !ok {
+			t = &VATTotal{Country: country, Rate: rate, Currency: l.Currency}
+			m[k] = t
+			out = append(out, t)
+		}
+		t.Count++
+		t.NetMinor += l.NetMinor
+		t.TaxMinor += l.TaxMinor
+		t.GrossMinor += l.GrossMinor()
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Country != out[j].Country {
+			return out[i].Country < out[j].Country
+		}
+		if c := compareRates(out[i].Rate, out[j].Rate); c != 0 {
+			return c > 0
+		}
+		return out[i].Currency < out[j].Currency
+	})
+
+	result := make([]VATTotal, len(out))
+	for i, t := range out {
+		result[i] = *t
+	}
+	return result
+}
+
+// compareRates compares two percents numerically, falling back to their text
+func compareRates(a, b string) int {
+	ra, okA := new(big.Rat).SetString(a)
+	rb, okB := new(big.Rat).SetString(b)
+	if okA && okB {
+		return ra.Cmp(rb)
+	}
+	return strings.Compare(a, b)
+}
+
+// WriteVATReclaimReport writes the report as an Excel workbook or a PDF.
+func WriteVATReclaimReport(w io.Writer, report VATReclaimReport, format ExportFormat) error {
+	switch format {
+	case FormatExcel:
+		return writeVATExcel(w, report)
+	case FormatPDF:
+		return writeVATPDF(w, report)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// writeVATExcel writes a Summary sheet of reclaimable tax per country and
+// rate, a Lines sheet with every taxed expense and a Meta sheet describing
+// the report.
+func writeVATExcel(w io.Writer, report VATReclaimReport) error {
+	f := excelize.NewFile()
+	headStyle := headerStyle(f)
+	boldStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	dateStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 14})
+
+	// Summary sheet
+	const summarySheet = "Summary"
+	f.SetSheetName("Sheet1", summarySheet)
+	headers := []string{"Country", "Rate %", "Currency", "Expenses", "Net", "VAT", "Gross"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		_ = f.SetCellStr(summarySheet, cell, h)
+	}
+	_ = f.SetCellStyle(summarySheet, "A1", "G1", headStyle)
+	_ = f.SetRowHeight(summarySheet, 1, 22)
+
+	row := 2
+	for _, t := range SummarizeVAT(report.Lines) {
+		_ = f.SetCellStr(summarySheet, fmt.Sprintf("A%d", row), t.Country)
+		setPercent(f, summarySheet, fmt.Sprintf("B%d", row), t.Rate)
+		_ = f.SetCellStr(summarySheet, fmt.Sprintf("C%d", row), t.Currency)
+		_ = f.SetCellInt(summarySheet, fmt.Sprintf("D%d", row), t.Count)
+		setMoney(f, summarySheet, fmt.Sprintf("E%d", row), t.NetMinor, t.Currency)
+		setMoney(f, summarySheet, fmt.Sprintf("F%d", row), t.TaxMinor, t.Currency)
+		setMoney(f, summarySheet, fmt.Sprintf("G%d", row), t.GrossMinor, t.Currency)
+		_ = f.SetCellStyle(summarySheet, fmt.Sprintf("E%d", row), fmt.Sprintf("G%d", row), moneyStyle(f, t.Currency, false))
+		row++
+	}
+	// Totals per currency, summed in integer minor units as in WriteExcelReport
+	for _, t := range VATTotals(report.Lines) {
+		_ = f.SetCellStr(summarySheet, fmt.Sprintf("A%d", row), "Totals:")
+		_ = f.SetCellStr(summarySheet, fmt.Sprintf("C%d", row), t.Currency)
+		_ = f.SetCellInt(summarySheet, fmt.Sprintf("D%d", row), t.Count)
+		setMoney(f, summarySheet, fmt.Sprintf("E%d", row), t.NetMinor, t.Currency)
+		setMoney(f, summarySheet, fmt.Sprintf("F%d", row), t.TaxMinor, t.Currency)
+		setMoney(f, summarySheet, fmt.Sprintf("G%d", row), t.GrossMinor, t.Currency)
+		_ = f.SetCellStyle(summarySheet, fmt.Sprintf("A%d", row), fmt.Sprintf("D%d", row), boldStyle)
+		_ = f.SetCellStyle(summarySheet, fmt.Sprintf("E%d", row), fmt.Sprintf("G%d", row), moneyStyle(f, t.Currency, true))
+		row++
+	}
+	_ = f.SetColWidth(summarySheet, "A", "D", 12)
+	_ = f.SetColWidth(summarySheet, "E", "G", 16)
+
+	// Lines sheet
+	const linesSheet = "Lines"
+	_, _ = f.NewSheet(linesSheet)
+	headers = []string{"Date", "Expense", "Description", "Category", "Country", "Rate %", "Currency", "Net", "VAT", "Gross", "VAT Number", "Reclaimable"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		_ = f.SetCellStr(linesSheet, cell, h)
+	}
+	_ = f.SetCellStyle(linesSheet, "A1", "L1", headStyle)
+	_ = f.SetRowHeight(linesSheet, 1, 22)
+
+	for i, l := range report.Lines {
+		row := i + 2
+		reclaimable := "Yes"
+		if !l.Reclaimable() {
+			reclaimable = "No"
+		}
+		_ = f.SetCellValue(linesSheet, fmt.Sprintf("A%d", row), l.Date)
+		_ = f.SetCellInt(linesSheet, fmt.Sprintf("B%d", row), int(l.ExpenseID))
+		_ = f.SetCellStr(linesSheet, fmt.Sprintf("C%d", row), l.Description)
+		_ = f.SetCellStr(linesSheet, fmt.Sprintf("D%d", row), l.Category)
+		_ = f.SetCellStr(linesSheet, fmt.Sprintf("E%d", row), l.Country)
+		setPercent(f, linesSheet, fmt.Sprintf("F%d", row), l.Rate)
+		_ = f.SetCellStr(linesSheet, fmt.Sprintf("G%d", row), l.Currency)
+		setMoney(f, linesSheet, fmt.Sprintf("H%d", row), l.NetMinor, l.Currency)
+		setMoney(f, linesSheet, fmt.Sprintf("I%d", row), l.TaxMinor, l.Currency)
+		setMoney(f, linesSheet, fmt.Sprintf("J%d", row), l.GrossMinor(), l.Currency)
+		_ = f.SetCellStr(linesSheet, fmt.Sprintf("K%d", row), l.VATNumber)
+		_ = f.SetCellStr(linesSheet, fmt.Sprintf("L%d", row), reclaimable)
+		_ = f.SetCellStyle(linesSheet, fmt.Sprintf("A%d", row), fmt.Sprintf("A%d", row), dateStyle)
+		_ = f.SetCellStyle(linesSheet, fmt.Sprintf("H%d", row), fmt.Sprintf("J%d", row), moneyStyle(f, l.Currency, false))
+	}
+	_ = f.AutoFilter(linesSheet, "A1:L1", nil)
+	_ = f.SetPanes(linesSheet, &excelize.Panes{Freeze: true, Split: true, XSplit: 0, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	_ = f.SetColWidth(linesSheet, "A", "B", 12)
+	_ = f.SetColWidth(linesSheet, "C", "C", 36)
+	_ = f.SetColWidth(linesSheet, "D", "D", 20)
+	_ = f.SetColWidth(linesSheet, "E", "G", 10)
+	_ = f.SetColWidth(linesSheet, "H", "J", 14)
+	_ = f.SetColWidth(linesSheet, "K", "L", 18)
+
+	// Meta sheet
+	const metaSheet = "Meta"
+	_, _ = f.NewSheet(metaSheet)
+	_ = f.SetCellStr(metaSheet, "A1", nonEmpty(report.Title, "VAT Reclaim Report"))
+	_ = f.SetCellStr(metaSheet, "A2", fmt.Sprintf("Date Range: %s - %s", formatDay(report.StartDate), formatDay(report.EndDate)))
+	_ = f.SetCellStr(metaSheet, "A3", notReclaimableNote)
+	_ = f.SetColWidth(metaSheet, "A", "A", 90)
+	titleStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 16}})
+	_ = f.SetCellStyle(metaSheet, "A1", "A1", titleStyle)
+
+	f.SetActiveSheet(0)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, bytes.NewReader(buf.Bytes()))
+	return err
+}
+
+// setPercent writes a rate as a number so it can be calculated with
+func setPercent(f *excelize.File, sheet, cell, rate string) {
+	if value, err := strconv.ParseFloat(rate, 64); err == nil {
+		_ = f.SetCellFloat(sheet, cell, value, -1, 64)
+		return
+	}
+	_ = f.SetCellStr(sheet, cell, rate)
+}
+
+// writeVATPDF writes the reclaimable tax per country and rate followed by
+// every taxed expense, in the style of WritePDFReport.
+func writeVATPDF(w io.Writer, report VATReclaimReport) error {
+	rangeText := fmt.Sprintf("Date Range: %s - %s", formatDay(report.StartDate), formatDay(report.EndDate))
+	pdf := newPDF(nonEmpty(report.Title, "VAT Reclaim Report"), rangeText)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	// Reclaimable tax per country and rate
+	headers := []string{"Country", "Rate %", "Expenses", "Net", "VAT", "Gross"}
+	widths := []float64{30, 25, 25, 50, 50, 50}
+	align := []string{"L", "R", "R", "R", "R", "R"}
+	tableHeader(pdf, headers, widths)
+
+	alt := false
+	for _, t := range SummarizeVAT(report.Lines) {
+		rowFill(pdf, alt)
+		alt = !alt
+
+		row := []string{
+			t.Country,
+			t.Rate,
+			fmt.Sprintf("%d", t.Count),
+			formatMoney(t.NetMinor, t.Currency),
+			formatMoney(t.TaxMinor, t.Currency),
+			formatMoney(t.GrossMinor, t.Currency),
+		}
+		for i, cell := range row {
+			pdf.CellFormat(widths[i], 7, cell, "1", 0, align[i], true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// Totals rows, one per currency
+	pdf.SetFont("Arial", "B", 10)
+	pdf.SetFillColor(255, 255, 255)
+	labelWidth := widths[0] + widths[1]
+	for _, t := range VATTotals(report.Lines) {
+		pdf.CellFormat(labelWidth, 8, "Totals:", "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 8, fmt.Sprintf("%d", t.Count), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 8, formatMoney(t.NetMinor, t.Currency), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 8, formatMoney(t.TaxMinor, t.Currency), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 8, formatMoney(t.GrossMinor, t.Currency), "1", 0, "R", false, 0, "")
+		pdf.Ln(-1)
+	}
+	pdf.Ln(6)
+
+	// Every taxed expense
+	headers = []string{"Date", "Description", "Country", "Rate %", "Net", "VAT", "Gross", "VAT Number"}
+	widths = []float64{22, 70, 18, 16, 36, 36, 36, 43}
+	align = []string{"L", "L", "L", "R", "R", "R", "R", "L"}
+	tableHeader(pdf, headers, widths)
+
+	alt = false
+	missing := false
+	for _, l := range report.Lines {
+		rowFill(pdf, alt)
+		alt = !alt
+
+		vatNumber := l.VATNumber
+		if !l.Reclaimable() {
+			vatNumber, missing = "(missing)", true
+		}
+		row := []string{
+			l.Date.Format("2006-01-02"),
+			l.Description,
+			l.Country,
+			l.Rate,
+			formatMoney(l.NetMinor, l.Currency),
+			formatMoney(l.TaxMinor, l.Currency),
+			formatMoney(l.GrossMinor(), l.Currency),
+			vatNumber,
+		}
+		for i, cell := range row {
+			pdf.CellFormat(widths[i], 7, fitText(pdf, tr(cell), widths[i]), "1", 0, align[i], true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	if missing {
+		pdf.Ln(2)
+		pdf.SetFont("Arial", "I", 9)
+		pdf.CellFormat(0, 6, notReclaimableNote, "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
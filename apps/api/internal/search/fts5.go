@@ -0,0 +1,110 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// fts5Backend keeps an SQLite FTS5 table keyed by expense ID. The driver only
+// includes FTS5 when built with -tags sqlite_fts5.
+type fts5Backend struct{}
+
+func (fts5Backend) Name() string { return "fts5" }
+
+func (fts5Backend) Prepare(db *gorm.DB) error {
+	err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS expense_search USING fts5(
+		description, client_notes, category, filenames,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`).Error
+	if err != nil {
+		return err
+	}
+
+	// Rebuild when the index has drifted from the expenses table, such as on
+	// first start or after expenses were written by a build without FTS5
+	var indexed, expenses int64
+	if err := db.Raw("SELECT COUNT(*) FROM expense_search").Scan(&indexed).Error; err != nil {
+		return err
+	}
+	if err := db.Raw("SELECT COUNT(*) FROM expenses WHERE deleted_at IS NULL").Scan(&expenses).Error; err != nil {
+		return err
+	}
+	if indexed == expenses {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM expense_search").Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO expense_search (rowid, description, client_notes, category, filenames)
+			SELECT e.id, e.description, e.client_notes, e.category,
+				COALESCE((SELECT group_concat(a.filename, ' ') FROM attachments a WHERE a.expense_id = e.id AND a.deleted_at IS NULL), '')
+			FROM expenses e WHERE e.deleted_at IS NULL`).Error
+	})
+}
+
+func (fts5Backend) Index(db *gorm.DB, doc Document) error {
+	if err := db.Exec("DELETE FROM expense_search WHERE rowid = ?", doc.ExpenseID).Error; err != nil {
+		return err
+	}
+	return db.Exec(
+		"INSERT INTO expense_search (rowid, description, client_notes, category, filenames) VALUES (?, ?, ?, ?, ?)",
+		doc.ExpenseID, doc.Description, doc.ClientNotes, doc.Category, strings.Join(doc.Filenames, " "),
+	).Error
+}
+
+func (fts5Backend) Remove(db *gorm.DB, expenseID uint) error {
+	return db.Exec("DELETE FROM expense_search WHERE rowid = ?", expenseID).Error
+}
+
+func (fts5Backend) Search(query *gorm.DB, terms []string, limit, offset int) ([]Hit, int64, error) {
+	if len(terms) == 0 {
+		return []Hit{}, 0, nil
+	}
+
+	query = query.
+		Joins("JOIN expense_search ON expense_search.rowid = expenses.id").
+		Where("expense_search MATCH ?", matchExpression(terms)).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// bm25 is lower for better matches; description weighs most, then notes, filenames and category
+	var rows []struct {
+		ID      uint
+		Rank    float64
+		Snippet string
+	}
+	err := query.
+		Select("expenses.id AS id, bm25(expense_search, 10.0, 5.0, 2.0, 3.0) AS rank, "+
+			"snippet(expense_search, -1, ?, ?, '…', 12) AS snippet", markStart, markEnd).
+		Order("rank, expenses.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]Hit, len(rows))
+	for i, row := range rows {
+		hits[i] = Hit{ExpenseID: row.ID, Score: -row.Rank, Snippet: highlight(row.Snippet)}
+	}
+	return hits, total, nil
+}
+
+// matchExpression ORs the terms as prefix queries, letting bm25 rank
+// expenses that match more of them first. Terms are letters and digits only,
+// so quoting them is enough to keep FTS5 syntax out.
+func matchExpression(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	return strings.Join(parts, " OR ")
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// likeBackend searches the expense columns directly with LIKE. It needs no
+// index of its own, so it works on any database gorm supports.
+type likeBackend struct{}
+
+// snippetRunes bounds the context kept around the first match
+const snippetRunes = 80
+
+func (likeBackend) Name() string                             { return "like" }
+func (likeBackend) Prepare(db *gorm.DB) error                { return nil }
+func (likeBackend) Index(db *gorm.DB, doc Document) error    { return nil }
+func (likeBackend) Remove(db *gorm.DB, expenseID uint) error { return nil }
+
+func (likeBackend) Search(query *gorm.DB, terms []string, limit, offset int) ([]Hit, int64, error) {
+	if len(terms) == 0 {
+		return []Hit{}, 0, nil
+	}
+
+	// Terms are letters and digits only, so they never contain LIKE wildcards.
+	// Each term scores by the weight of every field it appears in.
+	filename := "EXISTS (SELECT 1 FROM attachments WHERE attachments.expense_id = expenses.id AND attachments.deleted_at IS NULL AND LOWER(attachments.filename) LIKE ?)"
+	var matches, scores []string
+	var matchArgs, scoreArgs []interface{}
+	for _, term := range terms {
+		pattern := "%" + term + "%"
+		matches = append(matches, "LOWER(expenses.description) LIKE ? OR LOWER(expenses.client_notes) LIKE ? OR LOWER(expenses.category) LIKE ? OR "+filename)
+		matchArgs = append(matchArgs, pattern, pattern, pattern, pattern)
+		scores = append(scores,
+			"CASE WHEN LOWER(expenses.description) LIKE ? THEN 10 ELSE 0 END",
+			"CASE WHEN LOWER(expenses.client_notes) LIKE ? THEN 5 ELSE 0 END",
+			"CASE WHEN LOWER(expenses.category) LIKE ? THEN 2 ELSE 0 END",
+			"CASE WHEN "+filename+" THEN 3 ELSE 0 END")
+		scoreArgs = append(scoreArgs, pattern, pattern, pattern, pattern)
+	}
+
+	query = query.Where("("+strings.Join(matches, " OR ")+")", matchArgs...).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		ID          uint
+		Score       float64
+		Description string
+		ClientNotes string
+		Category    string
+	}
+	err := query.
+		Select("expenses.id AS id, expenses.description AS description, expenses.client_notes AS client_notes, "+
+			"expenses.category AS category, ("+strings.Join(scores, " + ")+") AS score", scoreArgs...).
+		Order("score DESC, expenses.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]Hit, len(rows))
+	var filenameHits []int
+	for i, row := range rows {
+		hits[i] = Hit{ExpenseID: row.ID, Score: row.Score}
+		snippet, ok := "", false
+		for _, field := range []string{row.Description, row.ClientNotes, row.Category} {
+			if snippet, ok = snippetAround(field, terms); ok {
+				break
+			}
+		}
+		if ok {
+			hits[i].Snippet = highlight(snippet)
+		} else {
+			filenameHits = append(filenameHits, i)
+		}
+	}
+
+	// Expenses that only matched on an attachment get their snippet from the filenames
+	if len(filenameHits) > 0 {
+		ids := make([]uint, len(filenameHits))
+		for i, hit := range filenameHits {
+			ids[i] = hits[hit].ExpenseID
+		}
+		var attachments []struct {
+			ExpenseID uint
+			Filename  string
+		}
+		err := query.Session(&gorm.Session{NewDB: true}).
+			Table("attachments").
+			Select("expense_id, filename").
+			Where("expense_id IN ? AND deleted_at IS NULL", ids).
+			Order("id").
+			Scan(&attachments).Error
+		if err != nil {
+			return nil, 0, err
+		}
+		filenames := make(map[uint][]string)
+		for _, a := range attachments {
+			filenames[a.ExpenseID] = append(filenames[a.ExpenseID], a.Filename)
+		}
+		for _, hit := range filenameHits {
+			snippet, _ := snippetAround(strings.Join(filenames[hits[hit].ExpenseID], " "), terms)
+			hits[hit].Snippet = highlight(snippet)
+		}
+	}
+
+	return hits, total, nil
+}
+
+// snippetAround cuts text down to the neighbourhood of its first term match
+// and marks every match in it. Like the LIKE patterns, terms match anywhere
+// in a word.
+func snippetAround(text string, terms []string) (string, bool) {
+	runes := []rune(text)
+	lower := []rune(strings.Map(unicode.ToLower, text))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(lower); i++ {
+		for _, term := range terms {
+			t := []rune(term)
+			if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == term {
+				spans = append(spans, span{i, i + len(t)})
+				i += len(t) - 1
+				break
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	start := spans[0].start - snippetRunes/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, s := range spans {
+		if s.start < start || s.end > end {
+			continue
+		}
+		b.WriteString(string(runes[pos:s.start]))
+		b.WriteString(markStart + string(runes[s.start:s.end]) + markEnd)
+		pos = s.end
+	}
+	b.WriteString(string(runes[pos:end]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
@@ -0,0 +1,135 @@
+// Package search provides full-text search over expenses behind a pluggable
+// backend, so search keeps working when the database is not SQLite.
+package search
+
+import (
+	"html"
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Document is the searchable text of one expense.
+type Document struct {
+	ExpenseID   uint
+	Description string
+	ClientNotes string
+	Category    string
+	Filenames   []string
+}
+
+// Hit is one ranked search result.
+type Hit struct {
+	ExpenseID uint
+	// Score orders hits; higher is better. Scores are only comparable within one search.
+	Score float64
+	// Snippet is HTML-escaped text around the best match with matches wrapped in <mark>.
+	Snippet string
+}
+
+// Backend indexes and searches expense documents.
+type Backend interface {
+	// Name identifies the backend in responses and logs.
+	Name() string
+	// Prepare creates any index structures and backfills them.
+	Prepare(db *gorm.DB) error
+	// Index adds or replaces an expense's document.
+	Index(db *gorm.DB, doc Document) error
+	// Remove drops an expense from the index.
+	Remove(db *gorm.DB, expenseID uint) error
+	// Search ranks the expenses selected by query, which must be scoped to the
+	// caller's visible expenses, against the terms. It returns one page of hits
+	// and the total number of matches.
+	Search(query *gorm.DB, terms []string, limit, offset int) ([]Hit, int64, error)
+}
+
+var (
+	backendMu sync.RWMutex
+	backend   Backend = likeBackend{}
+)
+
+// Initialize selects and prepares the search backend. name is "fts5", "like"
+// or "auto", which uses FTS5 when the SQLite build supports it.
+func Initialize(db *gorm.DB, name string) error {
+	var chosen Backend
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "like":
+		chosen = likeBackend{}
+	case "fts5":
+		chosen = fts5Backend{}
+	default:
+		chosen = likeBackend{}
+		if db.Dialector.Name() == "sqlite" {
+			if err := (fts5Backend{}).Prepare(db); err == nil {
+				chosen = fts5Backend{}
+			} else {
+				log.Printf("FTS5 unavailable (%v); falling back to LIKE search. Build with -tags sqlite_fts5 to enable it.", err)
+			}
+		}
+	}
+
+	if err := chosen.Prepare(db); err != nil {
+		return err
+	}
+
+	backendMu.Lock()
+	backend = chosen
+	backendMu.Unlock()
+
+	log.Printf("Search backend: %s", chosen.Name())
+	return nil
+}
+
+// GetBackend returns the active search backend
+func GetBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+	return backend
+}
+
+// stopWords are dropped from queries so natural phrasing still ranks on the words that matter.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "for": true, "from": true, "in": true,
+	"of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "with": true,
+}
+
+// Terms splits free text into lower-cased search terms. Punctuation separates
+// terms, and stop words are dropped unless nothing else is left.
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words {
+		if !stopWords[word] && !seen[word] {
+			terms = append(terms, word)
+			seen[word] = true
+		}
+	}
+	if len(terms) == 0 {
+		for _, word := range words {
+			if !seen[word] {
+				terms = append(terms, word)
+				seen[word] = true
+			}
+		}
+	}
+	return terms
+}
+
+// Markers delimit matches in raw snippets before they are escaped.
+const (
+	markStart = "\x01"
+	markEnd   = "\x02"
+)
+
+// highlight escapes a raw snippet for HTML and turns match markers into <mark> tags.
+func highlight(raw string) string {
+	escaped := html.EscapeString(raw)
+	return strings.NewReplacer(markStart, "<mark>", markEnd, "</mark>").Replace(escaped)
+}
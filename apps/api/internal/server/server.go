@@ -11,9 +11,14 @@ import (
     "github.com/gorilla/mux"
     "golang.org/x/time/rate"
     
+    "github.com/example/next-go-monorepo/apps/api/internal/auth"
     "github.com/example/next-go-monorepo/apps/api/internal/database"
     "github.com/example/next-go-monorepo/apps/api/internal/handlers"
     "github.com/example/next-go-monorepo/apps/api/internal/middleware"
+    "github.com/example/next-go-monorepo/apps/api/internal/pagination"
+    "github.com/example/next-go-monorepo/apps/api/internal/search"
+    "github.com/example/next-go-monorepo/apps/api/internal/secrets"
+    "github.com/example/next-go-monorepo/apps/api/internal/services"
 )
 
 // Config stores runtime configuration for the HTTP server.
@@ -22,15 +27,51 @@ type Config struct {
     RateLimitEnabled  bool
     RequestsPerSecond float64
     BurstSize         int
+    Auth              auth.Config
+    CursorSecret      string
+    // SearchBackend selects expense search: "fts5", "like" or "auto"
+    SearchBackend     string
+    // TrashRetentionDays is how long deleted expenses can be restored before they are purged
+    TrashRetentionDays int
+    // RequireIfMatch rejects expense updates and deletes that do not send the expense's ETag
+    RequireIfMatch    bool
+    // IdempotencyTTLHours is how long responses to requests with an Idempotency-Key are replayed
+    IdempotencyTTLHours int
+    // BankDetailsKey is the secret bank details are encrypted with; without it they cannot be stored
+    BankDetailsKey    string
+    // LegacyOwner is the token subject of the user given expenses created before organizations
+    LegacyOwner       string
+    // TrustedProxies are the proxy addresses or CIDR ranges whose forwarding headers name the client
+    TrustedProxies    []string
 }
 
 // Server represents the API HTTP server.
 type Server struct {
     cfg             Config
     router          *mux.Router
+    authenticate    func(http.Handler) http.Handler
     generalHandler  *handlers.GeneralHandler
     expenseHandler  *handlers.ExpenseHandler
     attachmentHandler *handlers.AttachmentHandler
+    organizationHandler *handlers.OrganizationHandler
+    approvalHandler   *handlers.ApprovalHandler
+    exchangeRateHandler *handlers.ExchangeRateHandler
+    trashHandler      *handlers.TrashHandler
+    auditHandler      *handlers.AuditHandler
+    receiptHandler    *handlers.ReceiptHandler
+    duplicateHandler  *handlers.DuplicateHandler
+    policyHandler     *handlers.PolicyHandler
+    categoryHandler   *handlers.CategoryHandler
+    projectHandler    *handlers.ProjectHandler
+    costCenterHandler *handlers.CostCenterHandler
+    tagHandler        *handlers.TagHandler
+    merchantHandler   *handlers.MerchantHandler
+    allowanceHandler  *handlers.AllowanceHandler
+    taxHandler        *handlers.TaxHandler
+    recurringHandler  *handlers.RecurringExpenseHandler
+    reportHandler     *handlers.ExpenseReportHandler
+    bankAccountHandler *handlers.BankAccountHandler
+    reimbursementHandler *handlers.ReimbursementHandler
 }
 
 // New creates a server with registered routes and middleware.
@@ -50,17 +91,116 @@ func New(cfg Config) *Server {
         cfg.RateLimitEnabled = getEnvWithDefault("RATE_LIMIT_ENABLED", "true") == "true"
     }
 
+    // Token verification settings
+    if cfg.Auth.HMACSecret == "" && cfg.Auth.JWKSFile == "" {
+        cfg.Auth.HMACSecret = getEnvWithDefault("AUTH_JWT_SECRET", "")
+        cfg.Auth.JWKSFile = getEnvWithDefault("AUTH_JWKS_FILE", "")
+    }
+    if cfg.Auth.Issuer == "" {
+        cfg.Auth.Issuer = getEnvWithDefault("AUTH_ISSUER", "")
+    }
+    if cfg.Auth.Audience == "" {
+        cfg.Auth.Audience = getEnvWithDefault("AUTH_AUDIENCE", "")
+    }
+    if cfg.Auth.Leeway <= 0 {
+        cfg.Auth.Leeway = time.Duration(parseInt(getEnvWithDefault("AUTH_LEEWAY_SECONDS", "30"), 30)) * time.Second
+    }
+
+    // Pagination cursors are signed; without a configured key they expire on restart
+    if cfg.CursorSecret == "" {
+        cfg.CursorSecret = getEnvWithDefault("PAGINATION_CURSOR_SECRET", "")
+    }
+    if cfg.CursorSecret != "" {
+        pagination.SetSecret([]byte(cfg.CursorSecret))
+    } else {
+        log.Println("PAGINATION_CURSOR_SECRET not set; pagination cursors will not survive a restart")
+    }
+
+    verifier, err := auth.NewVerifier(cfg.Auth)
+    if err != nil {
+        log.Fatalf("Failed to configure authentication (set AUTH_JWT_SECRET or AUTH_JWKS_FILE): %v", err)
+    }
+
     // Initialize database
+    if cfg.LegacyOwner == "" {
+        cfg.LegacyOwner = getEnvWithDefault("LEGACY_OWNER_SUBJECT", "")
+    }
+    database.LegacyOwner = cfg.LegacyOwner
     if err := database.InitializeDatabase(); err != nil {
         log.Fatalf("Failed to initialize database: %v", err)
     }
 
+    // Full-text search uses FTS5 when the SQLite build has it and LIKE queries otherwise
+    if cfg.SearchBackend == "" {
+        cfg.SearchBackend = getEnvWithDefault("SEARCH_BACKEND", "auto")
+    }
+    if err := search.Initialize(database.GetDB(), cfg.SearchBackend); err != nil {
+        log.Fatalf("Failed to initialize search: %v", err)
+    }
+
+    if cfg.TrashRetentionDays <= 0 {
+        cfg.TrashRetentionDays = parseInt(getEnvWithDefault("TRASH_RETENTION_DAYS", "30"), 30)
+    }
+    services.SetTrashRetention(time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour)
+
+    if !cfg.RequireIfMatch {
+        cfg.RequireIfMatch = getEnvWithDefault("REQUIRE_IF_MATCH", "false") == "true"
+    }
+    handlers.SetRequireIfMatch(cfg.RequireIfMatch)
+
+    if cfg.IdempotencyTTLHours <= 0 {
+        cfg.IdempotencyTTLHours = parseInt(getEnvWithDefault("IDEMPOTENCY_TTL_HOURS", "24"), 24)
+    }
+    services.SetIdempotencyTTL(time.Duration(cfg.IdempotencyTTLHours) * time.Hour)
+
+    // Bank details are encrypted at rest; without a key they can be neither stored nor read
+    if cfg.BankDetailsKey == "" {
+        cfg.BankDetailsKey = getEnvWithDefault("BANK_DETAILS_KEY", "")
+    }
+    if cfg.BankDetailsKey == "" {
+        log.Println("BANK_DETAILS_KEY not set; bank accounts and payout files are unavailable")
+    } else if len(cfg.BankDetailsKey) < 32 {
+        log.Fatalf("BANK_DETAILS_KEY must be at least 32 characters")
+    } else if err := secrets.SetKey([]byte(cfg.BankDetailsKey)); err != nil {
+        log.Fatalf("Failed to configure bank details encryption: %v", err)
+    }
+
+    // Forwarding headers are only believed from known proxies, or any client could choose its IP
+    if len(cfg.TrustedProxies) == 0 {
+        if proxies := getEnvWithDefault("TRUSTED_PROXIES", ""); proxies != "" {
+            cfg.TrustedProxies = strings.Split(proxies, ",")
+        }
+    }
+    if err := middleware.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+        log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+    }
+
     s := &Server{
         cfg:               cfg,
         router:           mux.NewRouter(),
+        authenticate:     middleware.Authenticate(verifier, services.NewUserService().ResolvePrincipal),
         generalHandler:   handlers.NewGeneralHandler(),
         expenseHandler:   handlers.NewExpenseHandler(),
         attachmentHandler: handlers.NewAttachmentHandler(),
+        organizationHandler: handlers.NewOrganizationHandler(),
+        approvalHandler:   handlers.NewApprovalHandler(),
+        exchangeRateHandler: handlers.NewExchangeRateHandler(),
+        trashHandler:      handlers.NewTrashHandler(),
+        auditHandler:      handlers.NewAuditHandler(),
+        receiptHandler:    handlers.NewReceiptHandler(),
+        duplicateHandler:  handlers.NewDuplicateHandler(),
+        policyHandler:     handlers.NewPolicyHandler(),
+        categoryHandler:   handlers.NewCategoryHandler(),
+        projectHandler:    handlers.NewProjectHandler(),
+        costCenterHandler: handlers.NewCostCenterHandler(),
+        tagHandler:        handlers.NewTagHandler(),
+        merchantHandler:   handlers.NewMerchantHandler(),
+        allowanceHandler:  handlers.NewAllowanceHandler(),
+        taxHandler:        handlers.NewTaxHandler(),
+        recurringHandler:  handlers.NewRecurringExpenseHandler(),
+        reportHandler:     handlers.NewExpenseReportHandler(),
+        bankAccountHandler: handlers.NewBankAccountHandler(),
+        reimbursementHandler: handlers.NewReimbursementHandler(),
     }
 
     s.registerRoutes()
@@ -96,32 +236,175 @@ func (s *Server) Start(addr string) error {
         IdleTimeout:       60 * time.Second,
     }
 
+    // Permanently remove trash older than the retention period
+    go services.NewTrashService().RunPurge(time.Hour)
+    // Forget idempotency keys once their replay window has passed
+    go services.NewIdempotencyService().RunPurge(time.Hour)
+    // Create the expenses recurring expenses are due for, catching up on any missed while down
+    go services.NewRecurringExpenseService().RunMaterialize(15 * time.Minute)
+
     log.Printf("Expense Management API listening on %s", addr)
 
     return srv.ListenAndServe()
 }
 
 func (s *Server) registerRoutes() {
-    // Health check and general endpoints
+    // Every request gets an ID for logs and the audit trail
+    s.router.Use(middleware.RequestID)
+
+    // Health check is public; everything under /api requires a bearer token
     s.router.HandleFunc("/", s.generalHandler.HealthCheck).Methods("GET")
-    s.router.HandleFunc("/api/categories", s.generalHandler.GetCategories).Methods("GET")
-    s.router.HandleFunc("/api/system/info", s.generalHandler.GetSystemInfo).Methods("GET")
+
+    api := s.router.PathPrefix("/api").Subrouter()
+    api.Use(s.authenticate)
+    // Retried POSTs with an Idempotency-Key replay the first response
+    api.Use(middleware.Idempotency(services.NewIdempotencyService()))
+
+    // General endpoints
+    api.HandleFunc("/categories", s.categoryHandler.ListCategories).Methods("GET")
+    api.HandleFunc("/categories", s.categoryHandler.CreateCategory).Methods("POST")
+    api.HandleFunc("/categories/{category_id:[0-9]+}", s.categoryHandler.UpdateCategory).Methods("PUT")
+    api.HandleFunc("/categories/{category_id:[0-9]+}", s.categoryHandler.DeleteCategory).Methods("DELETE")
+    api.HandleFunc("/projects", s.projectHandler.ListProjects).Methods("GET")
+    api.HandleFunc("/projects", s.projectHandler.CreateProject).Methods("POST")
+    api.HandleFunc("/projects/{project_id:[0-9]+}", s.projectHandler.GetProject).Methods("GET")
+    api.HandleFunc("/projects/{project_id:[0-9]+}", s.projectHandler.UpdateProject).Methods("PUT")
+    api.HandleFunc("/projects/{project_id:[0-9]+}", s.projectHandler.DeleteProject).Methods("DELETE")
+    api.HandleFunc("/cost-centers", s.costCenterHandler.ListCostCenters).Methods("GET")
+    api.HandleFunc("/cost-centers", s.costCenterHandler.CreateCostCenter).Methods("POST")
+    api.HandleFunc("/cost-centers/{cost_center_id:[0-9]+}", s.costCenterHandler.UpdateCostCenter).Methods("PUT")
+    api.HandleFunc("/cost-centers/{cost_center_id:[0-9]+}", s.costCenterHandler.DeleteCostCenter).Methods("DELETE")
+    api.HandleFunc("/tags", s.tagHandler.ListTags).Methods("GET")
+    api.HandleFunc("/tags/{tag_id:[0-9]+}", s.tagHandler.RenameTag).Methods("PUT")
+    api.HandleFunc("/tags/{tag_id:[0-9]+}", s.tagHandler.DeleteTag).Methods("DELETE")
+    api.HandleFunc("/merchants", s.merchantHandler.ListMerchants).Methods("GET")
+    api.HandleFunc("/merchants", s.merchantHandler.CreateMerchant).Methods("POST")
+    api.HandleFunc("/merchants/{merchant_id:[0-9]+}", s.merchantHandler.GetMerchant).Methods("GET")
+    api.HandleFunc("/merchants/{merchant_id:[0-9]+}", s.merchantHandler.UpdateMerchant).Methods("PUT")
+    api.HandleFunc("/merchants/{merchant_id:[0-9]+}", s.merchantHandler.DeleteMerchant).Methods("DELETE")
+    api.HandleFunc("/merchants/{merchant_id:[0-9]+}/merge", s.merchantHandler.MergeMerchants).Methods("POST")
+    api.HandleFunc("/mileage-rates", s.allowanceHandler.ListMileageRates).Methods("GET")
+    api.HandleFunc("/mileage-rates", s.allowanceHandler.CreateMileageRate).Methods("POST")
+    api.HandleFunc("/mileage-rates/{rate_id:[0-9]+}", s.allowanceHandler.DeleteMileageRate).Methods("DELETE")
+    api.HandleFunc("/per-diem-rates", s.allowanceHandler.ListPerDiemRates).Methods("GET")
+    api.HandleFunc("/per-diem-rates", s.allowanceHandler.CreatePerDiemRate).Methods("POST")
+    api.HandleFunc("/per-diem-rates/{rate_id:[0-9]+}", s.allowanceHandler.DeletePerDiemRate).Methods("DELETE")
+    api.HandleFunc("/tax-rates", s.taxHandler.ListTaxRates).Methods("GET")
+    api.HandleFunc("/tax-rates", s.taxHandler.CreateTaxRate).Methods("POST")
+    api.HandleFunc("/tax-rates/{rate_id:[0-9]+}", s.taxHandler.DeleteTaxRate).Methods("DELETE")
+    api.HandleFunc("/reports/vat-reclaim", s.taxHandler.GetVATReclaimReport).Methods("GET")
+    api.HandleFunc("/recurring-expenses", s.recurringHandler.ListRecurringExpenses).Methods("GET")
+    api.HandleFunc("/recurring-expenses", s.recurringHandler.CreateRecurringExpense).Methods("POST")
+    api.HandleFunc("/recurring-expenses/upcoming", s.recurringHandler.UpcomingOccurrences).Methods("GET")
+    api.HandleFunc("/recurring-expenses/suggestions", s.recurringHandler.SuggestRecurringExpenses).Methods("GET")
+    api.HandleFunc("/recurring-expenses/{recurring_id:[0-9]+}", s.recurringHandler.GetRecurringExpense).Methods("GET")
+    api.HandleFunc("/recurring-expenses/{recurring_id:[0-9]+}", s.recurringHandler.UpdateRecurringExpense).Methods("PUT")
+    api.HandleFunc("/recurring-expenses/{recurring_id:[0-9]+}", s.recurringHandler.DeleteRecurringExpense).Methods("DELETE")
+    api.HandleFunc("/expense-reports", s.reportHandler.ListExpenseReports).Methods("GET")
+    api.HandleFunc("/expense-reports", s.reportHandler.CreateExpenseReport).Methods("POST")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}", s.reportHandler.GetExpenseReport).Methods("GET")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}", s.reportHandler.UpdateExpenseReport).Methods("PUT")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}", s.reportHandler.DeleteExpenseReport).Methods("DELETE")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/expenses", s.reportHandler.AddExpenses).Methods("POST")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/expenses/{expense_id:[0-9]+}", s.reportHandler.RemoveExpense).Methods("DELETE")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/submit", s.reportHandler.SubmitExpenseReport).Methods("POST")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/approve", s.reportHandler.ApproveExpenseReport).Methods("POST")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/reject", s.reportHandler.RejectExpenseReport).Methods("POST")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/reimburse", s.reportHandler.ReimburseExpenseReport).Methods("POST")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/transitions", s.reportHandler.GetTransitions).Methods("GET")
+    api.HandleFunc("/expense-reports/{report_id:[0-9]+}/pdf", s.reportHandler.GetExpenseReportPDF).Methods("GET")
+    api.HandleFunc("/system/info", s.generalHandler.GetSystemInfo).Methods("GET")
+    
+    // Reimbursement endpoints; batches pay approved expenses back by bank transfer
+    api.HandleFunc("/bank-account", s.bankAccountHandler.GetMyBankAccount).Methods("GET")
+    api.HandleFunc("/bank-account", s.bankAccountHandler.SetMyBankAccount).Methods("PUT")
+    api.HandleFunc("/bank-account", s.bankAccountHandler.DeleteMyBankAccount).Methods("DELETE")
+    api.HandleFunc("/reimbursements/due", s.reimbursementHandler.ListDueReimbursements).Methods("GET")
+    api.HandleFunc("/reimbursement-batches", s.reimbursementHandler.ListReimbursementBatches).Methods("GET")
+    api.HandleFunc("/reimbursement-batches", s.reimbursementHandler.CreateReimbursementBatch).Methods("POST")
+    api.HandleFunc("/reimbursement-batches/{batch_id:[0-9]+}", s.reimbursementHandler.GetReimbursementBatch).Methods("GET")
+    api.HandleFunc("/reimbursement-batches/{batch_id:[0-9]+}", s.reimbursementHandler.DeleteReimbursementBatch).Methods("DELETE")
+    api.HandleFunc("/reimbursement-batches/{batch_id:[0-9]+}/pay", s.reimbursementHandler.PayReimbursementBatch).Methods("POST")
+    api.HandleFunc("/reimbursement-batches/{batch_id:[0-9]+}/reconcile", s.reimbursementHandler.ReconcileReimbursementBatch).Methods("POST")
+    api.HandleFunc("/reimbursement-batches/{batch_id:[0-9]+}/export", s.reimbursementHandler.ExportReimbursementBatch).Methods("GET")
     
     // Expense management endpoints
-    s.router.HandleFunc("/api/expenses", s.expenseHandler.CreateExpense).Methods("POST")
-    s.router.HandleFunc("/api/expenses", s.expenseHandler.GetExpenses).Methods("GET")
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}", s.expenseHandler.GetExpenseByID).Methods("GET")
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}", s.expenseHandler.UpdateExpense).Methods("PUT")
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}", s.expenseHandler.DeleteExpense).Methods("DELETE")
+    api.HandleFunc("/expenses", s.expenseHandler.CreateExpense).Methods("POST")
+    api.HandleFunc("/expenses", s.expenseHandler.GetExpenses).Methods("GET")
+    api.HandleFunc("/expenses/search", s.expenseHandler.SearchExpenses).Methods("GET")
+    api.HandleFunc("/expenses/summary", s.expenseHandler.SummarizeExpenses).Methods("GET")
+    api.HandleFunc("/expenses/import", s.expenseHandler.ImportExpenses).Methods("POST")
+    api.HandleFunc("/expenses/import/statement", s.expenseHandler.ImportStatement).Methods("POST")
+    api.HandleFunc("/expenses/duplicates", s.duplicateHandler.ListDuplicates).Methods("GET")
+    api.HandleFunc("/expenses/duplicates/{pair_id:[0-9]+}/dismiss", s.duplicateHandler.DismissDuplicate).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}", s.expenseHandler.GetExpenseByID).Methods("GET")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}", s.expenseHandler.UpdateExpense).Methods("PUT")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}", s.expenseHandler.DeleteExpense).Methods("DELETE")
+    
+    // Trash endpoints; deleted records stay restorable until purged
+    api.HandleFunc("/trash", s.trashHandler.ListTrash).Methods("GET")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/restore", s.trashHandler.RestoreExpense).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/attachments/{attachment_id:[0-9]+}/restore", s.trashHandler.RestoreAttachment).Methods("POST")
+    
+    // Audit trail endpoints
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/history", s.auditHandler.GetExpenseHistory).Methods("GET")
+    api.HandleFunc("/audit-events", s.auditHandler.ListAuditEvents).Methods("GET")
+    
+    // Approval workflow endpoints
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/submit", s.approvalHandler.SubmitExpense).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/approve", s.approvalHandler.ApproveExpense).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/reject", s.approvalHandler.RejectExpense).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/reimburse", s.approvalHandler.ReimburseExpense).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/transitions", s.approvalHandler.GetTransitions).Methods("GET")
+    api.HandleFunc("/approval-rules", s.approvalHandler.ListRules).Methods("GET")
+    api.HandleFunc("/approval-rules", s.approvalHandler.CreateRule).Methods("POST")
+    api.HandleFunc("/approval-rules/{rule_id:[0-9]+}", s.approvalHandler.DeleteRule).Methods("DELETE")
+    
+    // Expense policy endpoints
+    api.HandleFunc("/policies", s.policyHandler.ListRules).Methods("GET")
+    api.HandleFunc("/policies", s.policyHandler.CreateRule).Methods("POST")
+    api.HandleFunc("/policies/evaluate", s.policyHandler.Evaluate).Methods("POST")
+    api.HandleFunc("/policies/{policy_id:[0-9]+}", s.policyHandler.UpdateRule).Methods("PUT")
+    api.HandleFunc("/policies/{policy_id:[0-9]+}", s.policyHandler.DeleteRule).Methods("DELETE")
     
     // AI suggestion endpoints
-    s.router.HandleFunc("/api/expenses/ai-suggest", s.expenseHandler.GetAISuggestion).Methods("POST")
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}/ai-suggestions/{suggestion_id:[0-9]+}/approve", s.expenseHandler.ApproveSuggestion).Methods("POST")
+    api.HandleFunc("/expenses/ai-suggest", s.expenseHandler.GetAISuggestion).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/ai-suggestions/{suggestion_id:[0-9]+}/approve", s.expenseHandler.ApproveSuggestion).Methods("POST")
     
     // Attachment endpoints
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}/attachments", s.attachmentHandler.UploadAttachment).Methods("POST")
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}/attachments/{attachment_id:[0-9]+}", s.attachmentHandler.GetAttachment).Methods("GET")
-    s.router.HandleFunc("/api/expenses/{expense_id:[0-9]+}/attachments/{attachment_id:[0-9]+}", s.attachmentHandler.DeleteAttachment).Methods("DELETE")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/attachments", s.attachmentHandler.UploadAttachment).Methods("POST")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/attachments/{attachment_id:[0-9]+}", s.attachmentHandler.GetAttachment).Methods("GET")
+    api.HandleFunc("/expenses/{expense_id:[0-9]+}/attachments/{attachment_id:[0-9]+}", s.attachmentHandler.DeleteAttachment).Methods("DELETE")
+    
+    // Receipt matching endpoints
+    api.HandleFunc("/receipts", s.receiptHandler.UploadReceipt).Methods("POST")
+    api.HandleFunc("/receipts", s.receiptHandler.ListReceipts).Methods("GET")
+    api.HandleFunc("/receipts/{receipt_id:[0-9]+}", s.receiptHandler.GetReceipt).Methods("GET")
+    api.HandleFunc("/receipts/match", s.receiptHandler.MatchReceipts).Methods("POST")
+    api.HandleFunc("/receipts/matches", s.receiptHandler.ReviewQueue).Methods("GET")
+    api.HandleFunc("/receipts/matches/{match_id:[0-9]+}/confirm", s.receiptHandler.ConfirmMatch).Methods("POST")
+    api.HandleFunc("/receipts/matches/{match_id:[0-9]+}/reject", s.receiptHandler.RejectMatch).Methods("POST")
+    
+    // Exchange rate endpoints
+    api.HandleFunc("/exchange-rates", s.exchangeRateHandler.ListRates).Methods("GET")
+    api.HandleFunc("/exchange-rates", s.exchangeRateHandler.CreateRate).Methods("POST")
+    api.HandleFunc("/exchange-rates/import", s.exchangeRateHandler.ImportRates).Methods("POST")
+    
+    // Organization and membership endpoints; {org_id} selects the organization the request acts in
+    api.HandleFunc("/organizations", s.organizationHandler.ListOrganizations).Methods("GET")
+    api.HandleFunc("/organizations", s.organizationHandler.CreateOrganization).Methods("POST")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}", s.organizationHandler.UpdateOrganization).Methods("PUT")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/bank-account", s.bankAccountHandler.GetOrganizationBankAccount).Methods("GET")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/bank-account", s.bankAccountHandler.SetOrganizationBankAccount).Methods("PUT")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/bank-account", s.bankAccountHandler.DeleteOrganizationBankAccount).Methods("DELETE")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/members", s.organizationHandler.ListMembers).Methods("GET")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/members/{membership_id:[0-9]+}", s.organizationHandler.UpdateMember).Methods("PUT")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/members/{membership_id:[0-9]+}", s.organizationHandler.RemoveMember).Methods("DELETE")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/invitations", s.organizationHandler.InviteMember).Methods("POST")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/invitations", s.organizationHandler.ListInvitations).Methods("GET")
+    api.HandleFunc("/organizations/{org_id:[0-9]+}/invitations/{invitation_id:[0-9]+}", s.organizationHandler.RevokeInvitation).Methods("DELETE")
+    api.HandleFunc("/invitations", s.organizationHandler.ListMyInvitations).Methods("GET")
+    api.HandleFunc("/invitations/{invitation_id:[0-9]+}/accept", s.organizationHandler.AcceptInvitation).Methods("POST")
 }
 
 // withCORS middleware to handle CORS for all routes
@@ -133,11 +416,12 @@ func (s *Server) withCORS(handler http.Handler) http.Handler {
         if allowedOrigin != "" {
             w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
             w.Header().Set("Vary", "Origin")
+            w.Header().Set("Access-Control-Expose-Headers", "ETag, Link, Deprecation, "+middleware.RequestIDHeader+", "+middleware.IdempotentReplayHeader)
         }
 
         if r.Method == http.MethodOptions {
             w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+middleware.OrganizationHeader+", "+middleware.RequestIDHeader+", If-Match, If-None-Match, "+middleware.IdempotencyKeyHeader)
             w.WriteHeader(http.StatusNoContent)
             return
         }
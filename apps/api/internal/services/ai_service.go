@@ -7,8 +7,12 @@ import (
 	
 	"gorm.io/gorm"
 	
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
 	"github.com/example/next-go-monorepo/apps/api/internal/database"
 	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
 )
 
 type AIService struct {
@@ -21,23 +25,76 @@ func NewAIService() *AIService {
 	}
 }
 
-// GetAISuggestion generates AI suggestions for expense categorization
-func (s *AIService) GetAISuggestion(req models.AISuggestRequest) (*models.AISuggestResponse, error) {
+// GetAISuggestion generates AI suggestions for expense categorization, and a
+// category for each line of a split expense. Suggested categories are always
+// one of the organization's active categories, or empty if none fits.
+func (s *AIService) GetAISuggestion(p *auth.Principal, req models.AISuggestRequest) (*models.AISuggestResponse, error) {
+	db := scoped(s.db, p)
+	
+	merchant, err := expenseMerchant(db, req.MerchantID, "", req.Description)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Simple rule-based AI implementation
-	category := s.categorizeExpense(req.Description, req.Amount)
+	category, err := suggestedCategory(db, s.categorizeExpense(merchant, req.Description, req.Amount), req.Description)
+	if err != nil {
+		return nil, err
+	}
 	notes := s.generateNotes(req.Description, req.Amount, category)
 	
-	return &models.AISuggestResponse{
+	response := &models.AISuggestResponse{
 		Category:    category,
 		ClientNotes: notes,
-	}, nil
+		Merchant:    merchant,
+	}
+	for _, line := range req.Lines {
+		// A line without its own description is described by the expense's
+		description := line.Description
+		lineMerchant := merchant
+		if strings.TrimSpace(description) == "" {
+			description = req.Description
+		} else if lineMerchant, err = matchMerchant(db, description); err != nil {
+			return nil, err
+		}
+		lineCategory, err := suggestedCategory(db, s.categorizeExpense(lineMerchant, description, line.Amount), description)
+		if err != nil {
+			return nil, err
+		}
+		response.Lines = append(response.Lines, models.AISuggestLineResponse{
+			Description: line.Description,
+			Amount:      line.Amount,
+			Category:    lineCategory,
+		})
+	}
+	
+	return response, nil
 }
 
 // ApproveSuggestion handles approval or modification of AI suggestions
-func (s *AIService) ApproveSuggestion(expenseID uint, req models.ApproveSuggestionRequest) (*models.Expense, error) {
+func (s *AIService) ApproveSuggestion(p *auth.Principal, expenseID uint, req models.ApproveSuggestionRequest) (*models.Expense, error) {
+	db := scoped(s.db, p)
+	
+	// Get the expense first so suggestions on other users' expenses are indistinguishable from missing ones
+	var expense models.Expense
+	if err := db.Scopes(visibleTo(p, authz.ExpenseRead), withAllocations).First(&expense, expenseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense not found")
+		}
+		return nil, err
+	}
+	
+	if !authz.AllowedOn(p, authz.ExpenseUpdate, expense.UserID) {
+		return nil, errors.New("permission denied")
+	}
+	
+	if !expense.Editable() {
+		return nil, errors.New("expense is locked")
+	}
+	
 	// Get the AI suggestion
 	var suggestion models.AISuggestion
-	if err := s.db.First(&suggestion, req.SuggestionID).Error; err != nil {
+	if err := db.First(&suggestion, req.SuggestionID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("suggestion not found")
 		}
@@ -49,13 +106,8 @@ func (s *AIService) ApproveSuggestion(expenseID uint, req models.ApproveSuggesti
 		return nil, errors.New("suggestion does not belong to this expense")
 	}
 	
-	// Get the expense
-	var expense models.Expense
-	if err := s.db.First(&expense, expenseID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("expense not found")
-		}
-		return nil, err
+	if suggestion.AllocationID != nil {
+		return s.approveLineSuggestion(db, p, expense, suggestion, req)
 	}
 	
 	// Update the suggestion based on user's choice
@@ -68,6 +120,15 @@ func (s *AIService) ApproveSuggestion(expenseID uint, req models.ApproveSuggesti
 		userModified = true
 	}
 	
+	// The category may have been archived since it was suggested
+	if !strings.EqualFold(strings.TrimSpace(finalCategory), expense.Category) {
+		category, err := resolveCategory(db, finalCategory)
+		if err != nil {
+			return nil, err
+		}
+		finalCategory = category
+	}
+	
 	if !req.AcceptNotes && req.CustomNotes != nil {
 		finalNotes = *req.CustomNotes
 		userModified = true
@@ -79,29 +140,123 @@ func (s *AIService) ApproveSuggestion(expenseID uint, req models.ApproveSuggesti
 	suggestion.FinalCategory = finalCategory
 	suggestion.FinalNotes = finalNotes
 	
-	if err := s.db.Save(&suggestion).Error; err != nil {
-		return nil, err
-	}
-	
 	// Update the expense with final values
+	before := expense
 	expense.Category = finalCategory
 	expense.ClientNotes = finalNotes
 	expense.UpdatedAt = time.Now()
 	
-	if err := s.db.Save(&expense).Error; err != nil {
+	// The tax code follows the new category, keeping an entered rate
+	if expense.Category != before.Category && expense.TaxJurisdiction != "" {
+		t := taxDetails{jurisdiction: expense.TaxJurisdiction}
+		if expense.TaxRateID == nil {
+			t.rate = money.Decimal(expense.TaxRate)
+		}
+		if err := applyTax(db, &expense, t); err != nil {
+			return nil, err
+		}
+	}
+	
+	violations, err := checkPolicies(db, &expense, false)
+	if err != nil {
+		return &expense, err
+	}
+	
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&suggestion).Error; err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, &expense); err != nil {
+			return err
+		}
+		if err := tx.Omit("Allocations").Save(&expense).Error; err != nil {
+			return err
+		}
+		if err := recordChange(tx, p, audit.Change{
+			Action: "expense.apply_suggestion", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+			Before: before, After: expense,
+		}); err != nil {
+			return err
+		}
+		if err := recordViolations(tx, expense.ID, violations); err != nil {
+			return err
+		}
+		return indexExpense(tx, expense.ID)
+	})
+	if err != nil {
 		return nil, err
 	}
 	
 	// Reload expense with associations
-	if err := s.db.Preload("Attachments").Preload("AISuggestions").First(&expense, expense.ID).Error; err != nil {
+	if err := db.Scopes(withDetails).First(&expense, expense.ID).Error; err != nil {
 		return nil, err
 	}
 	
 	return &expense, nil
 }
 
-// categorizeExpense provides simple rule-based categorization
-func (s *AIService) categorizeExpense(description string, amount float64) string {
+// approveLineSuggestion applies the category suggested for one line of a split
+// expense to that line. Lines carry no notes, so only the category is applied.
+func (s *AIService) approveLineSuggestion(db *gorm.DB, p *auth.Principal, expense models.Expense, suggestion models.AISuggestion, req models.ApproveSuggestionRequest) (*models.Expense, error) {
+	var line models.ExpenseAllocation
+	if err := db.Where("expense_id = ?", expense.ID).First(&line, *suggestion.AllocationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("suggestion not found")
+		}
+		return nil, err
+	}
+	
+	finalCategory := suggestion.SuggestedCategory
+	if !req.AcceptCategory && req.CustomCategory != nil {
+		finalCategory = *req.CustomCategory
+	}
+	if !strings.EqualFold(strings.TrimSpace(finalCategory), line.Category) {
+		category, err := resolveCategory(db, finalCategory)
+		if err != nil {
+			return nil, err
+		}
+		finalCategory = category
+	}
+	
+	suggestion.WasAccepted = req.AcceptCategory && req.CustomCategory == nil
+	suggestion.UserModified = !req.AcceptCategory && req.CustomCategory != nil
+	suggestion.FinalCategory = finalCategory
+	
+	before := line
+	line.Category = finalCategory
+	
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&suggestion).Error; err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, &expense); err != nil {
+			return err
+		}
+		if err := tx.Omit("Project", "CostCenter").Save(&line).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "expense.apply_suggestion", EntityType: "expense_allocation", EntityID: line.ID, ExpenseID: expense.ID,
+			Before: before, After: line,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := db.Scopes(withDetails).First(&expense, expense.ID).Error; err != nil {
+		return nil, err
+	}
+	return &expense, nil
+}
+
+// categorizeExpense provides simple rule-based categorization. The merchant's
+// default category, when it has one, outranks every keyword.
+func (s *AIService) categorizeExpense(merchant *models.Merchant, description string, amount float64) string {
+	if merchant != nil && merchant.DefaultCategory != "" {
+		return merchant.DefaultCategory
+	}
+	
 	desc := strings.ToLower(description)
 	
 	// Travel-related keywords
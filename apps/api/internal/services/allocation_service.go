@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// allocatedExpenses matches expenses whose own value of column, or the value
+// on one of their allocation lines, is in the given list. Lines leaving the
+// column empty take the expense's value.
+const allocatedExpenses = `((expenses.%[1]s IN ? AND (NOT EXISTS (SELECT 1 FROM expense_allocations WHERE expense_allocations.expense_id = expenses.id)` +
+	` OR EXISTS (SELECT 1 FROM expense_allocations WHERE expense_allocations.expense_id = expenses.id AND COALESCE(expense_allocations.%[1]s, '') = '')))` +
+	` OR EXISTS (SELECT 1 FROM expense_allocations WHERE expense_allocations.expense_id = expenses.id AND expense_allocations.%[1]s IN ?))`
+
+// buildAllocations validates the requested lines of an expense whose amount
+// has been set and works out their amounts. Categories, projects and cost
+// centers the expense's current lines already use are accepted even if they
+// have since been archived or closed.
+func buildAllocations(db *gorm.DB, organizationID uint, expense *models.Expense, reqs []models.AllocationRequest, current []models.ExpenseAllocation) ([]models.ExpenseAllocation, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	categories := make(map[string]string)
+	projects := make(map[uint]bool)
+	costCenters := make(map[uint]bool)
+	for _, line := range current {
+		categories[strings.ToLower(line.Category)] = line.Category
+		if line.ProjectID != nil {
+			projects[*line.ProjectID] = true
+		}
+		if line.CostCenterID != nil {
+			costCenters[*line.CostCenterID] = true
+		}
+	}
+
+	byPercent := strings.TrimSpace(string(reqs[0].Percent)) != ""
+	lines := make([]models.ExpenseAllocation, len(reqs))
+	for i, req := range reqs {
+		hasAmount := strings.TrimSpace(string(req.Amount)) != ""
+		hasPercent := strings.TrimSpace(string(req.Percent)) != ""
+		if hasAmount == hasPercent {
+			return nil, errors.New("allocation needs an amount or a percent")
+		}
+		if hasPercent != byPercent {
+			return nil, errors.New("allocations mix amounts and percents")
+		}
+
+		line := models.ExpenseAllocation{
+			Position:    i,
+			Description: strings.TrimSpace(req.Description),
+		}
+		if byPercent {
+			percent, err := parsePercent(req.Percent)
+			if err != nil {
+				return nil, err
+			}
+			line.Percent = money.Decimal(money.FormatRate(percent))
+		} else {
+			minor, err := req.Amount.Minor(expense.Currency)
+			if err != nil || minor <= 0 {
+				return nil, errors.New("invalid allocation amount")
+			}
+			line.AmountMinor = minor
+		}
+
+		if name := strings.TrimSpace(req.Category); name != "" {
+			if used, ok := categories[strings.ToLower(name)]; ok {
+				line.Category = used
+			} else {
+				category, err := resolveCategory(db, name)
+				if err != nil {
+					return nil, err
+				}
+				line.Category = category
+			}
+		}
+		if req.ProjectID != nil && *req.ProjectID != 0 {
+			if !projects[*req.ProjectID] {
+				if err := openProject(db, *req.ProjectID); err != nil {
+					return nil, err
+				}
+			}
+			line.ProjectID = req.ProjectID
+		}
+		if req.CostCenterID != nil && *req.CostCenterID != 0 {
+			if !costCenters[*req.CostCenterID] {
+				if err := activeCostCenter(db, *req.CostCenterID); err != nil {
+					return nil, err
+				}
+			}
+			line.CostCenterID = req.CostCenterID
+		}
+		if req.UserID != nil && *req.UserID != 0 {
+			var count int64
+			err := db.Model(&models.Membership{}).
+				Where("organization_id = ? AND user_id = ?", organizationID, *req.UserID).Count(&count).Error
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, errors.New("allocation user not found")
+			}
+			line.UserID = req.UserID
+		}
+		lines[i] = line
+	}
+
+	if err := apportion(expense, lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// apportion sets the amounts of an expense's lines from its amount. Lines
+// given as percents are recomputed; lines given as amounts must add up to the
+// expense's amount. Base amounts are split in proportion to the amounts. Any
+// rounding difference goes to the last line so the lines always add up.
+func apportion(expense *models.Expense, lines []models.ExpenseAllocation) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if lines[0].Percent != "" {
+		shares := make([]*big.Rat, len(lines))
+		total := new(big.Rat)
+		for i, line := range lines {
+			percent, err := parsePercent(line.Percent)
+			if err != nil {
+				return err
+			}
+			total.Add(total, percent)
+			shares[i] = percent.Quo(percent, big.NewRat(100, 1))
+		}
+		if total.Cmp(big.NewRat(100, 1)) != 0 {
+			return errors.New("allocations do not add up to the amount")
+		}
+		amounts, err := split(expense.AmountMinor, expense.Currency, shares)
+		if err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].AmountMinor = amounts[i]
+		}
+	} else {
+		var total int64
+		for _, line := range lines {
+			// Amounts in another currency no longer describe the expense
+			if line.Currency != "" && line.Currency != expense.Currency {
+				return errors.New("allocations do not add up to the amount")
+			}
+			total += line.AmountMinor
+		}
+		if total != expense.AmountMinor {
+			return errors.New("allocations do not add up to the amount")
+		}
+	}
+
+	shares := make([]*big.Rat, len(lines))
+	for i, line := range lines {
+		if line.AmountMinor <= 0 {
+			return errors.New("invalid allocation amount")
+		}
+		shares[i] = big.NewRat(line.AmountMinor, expense.AmountMinor)
+	}
+	baseAmounts, err := split(expense.BaseAmountMinor, expense.BaseCurrency, shares)
+	if err != nil {
+		return err
+	}
+
+	for i := range lines {
+		lines[i].Currency = expense.Currency
+		lines[i].BaseAmountMinor = baseAmounts[i]
+		lines[i].BaseCurrency = expense.BaseCurrency
+		lines[i].Amount = money.FromMinor(lines[i].AmountMinor, lines[i].Currency)
+		lines[i].BaseAmount = money.FromMinor(lines[i].BaseAmountMinor, lines[i].BaseCurrency)
+	}
+	return nil
+}
+
+// split divides total minor units into the given shares, rounding each and
+// giving the remainder to the last
+func split(total int64, currency string, shares []*big.Rat) ([]int64, error) {
+	parts := make([]int64, len(shares))
+	remaining := total
+	for i, share := range shares[:len(shares)-1] {
+		part, err := money.Convert(total, currency, currency, share)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = part
+		remaining -= part
+	}
+	parts[len(parts)-1] = remaining
+	return parts, nil
+}
+
+// parsePercent reads a percentage above 0 and up to 100 with at most six decimals
+func parsePercent(value money.Decimal) (*big.Rat, error) {
+	text := strings.TrimSpace(string(value))
+	percent, ok := new(big.Rat).SetString(text)
+	if !ok || strings.ContainsAny(text, "eE/") || percent.Sign() <= 0 || percent.Cmp(big.NewRat(100, 1)) > 0 ||
+		!new(big.Rat).Mul(percent, big.NewRat(1000000, 1)).IsInt() {
+		return nil, errors.New("invalid allocation percent")
+	}
+	return percent, nil
+}
+
+// replaceAllocations swaps an expense's lines for new ones, dropping the AI
+// suggestions made for the old lines
+func replaceAllocations(tx *gorm.DB, expenseID uint, lines []models.ExpenseAllocation) error {
+	var old []uint
+	if err := tx.Model(&models.ExpenseAllocation{}).Where("expense_id = ?", expenseID).Pluck("id", &old).Error; err != nil {
+		return err
+	}
+	if len(old) > 0 {
+		if err := tx.Where("allocation_id IN ?", old).Delete(&models.AISuggestion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", old).Delete(&models.ExpenseAllocation{}).Error; err != nil {
+			return err
+		}
+	}
+	for i := range lines {
+		lines[i].ID = 0
+		lines[i].ExpenseID = expenseID
+		if err := tx.Omit("Project", "CostCenter").Create(&lines[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allocationLines returns what an expense is booked to, one entry per line,
+// or the whole expense when it is not split. Lines take the expense's
+// category, project and cost center where they leave them empty.
+func allocationLines(expense models.Expense) []models.ExpenseAllocation {
+	if len(expense.Allocations) == 0 {
+		return []models.ExpenseAllocation{{
+			ExpenseID:       expense.ID,
+			Description:     expense.Description,
+			Category:        expense.Category,
+			ProjectID:       expense.ProjectID,
+			CostCenterID:    expense.CostCenterID,
+			AmountMinor:     expense.AmountMinor,
+			Currency:        expense.Currency,
+			BaseAmountMinor: expense.BaseAmountMinor,
+			BaseCurrency:    expense.BaseCurrency,
+			Project:         expense.Project,
+			CostCenter:      expense.CostCenter,
+		}}
+	}
+	lines := make([]models.ExpenseAllocation, len(expense.Allocations))
+	for i, line := range expense.Allocations {
+		if line.Description == "" {
+			line.Description = expense.Description
+		}
+		if line.Category == "" {
+			line.Category = expense.Category
+		}
+		if line.ProjectID == nil {
+			line.ProjectID, line.Project = expense.ProjectID, expense.Project
+		}
+		if line.CostCenterID == nil {
+			line.CostCenterID, line.CostCenter = expense.CostCenterID, expense.CostCenter
+		}
+		lines[i] = line
+	}
+	return lines
+}
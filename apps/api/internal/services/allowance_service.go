@@ -0,0 +1,416 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// defaultVehicleType is claimed when a mileage claim names no vehicle
+const defaultVehicleType = "car"
+
+// kilometersPerMile converts distances between units
+var kilometersPerMile = big.NewRat(1609344, 1000000)
+
+// AllowanceService manages the mileage and per-diem rate tables computed
+// expenses are priced from
+type AllowanceService struct {
+	db *gorm.DB
+}
+
+func NewAllowanceService() *AllowanceService {
+	return &AllowanceService{
+		db: database.GetDB(),
+	}
+}
+
+// ListMileageRates returns the organization's mileage rates, newest first
+func (s *AllowanceService) ListMileageRates(p *auth.Principal) ([]models.MileageRate, error) {
+	rates := []models.MileageRate{}
+	err := scoped(s.db, p).Order("effective_from DESC, vehicle_type, country").Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// CreateMileageRate records a mileage rate, replacing any existing rate for
+// the same vehicle type, country and effective date
+func (s *AllowanceService) CreateMileageRate(p *auth.Principal, req models.CreateMileageRateRequest) (*models.MileageRate, error) {
+	effectiveFrom, err := parseRateDate(req.EffectiveFrom)
+	if err != nil {
+		return nil, errors.New("invalid effective date")
+	}
+	country, err := normalizeCountry(req.Country)
+	if err != nil {
+		return nil, err
+	}
+	vehicleType := strings.ToLower(strings.TrimSpace(req.VehicleType))
+	if vehicleType == "" {
+		vehicleType = defaultVehicleType
+	}
+	unit := models.DistanceUnit(strings.ToLower(strings.TrimSpace(string(req.Unit))))
+	if unit == "" {
+		unit = models.Kilometers
+	}
+	if unit != models.Kilometers && unit != models.Miles {
+		return nil, errors.New("invalid distance unit")
+	}
+	currency := money.Normalize(req.Currency)
+	if !money.Valid(currency) {
+		return nil, errors.New("unsupported currency")
+	}
+	rate, err := money.ParseRate(string(req.Rate))
+	if err != nil {
+		return nil, errors.New("invalid rate")
+	}
+
+	row := models.MileageRate{
+		VehicleType:   vehicleType,
+		Country:       country,
+		EffectiveFrom: effectiveFrom,
+		Unit:          unit,
+		Rate:          money.FormatRate(rate),
+		Currency:      currency,
+	}
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		change := audit.Change{Action: "mileage_rate.create", EntityType: "mileage_rate"}
+		var existing models.MileageRate
+		err := tx.Where("vehicle_type = ? AND country = ? AND effective_from = ?", vehicleType, country, effectiveFrom).First(&existing).Error
+		if err == nil {
+			change.Action, change.Before = "mileage_rate.update", existing
+			row.ID, row.CreatedAt = existing.ID, existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Save(&row).Error; err != nil {
+			return err
+		}
+		change.EntityID, change.After = row.ID, row
+		return recordChange(tx, p, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// DeleteMileageRate removes a mileage rate. Expenses already priced with it
+// keep their amounts.
+func (s *AllowanceService) DeleteMileageRate(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var rate models.MileageRate
+		if err := tx.First(&rate, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("rate not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&rate).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "mileage_rate.delete", EntityType: "mileage_rate", EntityID: rate.ID,
+			Before: rate,
+		})
+	})
+}
+
+// ListPerDiemRates returns the organization's per-diem rates, newest first
+func (s *AllowanceService) ListPerDiemRates(p *auth.Principal) ([]models.PerDiemRate, error) {
+	rates := []models.PerDiemRate{}
+	if err := scoped(s.db, p).Order("effective_from DESC, country").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// CreatePerDiemRate records a per-diem rate, replacing any existing rate for
+// the same country and effective date
+func (s *AllowanceService) CreatePerDiemRate(p *auth.Principal, req models.CreatePerDiemRateRequest) (*models.PerDiemRate, error) {
+	effectiveFrom, err := parseRateDate(req.EffectiveFrom)
+	if err != nil {
+		return nil, errors.New("invalid effective date")
+	}
+	country, err := normalizeCountry(req.Country)
+	if err != nil {
+		return nil, err
+	}
+	currency := money.Normalize(req.Currency)
+	if !money.Valid(currency) {
+		return nil, errors.New("unsupported currency")
+	}
+	daily, err := req.DailyRate.Minor(currency)
+	if err != nil || daily <= 0 {
+		return nil, errors.New("invalid rate")
+	}
+
+	partialDay := 100
+	if req.PartialDayPercent != nil {
+		partialDay = *req.PartialDayPercent
+	}
+	for _, percent := range []int{partialDay, req.BreakfastPercent, req.LunchPercent, req.DinnerPercent} {
+		if percent < 0 || percent > 100 {
+			return nil, errors.New("invalid percent")
+		}
+	}
+	if req.MinPartialDayHours < 0 || req.MinPartialDayHours > 24 {
+		return nil, errors.New("invalid minimum hours")
+	}
+
+	row := models.PerDiemRate{
+		Country:            country,
+		EffectiveFrom:      effectiveFrom,
+		DailyRateMinor:     daily,
+		Currency:           currency,
+		PartialDayPercent:  partialDay,
+		MinPartialDayHours: req.MinPartialDayHours,
+		BreakfastPercent:   req.BreakfastPercent,
+		LunchPercent:       req.LunchPercent,
+		DinnerPercent:      req.DinnerPercent,
+	}
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		change := audit.Change{Action: "per_diem_rate.create", EntityType: "per_diem_rate"}
+		var existing models.PerDiemRate
+		err := tx.Where("country = ? AND effective_from = ?", country, effectiveFrom).First(&existing).Error
+		if err == nil {
+			change.Action, change.Before = "per_diem_rate.update", existing
+			row.ID, row.CreatedAt = existing.ID, existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Save(&row).Error; err != nil {
+			return err
+		}
+		change.EntityID, change.After = row.ID, row
+		return recordChange(tx, p, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// DeletePerDiemRate removes a per-diem rate. Expenses already priced with it
+// keep their amounts.
+func (s *AllowanceService) DeletePerDiemRate(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var rate models.PerDiemRate
+		if err := tx.First(&rate, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("rate not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&rate).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "per_diem_rate.delete", EntityType: "per_diem_rate", EntityID: rate.ID,
+			Before: rate,
+		})
+	})
+}
+
+// expenseAmount returns the amount of an expense of the expense's type.
+// Standard expenses take the given amount. Mileage and per-diem amounts are
+// computed from their inputs at the rates in effect on the expense date, and
+// the inputs and rate are recorded on the expense along with its currency.
+func expenseAmount(db *gorm.DB, expense *models.Expense, amount money.Decimal, mileage *models.MileageRequest, perDiem *models.PerDiemRequest) (money.Decimal, error) {
+	switch expense.Type {
+	case models.ExpenseStandard:
+		if mileage != nil || perDiem != nil {
+			return "", errors.New("details do not match the expense type")
+		}
+		return amount, nil
+	case models.ExpenseMileage:
+		if amount != "" {
+			return "", errors.New("amount is computed")
+		}
+		if mileage == nil || perDiem != nil {
+			return "", errors.New("details do not match the expense type")
+		}
+		return computeMileage(db, expense, mileage)
+	case models.ExpensePerDiem:
+		if amount != "" {
+			return "", errors.New("amount is computed")
+		}
+		if perDiem == nil || mileage != nil {
+			return "", errors.New("details do not match the expense type")
+		}
+		return computePerDiem(db, expense, perDiem)
+	default:
+		return "", errors.New("invalid expense type")
+	}
+}
+
+// computeMileage prices a distance driven at the mileage rate for the vehicle
+// type and country on the expense date
+func computeMileage(db *gorm.DB, expense *models.Expense, req *models.MileageRequest) (money.Decimal, error) {
+	distance, err := money.ParseRate(string(req.Distance))
+	if err != nil {
+		return "", errors.New("invalid distance")
+	}
+	country, err := normalizeCountry(req.Country)
+	if err != nil {
+		return "", err
+	}
+	vehicleType := strings.ToLower(strings.TrimSpace(req.VehicleType))
+	if vehicleType == "" {
+		vehicleType = defaultVehicleType
+	}
+
+	var rate models.MileageRate
+	err = db.Where("vehicle_type = ? AND country IN ? AND effective_from <= ?", vehicleType, []string{country, ""}, rateDate(expense.Date)).
+		Order("country DESC, effective_from DESC").First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errors.New("no mileage rate")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	unit := models.DistanceUnit(strings.ToLower(strings.TrimSpace(string(req.Unit))))
+	if unit == "" {
+		unit = rate.Unit
+	}
+	if unit != models.Kilometers && unit != models.Miles {
+		return "", errors.New("invalid distance unit")
+	}
+	driven := new(big.Rat).Set(distance)
+	if unit == models.Miles && rate.Unit == models.Kilometers {
+		driven.Mul(driven, kilometersPerMile)
+	} else if unit == models.Kilometers && rate.Unit == models.Miles {
+		driven.Quo(driven, kilometersPerMile)
+	}
+
+	perUnit, err := money.ParseRate(rate.Rate)
+	if err != nil {
+		return "", err
+	}
+	minor, err := money.Round(driven.Mul(driven, perUnit), rate.Currency)
+	if err != nil {
+		return "", errors.New("invalid distance")
+	}
+
+	expense.Currency = rate.Currency
+	expense.Mileage = &models.MileageDetails{
+		Distance:          money.Decimal(money.FormatRate(distance)),
+		Unit:              unit,
+		VehicleType:       vehicleType,
+		Country:           country,
+		RateID:            rate.ID,
+		Rate:              rate.Rate,
+		RateUnit:          rate.Unit,
+		RateEffectiveFrom: rate.EffectiveFrom,
+	}
+	expense.PerDiem = nil
+	return money.FromMinor(minor, rate.Currency), nil
+}
+
+// computePerDiem prices a trip at the per-diem rate for its destination on
+// the day it started. The first and last day earn the partial-day share of the
+// daily rate and the days between the full rate; a trip within one day earns
+// the partial-day share if it lasted long enough. Provided meals are then
+// deducted, never taking the allowance below zero.
+func computePerDiem(db *gorm.DB, expense *models.Expense, req *models.PerDiemRequest) (money.Decimal, error) {
+	country, err := normalizeCountry(req.Country)
+	if err != nil {
+		return "", err
+	}
+	if country == "" {
+		return "", errors.New("invalid country")
+	}
+	if req.Start.IsZero() || !req.End.After(req.Start) {
+		return "", errors.New("invalid trip dates")
+	}
+
+	var rate models.PerDiemRate
+	err = db.Where("country IN ? AND effective_from <= ?", []string{country, ""}, rateDate(req.Start)).
+		Order("country DESC, effective_from DESC").First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errors.New("no per diem rate")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Days are counted in the time zone the trip started in
+	end := req.End.In(req.Start.Location())
+	startDay := time.Date(req.Start.Year(), req.Start.Month(), req.Start.Day(), 0, 0, 0, 0, time.UTC)
+	endDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	var full, partial int
+	if days := int(endDay.Sub(startDay) / (24 * time.Hour)); days > 0 {
+		full, partial = days-1, 2
+	} else if end.Sub(req.Start) >= time.Duration(rate.MinPartialDayHours)*time.Hour {
+		partial = 1
+	}
+
+	days := full + partial
+	for _, meals := range []int{req.BreakfastsProvided, req.LunchesProvided, req.DinnersProvided} {
+		if meals < 0 || meals > days {
+			return "", errors.New("invalid meals provided")
+		}
+	}
+
+	earned := full*100 + partial*rate.PartialDayPercent
+	allowance, err := money.Convert(rate.DailyRateMinor, rate.Currency, rate.Currency, big.NewRat(int64(earned), 100))
+	if err != nil {
+		return "", err
+	}
+	deducted := req.BreakfastsProvided*rate.BreakfastPercent + req.LunchesProvided*rate.LunchPercent + req.DinnersProvided*rate.DinnerPercent
+	deductions, err := money.Convert(rate.DailyRateMinor, rate.Currency, rate.Currency, big.NewRat(int64(deducted), 100))
+	if err != nil {
+		return "", err
+	}
+	if deductions > allowance {
+		deductions = allowance
+	}
+
+	expense.Currency = rate.Currency
+	expense.Date = req.Start
+	expense.Mileage = nil
+	expense.PerDiem = &models.PerDiemDetails{
+		Country:            country,
+		Start:              req.Start,
+		End:                req.End,
+		FullDays:           full,
+		PartialDays:        partial,
+		BreakfastsProvided: req.BreakfastsProvided,
+		LunchesProvided:    req.LunchesProvided,
+		DinnersProvided:    req.DinnersProvided,
+		RateID:             rate.ID,
+		DailyRate:          rate.DailyRate,
+		PartialDayPercent:  rate.PartialDayPercent,
+		BreakfastPercent:   rate.BreakfastPercent,
+		LunchPercent:       rate.LunchPercent,
+		DinnerPercent:      rate.DinnerPercent,
+		RateEffectiveFrom:  rate.EffectiveFrom,
+		Allowance:          money.FromMinor(allowance, rate.Currency),
+		Deductions:         money.FromMinor(deductions, rate.Currency),
+	}
+	return money.FromMinor(allowance-deductions, rate.Currency), nil
+}
+
+// normalizeCountry upper-cases an ISO 3166 alpha-2 country code; empty means any country
+func normalizeCountry(country string) (string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if country == "" {
+		return "", nil
+	}
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return "", errors.New("invalid country")
+	}
+	return country, nil
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// workflowStep describes which statuses an action may start from and the permission it needs
+type workflowStep struct {
+	from       []models.ExpenseStatus
+	permission authz.Action
+}
+
+// workflow is the expense state machine:
+// draft → submitted → approved/rejected → reimbursed, with rejected expenses resubmittable
+var workflow = map[models.ExpenseAction]workflowStep{
+	models.ActionSubmit:    {from: []models.ExpenseStatus{models.StatusDraft, models.StatusRejected}, permission: authz.ExpenseSubmit},
+	models.ActionApprove:   {from: []models.ExpenseStatus{models.StatusSubmitted}, permission: authz.ExpenseApprove},
+	models.ActionReject:    {from: []models.ExpenseStatus{models.StatusSubmitted}, permission: authz.ExpenseApprove},
+	models.ActionReimburse: {from: []models.ExpenseStatus{models.StatusApproved}, permission: authz.ExpenseReimburse},
+}
+
+// WorkflowPermission returns the access-control action required to perform a workflow step
+func WorkflowPermission(action models.ExpenseAction) (authz.Action, bool) {
+	step, ok := workflow[action]
+	return step.permission, ok
+}
+
+type ApprovalService struct {
+	db *gorm.DB
+}
+
+func NewApprovalService() *ApprovalService {
+	return &ApprovalService{
+		db: database.GetDB(),
+	}
+}
+
+// Transition applies a workflow action to an expense and records who did it.
+// Submitting re-checks the organization's policy rules; a broken blocking rule
+// fails with "policy violation", returning the expense with its violations.
+func (s *ApprovalService) Transition(p *auth.Principal, expenseID uint, action models.ExpenseAction, comment string) (*models.Expense, error) {
+	step, ok := workflow[action]
+	if !ok {
+		return nil, errors.New("unknown workflow action")
+	}
+
+	comment = strings.TrimSpace(comment)
+	if action == models.ActionReject && comment == "" {
+		return nil, errors.New("a comment is required when rejecting")
+	}
+
+	db := scoped(s.db, p)
+	var expense models.Expense
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(visibleTo(p, authz.ExpenseRead), withAllocations).First(&expense, expenseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("expense not found")
+			}
+			return err
+		}
+
+		if !authz.AllowedOn(p, step.permission, expense.UserID) {
+			return errors.New("permission denied")
+		}
+
+		// Expenses on a report move with it
+		if expense.ReportID != nil {
+			return errors.New("expense is on a report")
+		}
+		// Expenses in a reimbursement batch are reimbursed when it is reconciled
+		if expense.ReimbursementBatchID != nil {
+			return errors.New("expense is in a reimbursement batch")
+		}
+
+		from := currentStatus(expense)
+		if !containsStatus(step.from, from) {
+			return errors.New("invalid transition")
+		}
+
+		updates := map[string]interface{}{}
+		to := from
+		level := 0
+
+		switch action {
+		case models.ActionSubmit:
+			violations, err := checkPolicies(tx, &expense, true)
+			if err != nil {
+				return err
+			}
+			if err := recordViolations(tx, expense.ID, violations); err != nil {
+				return err
+			}
+			required, err := requiredApprovals(tx, expense.BaseAmountMinor)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			to = models.StatusSubmitted
+			updates["approval_count"] = 0
+			updates["required_approvals"] = required
+			updates["submitted_at"] = &now
+
+		case models.ActionApprove:
+			if err := s.checkApprover(tx, p, expense); err != nil {
+				return err
+			}
+			level = expense.ApprovalCount + 1
+			updates["approval_count"] = level
+			if level >= expense.RequiredApprovals {
+				to = models.StatusApproved
+			}
+
+		case models.ActionReject:
+			if expense.UserID == p.UserID {
+				return errors.New("cannot review own expense")
+			}
+			to = models.StatusRejected
+
+		case models.ActionReimburse:
+			to = models.StatusReimbursed
+			// The owner was paid back outside a reimbursement batch
+			if expense.Reimbursement == models.ReimbursementDue {
+				updates["reimbursement"] = models.ReimbursementPaid
+				updates["paid_at"] = time.Now()
+			}
+		}
+
+		return advance(tx, p, expense, action, to, level, updates, comment)
+	})
+	if err != nil {
+		if err.Error() == "policy violation" {
+			// The handler reports the violations that stopped the submission
+			return &expense, err
+		}
+		return nil, err
+	}
+
+	if err := db.Scopes(withDetails).First(&expense, expense.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &expense, nil
+}
+
+// advance applies updates moving an expense to status to, and records the
+// step and its audit event. The update is guarded on the status and approval
+// count that were read, so concurrent reviewers cannot both advance the same step.
+func advance(tx *gorm.DB, p *auth.Principal, expense models.Expense, action models.ExpenseAction, to models.ExpenseStatus, level int, updates map[string]interface{}, comment string) error {
+	from := currentStatus(expense)
+	updates["status"] = to
+	updates["updated_at"] = time.Now()
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := tx.Model(&models.Expense{}).
+		Where("id = ? AND status = ? AND approval_count = ?", expense.ID, from, expense.ApprovalCount).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("expense was modified concurrently")
+	}
+
+	transition := &models.ExpenseTransition{
+		ExpenseID:     expense.ID,
+		Action:        action,
+		FromStatus:    from,
+		ToStatus:      to,
+		ActorID:       p.UserID,
+		ApprovalLevel: level,
+		Comment:       comment,
+		ReportID:      expense.ReportID,
+	}
+	if err := tx.Create(transition).Error; err != nil {
+		return err
+	}
+
+	var after models.Expense
+	if err := tx.First(&after, expense.ID).Error; err != nil {
+		return err
+	}
+	return recordChange(tx, p, audit.Change{
+		Action: "expense." + string(action), EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+		Before: expense, After: after,
+	})
+}
+
+// GetTransitions returns the workflow history of an expense visible to the principal
+func (s *ApprovalService) GetTransitions(p *auth.Principal, expenseID uint) ([]models.ExpenseTransition, error) {
+	db := scoped(s.db, p)
+
+	var expense models.Expense
+	if err := db.Scopes(visibleTo(p, authz.ExpenseRead)).Select("id").First(&expense, expenseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense not found")
+		}
+		return nil, err
+	}
+
+	transitions := []models.ExpenseTransition{}
+	if err := db.Where("expense_id = ?", expenseID).Order("created_at, id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// ListRules returns the organization's approval thresholds in ascending order
+func (s *ApprovalService) ListRules(p *auth.Principal) ([]models.ApprovalRule, error) {
+	rules := []models.ApprovalRule{}
+	if err := scoped(s.db, p).Order("min_amount_minor").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// CreateRule adds an approval threshold, in the organization's base currency, to the organization
+func (s *ApprovalService) CreateRule(p *auth.Principal, req models.CreateApprovalRuleRequest) (*models.ApprovalRule, error) {
+	db := scoped(s.db, p)
+
+	currency, err := baseCurrency(db, p.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	minAmount := req.MinAmount
+	if minAmount == "" {
+		minAmount = "0"
+	}
+	minor, err := minAmount.Minor(currency)
+	if err != nil {
+		return nil, errors.New("invalid min_amount")
+	}
+	if minor < 0 {
+		return nil, errors.New("min_amount must not be negative")
+	}
+	if req.RequiredApprovals < 1 {
+		return nil, errors.New("required_approvals must be at least 1")
+	}
+
+	rule := &models.ApprovalRule{
+		MinAmountMinor:    minor,
+		Currency:          currency,
+		RequiredApprovals: req.RequiredApprovals,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "approval_rule.create", EntityType: "approval_rule", EntityID: rule.ID,
+			After: rule,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// DeleteRule removes an approval threshold from the organization
+func (s *ApprovalService) DeleteRule(p *auth.Principal, ruleID uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var rule models.ApprovalRule
+		if err := tx.First(&rule, ruleID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("approval rule not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "approval_rule.delete", EntityType: "approval_rule", EntityID: rule.ID,
+			Before: rule,
+		})
+	})
+}
+
+// requiredApprovals returns how many distinct approvers an expense, or an
+// expense report, needs given its amount in the organization's base currency
+func requiredApprovals(tx *gorm.DB, baseAmountMinor int64) (int, error) {
+	var rule models.ApprovalRule
+
+	err := tx.Where("min_amount_minor < ?", baseAmountMinor).Order("required_approvals DESC").First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return rule.RequiredApprovals, nil
+}
+
+// checkApprover enforces segregation of duties within the current review round
+func (s *ApprovalService) checkApprover(tx *gorm.DB, p *auth.Principal, expense models.Expense) error {
+	if expense.UserID == p.UserID {
+		return errors.New("cannot review own expense")
+	}
+
+	// Approvals count per review round, which starts at the latest submission
+	var round models.ExpenseTransition
+	err := tx.Where("expense_id = ? AND action = ?", expense.ID, models.ActionSubmit).Order("id DESC").First(&round).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var previous int64
+	err = tx.Model(&models.ExpenseTransition{}).
+		Where("expense_id = ? AND action = ? AND actor_id = ? AND id > ?", expense.ID, models.ActionApprove, p.UserID, round.ID).
+		Count(&previous).Error
+	if err != nil {
+		return err
+	}
+	if previous > 0 {
+		return errors.New("already approved by this user")
+	}
+
+	return nil
+}
+
+func currentStatus(expense models.Expense) models.ExpenseStatus {
+	if expense.Status == "" {
+		return models.StatusDraft
+	}
+	return expense.Status
+}
+
+func containsStatus(statuses []models.ExpenseStatus, status models.ExpenseStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -12,6 +12,9 @@ import (
     
     "gorm.io/gorm"
     
+    "github.com/example/next-go-monorepo/apps/api/internal/audit"
+    "github.com/example/next-go-monorepo/apps/api/internal/auth"
+    "github.com/example/next-go-monorepo/apps/api/internal/authz"
     "github.com/example/next-go-monorepo/apps/api/internal/database"
     "github.com/example/next-go-monorepo/apps/api/internal/models"
 )
@@ -56,76 +59,69 @@ func NewAttachmentService() *AttachmentService {
     return service
 }
 
-// UploadAttachment handles file upload for an expense
-func (s *AttachmentService) UploadAttachment(expenseID uint, file *multipart.FileHeader) (*models.Attachment, error) {
+// UploadAttachment handles file upload for an expense the principal may attach files to
+func (s *AttachmentService) UploadAttachment(p *auth.Principal, expenseID uint, file *multipart.FileHeader) (*models.Attachment, error) {
+    db := scoped(s.db, p)
+    
     // Verify expense exists
     var expense models.Expense
-    if err := s.db.First(&expense, expenseID).Error; err != nil {
+    if err := db.Scopes(visibleTo(p, authz.ExpenseRead)).First(&expense, expenseID).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, errors.New("expense not found")
         }
         return nil, err
     }
     
-    // Generate unique filename
-    timestamp := time.Now().Unix()
-    filename := fmt.Sprintf("%d_%s", timestamp, file.Filename)
+    if !authz.AllowedOn(p, authz.AttachmentUpload, expense.UserID) {
+        return nil, errors.New("permission denied")
+    }
     
-    var filePath string
-    var storageType string
+    if !expense.Editable() {
+        return nil, errors.New("expense is locked")
+    }
     
-    if s.useS3 {
-        // Upload to S3
-        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-        defer cancel()
-        
-        s3Key := fmt.Sprintf("expense-%d/%s", expenseID, filename)
-        key, err := s.s3Service.UploadFile(ctx, s3Key, file)
-        if err != nil {
-            return nil, fmt.Errorf("failed to upload to S3: %w", err)
-        }
-        
-        filePath = key
-        storageType = "s3"
-    } else {
-        // Upload to local filesystem
-        localPath := filepath.Join(s.uploadsPath, filename)
-        
-        // Open uploaded file
-        src, err := file.Open()
-        if err != nil {
-            return nil, fmt.Errorf("failed to open uploaded file: %w", err)
-        }
-        defer src.Close()
-        
-        // Create destination file
-        dst, err := os.Create(localPath)
-        if err != nil {
-            return nil, fmt.Errorf("failed to create file: %w", err)
-        }
-        defer dst.Close()
-        
-        // Copy file contents
-        if _, err = io.Copy(dst, src); err != nil {
-            return nil, fmt.Errorf("failed to save file: %w", err)
-        }
-        
-        filePath = localPath
-        storageType = "local"
+    hash, err := contentHash(file)
+    if err != nil {
+        return nil, err
+    }
+    
+    filePath, storageType, err := s.storeFile(file, fmt.Sprintf("expense-%d", expenseID))
+    if err != nil {
+        return nil, err
     }
     
     // Create attachment record
     attachment := &models.Attachment{
         ExpenseID:   expenseID,
+        UserID:      p.UserID,
         Filename:    file.Filename,
         FilePath:    filePath,
         ContentType: file.Header.Get("Content-Type"),
         FileSize:    file.Size,
         UploadedAt:  time.Now(),
         StorageType: storageType,
+        ContentHash: hash,
     }
     
-    if err := s.db.Create(attachment).Error; err != nil {
+    err = db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(attachment).Error; err != nil {
+            return err
+        }
+        if err := recordChange(tx, p, audit.Change{
+            Action: "attachment.upload", EntityType: "attachment", EntityID: attachment.ID, ExpenseID: expenseID,
+            After: attachment,
+        }); err != nil {
+            return err
+        }
+        if err := touchExpense(tx, expenseID); err != nil {
+            return err
+        }
+        if err := refreshDuplicates(tx, p.OrganizationID, expenseID); err != nil {
+            return err
+        }
+        return indexExpense(tx, expenseID)
+    })
+    if err != nil {
         // Clean up file if database operation fails
         s.cleanupFile(filePath, storageType)
         return nil, err
@@ -134,11 +130,11 @@ func (s *AttachmentService) UploadAttachment(expenseID uint, file *multipart.Fil
     return attachment, nil
 }
 
-// GetAttachment retrieves an attachment by ID
-func (s *AttachmentService) GetAttachment(expenseID, attachmentID uint) (*models.Attachment, error) {
+// GetAttachment retrieves an attachment by ID on an expense visible to the principal
+func (s *AttachmentService) GetAttachment(p *auth.Principal, expenseID, attachmentID uint) (*models.Attachment, error) {
     var attachment models.Attachment
     
-    if err := s.db.Where("expense_id = ? AND id = ?", expenseID, attachmentID).First(&attachment).Error; err != nil {
+    if err := s.visibleAttachments(p, authz.AttachmentRead).Where("attachments.expense_id = ? AND attachments.id = ?", expenseID, attachmentID).First(&attachment).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, errors.New("attachment not found")
         }
@@ -148,35 +144,130 @@ func (s *AttachmentService) GetAttachment(expenseID, attachmentID uint) (*models
     return &attachment, nil
 }
 
-// DeleteAttachment deletes an attachment and its file
-func (s *AttachmentService) DeleteAttachment(expenseID, attachmentID uint) error {
+// DeleteAttachment moves an attachment to the trash; its file is kept until purged
+func (s *AttachmentService) DeleteAttachment(p *auth.Principal, expenseID, attachmentID uint) error {
     var attachment models.Attachment
     
-    if err := s.db.Where("expense_id = ? AND id = ?", expenseID, attachmentID).First(&attachment).Error; err != nil {
+    if err := s.visibleAttachments(p, authz.AttachmentRead).Where("attachments.expense_id = ? AND attachments.id = ?", expenseID, attachmentID).First(&attachment).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return errors.New("attachment not found")
         }
         return err
     }
     
-    // Delete from database
-    if err := s.db.Delete(&attachment).Error; err != nil {
+    var expense models.Expense
+    if err := scoped(s.db, p).First(&expense, attachment.ExpenseID).Error; err != nil {
         return err
     }
+    if !authz.AllowedOn(p, authz.AttachmentDelete, expense.UserID) {
+        return errors.New("permission denied")
+    }
+    if !expense.Editable() {
+        return errors.New("expense is locked")
+    }
     
-    // Delete file from storage
-    s.cleanupFile(attachment.FilePath, attachment.StorageType)
+    err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Delete(&attachment).Error; err != nil {
+            return err
+        }
+        if err := recordChange(tx, p, audit.Change{
+            Action: "attachment.delete", EntityType: "attachment", EntityID: attachment.ID, ExpenseID: attachment.ExpenseID,
+            Before: attachment,
+        }); err != nil {
+            return err
+        }
+        if err := touchExpense(tx, attachment.ExpenseID); err != nil {
+            return err
+        }
+        return indexExpense(tx, attachment.ExpenseID)
+    })
+    if err != nil {
+        return err
+    }
     
     return nil
 }
 
 // GetAttachmentFile returns the file data for an attachment
-func (s *AttachmentService) GetAttachmentFile(expenseID, attachmentID uint) (io.ReadCloser, string, int64, error) {
-    attachment, err := s.GetAttachment(expenseID, attachmentID)
+func (s *AttachmentService) GetAttachmentFile(p *auth.Principal, expenseID, attachmentID uint) (io.ReadCloser, string, int64, error) {
+    attachment, err := s.GetAttachment(p, expenseID, attachmentID)
     if err != nil {
         return nil, "", 0, err
     }
     
+    return s.openFile(attachment)
+}
+
+// GetAttachmentURL returns a URL for accessing the attachment
+func (s *AttachmentService) GetAttachmentURL(p *auth.Principal, expenseID, attachmentID uint, expiration time.Duration) (string, error) {
+    attachment, err := s.GetAttachment(p, expenseID, attachmentID)
+    if err != nil {
+        return "", err
+    }
+    
+    if attachment.StorageType == "s3" && s.s3Service != nil {
+        // Generate signed URL for S3
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+        
+        url, err := s.s3Service.GetSignedURL(ctx, attachment.FilePath, expiration)
+        if err != nil {
+            return "", fmt.Errorf("failed to generate signed URL: %w", err)
+        }
+        
+        return url, nil
+    }
+    
+    // For local files, return the attachment ID (to be handled by download endpoint)
+    return fmt.Sprintf("/api/expenses/%d/attachments/%d", expenseID, attachmentID), nil
+}
+
+// storeFile saves an uploaded file under a unique name in S3, beneath prefix,
+// or on the local filesystem, returning where it went
+func (s *AttachmentService) storeFile(file *multipart.FileHeader, prefix string) (string, string, error) {
+    // Generate unique filename
+    timestamp := time.Now().Unix()
+    filename := fmt.Sprintf("%d_%s", timestamp, file.Filename)
+    
+    if s.useS3 {
+        // Upload to S3
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+        defer cancel()
+        
+        key, err := s.s3Service.UploadFile(ctx, prefix+"/"+filename, file)
+        if err != nil {
+            return "", "", fmt.Errorf("failed to upload to S3: %w", err)
+        }
+        return key, "s3", nil
+    }
+    
+    // Upload to local filesystem
+    localPath := filepath.Join(s.uploadsPath, filename)
+    
+    // Open uploaded file
+    src, err := file.Open()
+    if err != nil {
+        return "", "", fmt.Errorf("failed to open uploaded file: %w", err)
+    }
+    defer src.Close()
+    
+    // Create destination file
+    dst, err := os.Create(localPath)
+    if err != nil {
+        return "", "", fmt.Errorf("failed to create file: %w", err)
+    }
+    defer dst.Close()
+    
+    // Copy file contents
+    if _, err = io.Copy(dst, src); err != nil {
+        return "", "", fmt.Errorf("failed to save file: %w", err)
+    }
+    
+    return localPath, "local", nil
+}
+
+// openFile returns the stored file data of an attachment
+func (s *AttachmentService) openFile(attachment *models.Attachment) (io.ReadCloser, string, int64, error) {
     if attachment.StorageType == "s3" && s.s3Service != nil {
         // Download from S3
         ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
@@ -210,28 +301,11 @@ func (s *AttachmentService) GetAttachmentFile(expenseID, attachmentID uint) (io.
     }
 }
 
-// GetAttachmentURL returns a URL for accessing the attachment
-func (s *AttachmentService) GetAttachmentURL(expenseID, attachmentID uint, expiration time.Duration) (string, error) {
-    attachment, err := s.GetAttachment(expenseID, attachmentID)
-    if err != nil {
-        return "", err
-    }
-    
-    if attachment.StorageType == "s3" && s.s3Service != nil {
-        // Generate signed URL for S3
-        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-        defer cancel()
-        
-        url, err := s.s3Service.GetSignedURL(ctx, attachment.FilePath, expiration)
-        if err != nil {
-            return "", fmt.Errorf("failed to generate signed URL: %w", err)
-        }
-        
-        return url, nil
-    }
-    
-    // For local files, return the attachment ID (to be handled by download endpoint)
-    return fmt.Sprintf("/api/expenses/%d/attachments/%d", expenseID, attachmentID), nil
+// visibleAttachments limits attachment queries to expenses the principal may perform action on
+func (s *AttachmentService) visibleAttachments(p *auth.Principal, action authz.Action) *gorm.DB {
+    return scoped(s.db, p).Model(&models.Attachment{}).
+        Joins("JOIN expenses ON expenses.id = attachments.expense_id AND expenses.deleted_at IS NULL").
+        Scopes(visibleTo(p, action))
 }
 
 // cleanupFile removes a file based on storage type
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/pagination"
+)
+
+// auditKeys orders audit listings newest first; seq is unique within an organization
+var auditKeys = []pagination.Key{{Column: "audit_events.seq", Desc: true}}
+
+// AuditFilter narrows an audit event listing. Zero values match everything.
+type AuditFilter struct {
+	ActorID    uint
+	Action     string
+	EntityType string
+	EntityID   uint
+	ExpenseID  uint
+	From       *time.Time
+	To         *time.Time
+}
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService() *AuditService {
+	return &AuditService{
+		db: database.GetDB(),
+	}
+}
+
+// ExpenseHistory returns every recorded change to an expense visible to the
+// principal and its attachments, oldest first. Deleted expenses keep their history.
+func (s *AuditService) ExpenseHistory(p *auth.Principal, expenseID uint) ([]models.AuditEvent, error) {
+	db := scoped(s.db, p)
+
+	var expense models.Expense
+	if err := db.Unscoped().Scopes(visibleTo(p, authz.ExpenseRead)).Select("id").First(&expense, expenseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense not found")
+		}
+		return nil, err
+	}
+
+	events := []models.AuditEvent{}
+	if err := db.Where("expense_id = ?", expenseID).Order("seq").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListEvents returns a page of the organization's audit events, newest first
+func (s *AuditService) ListEvents(p *auth.Principal, filter AuditFilter, cursor string, limit int) (*models.AuditEventListResponse, error) {
+	var c *pagination.Cursor
+	if cursor != "" {
+		var err error
+		if c, err = pagination.Decode(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	query := scoped(s.db, p).Model(&models.AuditEvent{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ExpenseID != 0 {
+		query = query.Where("expense_id = ?", filter.ExpenseID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", filter.To.UTC())
+	}
+
+	page, err := pagination.Paginate(query, auditKeys, "seq:desc", c, 0, limit,
+		func(e models.AuditEvent) []interface{} { return []interface{}{e.Seq} })
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuditEventListResponse{
+		Events:     page.Items,
+		NextCursor: page.Next,
+		PrevCursor: page.Prev,
+	}, nil
+}
+
+// recordChange appends c to the audit log as a change made by the principal.
+// c.OrganizationID defaults to the principal's organization.
+func recordChange(tx *gorm.DB, p *auth.Principal, c audit.Change) error {
+	if c.OrganizationID == 0 {
+		c.OrganizationID = p.OrganizationID
+	}
+	c.ActorID = p.UserID
+	c.RequestID = p.RequestID
+	c.IP = p.ClientIP
+	return audit.Record(tx, c)
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/payout"
+	"github.com/example/next-go-monorepo/apps/api/internal/secrets"
+)
+
+type BankAccountService struct {
+	db *gorm.DB
+}
+
+func NewBankAccountService() *BankAccountService {
+	return &BankAccountService{
+		db: database.GetDB(),
+	}
+}
+
+// GetMyBankAccount returns the account the principal is reimbursed to
+func (s *BankAccountService) GetMyBankAccount(p *auth.Principal) (*models.BankAccount, error) {
+	return s.getBankAccount(p, &p.UserID)
+}
+
+// SetMyBankAccount stores or replaces the account the principal is reimbursed to
+func (s *BankAccountService) SetMyBankAccount(p *auth.Principal, req models.BankDetails) (*models.BankAccount, error) {
+	req.CompanyID = ""
+	return s.setBankAccount(p, &p.UserID, req)
+}
+
+// DeleteMyBankAccount removes the account the principal is reimbursed to.
+// Batches already created keep paying to it.
+func (s *BankAccountService) DeleteMyBankAccount(p *auth.Principal) error {
+	return s.deleteBankAccount(p, &p.UserID)
+}
+
+// GetOrganizationBankAccount returns the account the organization pays reimbursements from
+func (s *BankAccountService) GetOrganizationBankAccount(p *auth.Principal) (*models.BankAccount, error) {
+	return s.getBankAccount(p, nil)
+}
+
+// SetOrganizationBankAccount stores or replaces the account the organization pays reimbursements from
+func (s *BankAccountService) SetOrganizationBankAccount(p *auth.Principal, req models.BankDetails) (*models.BankAccount, error) {
+	return s.setBankAccount(p, nil, req)
+}
+
+// DeleteOrganizationBankAccount removes the account the organization pays reimbursements from
+func (s *BankAccountService) DeleteOrganizationBankAccount(p *auth.Principal) error {
+	return s.deleteBankAccount(p, nil)
+}
+
+func (s *BankAccountService) getBankAccount(p *auth.Principal, userID *uint) (*models.BankAccount, error) {
+	account, err := findBankAccount(scoped(s.db, p), userID)
+	if err != nil {
+		return nil, err
+	}
+	details, err := openBankDetails(account.EncryptedDetails, account.OrganizationID, account.UserID)
+	if err != nil {
+		return nil, err
+	}
+	account.Details = maskBankDetails(details)
+	return account, nil
+}
+
+func (s *BankAccountService) setBankAccount(p *auth.Principal, userID *uint, req models.BankDetails) (*models.BankAccount, error) {
+	if !secrets.Configured() {
+		return nil, errors.New("bank details encryption is not configured")
+	}
+	details, err := normalizeBankDetails(req, userID == nil)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &models.BankAccount{UserID: userID}
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		existing, err := findBankAccount(tx, userID)
+		if err != nil && err.Error() != "bank account not found" {
+			return err
+		}
+		var before *models.BankAccount
+		if existing != nil {
+			account = existing
+			before = &models.BankAccount{}
+			*before = *existing
+			if previous, err := openBankDetails(existing.EncryptedDetails, existing.OrganizationID, existing.UserID); err == nil {
+				before.Details = maskBankDetails(previous)
+			}
+		}
+
+		account.EncryptedDetails, err = sealBankDetails(details, p.OrganizationID, userID)
+		if err != nil {
+			return err
+		}
+		account.AccountLast4 = accountLast4(details)
+		account.Details = maskBankDetails(details)
+		if err := tx.Save(account).Error; err != nil {
+			return err
+		}
+
+		change := audit.Change{
+			Action: "bank_account.create", EntityType: "bank_account", EntityID: account.ID,
+			After: account,
+		}
+		if before != nil {
+			change.Action = "bank_account.update"
+			change.Before = before
+		}
+		return recordChange(tx, p, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *BankAccountService) deleteBankAccount(p *auth.Principal, userID *uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		account, err := findBankAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(account).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "bank_account.delete", EntityType: "bank_account", EntityID: account.ID,
+			Before: account,
+		})
+	})
+}
+
+// findBankAccount returns a member's account, or the organization's when userID is nil
+func findBankAccount(db *gorm.DB, userID *uint) (*models.BankAccount, error) {
+	query := db.Where("user_id IS NULL")
+	if userID != nil {
+		query = db.Where("user_id = ?", *userID)
+	}
+	var account models.BankAccount
+	if err := query.First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("bank account not found")
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// normalizeBankDetails tidies and checks bank details. An account needs an
+// IBAN for SEPA transfers or a routing and account number for ACH transfers,
+// and may have both.
+func normalizeBankDetails(req models.BankDetails, organization bool) (models.BankDetails, error) {
+	details := models.BankDetails{
+		HolderName:    strings.TrimSpace(req.HolderName),
+		IBAN:          payout.NormalizeIBAN(req.IBAN),
+		BIC:           strings.ToUpper(strings.TrimSpace(req.BIC)),
+		RoutingNumber: strings.TrimSpace(req.RoutingNumber),
+		AccountNumber: strings.ToUpper(strings.Join(strings.Fields(req.AccountNumber), "")),
+		AccountType:   strings.ToLower(strings.TrimSpace(req.AccountType)),
+		CompanyID:     strings.TrimSpace(req.CompanyID),
+	}
+
+	if details.HolderName == "" {
+		return details, errors.New("holder name is required")
+	}
+	if details.IBAN == "" && details.RoutingNumber == "" && details.AccountNumber == "" {
+		return details, errors.New("bank details need an IBAN or a routing and account number")
+	}
+	if details.IBAN != "" && !payout.ValidIBAN(details.IBAN) {
+		return details, errors.New("invalid IBAN")
+	}
+	if details.BIC != "" && !payout.ValidBIC(details.BIC) {
+		return details, errors.New("invalid BIC")
+	}
+	if details.RoutingNumber != "" || details.AccountNumber != "" {
+		if !payout.ValidRoutingNumber(details.RoutingNumber) {
+			return details, errors.New("invalid routing number")
+		}
+		if !payout.ValidAccountNumber(details.AccountNumber) {
+			return details, errors.New("invalid account number")
+		}
+		if details.AccountType == "" {
+			details.AccountType = "checking"
+		}
+	}
+	if details.AccountType != "" && details.AccountType != "checking" && details.AccountType != "savings" {
+		return details, errors.New("invalid account type")
+	}
+	if !organization {
+		details.CompanyID = ""
+	} else if len(details.CompanyID) > 10 {
+		return details, errors.New("invalid company ID")
+	}
+	return details, nil
+}
+
+// bankDetailsContext binds sealed bank details to their owner, so details
+// copied onto another member's row do not open
+func bankDetailsContext(organizationID uint, userID *uint) string {
+	owner := uint(0)
+	if userID != nil {
+		owner = *userID
+	}
+	return fmt.Sprintf("bank-account:%d:%d", organizationID, owner)
+}
+
+func sealBankDetails(details models.BankDetails, organizationID uint, userID *uint) (string, error) {
+	plaintext, err := json.Marshal(details)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := secrets.Seal(plaintext, bankDetailsContext(organizationID, userID))
+	if errors.Is(err, secrets.ErrNotConfigured) {
+		return "", errors.New("bank details encryption is not configured")
+	}
+	return sealed, err
+}
+
+func openBankDetails(sealed string, organizationID uint, userID *uint) (models.BankDetails, error) {
+	var details models.BankDetails
+	plaintext, err := secrets.Open(sealed, bankDetailsContext(organizationID, userID))
+	if err != nil {
+		if errors.Is(err, secrets.ErrNotConfigured) {
+			return details, errors.New("bank details encryption is not configured")
+		}
+		return details, errors.New("bank details cannot be decrypted")
+	}
+	err = json.Unmarshal(plaintext, &details)
+	return details, err
+}
+
+// maskBankDetails hides all but the ends of account numbers. Routing numbers
+// and BICs name the bank, not the account, and are shown in full.
+func maskBankDetails(details models.BankDetails) models.BankDetails {
+	if details.IBAN != "" {
+		details.IBAN = details.IBAN[:4] + strings.Repeat("*", len(details.IBAN)-8) + details.IBAN[len(details.IBAN)-4:]
+	}
+	if n := len(details.AccountNumber); n > 4 {
+		details.AccountNumber = strings.Repeat("*", n-4) + details.AccountNumber[n-4:]
+	}
+	return details
+}
+
+func accountLast4(details models.BankDetails) string {
+	number := details.IBAN
+	if number == "" {
+		number = details.AccountNumber
+	}
+	if len(number) > 4 {
+		return number[len(number)-4:]
+	}
+	return number
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// fallbackCategory is suggested when nothing better applies, if the
+// organization still has it
+const fallbackCategory = "Other"
+
+type CategoryService struct {
+	db *gorm.DB
+}
+
+func NewCategoryService() *CategoryService {
+	return &CategoryService{
+		db: database.GetDB(),
+	}
+}
+
+// ListCategories returns the organization's categories by name, leaving out
+// archived ones unless asked for
+func (s *CategoryService) ListCategories(p *auth.Principal, includeArchived bool) ([]models.Category, error) {
+	query := scoped(s.db, p).Order("name")
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+	categories := []models.Category{}
+	if err := query.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// CreateCategory adds a category to the organization
+func (s *CategoryService) CreateCategory(p *auth.Principal, req models.CreateCategoryRequest) (*models.Category, error) {
+	db := scoped(s.db, p)
+	currency, err := baseCurrency(db, p.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	category := &models.Category{
+		GLCode:   strings.TrimSpace(req.GLCode),
+		TaxCode:  strings.TrimSpace(req.TaxCode),
+		Currency: currency,
+	}
+	if req.ReceiptRequiredAbove != nil {
+		if err := setReceiptThreshold(category, *req.ReceiptRequiredAbove); err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := setCategoryName(tx, category, req.Name); err != nil {
+			return err
+		}
+		if req.ParentID != nil && *req.ParentID != 0 {
+			if err := setCategoryParent(tx, category, *req.ParentID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(category).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "category.create", EntityType: "category", EntityID: category.ID,
+			After: category,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// UpdateCategory changes a category. Renaming it relabels the expenses and
+// policy rules that use it; archiving it archives its subcategories too.
+func (s *CategoryService) UpdateCategory(p *auth.Principal, id uint, req models.UpdateCategoryRequest) (*models.Category, error) {
+	var category models.Category
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&category, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("category not found")
+			}
+			return err
+		}
+		before := category
+
+		if req.Name != nil {
+			if err := setCategoryName(tx, &category, *req.Name); err != nil {
+				return err
+			}
+		}
+		if req.Archived != nil {
+			category.Archived = *req.Archived
+		}
+		if req.ParentID != nil {
+			if *req.ParentID == 0 {
+				category.ParentID = nil
+			} else if err := setCategoryParent(tx, &category, *req.ParentID); err != nil {
+				return err
+			}
+		}
+		if req.GLCode != nil {
+			category.GLCode = strings.TrimSpace(*req.GLCode)
+		}
+		if req.TaxCode != nil {
+			category.TaxCode = strings.TrimSpace(*req.TaxCode)
+		}
+		if req.ReceiptRequiredAbove != nil {
+			currency, err := baseCurrency(tx, p.OrganizationID)
+			if err != nil {
+				return err
+			}
+			category.Currency = currency
+			if err := setReceiptThreshold(&category, *req.ReceiptRequiredAbove); err != nil {
+				return err
+			}
+		}
+		if !category.Archived && category.ParentID != nil {
+			var parent models.Category
+			if err := tx.First(&parent, *category.ParentID).Error; err != nil {
+				return err
+			}
+			if parent.Archived {
+				return errors.New("parent category is archived")
+			}
+		}
+
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		if category.Name != before.Name {
+			if err := relabelCategory(tx, before.Name, category.Name); err != nil {
+				return err
+			}
+		}
+		if category.Archived && !before.Archived {
+			if err := archiveSubcategories(tx, category.ID); err != nil {
+				return err
+			}
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "category.update", EntityType: "category", EntityID: category.ID,
+			Before: before, After: category,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// DeleteCategory removes a category no expense, trashed or not, has used and
+// that has no subcategories. Categories in use can be archived instead.
+func (s *CategoryService) DeleteCategory(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("category not found")
+			}
+			return err
+		}
+
+		var expenses, children int64
+		if err := tx.Unscoped().Model(&models.Expense{}).Where("category = ?", category.Name).Count(&expenses).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+			return err
+		}
+		if expenses > 0 || children > 0 {
+			return errors.New("category is in use")
+		}
+
+		if err := tx.Delete(&category).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "category.delete", EntityType: "category", EntityID: category.ID,
+			Before: category,
+		})
+	})
+}
+
+// resolveCategory checks that name, ignoring case, is one of the
+// organization's active categories and returns it as the category spells it.
+// An empty name means no category and is accepted.
+func resolveCategory(db *gorm.DB, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	var category models.Category
+	err := db.Where("LOWER(name) = LOWER(?) AND archived = ?", name, false).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", errors.New("unknown category")
+	}
+	if err != nil {
+		return "", err
+	}
+	return category.Name, nil
+}
+
+// suggestedCategory turns a categorizer's guess into one of the organization's
+// active categories: the guess itself, else a category named in the
+// description, else the fallback category. It returns "" when none is active.
+func suggestedCategory(db *gorm.DB, guess, description string) (string, error) {
+	var categories []models.Category
+	if err := db.Where("archived = ?", false).Order("LENGTH(name) DESC, name").Find(&categories).Error; err != nil {
+		return "", err
+	}
+	for _, c := range categories {
+		if strings.EqualFold(c.Name, guess) {
+			return c.Name, nil
+		}
+	}
+	text := strings.ToLower(description)
+	for _, c := range categories {
+		if strings.Contains(text, strings.ToLower(c.Name)) {
+			return c.Name, nil
+		}
+	}
+	for _, c := range categories {
+		if strings.EqualFold(c.Name, fallbackCategory) {
+			return c.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// withSubcategories adds the names of the categories nested, at any depth,
+// under the named ones
+func withSubcategories(db *gorm.DB, names []string) ([]string, error) {
+	var categories []models.Category
+	if err := db.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	children := make(map[uint][]models.Category)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	seen := make(map[string]bool, len(names))
+	all := append([]string{}, names...)
+	for _, name := range names {
+		seen[name] = true
+	}
+	var walk func(id uint)
+	walk = func(id uint) {
+		for _, child := range children[id] {
+			if !seen[child.Name] {
+				seen[child.Name] = true
+				all = append(all, child.Name)
+			}
+			walk(child.ID)
+		}
+	}
+	for _, c := range categories {
+		for _, name := range names {
+			if strings.EqualFold(c.Name, name) {
+				walk(c.ID)
+			}
+		}
+	}
+	return all, nil
+}
+
+// rebaseCategoryThresholds re-expresses the organization's category receipt
+// thresholds in a new base currency, keeping their values
+func rebaseCategoryThresholds(tx *gorm.DB, organizationID uint, currency string) error {
+	db := database.WithTenant(tx, organizationID)
+	var categories []models.Category
+	if err := db.Where("receipt_required_above_minor IS NOT NULL").Find(&categories).Error; err != nil {
+		return err
+	}
+	for _, c := range categories {
+		c.Currency = currency
+		if err := setReceiptThreshold(&c, *c.ReceiptRequiredAbove); err != nil {
+			return err
+		}
+		if err := db.Save(&c).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCategoryName validates and sets a category's name, which must be unique
+// within the organization regardless of case
+func setCategoryName(tx *gorm.DB, category *models.Category, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("category name is required")
+	}
+	var count int64
+	err := tx.Model(&models.Category{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, category.ID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("category already exists")
+	}
+	category.Name = name
+	return nil
+}
+
+// setCategoryParent nests a category under another, refusing cycles
+func setCategoryParent(tx *gorm.DB, category *models.Category, parentID uint) error {
+	var parent models.Category
+	if err := tx.First(&parent, parentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("parent category not found")
+		}
+		return err
+	}
+	if parent.Archived && !category.Archived {
+		return errors.New("parent category is archived")
+	}
+
+	// Walk up from the new parent; meeting the category means it would become its own ancestor
+	for ancestor := &parent; ; {
+		if category.ID != 0 && ancestor.ID == category.ID {
+			return errors.New("category cannot be nested under itself")
+		}
+		if ancestor.ParentID == nil {
+			break
+		}
+		var next models.Category
+		if err := tx.First(&next, *ancestor.ParentID).Error; err != nil {
+			return err
+		}
+		ancestor = &next
+	}
+
+	category.ParentID = &parent.ID
+	return nil
+}
+
+// setReceiptThreshold sets a category's receipt threshold in its currency; an
+// empty amount clears it
+func setReceiptThreshold(category *models.Category, amount money.Decimal) error {
+	if strings.TrimSpace(string(amount)) == "" {
+		category.ReceiptRequiredAboveMinor = nil
+		return nil
+	}
+	threshold, err := amount.Minor(category.Currency)
+	if err != nil || threshold < 0 {
+		return errors.New("invalid receipt threshold")
+	}
+	category.ReceiptRequiredAboveMinor = &threshold
+	return nil
+}
+
+// relabelCategory moves the expenses and policy rules using a category's old
+// name to its new one
+func relabelCategory(tx *gorm.DB, from, to string) error {
+	var ids []uint
+	if err := tx.Unscoped().Model(&models.Expense{}).Where("category = ?", from).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		err := tx.Unscoped().Model(&models.Expense{}).Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{"category": to, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		var live []uint
+		if err := tx.Model(&models.Expense{}).Where("id IN ?", ids).Pluck("id", &live).Error; err != nil {
+			return err
+		}
+		for _, id := range live {
+			if err := indexExpense(tx, id); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Model(&models.PolicyRule{}).Where("category = ?", from).Update("category", to).Error
+}
+
+// archiveSubcategories archives every category nested under the given one
+func archiveSubcategories(tx *gorm.DB, parentID uint) error {
+	var children []models.Category
+	if err := tx.Where("parent_id = ? AND archived = ?", parentID, false).Find(&children).Error; err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := tx.Model(&child).Update("archived", true).Error; err != nil {
+			return err
+		}
+		if err := archiveSubcategories(tx, child.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
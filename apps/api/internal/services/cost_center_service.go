@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+type CostCenterService struct {
+	db *gorm.DB
+}
+
+func NewCostCenterService() *CostCenterService {
+	return &CostCenterService{
+		db: database.GetDB(),
+	}
+}
+
+// ListCostCenters returns the organization's cost centers by code, leaving out
+// archived ones unless asked for
+func (s *CostCenterService) ListCostCenters(p *auth.Principal, includeArchived bool) ([]models.CostCenter, error) {
+	query := scoped(s.db, p).Order("code")
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+	costCenters := []models.CostCenter{}
+	if err := query.Find(&costCenters).Error; err != nil {
+		return nil, err
+	}
+	return costCenters, nil
+}
+
+// CreateCostCenter adds a cost center to the organization
+func (s *CostCenterService) CreateCostCenter(p *auth.Principal, req models.CostCenterRequest) (*models.CostCenter, error) {
+	costCenter := &models.CostCenter{}
+	if req.Archived != nil {
+		costCenter.Archived = *req.Archived
+	}
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := setCostCenterFields(tx, costCenter, req); err != nil {
+			return err
+		}
+		if costCenter.Code == "" {
+			return errors.New("cost center code is required")
+		}
+		if costCenter.Name == "" {
+			return errors.New("cost center name is required")
+		}
+		if err := tx.Create(costCenter).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "cost_center.create", EntityType: "cost_center", EntityID: costCenter.ID,
+			After: costCenter,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return costCenter, nil
+}
+
+// UpdateCostCenter changes a cost center's code, name or archived flag
+func (s *CostCenterService) UpdateCostCenter(p *auth.Principal, id uint, req models.CostCenterRequest) (*models.CostCenter, error) {
+	var costCenter models.CostCenter
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&costCenter, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("cost center not found")
+			}
+			return err
+		}
+		before := costCenter
+
+		if err := setCostCenterFields(tx, &costCenter, req); err != nil {
+			return err
+		}
+		if req.Archived != nil {
+			costCenter.Archived = *req.Archived
+		}
+
+		if err := tx.Save(&costCenter).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "cost_center.update", EntityType: "cost_center", EntityID: costCenter.ID,
+			Before: before, After: costCenter,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &costCenter, nil
+}
+
+// DeleteCostCenter removes a cost center no expense, trashed or not, is
+// allocated to. Cost centers in use can be archived instead.
+func (s *CostCenterService) DeleteCostCenter(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var costCenter models.CostCenter
+		if err := tx.First(&costCenter, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("cost center not found")
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.Expense{}).Where("cost_center_id = ?", costCenter.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("cost center is in use")
+		}
+
+		if err := tx.Delete(&costCenter).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "cost_center.delete", EntityType: "cost_center", EntityID: costCenter.ID,
+			Before: costCenter,
+		})
+	})
+}
+
+// activeCostCenter checks that a cost center exists and is not archived
+func activeCostCenter(db *gorm.DB, id uint) error {
+	var costCenter models.CostCenter
+	if err := db.Select("id", "archived").First(&costCenter, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("cost center not found")
+		}
+		return err
+	}
+	if costCenter.Archived {
+		return errors.New("cost center is archived")
+	}
+	return nil
+}
+
+// setCostCenterFields applies the code and name in req. Codes are unique
+// within the organization regardless of case.
+func setCostCenterFields(tx *gorm.DB, costCenter *models.CostCenter, req models.CostCenterRequest) error {
+	if req.Code != nil {
+		code := strings.TrimSpace(*req.Code)
+		if code == "" {
+			return errors.New("cost center code is required")
+		}
+		var count int64
+		err := tx.Model(&models.CostCenter{}).Where("LOWER(code) = LOWER(?) AND id <> ?", code, costCenter.ID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("cost center already exists")
+		}
+		costCenter.Code = code
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return errors.New("cost center name is required")
+		}
+		costCenter.Name = name
+	}
+	return nil
+}
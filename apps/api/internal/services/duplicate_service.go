@@ -0,0 +1,273 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/matching"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+type DuplicateService struct {
+	db *gorm.DB
+}
+
+func NewDuplicateService() *DuplicateService {
+	return &DuplicateService{
+		db: database.GetDB(),
+	}
+}
+
+// ListDuplicates returns the suspected pairs whose expenses are both live and
+// visible to the principal, most likely duplicates first
+func (s *DuplicateService) ListDuplicates(p *auth.Principal, limit int) ([]models.DuplicatePair, error) {
+	pairs := []models.DuplicatePair{}
+	err := scoped(s.db, p).
+		Joins("JOIN expenses ON expenses.id = duplicate_pairs.expense_id AND expenses.deleted_at IS NULL").
+		Joins("JOIN expenses AS originals ON originals.id = duplicate_pairs.duplicate_of_id AND originals.deleted_at IS NULL").
+		Scopes(visibleTo(p, authz.ExpenseRead)).
+		Where("duplicate_pairs.status = ?", models.DuplicateSuspected).
+		Preload("Expense.Attachments").Preload("DuplicateOf.Attachments").
+		Order("duplicate_pairs.score DESC, duplicate_pairs.id DESC").
+		Limit(limit).
+		Find(&pairs).Error
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// DismissDuplicate marks a suspected pair as two distinct expenses so it is not flagged again
+func (s *DuplicateService) DismissDuplicate(p *auth.Principal, id uint) (*models.DuplicatePair, error) {
+	db := scoped(s.db, p)
+	var pair models.DuplicatePair
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&pair, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("duplicate not found")
+			}
+			return err
+		}
+
+		var expense models.Expense
+		if err := tx.Scopes(visibleTo(p, authz.ExpenseRead)).First(&expense, pair.ExpenseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("duplicate not found")
+			}
+			return err
+		}
+		if !authz.AllowedOn(p, authz.ExpenseUpdate, expense.UserID) {
+			return errors.New("permission denied")
+		}
+		if pair.Status != models.DuplicateSuspected {
+			return errors.New("duplicate already dismissed")
+		}
+
+		before := pair
+		now := time.Now()
+		pair.Status = models.DuplicateDismissed
+		pair.DismissedByID = p.UserID
+		pair.DismissedAt = &now
+		if err := tx.Save(&pair).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "duplicate.dismiss", EntityType: "duplicate_pair", EntityID: pair.ID, ExpenseID: pair.ExpenseID,
+			Before: before, After: pair,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Preload("Expense").Preload("DuplicateOf").First(&pair, pair.ID).Error; err != nil {
+		return nil, err
+	}
+	return &pair, nil
+}
+
+// duplicateMode returns how the organization treats likely duplicates
+func duplicateMode(db *gorm.DB, organizationID uint) (models.DuplicateMode, error) {
+	var org models.Organization
+	if err := db.Select("id", "duplicate_mode").First(&org, organizationID).Error; err != nil {
+		return "", err
+	}
+	if org.DuplicateMode == "" {
+		return models.DuplicateWarn, nil
+	}
+	return org.DuplicateMode, nil
+}
+
+// findDuplicates compares an expense, saved or not, with the owner's other
+// live expenses dated nearby or sharing one of its attachment files. Pairs
+// dismissed before are left out.
+func findDuplicates(db *gorm.DB, expense *models.Expense) ([]models.PossibleDuplicate, error) {
+	var candidates []models.Expense
+	err := db.Where("user_id = ? AND id <> ?", expense.UserID, expense.ID).
+		Where("date BETWEEN ? AND ?", expense.Date.Add(-matching.DuplicateWindow), expense.Date.Add(matching.DuplicateWindow)).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	if expense.ID != 0 {
+		err := db.Model(&models.Attachment{}).Where("expense_id = ? AND content_hash <> ''", expense.ID).
+			Distinct().Pluck("content_hash", &hashes).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	sharing := make(map[uint]bool)
+	if len(hashes) > 0 {
+		var ids []uint
+		err := db.Model(&models.Attachment{}).
+			Joins("JOIN expenses ON expenses.id = attachments.expense_id AND expenses.deleted_at IS NULL").
+			Where("expenses.user_id = ? AND expenses.id <> ?", expense.UserID, expense.ID).
+			Where("attachments.content_hash IN ?", hashes).
+			Distinct().Pluck("attachments.expense_id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		known := make(map[uint]bool, len(candidates))
+		for _, c := range candidates {
+			known[c.ID] = true
+		}
+		var missing []uint
+		for _, id := range ids {
+			sharing[id] = true
+			if !known[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			var more []models.Expense
+			if err := db.Where("id IN ?", missing).Find(&more).Error; err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, more...)
+		}
+	}
+
+	dismissed := make(map[uint]bool)
+	if expense.ID != 0 {
+		var pairs []models.DuplicatePair
+		err := db.Where("status = ? AND (expense_id = ? OR duplicate_of_id = ?)", models.DuplicateDismissed, expense.ID, expense.ID).
+			Find(&pairs).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range pairs {
+			dismissed[pair.ExpenseID] = true
+			dismissed[pair.DuplicateOfID] = true
+		}
+	}
+
+	subject := duplicateCandidate(*expense)
+	duplicates := []models.PossibleDuplicate{}
+	for _, c := range candidates {
+		if dismissed[c.ID] {
+			continue
+		}
+		score := matching.Duplicate(subject, duplicateCandidate(c), sharing[c.ID])
+		if score.Total < matching.DuplicateScore {
+			continue
+		}
+		duplicates = append(duplicates, models.PossibleDuplicate{
+			Expense: c, Score: score.Total, AmountScore: score.Amount, DateScore: score.Date, TextScore: score.Text,
+			SameAttachment: sharing[c.ID],
+		})
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Score > duplicates[j].Score })
+	return duplicates, nil
+}
+
+// recordDuplicates replaces the suspected pairs involving a saved expense with
+// duplicates, filling in their pair IDs. Dismissed pairs stay untouched.
+func recordDuplicates(tx *gorm.DB, expense *models.Expense, duplicates []models.PossibleDuplicate) error {
+	var existing []models.DuplicatePair
+	err := tx.Where("status = ? AND (expense_id = ? OR duplicate_of_id = ?)", models.DuplicateSuspected, expense.ID, expense.ID).
+		Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	byKey := make(map[[2]uint]models.DuplicatePair, len(existing))
+	for _, pair := range existing {
+		byKey[[2]uint{pair.ExpenseID, pair.DuplicateOfID}] = pair
+	}
+
+	kept := []uint{0}
+	for i, d := range duplicates {
+		later, earlier := expense.ID, d.Expense.ID
+		if earlier > later {
+			later, earlier = earlier, later
+		}
+		pair := byKey[[2]uint{later, earlier}]
+		pair.ExpenseID, pair.DuplicateOfID = later, earlier
+		pair.Score, pair.AmountScore, pair.DateScore, pair.TextScore = d.Score, d.AmountScore, d.DateScore, d.TextScore
+		pair.SameAttachment = d.SameAttachment
+		pair.Status = models.DuplicateSuspected
+		if err := tx.Save(&pair).Error; err != nil {
+			return err
+		}
+		duplicates[i].PairID = pair.ID
+		kept = append(kept, pair.ID)
+	}
+
+	return tx.Where("status = ? AND (expense_id = ? OR duplicate_of_id = ?) AND id NOT IN ?", models.DuplicateSuspected, expense.ID, expense.ID, kept).
+		Delete(&models.DuplicatePair{}).Error
+}
+
+// refreshDuplicates re-examines an expense whose attachments changed. It only
+// records suspected pairs; attachments are never blocked.
+func refreshDuplicates(tx *gorm.DB, organizationID, expenseID uint) error {
+	mode, err := duplicateMode(tx, organizationID)
+	if err != nil || mode == models.DuplicateIgnore {
+		return err
+	}
+	var expense models.Expense
+	if err := tx.First(&expense, expenseID).Error; err != nil {
+		return err
+	}
+	duplicates, err := findDuplicates(tx, &expense)
+	if err != nil {
+		return err
+	}
+	return recordDuplicates(tx, &expense, duplicates)
+}
+
+func duplicateCandidate(e models.Expense) matching.Candidate {
+	return matching.Candidate{
+		AmountMinor: e.AmountMinor,
+		Currency:    e.Currency,
+		Date:        e.Date,
+		Text:        e.Description,
+	}
+}
+
+// contentHash returns the hex SHA-256 of an uploaded file
+func contentHash(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
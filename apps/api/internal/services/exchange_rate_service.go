@@ -0,0 +1,336 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// ecbDateLayouts are the date formats used by the ECB reference rate files:
+// the historical file uses ISO dates, the daily file spells the month out.
+var ecbDateLayouts = []string{"2006-01-02", "02 January 2006", "2 January 2006"}
+
+// ExchangeRateFilter narrows the exchange rates returned by ListRates
+type ExchangeRateFilter struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	From          *time.Time
+	To            *time.Time
+}
+
+type ExchangeRateService struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateService() *ExchangeRateService {
+	return &ExchangeRateService{
+		db: database.GetDB(),
+	}
+}
+
+// ListRates returns the organization's exchange rates, newest first
+func (s *ExchangeRateService) ListRates(p *auth.Principal, filter ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	query := scoped(s.db, p)
+	if filter.BaseCurrency != "" {
+		query = query.Where("base_currency = ?", money.Normalize(filter.BaseCurrency))
+	}
+	if filter.QuoteCurrency != "" {
+		query = query.Where("quote_currency = ?", money.Normalize(filter.QuoteCurrency))
+	}
+	if filter.From != nil {
+		query = query.Where("date >= ?", rateDate(*filter.From))
+	}
+	if filter.To != nil {
+		query = query.Where("date <= ?", rateDate(*filter.To))
+	}
+
+	rates := []models.ExchangeRate{}
+	if err := query.Order("date DESC, base_currency, quote_currency").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// CreateRate records a single exchange rate, replacing any existing rate for the same day and pair
+func (s *ExchangeRateService) CreateRate(p *auth.Principal, req models.CreateExchangeRateRequest) (*models.ExchangeRate, error) {
+	date, err := parseRateDate(req.Date)
+	if err != nil {
+		return nil, errors.New("invalid date")
+	}
+
+	base := money.Normalize(req.BaseCurrency)
+	quote := money.Normalize(req.QuoteCurrency)
+	if !money.Valid(base) || !money.Valid(quote) {
+		return nil, errors.New("unsupported currency")
+	}
+	if base == quote {
+		return nil, errors.New("base and quote currency must differ")
+	}
+
+	rate, err := money.ParseRate(string(req.Rate))
+	if err != nil {
+		return nil, errors.New("invalid exchange rate")
+	}
+
+	row := &models.ExchangeRate{
+		Date:          date,
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          money.FormatRate(rate),
+		Source:        "manual",
+	}
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var before *models.ExchangeRate
+		var existing models.ExchangeRate
+		err := tx.Where("date = ? AND base_currency = ? AND quote_currency = ?", date, base, quote).First(&existing).Error
+		if err == nil {
+			before = &existing
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := upsertRates(tx, []models.ExchangeRate{*row}); err != nil {
+			return err
+		}
+		if err := tx.Where("date = ? AND base_currency = ? AND quote_currency = ?", date, base, quote).First(row).Error; err != nil {
+			return err
+		}
+
+		change := audit.Change{Action: "exchange_rate.create", EntityType: "exchange_rate", EntityID: row.ID, After: row}
+		if before != nil {
+			change.Action, change.Before = "exchange_rate.update", before
+		}
+		return recordChange(tx, p, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// ImportECB loads an ECB reference rate CSV (daily or historical) into the organization's rate table.
+// ECB files quote every currency against the euro, so base defaults to EUR.
+func (s *ExchangeRateService) ImportECB(p *auth.Principal, r io.Reader, base string) (*models.ImportExchangeRatesResponse, error) {
+	base = money.Normalize(base)
+	if base == "" {
+		base = "EUR"
+	}
+	if !money.Valid(base) {
+		return nil, errors.New("unsupported currency")
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("invalid exchange rate file")
+	}
+	if len(header) < 2 || !strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(header[0], "\ufeff")), "date") {
+		return nil, errors.New("invalid exchange rate file")
+	}
+
+	// Columns for currencies we do not support are skipped rather than rejected
+	columns := make(map[int]string)
+	for i, name := range header[1:] {
+		code := money.Normalize(name)
+		if money.Valid(code) && code != base {
+			columns[i+1] = code
+		}
+	}
+
+	var rows []models.ExchangeRate
+	currencies := make(map[string]bool)
+	var first, last time.Time
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid exchange rate file: line %d: %v", line, err)
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		date, err := parseRateDate(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid exchange rate file: line %d: invalid date %q", line, record[0])
+		}
+
+		for i, quote := range columns {
+			if i >= len(record) {
+				continue
+			}
+			value := strings.TrimSpace(record[i])
+			if value == "" || strings.EqualFold(value, "N/A") {
+				continue
+			}
+			rate, err := money.ParseRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exchange rate file: line %d: invalid rate %q for %s", line, value, quote)
+			}
+
+			rows = append(rows, models.ExchangeRate{
+				Date:          date,
+				BaseCurrency:  base,
+				QuoteCurrency: quote,
+				Rate:          money.FormatRate(rate),
+				Source:        "ecb",
+			})
+			currencies[quote] = true
+		}
+
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("no exchange rates found in file")
+	}
+
+	resp := &models.ImportExchangeRatesResponse{
+		Imported:     len(rows),
+		BaseCurrency: base,
+		Currencies:   make([]string, 0, len(currencies)),
+		From:         first.Format("2006-01-02"),
+		To:           last.Format("2006-01-02"),
+	}
+	for code := range currencies {
+		resp.Currencies = append(resp.Currencies, code)
+	}
+	sort.Strings(resp.Currencies)
+
+	// A bulk import is recorded as one event summarizing what was loaded
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := upsertRates(tx, rows); err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "exchange_rate.import", EntityType: "exchange_rate",
+			After: resp,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// upsertRates inserts rates, overwriting any existing rate for the same day and pair
+func upsertRates(db *gorm.DB, rows []models.ExchangeRate) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "date"}, {Name: "base_currency"}, {Name: "quote_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(rows, 500).Error
+}
+
+// lookupRate finds the number of units of to per unit of from in effect on date, using the
+// most recent rate on or before that day. Pairs without a direct rate are derived from the
+// inverse pair or crossed through a currency both are quoted against.
+func lookupRate(db *gorm.DB, date time.Time, from, to string) (*big.Rat, time.Time, error) {
+	day := rateDate(date)
+	if from == to {
+		return big.NewRat(1, 1), day, nil
+	}
+
+	var best *big.Rat
+	var bestDate time.Time
+	consider := func(rate *big.Rat, on time.Time) {
+		if best == nil || on.After(bestDate) {
+			best, bestDate = rate, on
+		}
+	}
+
+	var direct models.ExchangeRate
+	err := db.Where("base_currency = ? AND quote_currency = ? AND date <= ?", from, to, day).Order("date DESC").First(&direct).Error
+	if err == nil {
+		rate, err := money.ParseRate(direct.Rate)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		consider(rate, direct.Date)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, err
+	}
+
+	var inverse models.ExchangeRate
+	err = db.Where("base_currency = ? AND quote_currency = ? AND date <= ?", to, from, day).Order("date DESC").First(&inverse).Error
+	if err == nil {
+		rate, err := money.ParseRate(inverse.Rate)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		consider(new(big.Rat).Inv(rate), inverse.Date)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, err
+	}
+
+	// Cross rate: both currencies quoted against the same base on the same day
+	var leg models.ExchangeRate
+	err = db.Where("quote_currency = ? AND base_currency <> ? AND date <= ?", from, to, day).
+		Where("EXISTS (SELECT 1 FROM exchange_rates x WHERE x.organization_id = exchange_rates.organization_id AND x.date = exchange_rates.date AND x.base_currency = exchange_rates.base_currency AND x.quote_currency = ?)", to).
+		Order("date DESC").First(&leg).Error
+	if err == nil {
+		var other models.ExchangeRate
+		if err := db.Where("base_currency = ? AND quote_currency = ? AND date = ?", leg.BaseCurrency, to, leg.Date).First(&other).Error; err != nil {
+			return nil, time.Time{}, err
+		}
+		fromRate, err := money.ParseRate(leg.Rate)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		toRate, err := money.ParseRate(other.Rate)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		consider(new(big.Rat).Quo(toRate, fromRate), leg.Date)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, err
+	}
+
+	if best == nil {
+		return nil, time.Time{}, fmt.Errorf("no exchange rate from %s to %s on or before %s", from, to, day.Format("2006-01-02"))
+	}
+	return best, bestDate, nil
+}
+
+// rateDate reduces a timestamp to the calendar day it falls on, as stored in the rate table
+func rateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func parseRateDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range ecbDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return rateDate(t), nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return rateDate(t), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,699 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/reporting"
+)
+
+// maxReceiptSize bounds the receipt files read for the thumbnails of a report summary
+const maxReceiptSize = 10 << 20
+
+// ExpenseReportService manages expense reports: the expenses on them, their
+// workflow and their PDF summaries
+type ExpenseReportService struct {
+	db          *gorm.DB
+	attachments *AttachmentService
+}
+
+func NewExpenseReportService() *ExpenseReportService {
+	return &ExpenseReportService{
+		db:          database.GetDB(),
+		attachments: NewAttachmentService(),
+	}
+}
+
+// ListExpenseReports returns the expense reports visible to the principal,
+// newest first, optionally only those with the given status
+func (s *ExpenseReportService) ListExpenseReports(p *auth.Principal, status models.ExpenseStatus) ([]models.ExpenseReport, error) {
+	db := scoped(s.db, p)
+	query := db.Scopes(reportsVisibleTo(p, authz.ExpenseRead), withReportExpenses)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	reports := []models.ExpenseReport{}
+	if err := query.Order("created_at DESC, id DESC").Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	for i := range reports {
+		if err := fillReportTotals(db, &reports[i]); err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
+
+// GetExpenseReport returns one expense report with its expenses and totals
+func (s *ExpenseReportService) GetExpenseReport(p *auth.Principal, id uint) (*models.ExpenseReport, error) {
+	db := scoped(s.db, p)
+	var report models.ExpenseReport
+	if err := db.Scopes(reportsVisibleTo(p, authz.ExpenseRead), withReportExpenses).First(&report, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense report not found")
+		}
+		return nil, err
+	}
+	if err := fillReportTotals(db, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// CreateExpenseReport creates a draft expense report owned by the principal
+// holding the requested expenses
+func (s *ExpenseReportService) CreateExpenseReport(p *auth.Principal, req models.CreateExpenseReportRequest) (*models.ExpenseReport, error) {
+	report := &models.ExpenseReport{
+		UserID:  p.UserID,
+		Title:   strings.TrimSpace(req.Title),
+		Purpose: strings.TrimSpace(req.Purpose),
+		Status:  models.StatusDraft,
+	}
+	if report.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if err := setReportPeriod(report, &req.PeriodStart, &req.PeriodEnd); err != nil {
+		return nil, err
+	}
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Expenses").Create(report).Error; err != nil {
+			return err
+		}
+		if err := recordChange(tx, p, audit.Change{
+			Action: "expense_report.create", EntityType: "expense_report", EntityID: report.ID,
+			After: report,
+		}); err != nil {
+			return err
+		}
+		return addToReport(tx, p, report, req.ExpenseIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetExpenseReport(p, report.ID)
+}
+
+// UpdateExpenseReport changes the title, purpose or period of an expense
+// report that is not under review or past approval
+func (s *ExpenseReportService) UpdateExpenseReport(p *auth.Principal, id uint, req models.UpdateExpenseReportRequest) (*models.ExpenseReport, error) {
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		report, err := editableReport(tx, p, id, authz.ExpenseUpdate)
+		if err != nil {
+			return err
+		}
+		before := *report
+
+		if req.Title != nil {
+			report.Title = strings.TrimSpace(*req.Title)
+			if report.Title == "" {
+				return errors.New("title is required")
+			}
+		}
+		if req.Purpose != nil {
+			report.Purpose = strings.TrimSpace(*req.Purpose)
+		}
+		if err := setReportPeriod(report, req.PeriodStart, req.PeriodEnd); err != nil {
+			return err
+		}
+		if req.PeriodStart != nil || req.PeriodEnd != nil {
+			var dates []time.Time
+			if err := tx.Model(&models.Expense{}).Where("report_id = ?", report.ID).Pluck("date", &dates).Error; err != nil {
+				return err
+			}
+			for _, date := range dates {
+				if !report.Covers(date) {
+					return errors.New("expense date is outside the report period")
+				}
+			}
+		}
+
+		report.UpdatedAt = time.Now()
+		if err := tx.Omit("Expenses").Save(report).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "expense_report.update", EntityType: "expense_report", EntityID: report.ID,
+			Before: before, After: report,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetExpenseReport(p, id)
+}
+
+// DeleteExpenseReport deletes an expense report that is not under review or
+// past approval. Its expenses are kept, off any report.
+func (s *ExpenseReportService) DeleteExpenseReport(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		report, err := editableReport(tx, p, id, authz.ExpenseDelete)
+		if err != nil {
+			return err
+		}
+		// Deleted expenses are detached too so a restore does not bring them back onto a report
+		err = tx.Unscoped().Model(&models.Expense{}).Where("report_id = ?", report.ID).
+			Updates(map[string]interface{}{"report_id": nil, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("report_id = ?", report.ID).Delete(&models.ExpenseReportTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(report).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "expense_report.delete", EntityType: "expense_report", EntityID: report.ID,
+			Before: report,
+		})
+	})
+}
+
+// AddExpenses puts expenses of the report's owner on an expense report that
+// is not under review or past approval
+func (s *ExpenseReportService) AddExpenses(p *auth.Principal, id uint, expenseIDs []uint) (*models.ExpenseReport, error) {
+	if len(expenseIDs) == 0 {
+		return nil, errors.New("expense_ids is required")
+	}
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		report, err := editableReport(tx, p, id, authz.ExpenseUpdate)
+		if err != nil {
+			return err
+		}
+		return addToReport(tx, p, report, expenseIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetExpenseReport(p, id)
+}
+
+// RemoveExpense takes an expense off an expense report that is not under
+// review or past approval
+func (s *ExpenseReportService) RemoveExpense(p *auth.Principal, id, expenseID uint) (*models.ExpenseReport, error) {
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		report, err := editableReport(tx, p, id, authz.ExpenseUpdate)
+		if err != nil {
+			return err
+		}
+
+		var expense models.Expense
+		if err := tx.Where("report_id = ?", report.ID).First(&expense, expenseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("expense not found")
+			}
+			return err
+		}
+		if err := bumpVersion(tx, &expense); err != nil {
+			return err
+		}
+		if err := tx.Model(&expense).UpdateColumn("report_id", nil).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "expense_report.remove_expense", EntityType: "expense_report", EntityID: report.ID, ExpenseID: expense.ID,
+			Before: expense,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetExpenseReport(p, id)
+}
+
+// Transition applies a workflow action to an expense report and, in the same
+// step, to each of its expenses. Submitting re-checks the organization's
+// policy rules on every expense; broken blocking rules fail with "policy
+// violation", returning the report with the violations. The approvals a
+// report needs follow from its total.
+func (s *ExpenseReportService) Transition(p *auth.Principal, id uint, action models.ExpenseAction, comment string) (*models.ExpenseReport, error) {
+	step, ok := workflow[action]
+	if !ok {
+		return nil, errors.New("unknown workflow action")
+	}
+
+	comment = strings.TrimSpace(comment)
+	if action == models.ActionReject && comment == "" {
+		return nil, errors.New("a comment is required when rejecting")
+	}
+
+	db := scoped(s.db, p)
+	var report models.ExpenseReport
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Scopes(reportsVisibleTo(p, authz.ExpenseRead)).
+			Preload("Expenses", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+			Preload("Expenses.Allocations").
+			First(&report, id).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("expense report not found")
+			}
+			return err
+		}
+
+		if !authz.AllowedOn(p, step.permission, report.UserID) {
+			return errors.New("permission denied")
+		}
+
+		from := report.Status
+		if from == "" {
+			from = models.StatusDraft
+		}
+		if !containsStatus(step.from, from) {
+			return errors.New("invalid transition")
+		}
+		if len(report.Expenses) == 0 {
+			return errors.New("expense report is empty")
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"updated_at": now}
+		expenseUpdates := map[string]interface{}{}
+		to := from
+		level := 0
+
+		switch action {
+		case models.ActionSubmit:
+			var total int64
+			var blocked []models.PolicyViolation
+			for i := range report.Expenses {
+				expense := &report.Expenses[i]
+				if !report.Covers(expense.Date) {
+					return errors.New("expense date is outside the report period")
+				}
+				violations, err := checkPolicies(tx, expense, true)
+				if err != nil {
+					if err.Error() != "policy violation" {
+						return err
+					}
+					for _, v := range expense.PolicyViolations {
+						v.ExpenseID = expense.ID
+						blocked = append(blocked, v)
+					}
+					continue
+				}
+				if err := recordViolations(tx, expense.ID, violations); err != nil {
+					return err
+				}
+				total += expense.BaseAmountMinor
+			}
+			if len(blocked) > 0 {
+				report.PolicyViolations = blocked
+				return errors.New("policy violation")
+			}
+			required, err := requiredApprovals(tx, total)
+			if err != nil {
+				return err
+			}
+			to = models.StatusSubmitted
+			updates["approval_count"] = 0
+			updates["required_approvals"] = required
+			updates["submitted_at"] = &now
+			expenseUpdates["approval_count"] = 0
+			expenseUpdates["required_approvals"] = required
+			expenseUpdates["submitted_at"] = &now
+
+		case models.ActionApprove:
+			if err := checkReportApprover(tx, p, report); err != nil {
+				return err
+			}
+			level = report.ApprovalCount + 1
+			updates["approval_count"] = level
+			expenseUpdates["approval_count"] = level
+			if level >= report.RequiredApprovals {
+				to = models.StatusApproved
+			}
+
+		case models.ActionReject:
+			if report.UserID == p.UserID {
+				return errors.New("cannot review own expense report")
+			}
+			to = models.StatusRejected
+
+		case models.ActionReimburse:
+			for _, expense := range report.Expenses {
+				if expense.ReimbursementBatchID != nil {
+					return errors.New("expense is in a reimbursement batch")
+				}
+			}
+			to = models.StatusReimbursed
+		}
+		updates["status"] = to
+
+		// Guard on the status we read so concurrent reviewers cannot both advance the same step
+		result := tx.Model(&models.ExpenseReport{}).
+			Where("id = ? AND status = ? AND approval_count = ?", report.ID, from, report.ApprovalCount).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("expense report was modified concurrently")
+		}
+
+		for _, expense := range report.Expenses {
+			// A rejected report may have gained draft expenses since
+			if !containsStatus(step.from, currentStatus(expense)) {
+				return errors.New("expense was modified concurrently")
+			}
+			perExpense := make(map[string]interface{}, len(expenseUpdates))
+			for k, v := range expenseUpdates {
+				perExpense[k] = v
+			}
+			if action == models.ActionReimburse && expense.Reimbursement == models.ReimbursementDue {
+				perExpense["reimbursement"] = models.ReimbursementPaid
+				perExpense["paid_at"] = now
+			}
+			if err := advance(tx, p, expense, action, to, level, perExpense, comment); err != nil {
+				return err
+			}
+		}
+
+		transition := &models.ExpenseReportTransition{
+			ReportID:      report.ID,
+			Action:        action,
+			FromStatus:    from,
+			ToStatus:      to,
+			ActorID:       p.UserID,
+			ApprovalLevel: level,
+			Comment:       comment,
+		}
+		if err := tx.Create(transition).Error; err != nil {
+			return err
+		}
+
+		before := report
+		before.Expenses = nil
+		var after models.ExpenseReport
+		if err := tx.First(&after, report.ID).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "expense_report." + string(action), EntityType: "expense_report", EntityID: report.ID,
+			Before: before, After: after,
+		})
+	})
+	if err != nil {
+		if err.Error() == "policy violation" {
+			// The handler reports the violations that stopped the submission
+			return &report, err
+		}
+		return nil, err
+	}
+
+	return s.GetExpenseReport(p, id)
+}
+
+// GetTransitions returns the workflow history of an expense report visible to the principal
+func (s *ExpenseReportService) GetTransitions(p *auth.Principal, id uint) ([]models.ExpenseReportTransition, error) {
+	db := scoped(s.db, p)
+
+	var report models.ExpenseReport
+	if err := db.Scopes(reportsVisibleTo(p, authz.ExpenseRead)).Select("id").First(&report, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense report not found")
+		}
+		return nil, err
+	}
+
+	transitions := []models.ExpenseReportTransition{}
+	if err := db.Where("report_id = ?", id).Order("created_at, id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// WriteExpenseReportPDF writes the PDF summary of an expense report. Receipts
+// are included when the principal may read the owner's attachments.
+func (s *ExpenseReportService) WriteExpenseReportPDF(p *auth.Principal, id uint, w io.Writer) error {
+	report, err := s.GetExpenseReport(p, id)
+	if err != nil {
+		return err
+	}
+
+	summary := reporting.ExpenseReportSummary{
+		Title:          report.Title,
+		Purpose:        report.Purpose,
+		Status:         string(report.Status),
+		Records:        reportRecords(report),
+		BaseTotalMinor: report.BaseTotalMinor,
+		BaseCurrency:   report.BaseCurrency,
+	}
+	if report.PeriodStart != nil {
+		summary.StartDate = *report.PeriodStart
+	}
+	if report.PeriodEnd != nil {
+		summary.EndDate = *report.PeriodEnd
+	}
+
+	var owner models.User
+	if err := s.db.Select("id", "name", "email").First(&owner, report.UserID).Error; err == nil {
+		summary.Owner = owner.Name
+		if summary.Owner == "" {
+			summary.Owner = owner.Email
+		}
+	}
+
+	if authz.AllowedOn(p, authz.AttachmentRead, report.UserID) {
+		for _, expense := range report.Expenses {
+			for i := range expense.Attachments {
+				summary.Receipts = append(summary.Receipts, s.receipt(expense, &expense.Attachments[i]))
+			}
+		}
+	}
+
+	return reporting.WriteExpenseReportPDF(w, summary)
+}
+
+// receipt reads an image attachment for its thumbnail. Other files, and
+// images that are too large or cannot be read, are captioned only.
+func (s *ExpenseReportService) receipt(expense models.Expense, attachment *models.Attachment) reporting.Receipt {
+	receipt := reporting.Receipt{
+		Caption:     fmt.Sprintf("%s %s", expense.Date.Format("2006-01-02"), attachment.Filename),
+		ContentType: attachment.ContentType,
+	}
+	if !strings.HasPrefix(strings.ToLower(attachment.ContentType), "image/") || attachment.FileSize > maxReceiptSize {
+		return receipt
+	}
+
+	reader, _, _, err := s.attachments.openFile(attachment)
+	if err != nil {
+		log.Printf("Expense report receipt %d: %v", attachment.ID, err)
+		return receipt
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxReceiptSize))
+	if err != nil {
+		log.Printf("Expense report receipt %d: %v", attachment.ID, err)
+		return receipt
+	}
+	receipt.Data = data
+	return receipt
+}
+
+// editableReport loads an expense report the principal may perform action on
+// and that is not under review or past approval
+func editableReport(tx *gorm.DB, p *auth.Principal, id uint, action authz.Action) (*models.ExpenseReport, error) {
+	var report models.ExpenseReport
+	if err := tx.Scopes(reportsVisibleTo(p, authz.ExpenseRead)).First(&report, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense report not found")
+		}
+		return nil, err
+	}
+	if !authz.AllowedOn(p, action, report.UserID) {
+		return nil, errors.New("permission denied")
+	}
+	if !report.Editable() {
+		return nil, errors.New("expense report is locked")
+	}
+	return &report, nil
+}
+
+// addToReport puts the expenses on the report. They must belong to the
+// report's owner, be editable, be on no other report and be dated within the
+// report's period. Expenses already on the report are left as they are.
+func addToReport(tx *gorm.DB, p *auth.Principal, report *models.ExpenseReport, expenseIDs []uint) error {
+	for _, id := range expenseIDs {
+		var expense models.Expense
+		if err := tx.Scopes(visibleTo(p, authz.ExpenseRead)).First(&expense, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("expense not found")
+			}
+			return err
+		}
+		if expense.ReportID != nil {
+			if *expense.ReportID == report.ID {
+				continue
+			}
+			return errors.New("expense is on another report")
+		}
+		if expense.UserID != report.UserID {
+			return errors.New("expense belongs to another user")
+		}
+		if !expense.Editable() {
+			return errors.New("expense is locked")
+		}
+		if !report.Covers(expense.Date) {
+			return errors.New("expense date is outside the report period")
+		}
+
+		if err := bumpVersion(tx, &expense); err != nil {
+			return err
+		}
+		if err := tx.Model(&expense).UpdateColumn("report_id", report.ID).Error; err != nil {
+			return err
+		}
+		expense.ReportID = &report.ID
+		if err := recordChange(tx, p, audit.Change{
+			Action: "expense_report.add_expense", EntityType: "expense_report", EntityID: report.ID, ExpenseID: expense.ID,
+			After: expense,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setReportPeriod applies the requested period bounds; nil leaves a bound as
+// it is and an empty string removes it
+func setReportPeriod(report *models.ExpenseReport, start, end *string) error {
+	if start != nil {
+		report.PeriodStart = nil
+		if strings.TrimSpace(*start) != "" {
+			day, err := parseRateDate(*start)
+			if err != nil {
+				return errors.New("invalid period start")
+			}
+			report.PeriodStart = &day
+		}
+	}
+	if end != nil {
+		report.PeriodEnd = nil
+		if strings.TrimSpace(*end) != "" {
+			day, err := parseRateDate(*end)
+			if err != nil {
+				return errors.New("invalid period end")
+			}
+			report.PeriodEnd = &day
+		}
+	}
+	if report.PeriodStart != nil && report.PeriodEnd != nil && report.PeriodEnd.Before(*report.PeriodStart) {
+		return errors.New("period end is before period start")
+	}
+	return nil
+}
+
+// checkReportPeriod fails when an expense on a report is dated outside the report's period
+func checkReportPeriod(db *gorm.DB, expense *models.Expense) error {
+	if expense.ReportID == nil {
+		return nil
+	}
+	var report models.ExpenseReport
+	if err := db.Select("id", "period_start", "period_end").First(&report, *expense.ReportID).Error; err != nil {
+		return err
+	}
+	if !report.Covers(expense.Date) {
+		return errors.New("expense date is outside the report period")
+	}
+	return nil
+}
+
+// checkReportApprover enforces segregation of duties within the report's
+// current review round, as checkApprover does for single expenses
+func checkReportApprover(tx *gorm.DB, p *auth.Principal, report models.ExpenseReport) error {
+	if report.UserID == p.UserID {
+		return errors.New("cannot review own expense report")
+	}
+
+	var round models.ExpenseReportTransition
+	err := tx.Where("report_id = ? AND action = ?", report.ID, models.ActionSubmit).Order("id DESC").First(&round).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var previous int64
+	err = tx.Model(&models.ExpenseReportTransition{}).
+		Where("report_id = ? AND action = ? AND actor_id = ? AND id > ?", report.ID, models.ActionApprove, p.UserID, round.ID).
+		Count(&previous).Error
+	if err != nil {
+		return err
+	}
+	if previous > 0 {
+		return errors.New("already approved by this user")
+	}
+	return nil
+}
+
+// fillReportTotals totals the report's expenses per currency, per category
+// and currency, and in the organization's base currency
+func fillReportTotals(db *gorm.DB, report *models.ExpenseReport) error {
+	currency, err := baseCurrency(db, report.OrganizationID)
+	if err != nil {
+		return err
+	}
+	report.BaseCurrency = currency
+	report.BaseTotalMinor = 0
+	for _, expense := range report.Expenses {
+		report.BaseTotalMinor += expense.BaseAmountMinor
+	}
+	report.BaseTotal = money.FromMinor(report.BaseTotalMinor, currency)
+
+	records := reportRecords(report)
+	report.Totals = summaryGroups(reporting.Totals(records))
+	report.CategoryTotals = summaryGroups(reporting.Summarize(records, "category"))
+	return nil
+}
+
+// reportRecords lists the lines of the report's expenses in the currencies
+// they were paid in. A split expense contributes one record per line.
+func reportRecords(report *models.ExpenseReport) []reporting.Record {
+	var records []reporting.Record
+	for _, e := range report.Expenses {
+		for _, line := range allocationLines(e) {
+			record := reporting.Record{
+				Date:           e.Date,
+				Category:       line.Category,
+				Item:           line.Description,
+				Quantity:       1,
+				UnitPriceMinor: line.AmountMinor,
+				Currency:       line.Currency,
+			}
+			if line.Project != nil {
+				record.Project = line.Project.Name
+			}
+			if line.CostCenter != nil {
+				record.CostCenter = line.CostCenter.Code
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// withReportExpenses preloads a report's expenses, oldest first, with what
+// their totals and summary need
+func withReportExpenses(db *gorm.DB) *gorm.DB {
+	return db.Preload("Expenses", func(tx *gorm.DB) *gorm.DB { return tx.Order("date, id") }).
+		Preload("Expenses.Attachments").Preload("Expenses.Project").Preload("Expenses.CostCenter").
+		Preload("Expenses.Tags").Preload("Expenses.Allocations", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") })
+}
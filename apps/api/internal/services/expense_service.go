@@ -2,15 +2,41 @@ package services
 
 import (
     "errors"
+    "fmt"
     "strings"
     "time"
     
     "gorm.io/gorm"
     
+    "github.com/example/next-go-monorepo/apps/api/internal/audit"
+    "github.com/example/next-go-monorepo/apps/api/internal/auth"
+    "github.com/example/next-go-monorepo/apps/api/internal/authz"
     "github.com/example/next-go-monorepo/apps/api/internal/database"
     "github.com/example/next-go-monorepo/apps/api/internal/models"
+    "github.com/example/next-go-monorepo/apps/api/internal/money"
+    "github.com/example/next-go-monorepo/apps/api/internal/pagination"
+    "github.com/example/next-go-monorepo/apps/api/internal/reporting"
+    "github.com/example/next-go-monorepo/apps/api/internal/search"
 )
 
+// expenseSortFields maps the fields clients may sort by to indexed columns
+// and the matching value on a loaded expense, used to build cursors
+var expenseSortFields = map[string]struct {
+    column string
+    value  func(models.Expense) interface{}
+}{
+    "id":         {"expenses.id", func(e models.Expense) interface{} { return e.ID }},
+    "date":       {"expenses.date", func(e models.Expense) interface{} { return e.Date }},
+    "amount":     {"expenses.base_amount_minor", func(e models.Expense) interface{} { return e.BaseAmountMinor }},
+    "category":   {"expenses.category", func(e models.Expense) interface{} { return e.Category }},
+    "status":     {"expenses.status", func(e models.Expense) interface{} { return string(e.Status) }},
+    "created_at": {"expenses.created_at", func(e models.Expense) interface{} { return e.CreatedAt }},
+    "updated_at": {"expenses.updated_at", func(e models.Expense) interface{} { return e.UpdatedAt }},
+}
+
+// defaultExpenseSort lists the newest expenses first
+var defaultExpenseSort = []models.SortKey{{Field: "created_at", Desc: true}}
+
 type ExpenseService struct {
     db *gorm.DB
 }
@@ -21,14 +47,22 @@ func NewExpenseService() *ExpenseService {
     }
 }
 
-// CreateExpense creates a new expense
-func (s *ExpenseService) CreateExpense(req models.CreateExpenseRequest) (*models.Expense, error) {
+// CreateExpense creates a new expense owned by the principal. The organization's
+// policy rules are checked first: broken warning rules are recorded on the expense,
+// while a broken blocking rule stops it, returning the unsaved expense with its
+// violations alongside a "policy violation" error. Expenses resembling one of the
+// owner's others are returned with their lookalikes in PossibleDuplicates. If the
+// organization blocks duplicates, such an expense is likewise returned unsaved
+// alongside a "possible duplicate" error.
+func (s *ExpenseService) CreateExpense(p *auth.Principal, req models.CreateExpenseRequest) (*models.Expense, error) {
     expense := &models.Expense{
+        UserID:      p.UserID,
         Description: req.Description,
-        Amount:      req.Amount,
+        Currency:    req.Currency,
         Date:        req.Date,
         Category:    req.Category,
         ClientNotes: req.ClientNotes,
+        Attendees:   req.Attendees,
         CreatedAt:   time.Now(),
         UpdatedAt:   time.Now(),
     }
@@ -36,53 +70,329 @@ func (s *ExpenseService) CreateExpense(req models.CreateExpenseRequest) (*models
     if expense.Date.IsZero() {
         expense.Date = time.Now()
     }
+    if expense.Attendees == 0 {
+        expense.Attendees = 1
+    }
+    if expense.Attendees < 0 {
+        return nil, errors.New("attendees must be at least 1")
+    }
+    
+    expense.Reimbursement = models.ReimbursementDue
+    if req.Reimbursable != nil && !*req.Reimbursable {
+        expense.Reimbursement = models.ReimbursementNone
+    }
+    
+    expense.Type = models.ExpenseType(strings.ToLower(strings.TrimSpace(string(req.Type))))
+    if expense.Type == "" {
+        expense.Type = models.ExpenseStandard
+    }
+    // Computed expenses are in the currency of the rate they are priced with
+    if expense.Type != models.ExpenseStandard && strings.TrimSpace(req.Currency) != "" {
+        return nil, errors.New("amount is computed")
+    }
+    
+    db := scoped(s.db, p)
+    amount, err := expenseAmount(db, expense, req.Amount, req.Mileage, req.PerDiem)
+    if err != nil {
+        return nil, err
+    }
+    if err := setAmount(db, p.OrganizationID, expense, amount); err != nil {
+        return nil, err
+    }
+    
+    category, err := resolveCategory(db, req.Category)
+    if err != nil {
+        return nil, err
+    }
+    expense.Category = category
+    if expense.MerchantVATNumber, err = normalizeVATNumber(req.MerchantVATNumber); err != nil {
+        return nil, err
+    }
+    
+    // The merchant's default category and tax ID fill in those the request leaves out
+    merchant, err := expenseMerchant(db, req.MerchantID, req.Merchant, req.Description)
+    if err != nil {
+        return nil, err
+    }
+    if err := applyMerchantDefaults(db, expense, merchant); err != nil {
+        return nil, err
+    }
+    
+    // The category's tax code picks the rate unless the request names one
+    if err := applyTax(db, expense, taxDetails{
+        jurisdiction: req.TaxJurisdiction, code: req.TaxCode, rate: req.TaxRate, amount: req.TaxAmount,
+    }); err != nil {
+        return nil, err
+    }
+    
+    if req.ProjectID != nil && *req.ProjectID != 0 {
+        if err := openProject(db, *req.ProjectID); err != nil {
+            return nil, err
+        }
+        expense.ProjectID = req.ProjectID
+    }
+    if req.CostCenterID != nil && *req.CostCenterID != 0 {
+        if err := activeCostCenter(db, *req.CostCenterID); err != nil {
+            return nil, err
+        }
+        expense.CostCenterID = req.CostCenterID
+    }
     
-    if err := s.db.Create(expense).Error; err != nil {
+    allocations, err := buildAllocations(db, p.OrganizationID, expense, req.Allocations, nil)
+    if err != nil {
         return nil, err
     }
+    expense.Allocations = allocations
     
-    // Generate AI suggestions if requested
+    violations, err := checkPolicies(db, expense, false)
+    if err != nil {
+        return expense, err
+    }
+    
+    duplicates, err := s.checkDuplicates(db, p.OrganizationID, expense)
+    if err != nil {
+        return expense, err
+    }
+    
+    err = db.Transaction(func(tx *gorm.DB) error {
+        if merchant != nil {
+            if err := saveMerchant(tx, p, merchant); err != nil {
+                return err
+            }
+            expense.MerchantID = &merchant.ID
+        }
+        if err := tx.Omit("Allocations").Create(expense).Error; err != nil {
+            return err
+        }
+        if err := setTags(tx, expense, req.Tags); err != nil {
+            return err
+        }
+        if err := replaceAllocations(tx, expense.ID, allocations); err != nil {
+            return err
+        }
+        if err := recordChange(tx, p, audit.Change{
+            Action: "expense.create", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+            After: expense,
+        }); err != nil {
+            return err
+        }
+        if err := recordViolations(tx, expense.ID, violations); err != nil {
+            return err
+        }
+        if err := recordDuplicates(tx, expense, duplicates); err != nil {
+            return err
+        }
+        return indexExpense(tx, expense.ID)
+    })
+    if err != nil {
+        return nil, err
+    }
+    
+    // Generate AI suggestions if requested, and one per line of a split expense
     if req.RequestAISuggestion {
-        if err := s.generateAISuggestion(expense.ID, req.Description, req.Amount); err != nil {
+        if err := s.generateAISuggestion(db, expense.ID, nil, merchant, req.Description, money.Float(expense.AmountMinor, expense.Currency)); err != nil {
             // Log error but don't fail the expense creation
             // TODO: Add proper logging
         }
+        for _, line := range allocations {
+            description, lineMerchant := line.Description, merchant
+            if description == "" {
+                description = req.Description
+            } else if lineMerchant, err = matchMerchant(db, description); err != nil {
+                break
+            }
+            id := line.ID
+            if err := s.generateAISuggestion(db, expense.ID, &id, lineMerchant, description, money.Float(line.AmountMinor, line.Currency)); err != nil {
+                break
+            }
+        }
     }
     
     // Reload expense with associations
-    if err := s.db.Preload("Attachments").Preload("AISuggestions").First(expense, expense.ID).Error; err != nil {
+    if err := db.Scopes(withDetails).First(expense, expense.ID).Error; err != nil {
         return nil, err
     }
+    expense.PossibleDuplicates = duplicates
     
     return expense, nil
 }
 
-// GetExpenses retrieves expenses with pagination
-func (s *ExpenseService) GetExpenses(skip, limit int) ([]models.Expense, error) {
-    var expenses []models.Expense
+// GetExpenses retrieves a filtered, sorted page of the expenses visible to the principal
+// along with the total number of matches and cursors for the adjacent pages
+func (s *ExpenseService) GetExpenses(p *auth.Principal, q models.ExpenseQuery) (*models.ExpenseListResponse, error) {
+    if len(q.Sort) == 0 {
+        q.Sort = defaultExpenseSort
+    }
+    
+    sort := withIDTiebreak(q.Sort)
+    keys := make([]pagination.Key, len(sort))
+    for i, key := range sort {
+        field, ok := expenseSortFields[key.Field]
+        if !ok {
+            return nil, fmt.Errorf("invalid sort field %q", key.Field)
+        }
+        keys[i] = pagination.Key{Column: field.column, Desc: key.Desc}
+    }
     
-    query := s.db.Preload("Attachments").Preload("AISuggestions")
+    var cursor *pagination.Cursor
+    if q.Cursor != "" {
+        var err error
+        if cursor, err = pagination.Decode(q.Cursor); err != nil {
+            return nil, err
+        }
+        q.Skip = 0
+    }
     
-    if skip > 0 {
-        query = query.Offset(skip)
+    db := scoped(s.db, p)
+    filter, err := expenseFilter(db, p.OrganizationID, &q.Filter)
+    if err != nil {
+        return nil, err
     }
     
-    if limit > 0 {
-        query = query.Limit(limit)
+    query := db.Model(&models.Expense{}).Scopes(visibleTo(p, authz.ExpenseRead), filter)
+    
+    var total int64
+    if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+        return nil, err
     }
     
-    if err := query.Order("created_at DESC").Find(&expenses).Error; err != nil {
+    page, err := pagination.Paginate(query.Scopes(withDetails), keys, FormatSort(sort), cursor, q.Skip, q.Limit,
+        func(e models.Expense) []interface{} {
+            values := make([]interface{}, len(sort))
+            for i, key := range sort {
+                values[i] = expenseSortFields[key.Field].value(e)
+            }
+            return values
+        })
+    if err != nil {
         return nil, err
     }
     
-    return expenses, nil
+    return &models.ExpenseListResponse{
+        Expenses:   page.Items,
+        Total:      total,
+        Skip:       q.Skip,
+        Limit:      q.Limit,
+        Sort:       FormatSort(q.Sort),
+        Filters:    q.Filter,
+        NextCursor: page.Next,
+        PrevCursor: page.Prev,
+    }, nil
 }
 
-// GetExpenseByID retrieves a specific expense
-func (s *ExpenseService) GetExpenseByID(id uint) (*models.Expense, error) {
+// expenseSummaryGroups lists the dimensions expenses can be summarized by
+var expenseSummaryGroups = []string{"category", "project", "cost_center", "merchant", "tag"}
+
+// SummarizeExpenses totals the filtered expenses visible to the principal by one
+// dimension, in the base currency each expense was booked in. Split expenses
+// count line by line, and only the lines matching the category, project and
+// cost center filters count.
+func (s *ExpenseService) SummarizeExpenses(p *auth.Principal, filter models.ExpenseFilter, groupBy string) (*models.ExpenseSummaryResponse, error) {
+    if groupBy == "" {
+        groupBy = "category"
+    }
+    valid := false
+    for _, g := range expenseSummaryGroups {
+        valid = valid || g == groupBy
+    }
+    if !valid {
+        return nil, fmt.Errorf("invalid group_by %q", groupBy)
+    }
+    
+    db := scoped(s.db, p)
+    scope, err := expenseFilter(db, p.OrganizationID, &filter)
+    if err != nil {
+        return nil, err
+    }
+    
+    var expenses []models.Expense
+    err = db.Scopes(visibleTo(p, authz.ExpenseRead), scope, withAllocations).
+        Preload("Project").Preload("CostCenter").Preload("Merchant").Preload("Tags").
+        Preload("Allocations.Project").Preload("Allocations.CostCenter").
+        Find(&expenses).Error
+    if err != nil {
+        return nil, err
+    }
+    
+    categories := make(map[string]bool)
+    if len(filter.Categories) > 0 {
+        names, err := withSubcategories(db, filter.Categories)
+        if err != nil {
+            return nil, err
+        }
+        for _, name := range names {
+            categories[name] = true
+        }
+    }
+    projects := make(map[uint]bool)
+    for _, id := range filter.ProjectIDs {
+        projects[id] = true
+    }
+    costCenters := make(map[uint]bool)
+    for _, id := range filter.CostCenterIDs {
+        costCenters[id] = true
+    }
+    
+    var records []reporting.Record
+    for _, e := range expenses {
+        for _, line := range allocationLines(e) {
+            if len(categories) > 0 && !categories[line.Category] ||
+                len(projects) > 0 && (line.ProjectID == nil || !projects[*line.ProjectID]) ||
+                len(costCenters) > 0 && (line.CostCenterID == nil || !costCenters[*line.CostCenterID]) {
+                continue
+            }
+            record := reporting.Record{
+                Date:           e.Date,
+                Category:       line.Category,
+                Item:           line.Description,
+                Quantity:       1,
+                UnitPriceMinor: line.BaseAmountMinor,
+                Currency:       line.BaseCurrency,
+            }
+            if line.Project != nil {
+                record.Project = line.Project.Name
+            }
+            if line.CostCenter != nil {
+                record.CostCenter = line.CostCenter.Code
+            }
+            if e.Merchant != nil {
+                record.Merchant = e.Merchant.Name
+            }
+            for _, tag := range e.Tags {
+                record.Tags = append(record.Tags, tag.Name)
+            }
+            records = append(records, record)
+        }
+    }
+    
+    return &models.ExpenseSummaryResponse{
+        GroupBy: groupBy,
+        Groups:  summaryGroups(reporting.Summarize(records, groupBy)),
+        Totals:  summaryGroups(reporting.Totals(records)),
+        Filters: filter,
+    }, nil
+}
+
+// summaryGroups converts report totals for the API
+func summaryGroups(totals []reporting.Total) []models.ExpenseSummaryGroup {
+    groups := make([]models.ExpenseSummaryGroup, len(totals))
+    for i, t := range totals {
+        groups[i] = models.ExpenseSummaryGroup{
+            Key:         t.Key,
+            Count:       t.Quantity,
+            Amount:      money.FromMinor(t.Revenue, t.Currency),
+            AmountMinor: t.Revenue,
+            Currency:    t.Currency,
+        }
+    }
+    return groups
+}
+
+// GetExpenseByID retrieves a specific expense visible to the principal
+func (s *ExpenseService) GetExpenseByID(p *auth.Principal, id uint) (*models.Expense, error) {
     var expense models.Expense
     
-    if err := s.db.Preload("Attachments").Preload("AISuggestions").First(&expense, id).Error; err != nil {
+    if err := scoped(s.db, p).Scopes(visibleTo(p, authz.ExpenseRead), withDetails).First(&expense, id).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, errors.New("expense not found")
         }
@@ -92,86 +402,624 @@ func (s *ExpenseService) GetExpenseByID(id uint) (*models.Expense, error) {
     return &expense, nil
 }
 
-// UpdateExpense updates an existing expense
-func (s *ExpenseService) UpdateExpense(id uint, req models.UpdateExpenseRequest) (*models.Expense, error) {
+// UpdateExpense updates an existing expense the principal may modify.
+// A non-empty ifMatch lists the versions the caller expects the expense to be at.
+// Policy violations and likely duplicates are handled as in CreateExpense.
+func (s *ExpenseService) UpdateExpense(p *auth.Principal, id uint, req models.UpdateExpenseRequest, ifMatch []int64) (*models.Expense, error) {
     var expense models.Expense
     
-    if err := s.db.First(&expense, id).Error; err != nil {
+    db := scoped(s.db, p)
+    if err := db.Scopes(visibleTo(p, authz.ExpenseRead), withAllocations).Preload("Tags").First(&expense, id).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return nil, errors.New("expense not found")
         }
         return nil, err
     }
     
+    if !authz.AllowedOn(p, authz.ExpenseUpdate, expense.UserID) {
+        return nil, errors.New("permission denied")
+    }
+    
+    if !expense.Editable() {
+        return nil, errors.New("expense is locked")
+    }
+    
+    if !versionMatches(expense.Version, ifMatch) {
+        return nil, errors.New("version mismatch")
+    }
+    
+    before := expense
+    
     // Update fields that are provided
     if req.Description != nil {
         expense.Description = *req.Description
     }
+    amount := money.FromMinor(expense.AmountMinor, expense.Currency)
+    if expense.Type != models.ExpenseStandard {
+        // A per-diem expense is dated by its trip
+        if req.Amount != nil || req.Currency != nil || (req.Date != nil && expense.Type == models.ExpensePerDiem) {
+            return nil, errors.New("amount is computed")
+        }
+    }
     if req.Amount != nil {
-        expense.Amount = *req.Amount
+        amount = *req.Amount
+    }
+    if req.Currency != nil {
+        expense.Currency = *req.Currency
     }
     if req.Date != nil {
         expense.Date = *req.Date
     }
-    if req.Category != nil {
-        expense.Category = *req.Category
+    // An archived category may stay on the expenses that have it, but not be chosen anew
+    if req.Category != nil && !strings.EqualFold(strings.TrimSpace(*req.Category), expense.Category) {
+        category, err := resolveCategory(db, *req.Category)
+        if err != nil {
+            return nil, err
+        }
+        expense.Category = category
     }
     if req.ClientNotes != nil {
         expense.ClientNotes = *req.ClientNotes
     }
+    if req.Attendees != nil {
+        if *req.Attendees < 1 {
+            return nil, errors.New("attendees must be at least 1")
+        }
+        expense.Attendees = *req.Attendees
+    }
+    if req.Reimbursable != nil {
+        expense.Reimbursement = models.ReimbursementDue
+        if !*req.Reimbursable {
+            expense.Reimbursement = models.ReimbursementNone
+        }
+    }
+    // A closed project or archived cost center may stay on its expenses, but not be chosen anew
+    if req.ProjectID != nil {
+        if *req.ProjectID == 0 {
+            expense.ProjectID = nil
+        } else if expense.ProjectID == nil || *expense.ProjectID != *req.ProjectID {
+            if err := openProject(db, *req.ProjectID); err != nil {
+                return nil, err
+            }
+            expense.ProjectID = req.ProjectID
+        }
+    }
+    if req.CostCenterID != nil {
+        if *req.CostCenterID == 0 {
+            expense.CostCenterID = nil
+        } else if expense.CostCenterID == nil || *expense.CostCenterID != *req.CostCenterID {
+            if err := activeCostCenter(db, *req.CostCenterID); err != nil {
+                return nil, err
+            }
+            expense.CostCenterID = req.CostCenterID
+        }
+    }
+    
+    if req.Mileage != nil || req.PerDiem != nil {
+        computed, err := expenseAmount(db, &expense, "", req.Mileage, req.PerDiem)
+        if err != nil {
+            return nil, err
+        }
+        amount = computed
+    }
+    
+    if req.Amount != nil || req.Currency != nil || req.Date != nil || req.Mileage != nil || req.PerDiem != nil {
+        if err := setAmount(db, p.OrganizationID, &expense, amount); err != nil {
+            return nil, err
+        }
+    }
+    if req.Date != nil || req.PerDiem != nil {
+        if err := checkReportPeriod(db, &expense); err != nil {
+            return nil, err
+        }
+    }
+    
+    // Tax is worked out again when its inputs change, keeping an entered rate
+    // but not an entered tax amount
+    taxChanged := req.TaxJurisdiction != nil || req.TaxCode != nil || req.TaxRate != nil || req.TaxAmount != nil
+    amountChanged := req.Amount != nil || req.Currency != nil || req.Date != nil || req.Mileage != nil || req.PerDiem != nil
+    if taxChanged || (amountChanged && expense.TaxJurisdiction != "") {
+        t := taxDetails{jurisdiction: expense.TaxJurisdiction, code: expense.TaxCode}
+        if expense.TaxRateID == nil {
+            t.rate = money.Decimal(expense.TaxRate)
+        }
+        if req.TaxJurisdiction != nil {
+            t.jurisdiction = *req.TaxJurisdiction
+            if strings.TrimSpace(t.jurisdiction) == "" {
+                t.rate = ""
+            }
+        }
+        if req.TaxCode != nil {
+            t.code = *req.TaxCode
+        }
+        if req.TaxRate != nil {
+            t.rate = *req.TaxRate
+        }
+        if req.TaxAmount != nil {
+            t.amount = *req.TaxAmount
+        }
+        if err := applyTax(db, &expense, t); err != nil {
+            return nil, err
+        }
+    }
+    if req.MerchantVATNumber != nil {
+        vatNumber, err := normalizeVATNumber(*req.MerchantVATNumber)
+        if err != nil {
+            return nil, err
+        }
+        expense.MerchantVATNumber = vatNumber
+    }
+    
+    // A changed description is matched to a merchant only if the expense has none
+    var merchant *models.Merchant
+    if req.MerchantID != nil || req.Merchant != nil {
+        var merchantID *uint
+        if req.MerchantID != nil && *req.MerchantID != 0 {
+            merchantID = req.MerchantID
+        }
+        name := ""
+        if req.Merchant != nil {
+            name = *req.Merchant
+        }
+        if merchantID == nil && strings.TrimSpace(name) == "" {
+            expense.MerchantID = nil
+        } else {
+            found, err := expenseMerchant(db, merchantID, name, "")
+            if err != nil {
+                return nil, err
+            }
+            merchant = found
+        }
+    } else if req.Description != nil && expense.MerchantID == nil {
+        found, err := matchMerchant(db, expense.Description)
+        if err != nil {
+            return nil, err
+        }
+        merchant = found
+    }
+    if err := applyMerchantDefaults(db, &expense, merchant); err != nil {
+        return nil, err
+    }
+    
+    // New lines replace the old ones; otherwise the old ones follow the amount
+    allocations := append([]models.ExpenseAllocation{}, expense.Allocations...)
+    if req.Allocations != nil {
+        lines, err := buildAllocations(db, p.OrganizationID, &expense, *req.Allocations, expense.Allocations)
+        if err != nil {
+            return nil, err
+        }
+        allocations = lines
+    } else if err := apportion(&expense, allocations); err != nil {
+        return nil, err
+    }
+    expense.Allocations = allocations
     
     expense.UpdatedAt = time.Now()
     
-    if err := s.db.Save(&expense).Error; err != nil {
+    violations, err := checkPolicies(db, &expense, false)
+    if err != nil {
+        return &expense, err
+    }
+    
+    duplicates, err := s.checkDuplicates(db, p.OrganizationID, &expense)
+    if err != nil {
+        return &expense, err
+    }
+    
+    err = db.Transaction(func(tx *gorm.DB) error {
+        if merchant != nil {
+            if err := saveMerchant(tx, p, merchant); err != nil {
+                return err
+            }
+            expense.MerchantID = &merchant.ID
+        }
+        if err := bumpVersion(tx, &expense); err != nil {
+            return err
+        }
+        if err := tx.Omit("Tags", "Allocations").Save(&expense).Error; err != nil {
+            return err
+        }
+        if req.Allocations != nil {
+            if err := replaceAllocations(tx, expense.ID, allocations); err != nil {
+                return err
+            }
+        } else {
+            for i := range allocations {
+                if err := tx.Omit("Project", "CostCenter").Save(&allocations[i]).Error; err != nil {
+                    return err
+                }
+            }
+        }
+        if req.Tags != nil {
+            if err := setTags(tx, &expense, *req.Tags); err != nil {
+                return err
+            }
+        }
+        if err := recordChange(tx, p, audit.Change{
+            Action: "expense.update", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+            Before: before, After: expense,
+        }); err != nil {
+            return err
+        }
+        if err := recordViolations(tx, expense.ID, violations); err != nil {
+            return err
+        }
+        if err := recordDuplicates(tx, &expense, duplicates); err != nil {
+            return err
+        }
+        return indexExpense(tx, expense.ID)
+    })
+    if err != nil {
         return nil, err
     }
     
     // Reload with associations
-    if err := s.db.Preload("Attachments").Preload("AISuggestions").First(&expense, expense.ID).Error; err != nil {
+    if err := db.Scopes(withDetails).First(&expense, expense.ID).Error; err != nil {
         return nil, err
     }
+    expense.PossibleDuplicates = duplicates
     
     return &expense, nil
 }
 
-// DeleteExpense deletes an expense and its associated data
-func (s *ExpenseService) DeleteExpense(id uint) error {
+// checkDuplicates finds the expense's lookalikes unless the organization ignores
+// duplicates, failing with "possible duplicate" when it blocks them
+func (s *ExpenseService) checkDuplicates(db *gorm.DB, organizationID uint, expense *models.Expense) ([]models.PossibleDuplicate, error) {
+    mode, err := duplicateMode(db, organizationID)
+    if err != nil || mode == models.DuplicateIgnore {
+        return nil, err
+    }
+    duplicates, err := findDuplicates(db, expense)
+    if err != nil {
+        return nil, err
+    }
+    if mode == models.DuplicateBlock && len(duplicates) > 0 {
+        expense.PossibleDuplicates = duplicates
+        return nil, errors.New("possible duplicate")
+    }
+    return duplicates, nil
+}
+
+// DeleteExpense moves an expense the principal may remove, and its attachments, to the trash.
+// Files are kept until the retention period passes and the purge job removes them.
+// A non-empty ifMatch lists the versions the caller expects the expense to be at.
+func (s *ExpenseService) DeleteExpense(p *auth.Principal, id uint, ifMatch []int64) error {
     var expense models.Expense
     
-    if err := s.db.First(&expense, id).Error; err != nil {
+    db := scoped(s.db, p)
+    if err := db.Scopes(visibleTo(p, authz.ExpenseRead)).First(&expense, id).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
             return errors.New("expense not found")
         }
         return err
     }
     
-    // Delete associated attachments and AI suggestions (cascade)
-    if err := s.db.Select("Attachments", "AISuggestions").Delete(&expense).Error; err != nil {
+    if !authz.AllowedOn(p, authz.ExpenseDelete, expense.UserID) {
+        return errors.New("permission denied")
+    }
+    
+    if !expense.Editable() {
+        return errors.New("expense is locked")
+    }
+    
+    if !versionMatches(expense.Version, ifMatch) {
+        return errors.New("version mismatch")
+    }
+    
+    before := expense
+    
+    // Attachments share the expense's deletion time so a restore brings back exactly these
+    deletedAt := time.Now()
+    return db.Transaction(func(tx *gorm.DB) error {
+        if err := bumpVersion(tx, &expense); err != nil {
+            return err
+        }
+        if err := tx.Model(&models.Attachment{}).Where("expense_id = ?", expense.ID).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+            return err
+        }
+        // A deleted expense leaves its report, so a restore does not put it back on one that moved on
+        if err := tx.Model(&expense).UpdateColumns(map[string]interface{}{"deleted_at": deletedAt, "report_id": nil}).Error; err != nil {
+            return err
+        }
+        if err := recordChange(tx, p, audit.Change{
+            Action: "expense.delete", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+            Before: before,
+        }); err != nil {
+            return err
+        }
+        return search.GetBackend().Remove(tx, expense.ID)
+    })
+}
+
+// versionMatches reports whether version is one of the expected versions; no expectation matches any
+func versionMatches(version int64, expected []int64) bool {
+    if len(expected) == 0 {
+        return true
+    }
+    for _, v := range expected {
+        if v == version {
+            return true
+        }
+    }
+    return false
+}
+
+// bumpVersion advances the expense's version. It is guarded on the version that
+// was read, so it fails when another change committed since the expense was loaded.
+func bumpVersion(tx *gorm.DB, expense *models.Expense) error {
+    result := tx.Model(&models.Expense{}).
+        Where("id = ? AND version = ?", expense.ID, expense.Version).
+        UpdateColumn("version", gorm.Expr("version + 1"))
+    if result.Error != nil {
+        return result.Error
+    }
+    if result.RowsAffected == 0 {
+        return errors.New("expense was modified concurrently")
+    }
+    expense.Version++
+    return nil
+}
+
+// touchExpense advances the version of an expense whose representation changed
+// through a related record such as an attachment
+func touchExpense(tx *gorm.DB, expenseID uint) error {
+    return tx.Model(&models.Expense{}).Where("id = ?", expenseID).
+        UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+// setTags replaces the expense's tags with the named ones, creating unknown tags
+func setTags(tx *gorm.DB, expense *models.Expense, names []string) error {
+    tags, err := resolveTags(tx, names)
+    if err != nil {
+        return err
+    }
+    if err := tx.Model(expense).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+        return err
+    }
+    expense.Tags = tags
+    return nil
+}
+
+// withDetails preloads what an expense is returned with
+func withDetails(db *gorm.DB) *gorm.DB {
+    return db.Preload("Attachments").Preload("AISuggestions").Preload("PolicyViolations").
+        Preload("Project").Preload("CostCenter").Preload("Merchant").Preload("Tags").Scopes(withAllocations)
+}
+
+// withAllocations preloads an expense's lines in order
+func withAllocations(db *gorm.DB) *gorm.DB {
+    return db.Preload("Allocations", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") })
+}
+
+// expenseFilter builds a query scope for the filter and normalizes its amount bounds.
+// Amounts are in the filter's currency when one is given and in the organization's base currency otherwise.
+func expenseFilter(db *gorm.DB, organizationID uint, filter *models.ExpenseFilter) (func(*gorm.DB) *gorm.DB, error) {
+    amountColumn := "expenses.base_amount_minor"
+    currency := money.Normalize(filter.Currency)
+    if currency != "" {
+        if !money.Valid(currency) {
+            return nil, errors.New("unsupported currency")
+        }
+        amountColumn = "expenses.amount_minor"
+    } else if filter.MinAmount != "" || filter.MaxAmount != "" {
+        base, err := baseCurrency(db, organizationID)
+        if err != nil {
+            return nil, err
+        }
+        currency = base
+    }
+    
+    var minAmount, maxAmount int64
+    var err error
+    if filter.MinAmount != "" {
+        if minAmount, err = filter.MinAmount.Minor(currency); err != nil {
+            return nil, errors.New("invalid min_amount")
+        }
+        filter.MinAmount = money.FromMinor(minAmount, currency)
+    }
+    if filter.MaxAmount != "" {
+        if maxAmount, err = filter.MaxAmount.Minor(currency); err != nil {
+            return nil, errors.New("invalid max_amount")
+        }
+        filter.MaxAmount = money.FromMinor(maxAmount, currency)
+    }
+    
+    // A category selects its subcategories too
+    var categories []string
+    if len(filter.Categories) > 0 {
+        if categories, err = withSubcategories(db, filter.Categories); err != nil {
+            return nil, err
+        }
+    }
+    
+    var tags []string
+    for _, tag := range filter.Tags {
+        tags = append(tags, strings.ToLower(strings.TrimSpace(tag)))
+    }
+    
+    var threshold int64
+    if filter.MissingReceipt {
+        if threshold, err = receiptThreshold(db, organizationID); err != nil {
+            return nil, err
+        }
+    }
+    
+    f := *filter
+    
+    return func(db *gorm.DB) *gorm.DB {
+        if f.From != nil {
+            db = db.Where("expenses.date >= ?", *f.From)
+        }
+        if f.To != nil {
+            db = db.Where("expenses.date <= ?", *f.To)
+        }
+        if f.Currency != "" {
+            db = db.Where("expenses.currency = ?", currency)
+        }
+        if f.MinAmount != "" {
+            db = db.Where(amountColumn+" >= ?", minAmount)
+        }
+        if f.MaxAmount != "" {
+            db = db.Where(amountColumn+" <= ?", maxAmount)
+        }
+        // Split expenses match on any of their lines
+        if len(categories) > 0 {
+            db = db.Where(fmt.Sprintf(allocatedExpenses, "category"), categories, categories)
+        }
+        if len(f.Statuses) > 0 {
+            db = db.Where("expenses.status IN ?", f.Statuses)
+        }
+        if len(f.ProjectIDs) > 0 {
+            db = db.Where(fmt.Sprintf(allocatedExpenses, "project_id"), f.ProjectIDs, f.ProjectIDs)
+        }
+        if len(f.CostCenterIDs) > 0 {
+            db = db.Where(fmt.Sprintf(allocatedExpenses, "cost_center_id"), f.CostCenterIDs, f.CostCenterIDs)
+        }
+        if len(f.MerchantIDs) > 0 {
+            db = db.Where("expenses.merchant_id IN ?", f.MerchantIDs)
+        }
+        if len(tags) > 0 {
+            db = db.Where("EXISTS (SELECT 1 FROM expense_tags JOIN tags ON tags.id = expense_tags.tag_id WHERE expense_tags.expense_id = expenses.id AND LOWER(tags.name) IN ?)", tags)
+        }
+        if f.Text != "" {
+            pattern := "%" + escapeLike(f.Text) + "%"
+            db = db.Where(`(expenses.description LIKE ? ESCAPE '\' OR expenses.client_notes LIKE ? ESCAPE '\')`, pattern, pattern)
+        }
+        if f.HasAttachments != nil {
+            exists := "EXISTS (SELECT 1 FROM attachments WHERE attachments.expense_id = expenses.id AND attachments.deleted_at IS NULL)"
+            if *f.HasAttachments {
+                db = db.Where(exists)
+            } else {
+                db = db.Where("NOT " + exists)
+            }
+        }
+        if f.MissingReceipt {
+            db = missingReceipt(threshold)(db)
+        }
+        return db
+    }, nil
+}
+
+// withIDTiebreak appends the ID, in the direction of the last key, so every expense has a distinct position
+func withIDTiebreak(keys []models.SortKey) []models.SortKey {
+    for _, key := range keys {
+        if key.Field == "id" {
+            return keys
+        }
+    }
+    return append(append([]models.SortKey{}, keys...), models.SortKey{Field: "id", Desc: keys[len(keys)-1].Desc})
+}
+
+// FormatSort renders sort keys in the form accepted by the sort query parameter, e.g. "date:desc,id:asc"
+func FormatSort(keys []models.SortKey) string {
+    parts := make([]string, len(keys))
+    for i, key := range keys {
+        direction := "asc"
+        if key.Desc {
+            direction = "desc"
+        }
+        parts[i] = key.Field + ":" + direction
+    }
+    return strings.Join(parts, ",")
+}
+
+// escapeLike escapes LIKE wildcards so user text matches literally
+func escapeLike(text string) string {
+    return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+// setAmount parses amount in the expense's currency, defaulting to the organization's
+// base currency, and converts it into the base currency at the rate in effect on the expense date
+func setAmount(db *gorm.DB, organizationID uint, expense *models.Expense, amount money.Decimal) error {
+    base, err := baseCurrency(db, organizationID)
+    if err != nil {
+        return err
+    }
+    
+    currency := money.Normalize(expense.Currency)
+    if currency == "" {
+        currency = base
+    }
+    if !money.Valid(currency) {
+        return errors.New("unsupported currency")
+    }
+    
+    minor, err := amount.Minor(currency)
+    if err != nil {
+        return errors.New("invalid amount")
+    }
+    if minor <= 0 {
+        return errors.New("amount must be greater than 0")
+    }
+    
+    rate, rateDate, err := lookupRate(db, expense.Date, currency, base)
+    if err != nil {
+        return err
+    }
+    
+    // Convert with the rate exactly as recorded so the base amount can be reproduced from it
+    if rate, err = money.ParseRate(money.FormatRate(rate)); err != nil {
         return err
     }
     
+    baseMinor, err := money.Convert(minor, currency, base, rate)
+    if err != nil {
+        return errors.New("invalid amount")
+    }
+    
+    expense.Currency = currency
+    expense.AmountMinor = minor
+    expense.BaseCurrency = base
+    expense.BaseAmountMinor = baseMinor
+    expense.Amount = money.FromMinor(minor, currency)
+    expense.BaseAmount = money.FromMinor(baseMinor, base)
+    expense.ExchangeRate = money.FormatRate(rate)
+    expense.ExchangeRateDate = &rateDate
     return nil
 }
 
-// generateAISuggestion generates AI suggestions for an expense
-func (s *ExpenseService) generateAISuggestion(expenseID uint, description string, amount float64) error {
+// baseCurrency returns the currency the organization reports in
+func baseCurrency(db *gorm.DB, organizationID uint) (string, error) {
+    var org models.Organization
+    if err := db.Select("id", "base_currency").First(&org, organizationID).Error; err != nil {
+        return "", err
+    }
+    if org.BaseCurrency == "" {
+        return "USD", nil
+    }
+    return org.BaseCurrency, nil
+}
+
+// generateAISuggestion generates AI suggestions for an expense, or for one of
+// its lines when allocationID is set. merchant is the one the description was
+// normalized to, or nil.
+func (s *ExpenseService) generateAISuggestion(db *gorm.DB, expenseID uint, allocationID *uint, merchant *models.Merchant, description string, amount float64) error {
     // Simple rule-based AI for now (could be replaced with actual AI service)
-    category := s.categorizeExpense(description, amount)
+    category, err := suggestedCategory(db, s.categorizeExpense(merchant, description, amount), description)
+    if err != nil {
+        return err
+    }
     notes := s.generateNotes(description, amount, category)
     
     suggestion := &models.AISuggestion{
         ExpenseID:         expenseID,
+        AllocationID:      allocationID,
         SuggestedCategory: category,
         SuggestedNotes:    notes,
         CreatedAt:         time.Now(),
         ModelUsed:         "rule-based-v1",
     }
     
-    return s.db.Create(suggestion).Error
+    return db.Create(suggestion).Error
 }
 
-// categorizeExpense provides simple rule-based categorization
-func (s *ExpenseService) categorizeExpense(description string, amount float64) string {
+// categorizeExpense provides simple rule-based categorization. The merchant's
+// default category, when it has one, outranks every keyword.
+func (s *ExpenseService) categorizeExpense(merchant *models.Merchant, description string, amount float64) string {
+    if merchant != nil && merchant.DefaultCategory != "" {
+        return merchant.DefaultCategory
+    }
+    
     desc := strings.ToLower(description)
     
     // Travel-related keywords
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// DefaultIdempotencyTTL is how long the response to a request with an Idempotency-Key is kept
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+var idempotencyTTL = DefaultIdempotencyTTL
+
+// SetIdempotencyTTL sets how long idempotency keys and their responses are
+// kept. It must be called before serving requests.
+func SetIdempotencyTTL(ttl time.Duration) {
+	if ttl > 0 {
+		idempotencyTTL = ttl
+	}
+}
+
+type IdempotencyService struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyService() *IdempotencyService {
+	return &IdempotencyService{
+		db: database.GetDB(),
+	}
+}
+
+// Begin claims key for a request with the given fingerprint. It returns nil
+// when the request should be handled, or the stored record to replay when the
+// key already completed an identical request.
+func (s *IdempotencyService) Begin(p *auth.Principal, key, fingerprint string) (*models.IdempotencyRecord, error) {
+	owned := s.db.Where("user_id = ? AND organization_id = ? AND idempotency_key = ?", p.UserID, p.OrganizationID, key).Session(&gorm.Session{})
+
+	// An expired key is free to use again
+	if err := owned.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyRecord{}).Error; err != nil {
+		return nil, err
+	}
+
+	record := models.IdempotencyRecord{
+		UserID:         p.UserID,
+		OrganizationID: p.OrganizationID,
+		Key:            key,
+		Fingerprint:    fingerprint,
+		ExpiresAt:      time.Now().Add(idempotencyTTL),
+	}
+	// The unique index makes concurrent first attempts race for the key; only one inserts
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyRecord
+	if err := owned.First(&existing).Error; err != nil {
+		return nil, err
+	}
+	switch {
+	case existing.Fingerprint != fingerprint:
+		return nil, errors.New("idempotency key reused")
+	case existing.StatusCode == 0:
+		return nil, errors.New("idempotency key in progress")
+	}
+	return &existing, nil
+}
+
+// Complete stores the response to the request that claimed key
+func (s *IdempotencyService) Complete(p *auth.Principal, key string, status int, header http.Header, body []byte) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Model(&models.IdempotencyRecord{}).
+		Where("user_id = ? AND organization_id = ? AND idempotency_key = ?", p.UserID, p.OrganizationID, key).
+		Updates(map[string]interface{}{"status_code": status, "header": string(encoded), "body": body}).Error
+}
+
+// Release gives up a claimed key so that a retry is handled afresh
+func (s *IdempotencyService) Release(p *auth.Principal, key string) error {
+	return s.db.Where("user_id = ? AND organization_id = ? AND idempotency_key = ?", p.UserID, p.OrganizationID, key).
+		Delete(&models.IdempotencyRecord{}).Error
+}
+
+// Purge deletes expired idempotency records
+func (s *IdempotencyService) Purge() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// RunPurge purges expired records now and then every interval; it blocks, so run it in a goroutine
+func (s *IdempotencyService) RunPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Purge(); err != nil {
+			log.Printf("Idempotency key purge failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
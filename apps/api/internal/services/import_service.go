@@ -0,0 +1,294 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/importing"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// maxImportRows bounds how many rows one import may contain
+const maxImportRows = 5000
+
+type ImportService struct {
+	db       *gorm.DB
+	expenses *ExpenseService
+	ai       *AIService
+	receipts *ReceiptService
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{
+		db:       database.GetDB(),
+		expenses: NewExpenseService(),
+		ai:       NewAIService(),
+		receipts: NewReceiptService(),
+	}
+}
+
+// importedRow is a validated row waiting to be saved
+type importedRow struct {
+	number      int
+	expense     *models.Expense
+	categorized bool
+}
+
+// ImportExpenses creates expenses owned by the principal from the rows of a
+// CSV or XLSX file. Every row is validated first; row problems are reported in
+// the response rather than as an error.
+func (s *ImportService) ImportExpenses(p *auth.Principal, filename string, data []byte, opts models.ExpenseImportOptions) (*models.ExpenseImportResponse, error) {
+	if opts.Mode == "" {
+		opts.Mode = models.ImportAtomic
+	}
+	if opts.Mode != models.ImportAtomic && opts.Mode != models.ImportBestEffort {
+		return nil, errors.New("invalid import mode")
+	}
+
+	var layout string
+	if opts.DateFormat != "" {
+		var err error
+		if layout, err = importing.DateLayout(opts.DateFormat); err != nil {
+			return nil, err
+		}
+	}
+	opts.Currency = money.Normalize(opts.Currency)
+	if opts.Currency != "" && !money.Valid(opts.Currency) {
+		return nil, errors.New("unsupported currency")
+	}
+
+	table, err := importing.ReadTable(importing.DetectFormat(filename, data), data, opts.Sheet)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.Rows) > maxImportRows {
+		return nil, fmt.Errorf("too many rows, at most %d per import", maxImportRows)
+	}
+	mapping, err := table.Map(opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.ExpenseImportResponse{
+		DryRun:   opts.DryRun,
+		Mode:     opts.Mode,
+		Columns:  table.Header,
+		Mapping:  mapping.Names(table),
+		Errors:   []models.ImportRowError{},
+		Expenses: []models.Expense{},
+	}
+
+	db := scoped(s.db, p)
+	var rows []importedRow
+	for i, cells := range table.Rows {
+		if importing.Blank(cells) {
+			continue
+		}
+		response.Rows++
+
+		row := importedRow{number: i + 2}
+		var rowErrors []models.ImportRowError
+		row.expense, row.categorized, rowErrors, err = s.parseRow(db, p, table, mapping, cells, row.number, layout, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(rowErrors) > 0 {
+			response.Errors = append(response.Errors, rowErrors...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	response.Valid = len(rows)
+
+	if opts.DryRun {
+		for _, row := range rows {
+			response.Expenses = append(response.Expenses, *row.expense)
+			if row.categorized {
+				response.Categorized++
+			}
+		}
+		return response, nil
+	}
+	if opts.Mode == models.ImportAtomic && len(response.Errors) > 0 {
+		return response, nil
+	}
+
+	save := func(tx *gorm.DB, expense *models.Expense) error {
+		if err := tx.Create(expense).Error; err != nil {
+			return err
+		}
+		if err := recordChange(tx, p, audit.Change{
+			Action: "expense.import", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+			After: expense,
+		}); err != nil {
+			return err
+		}
+		return indexExpense(tx, expense.ID)
+	}
+
+	var saved []importedRow
+	if opts.Mode == models.ImportAtomic {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				if err := save(tx, row.expense); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		saved = rows
+	} else {
+		// Each row commits on its own so one failure cannot take the others with it
+		for _, row := range rows {
+			row := row
+			if err := db.Transaction(func(tx *gorm.DB) error { return save(tx, row.expense) }); err != nil {
+				log.Printf("Failed to import row %d: %v", row.number, err)
+				response.Errors = append(response.Errors, models.ImportRowError{Row: row.number, Message: "failed to save expense"})
+				continue
+			}
+			saved = append(saved, row)
+		}
+	}
+
+	for _, row := range saved {
+		response.Expenses = append(response.Expenses, *row.expense)
+		if row.categorized {
+			response.Categorized++
+		}
+	}
+	response.Imported = len(saved)
+	return response, nil
+}
+
+// parseRow validates one row and builds the expense it describes. Problems
+// with the row's values are returned as row errors; err is reserved for
+// failures that should abort the import.
+func (s *ImportService) parseRow(db *gorm.DB, p *auth.Principal, table *importing.Table, mapping importing.Mapping, cells []string, number int, layout string, opts models.ExpenseImportOptions) (*models.Expense, bool, []models.ImportRowError, error) {
+	var rowErrors []models.ImportRowError
+	reject := func(field, format string, args ...interface{}) {
+		rowErrors = append(rowErrors, models.ImportRowError{Row: number, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	cell := func(field string) string {
+		column, ok := mapping[field]
+		if !ok {
+			return ""
+		}
+		return table.Cell(cells, column)
+	}
+
+	now := time.Now()
+	expense := &models.Expense{
+		OrganizationID:   p.OrganizationID,
+		UserID:           p.UserID,
+		Status:           models.StatusDraft,
+		Version:          1,
+		Type:             models.ExpenseStandard,
+		Description:      cell(importing.FieldDescription),
+		Currency:         cell(importing.FieldCurrency),
+		Category:         cell(importing.FieldCategory),
+		ClientNotes:      cell(importing.FieldClientNotes),
+		Attendees:        1,
+		Reimbursement:    models.ReimbursementDue,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Attachments:      []models.Attachment{},
+		AISuggestions:    []models.AISuggestion{},
+		PolicyViolations: []models.PolicyViolation{},
+		Tags:             []models.Tag{},
+		Allocations:      []models.ExpenseAllocation{},
+	}
+	if expense.Currency == "" {
+		expense.Currency = opts.Currency
+	}
+
+	if expense.Description == "" {
+		reject(importing.FieldDescription, "description is required")
+	}
+
+	dateValid := false
+	if value := cell(importing.FieldDate); value == "" {
+		reject(importing.FieldDate, "date is required")
+	} else if date, err := table.ParseDate(value, layout); err != nil {
+		reject(importing.FieldDate, "invalid date %q", value)
+	} else {
+		expense.Date = date
+		dateValid = true
+	}
+
+	amount := cell(importing.FieldAmount)
+	if amount == "" {
+		reject(importing.FieldAmount, "amount is required")
+	} else if dateValid {
+		// Conversion needs the date to find the exchange rate
+		if err := setAmount(db, p.OrganizationID, expense, importing.ParseAmount(amount, opts.DecimalComma)); err != nil {
+			field, message, ok := amountProblem(err, amount, expense.Currency)
+			if !ok {
+				return nil, false, nil, err
+			}
+			reject(field, "%s", message)
+		}
+	}
+
+	if expense.Category != "" {
+		category, err := resolveCategory(db, expense.Category)
+		switch {
+		case err == nil:
+			expense.Category = category
+		case err.Error() == "unknown category":
+			reject(importing.FieldCategory, "unknown or archived category %q", expense.Category)
+		default:
+			return nil, false, nil, err
+		}
+	}
+
+	// Only merchants the organization already knows are matched
+	merchant, err := matchMerchant(db, expense.Description)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if merchant != nil {
+		expense.MerchantID = &merchant.ID
+		if err := applyMerchantDefaults(db, expense, merchant); err != nil {
+			return nil, false, nil, err
+		}
+	}
+
+	categorized := false
+	if len(rowErrors) == 0 && expense.Category == "" && opts.Categorize {
+		category, err := suggestedCategory(db, s.expenses.categorizeExpense(merchant, expense.Description, money.Float(expense.AmountMinor, expense.Currency)), expense.Description)
+		if err != nil {
+			return nil, false, nil, err
+		}
+		expense.Category = category
+		categorized = category != ""
+	}
+	return expense, categorized, rowErrors, nil
+}
+
+// amountProblem describes a setAmount failure caused by the imported values.
+// It returns false for failures that should abort the import.
+func amountProblem(err error, amount, currency string) (field, message string, ok bool) {
+	switch {
+	case err.Error() == "invalid amount":
+		return importing.FieldAmount, fmt.Sprintf("invalid amount %q", amount), true
+	case err.Error() == "amount must be greater than 0":
+		return importing.FieldAmount, "amount must be greater than 0", true
+	case err.Error() == "unsupported currency":
+		return importing.FieldCurrency, fmt.Sprintf("unsupported currency %q", currency), true
+	case strings.HasPrefix(err.Error(), "no exchange rate"):
+		return importing.FieldCurrency, err.Error(), true
+	}
+	return "", "", false
+}
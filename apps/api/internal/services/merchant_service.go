@@ -0,0 +1,446 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/matching"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// MerchantService manages the vendors expenses are normalized to
+type MerchantService struct {
+	db *gorm.DB
+}
+
+func NewMerchantService() *MerchantService {
+	return &MerchantService{
+		db: database.GetDB(),
+	}
+}
+
+// ListMerchants returns the organization's merchants by name with their aliases
+func (s *MerchantService) ListMerchants(p *auth.Principal) ([]models.Merchant, error) {
+	merchants := []models.Merchant{}
+	if err := scoped(s.db, p).Scopes(withAliases).Order("name, id").Find(&merchants).Error; err != nil {
+		return nil, err
+	}
+	return merchants, nil
+}
+
+// GetMerchant returns a merchant with its aliases
+func (s *MerchantService) GetMerchant(p *auth.Principal, id uint) (*models.Merchant, error) {
+	var merchant models.Merchant
+	if err := scoped(s.db, p).Scopes(withAliases).First(&merchant, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("merchant not found")
+		}
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// CreateMerchant adds a merchant to the organization
+func (s *MerchantService) CreateMerchant(p *auth.Principal, req models.MerchantRequest) (*models.Merchant, error) {
+	merchant := &models.Merchant{}
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if req.Name == nil {
+			return errors.New("merchant name is required")
+		}
+		if err := setMerchantFields(tx, merchant, req); err != nil {
+			return err
+		}
+		if err := tx.Omit("Aliases").Create(merchant).Error; err != nil {
+			return err
+		}
+		if req.Aliases != nil {
+			if err := setMerchantAliases(tx, merchant, *req.Aliases); err != nil {
+				return err
+			}
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "merchant.create", EntityType: "merchant", EntityID: merchant.ID,
+			After: merchant,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merchant, nil
+}
+
+// UpdateMerchant changes a merchant's name, default category, tax ID or
+// aliases. Its expenses keep their category and VAT number.
+func (s *MerchantService) UpdateMerchant(p *auth.Principal, id uint, req models.MerchantRequest) (*models.Merchant, error) {
+	var merchant models.Merchant
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(withAliases).First(&merchant, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("merchant not found")
+			}
+			return err
+		}
+		before := merchant
+
+		if err := setMerchantFields(tx, &merchant, req); err != nil {
+			return err
+		}
+		if err := tx.Omit("Aliases").Save(&merchant).Error; err != nil {
+			return err
+		}
+		if req.Aliases != nil {
+			if err := setMerchantAliases(tx, &merchant, *req.Aliases); err != nil {
+				return err
+			}
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "merchant.update", EntityType: "merchant", EntityID: merchant.ID,
+			Before: before, After: merchant,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// DeleteMerchant removes a merchant no expense, trashed or not, is bought
+// from. Merchants in use can be merged into another instead.
+func (s *MerchantService) DeleteMerchant(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var merchant models.Merchant
+		if err := tx.Scopes(withAliases).First(&merchant, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("merchant not found")
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.Expense{}).Where("merchant_id = ?", merchant.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("merchant is in use")
+		}
+
+		if err := tx.Where("merchant_id = ?", merchant.ID).Delete(&models.MerchantAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&merchant).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "merchant.delete", EntityType: "merchant", EntityID: merchant.ID,
+			Before: merchant,
+		})
+	})
+}
+
+// MergeMerchants folds the merchants with ids into the one with id. Their
+// expenses, trashed ones included, move to it, and their names and aliases
+// become its aliases. A default category or tax ID it lacks is taken from the
+// first merged merchant that has one.
+func (s *MerchantService) MergeMerchants(p *auth.Principal, id uint, req models.MergeMerchantsRequest) (*models.Merchant, error) {
+	var ids []uint
+	seen := make(map[uint]bool, len(req.MerchantIDs))
+	for _, sourceID := range req.MerchantIDs {
+		if sourceID == id {
+			return nil, errors.New("cannot merge a merchant into itself")
+		}
+		if !seen[sourceID] {
+			seen[sourceID] = true
+			ids = append(ids, sourceID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no merchants to merge")
+	}
+
+	var merchant models.Merchant
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(withAliases).First(&merchant, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("merchant not found")
+			}
+			return err
+		}
+		before := merchant
+
+		var sources []models.Merchant
+		if err := tx.Where("id IN ?", ids).Order("id").Find(&sources).Error; err != nil {
+			return err
+		}
+		if len(sources) != len(ids) {
+			return errors.New("merchant not found")
+		}
+
+		err := tx.Unscoped().Model(&models.Expense{}).Where("merchant_id IN ?", ids).
+			UpdateColumns(map[string]interface{}{"merchant_id": merchant.ID, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.MerchantAlias{}).Where("merchant_id IN ?", ids).Update("merchant_id", merchant.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Merchant{}, ids).Error; err != nil {
+			return err
+		}
+
+		for _, source := range sources {
+			alias := models.MerchantAlias{MerchantID: merchant.ID, Alias: source.Name, Key: source.Key}
+			if err := tx.Create(&alias).Error; err != nil {
+				return err
+			}
+			if merchant.DefaultCategory == "" {
+				merchant.DefaultCategory = source.DefaultCategory
+			}
+			if merchant.TaxID == "" {
+				merchant.TaxID = source.TaxID
+			}
+			if err := recordChange(tx, p, audit.Change{
+				Action: "merchant.delete", EntityType: "merchant", EntityID: source.ID,
+				Before: source,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Aliases").Save(&merchant).Error; err != nil {
+			return err
+		}
+		if err := tx.Scopes(withAliases).First(&merchant, merchant.ID).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "merchant.merge", EntityType: "merchant", EntityID: merchant.ID,
+			Before: before, After: merchant,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// withAliases preloads a merchant's aliases in order
+func withAliases(db *gorm.DB) *gorm.DB {
+	return db.Preload("Aliases", func(tx *gorm.DB) *gorm.DB { return tx.Order("key") })
+}
+
+// setMerchantFields applies the name, default category and tax ID in req
+func setMerchantFields(tx *gorm.DB, merchant *models.Merchant, req models.MerchantRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		key := matching.Normalize(name)
+		if key == "" {
+			return errors.New("merchant name is required")
+		}
+		if err := checkMerchantKey(tx, key, merchant.ID); err != nil {
+			return err
+		}
+		merchant.Name, merchant.Key = name, key
+	}
+	// An archived category may stay the default, but not be chosen anew
+	if req.DefaultCategory != nil && !strings.EqualFold(strings.TrimSpace(*req.DefaultCategory), merchant.DefaultCategory) {
+		category, err := resolveCategory(tx, *req.DefaultCategory)
+		if err != nil {
+			return err
+		}
+		merchant.DefaultCategory = category
+	}
+	if req.TaxID != nil {
+		taxID, err := normalizeVATNumber(*req.TaxID)
+		if err != nil {
+			return errors.New("invalid tax ID")
+		}
+		merchant.TaxID = taxID
+	}
+	return nil
+}
+
+// setMerchantAliases replaces a merchant's aliases. Aliases normalizing to
+// its name or to one another are dropped.
+func setMerchantAliases(tx *gorm.DB, merchant *models.Merchant, aliases []string) error {
+	if err := tx.Where("merchant_id = ?", merchant.ID).Delete(&models.MerchantAlias{}).Error; err != nil {
+		return err
+	}
+	merchant.Aliases = []models.MerchantAlias{}
+	seen := map[string]bool{merchant.Key: true}
+	for _, raw := range aliases {
+		alias := strings.TrimSpace(raw)
+		key := matching.Normalize(alias)
+		if key == "" {
+			return errors.New("invalid merchant alias")
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := checkMerchantKey(tx, key, merchant.ID); err != nil {
+			return err
+		}
+		row := models.MerchantAlias{MerchantID: merchant.ID, Alias: alias, Key: key}
+		if err := tx.Create(&row).Error; err != nil {
+			return err
+		}
+		merchant.Aliases = append(merchant.Aliases, row)
+	}
+	sort.Slice(merchant.Aliases, func(i, j int) bool { return merchant.Aliases[i].Key < merchant.Aliases[j].Key })
+	return nil
+}
+
+// checkMerchantKey fails when another merchant has the normalized name as its
+// name or one of its aliases, since a description could not tell them apart
+func checkMerchantKey(tx *gorm.DB, key string, merchantID uint) error {
+	var count int64
+	if err := tx.Model(&models.Merchant{}).Where("key = ? AND id <> ?", key, merchantID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := tx.Model(&models.MerchantAlias{}).Where("key = ? AND merchant_id <> ?", key, merchantID).Count(&count).Error; err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		return errors.New("merchant already exists")
+	}
+	return nil
+}
+
+// matchMerchant finds the merchant a description mentions: the one whose
+// normalized name or alias appears as whole words in the normalized
+// description. The longest wins, so "Uber Eats" is preferred over "Uber". It
+// returns nil when no merchant is mentioned.
+func matchMerchant(db *gorm.DB, description string) (*models.Merchant, error) {
+	text := matching.Normalize(description)
+	if text == "" {
+		return nil, nil
+	}
+	text = " " + text + " "
+
+	type candidate struct {
+		MerchantID uint
+		Key        string
+	}
+	var names, aliases []candidate
+	if err := db.Model(&models.Merchant{}).Select("id AS merchant_id, key").Scan(&names).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.MerchantAlias{}).Select("merchant_id, key").Scan(&aliases).Error; err != nil {
+		return nil, err
+	}
+
+	var best candidate
+	for _, c := range append(names, aliases...) {
+		if len(c.Key) > len(best.Key) && strings.Contains(text, " "+c.Key+" ") {
+			best = c
+		}
+	}
+	if best.MerchantID == 0 {
+		return nil, nil
+	}
+	var merchant models.Merchant
+	if err := db.First(&merchant, best.MerchantID).Error; err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// expenseMerchant finds the merchant an expense is bought from: the one with
+// id when set, else the one named, else the one its description mentions. A
+// named merchant the organization does not know yet is returned unsaved, for
+// saveMerchant to create along with the expense.
+func expenseMerchant(db *gorm.DB, id *uint, name, description string) (*models.Merchant, error) {
+	if id != nil && *id != 0 {
+		var merchant models.Merchant
+		if err := db.First(&merchant, *id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("merchant not found")
+			}
+			return nil, err
+		}
+		return &merchant, nil
+	}
+	if strings.TrimSpace(name) == "" {
+		return matchMerchant(db, description)
+	}
+	return namedMerchant(db, name)
+}
+
+// namedMerchant finds the merchant whose name or alias normalizes like name,
+// or returns an unsaved one called name
+func namedMerchant(db *gorm.DB, name string) (*models.Merchant, error) {
+	name = strings.TrimSpace(name)
+	key := matching.Normalize(name)
+	if key == "" {
+		return nil, errors.New("merchant name is required")
+	}
+
+	var merchant models.Merchant
+	err := db.Where("key = ?", key).First(&merchant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var alias models.MerchantAlias
+		err = db.Where("key = ?", key).First(&alias).Error
+		if err == nil {
+			err = db.First(&merchant, alias.MerchantID).Error
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Merchant{Name: name, Key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// saveMerchant creates a merchant found by expenseMerchant if it is new. One
+// created meanwhile under the same name, such as by an earlier line of the
+// same import, is used instead.
+func saveMerchant(tx *gorm.DB, p *auth.Principal, merchant *models.Merchant) error {
+	if merchant.ID != 0 {
+		return nil
+	}
+	existing, err := namedMerchant(tx, merchant.Name)
+	if err != nil {
+		return err
+	}
+	if existing.ID != 0 {
+		*merchant = *existing
+		return nil
+	}
+	if err := tx.Omit("Aliases").Create(merchant).Error; err != nil {
+		return err
+	}
+	return recordChange(tx, p, audit.Change{
+		Action: "merchant.create", EntityType: "merchant", EntityID: merchant.ID,
+		After: merchant,
+	})
+}
+
+// applyMerchantDefaults fills the category and VAT number an expense was
+// given none of from its merchant. A default category since archived is left out.
+func applyMerchantDefaults(db *gorm.DB, expense *models.Expense, merchant *models.Merchant) error {
+	if merchant == nil {
+		return nil
+	}
+	if expense.Category == "" && merchant.DefaultCategory != "" {
+		category, err := resolveCategory(db, merchant.DefaultCategory)
+		if err != nil && err.Error() != "unknown category" {
+			return err
+		}
+		expense.Category = category
+	}
+	if expense.MerchantVATNumber == "" {
+		expense.MerchantVATNumber = merchant.TaxID
+	}
+	return nil
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// invitationTTL is how long an invitation can be accepted for
+const invitationTTL = 14 * 24 * time.Hour
+
+type OrganizationService struct {
+	db *gorm.DB
+}
+
+func NewOrganizationService() *OrganizationService {
+	return &OrganizationService{
+		db: database.GetDB(),
+	}
+}
+
+// CreateOrganization creates an organization with the principal as its owner
+func (s *OrganizationService) CreateOrganization(p *auth.Principal, req models.CreateOrganizationRequest) (*models.Membership, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("organization name is required")
+	}
+
+	currency := money.Normalize(req.BaseCurrency)
+	if currency == "" {
+		currency = "USD"
+	}
+	if !money.Valid(currency) {
+		return nil, errors.New("unsupported currency")
+	}
+
+	var membership *models.Membership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		membership, err = createOrganization(tx, &models.Organization{Name: name, BaseCurrency: currency}, p)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// UpdateOrganization changes the name or base currency of the principal's organization.
+// The base currency is fixed once amounts have been recorded in it.
+func (s *OrganizationService) UpdateOrganization(p *auth.Principal, req models.UpdateOrganizationRequest) (*models.Organization, error) {
+	var org models.Organization
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&org, p.OrganizationID).Error; err != nil {
+			return err
+		}
+		before := org
+
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				return errors.New("organization name is required")
+			}
+			org.Name = name
+		}
+
+		if req.BaseCurrency != nil {
+			currency := money.Normalize(*req.BaseCurrency)
+			if !money.Valid(currency) {
+				return errors.New("unsupported currency")
+			}
+			if currency != org.BaseCurrency {
+				// Stored base amounts and thresholds are denominated in the current base currency
+				var expenses, rules int64
+				if err := database.WithTenant(tx, org.ID).Model(&models.Expense{}).Count(&expenses).Error; err != nil {
+					return err
+				}
+				if err := database.WithTenant(tx, org.ID).Model(&models.ApprovalRule{}).Count(&rules).Error; err != nil {
+					return err
+				}
+				if expenses > 0 || rules > 0 {
+					return errors.New("base currency cannot change once expenses or approval rules exist")
+				}
+				// Keep the receipt threshold's value, now in the new currency
+				if req.ReceiptRequiredAbove == nil {
+					req.ReceiptRequiredAbove = &org.ReceiptRequiredAbove
+				}
+				if err := rebaseCategoryThresholds(tx, org.ID, currency); err != nil {
+					return err
+				}
+				if err := rebaseProjectBudgets(tx, org.ID, currency); err != nil {
+					return err
+				}
+				org.BaseCurrency = currency
+			}
+		}
+
+		if req.DuplicateMode != nil {
+			mode := models.DuplicateMode(strings.ToLower(strings.TrimSpace(*req.DuplicateMode)))
+			if !mode.Valid() {
+				return errors.New("invalid duplicate mode")
+			}
+			org.DuplicateMode = mode
+		}
+
+		if req.ReceiptRequiredAbove != nil {
+			threshold, err := req.ReceiptRequiredAbove.Minor(org.BaseCurrency)
+			if err != nil || threshold < 0 {
+				return errors.New("invalid receipt threshold")
+			}
+			org.ReceiptRequiredAboveMinor = threshold
+		}
+
+		if err := tx.Save(&org).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "organization.update", EntityType: "organization", EntityID: org.ID,
+			Before: before, After: org,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// ListMemberships returns the principal's memberships across all organizations
+func (s *OrganizationService) ListMemberships(p *auth.Principal) ([]models.Membership, error) {
+	var memberships []models.Membership
+
+	if err := s.db.Preload("Organization").Where("user_id = ?", p.UserID).Order("created_at, id").Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// ListMembers returns the members of the principal's organization
+func (s *OrganizationService) ListMembers(p *auth.Principal) ([]models.Membership, error) {
+	var members []models.Membership
+
+	if err := s.db.Preload("User").Where("organization_id = ?", p.OrganizationID).Order("created_at, id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// InviteMember invites an email address to the principal's organization
+func (s *OrganizationService) InviteMember(p *auth.Principal, req models.InviteMemberRequest) (*models.Invitation, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil, errors.New("invalid email address")
+	}
+	email := strings.ToLower(addr.Address)
+
+	if !req.Role.Valid() {
+		return nil, errors.New("invalid role")
+	}
+	if !authz.CanAssignRole(p, "", req.Role) {
+		return nil, errors.New("permission denied")
+	}
+
+	var existing int64
+	err = s.db.Model(&models.Membership{}).
+		Joins("JOIN users ON users.id = memberships.user_id").
+		Where("memberships.organization_id = ? AND LOWER(users.email) = ?", p.OrganizationID, email).
+		Count(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, errors.New("user is already a member")
+	}
+
+	// Re-inviting refreshes any pending invitation instead of stacking duplicates
+	var invitation models.Invitation
+	err = s.db.Where("organization_id = ? AND email = ? AND accepted_at IS NULL", p.OrganizationID, email).First(&invitation).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	change := audit.Change{Action: "invitation.create", EntityType: "invitation"}
+	if invitation.ID != 0 {
+		change.Action, change.Before = "invitation.refresh", invitation
+	}
+
+	invitation.OrganizationID = p.OrganizationID
+	invitation.Email = email
+	invitation.Role = req.Role
+	invitation.InvitedByID = p.UserID
+	invitation.ExpiresAt = time.Now().Add(invitationTTL)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&invitation).Error; err != nil {
+			return err
+		}
+		change.EntityID, change.After = invitation.ID, invitation
+		return recordChange(tx, p, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// ListInvitations returns pending invitations for the principal's organization
+func (s *OrganizationService) ListInvitations(p *auth.Principal) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+
+	if err := s.db.Where("organization_id = ? AND accepted_at IS NULL", p.OrganizationID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation deletes a pending invitation in the principal's organization
+func (s *OrganizationService) RevokeInvitation(p *auth.Principal, invitationID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		if err := tx.Where("organization_id = ? AND accepted_at IS NULL", p.OrganizationID).First(&invitation, invitationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invitation not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&invitation).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "invitation.revoke", EntityType: "invitation", EntityID: invitation.ID,
+			Before: invitation,
+		})
+	})
+}
+
+// UpdateMemberRole changes the role of a member of the principal's organization
+func (s *OrganizationService) UpdateMemberRole(p *auth.Principal, membershipID uint, role models.Role) (*models.Membership, error) {
+	if !role.Valid() {
+		return nil, errors.New("invalid role")
+	}
+
+	var membership models.Membership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", p.OrganizationID).First(&membership, membershipID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("member not found")
+			}
+			return err
+		}
+
+		if !authz.CanAssignRole(p, membership.Role, role) {
+			return errors.New("permission denied")
+		}
+
+		if membership.Role == models.RoleOwner && role != models.RoleOwner {
+			if err := ensureAnotherOwner(tx, membership); err != nil {
+				return err
+			}
+		}
+
+		before := membership
+		membership.Role = role
+		if err := tx.Save(&membership).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "membership.update", EntityType: "membership", EntityID: membership.ID,
+			Before: before, After: membership,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("User").First(&membership, membership.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// RemoveMember removes a member from the principal's organization
+func (s *OrganizationService) RemoveMember(p *auth.Principal, membershipID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var membership models.Membership
+		if err := tx.Where("organization_id = ?", p.OrganizationID).First(&membership, membershipID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("member not found")
+			}
+			return err
+		}
+
+		if !authz.CanAssignRole(p, membership.Role, membership.Role) {
+			return errors.New("permission denied")
+		}
+
+		if membership.Role == models.RoleOwner {
+			if err := ensureAnotherOwner(tx, membership); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&membership).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "membership.delete", EntityType: "membership", EntityID: membership.ID,
+			Before: membership,
+		})
+	})
+}
+
+// PendingInvitations returns open invitations addressed to the principal's email
+func (s *OrganizationService) PendingInvitations(p *auth.Principal) ([]models.Invitation, error) {
+	invitations := []models.Invitation{}
+	if p.Email == "" {
+		return invitations, nil
+	}
+
+	err := s.db.Preload("Organization").
+		Where("email = ? AND accepted_at IS NULL AND expires_at > ?", strings.ToLower(p.Email), time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation turns an invitation addressed to the principal into a membership
+func (s *OrganizationService) AcceptInvitation(p *auth.Principal, invitationID uint) (*models.Membership, error) {
+	var membership models.Membership
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		err := tx.Where("email = ? AND accepted_at IS NULL", strings.ToLower(p.Email)).First(&invitation, invitationID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invitation not found")
+			}
+			return err
+		}
+
+		if time.Now().After(invitation.ExpiresAt) {
+			return errors.New("invitation has expired")
+		}
+
+		var existing int64
+		if err := tx.Model(&models.Membership{}).Where("organization_id = ? AND user_id = ?", invitation.OrganizationID, p.UserID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errors.New("user is already a member")
+		}
+
+		now := time.Now()
+		invitation.AcceptedAt = &now
+		if err := tx.Save(&invitation).Error; err != nil {
+			return err
+		}
+
+		membership = models.Membership{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         p.UserID,
+			Role:           invitation.Role,
+		}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			OrganizationID: invitation.OrganizationID,
+			Action:         "invitation.accept",
+			EntityType:     "membership",
+			EntityID:       membership.ID,
+			After:          membership,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("Organization").First(&membership, membership.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// createOrganization creates an organization owned by the principal inside tx
+func createOrganization(tx *gorm.DB, org *models.Organization, p *auth.Principal) (*models.Membership, error) {
+	if err := tx.Create(org).Error; err != nil {
+		return nil, err
+	}
+
+	membership := &models.Membership{
+		OrganizationID: org.ID,
+		UserID:         p.UserID,
+		Role:           models.RoleOwner,
+		Organization:   org,
+	}
+	if err := tx.Omit("Organization").Create(membership).Error; err != nil {
+		return nil, err
+	}
+
+	if err := database.SeedCategories(tx, org.ID); err != nil {
+		return nil, err
+	}
+
+	err := recordChange(tx, p, audit.Change{
+		OrganizationID: org.ID,
+		Action:         "organization.create",
+		EntityType:     "organization",
+		EntityID:       org.ID,
+		After:          org,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// provisionPersonalOrganization gives a first-time user an organization of their own
+func provisionPersonalOrganization(tx *gorm.DB, user *models.User) (*models.Membership, error) {
+	owner := user.Name
+	if owner == "" {
+		owner = user.Email
+	}
+	if owner == "" {
+		owner = user.Subject
+	}
+
+	return createOrganization(tx, &models.Organization{Name: fmt.Sprintf("%s's organization", owner)}, &auth.Principal{UserID: user.ID})
+}
+
+// ensureAnotherOwner fails if membership is the organization's only owner
+func ensureAnotherOwner(tx *gorm.DB, membership models.Membership) error {
+	var owners int64
+	err := tx.Model(&models.Membership{}).
+		Where("organization_id = ? AND role = ? AND id <> ?", membership.OrganizationID, models.RoleOwner, membership.ID).
+		Count(&owners).Error
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return errors.New("organization must keep at least one owner")
+	}
+
+	return nil
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/policy"
+)
+
+// compiledRule is a policy rule with its condition parsed
+type compiledRule struct {
+	rule      models.PolicyRule
+	condition *policy.Condition
+	// categories are the lower-cased rule category and its subcategories; empty applies everywhere
+	categories map[string]bool
+}
+
+type PolicyService struct {
+	db *gorm.DB
+}
+
+func NewPolicyService() *PolicyService {
+	return &PolicyService{
+		db: database.GetDB(),
+	}
+}
+
+// ListRules returns the organization's policy rules, active or not
+func (s *PolicyService) ListRules(p *auth.Principal) ([]models.PolicyRule, error) {
+	rules := []models.PolicyRule{}
+	if err := scoped(s.db, p).Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// CreateRule adds a policy rule to the organization
+func (s *PolicyService) CreateRule(p *auth.Principal, req models.PolicyRuleRequest) (*models.PolicyRule, error) {
+	compiled, err := compileRule(req)
+	if err != nil {
+		return nil, err
+	}
+	rule := &compiled.rule
+
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "policy_rule.create", EntityType: "policy_rule", EntityID: rule.ID,
+			After: rule,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces a policy rule. Violations already recorded stay until
+// their expenses are next saved or submitted.
+func (s *PolicyService) UpdateRule(p *auth.Principal, id uint, req models.PolicyRuleRequest) (*models.PolicyRule, error) {
+	compiled, err := compileRule(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var rule models.PolicyRule
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&rule, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("policy rule not found")
+			}
+			return err
+		}
+		before := rule
+
+		rule.Name = compiled.rule.Name
+		rule.Message = compiled.rule.Message
+		rule.Category = compiled.rule.Category
+		rule.Severity = compiled.rule.Severity
+		rule.Condition = compiled.rule.Condition
+		rule.Active = compiled.rule.Active
+		if err := tx.Save(&rule).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "policy_rule.update", EntityType: "policy_rule", EntityID: rule.ID,
+			Before: before, After: rule,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule removes a policy rule from the organization
+func (s *PolicyService) DeleteRule(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var rule models.PolicyRule
+		if err := tx.First(&rule, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("policy rule not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "policy_rule.delete", EntityType: "policy_rule", EntityID: rule.ID,
+			Before: rule,
+		})
+	})
+}
+
+// Evaluate is a dry run: it reports the violations a saved or described
+// expense would get, from the organization's active rules or from the
+// unsaved rules in the request. Nothing is stored.
+func (s *PolicyService) Evaluate(p *auth.Principal, req models.EvaluatePolicyRequest) (*models.PolicyEvaluation, error) {
+	db := scoped(s.db, p)
+
+	var rules []compiledRule
+	if len(req.Rules) > 0 {
+		for _, r := range req.Rules {
+			compiled, err := compileRule(r)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, compiled)
+		}
+		if err := resolveRuleCategories(db, rules); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if rules, err = activeRules(db); err != nil {
+			return nil, err
+		}
+	}
+
+	var expense models.Expense
+	attachments := 0
+	switch {
+	case req.ExpenseID != 0:
+		if err := db.Scopes(visibleTo(p, authz.ExpenseRead), withAllocations).First(&expense, req.ExpenseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("expense not found")
+			}
+			return nil, err
+		}
+		var err error
+		if attachments, err = attachmentCount(db, expense.ID); err != nil {
+			return nil, err
+		}
+	case req.Expense != nil:
+		e := req.Expense
+		expense = models.Expense{
+			Type:        models.ExpenseStandard,
+			Description: e.Description,
+			Currency:    e.Currency,
+			Date:        e.Date,
+			Category:    e.Category,
+			ClientNotes: e.ClientNotes,
+			Attendees:   e.Attendees,
+			CreatedAt:   time.Now(),
+		}
+		if expense.Date.IsZero() {
+			expense.Date = time.Now()
+		}
+		if err := setAmount(db, p.OrganizationID, &expense, e.Amount); err != nil {
+			return nil, err
+		}
+		attachments = e.Attachments
+	default:
+		return nil, errors.New("expense or expense_id is required")
+	}
+
+	violations, blocked := applyRules(rules, &expense, attachments, false)
+	return &models.PolicyEvaluation{Violations: violations, Blocked: blocked}, nil
+}
+
+// checkPolicies evaluates the organization's active rules against an expense
+// about to be saved or submitted, with its Allocations set to the lines it
+// will have. When a blocking rule is broken it fails with
+// "policy violation" and leaves the violations on the expense. Receipts are
+// attached after an expense is saved, so rules about attachments only block
+// on submission.
+func checkPolicies(db *gorm.DB, expense *models.Expense, submitting bool) ([]models.PolicyViolation, error) {
+	rules, err := activeRules(db)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	attachments := 0
+	if expense.ID != 0 {
+		if attachments, err = attachmentCount(db, expense.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	violations, blocked := applyRules(rules, expense, attachments, !submitting)
+	if blocked {
+		expense.PolicyViolations = violations
+		return nil, errors.New("policy violation")
+	}
+	return violations, nil
+}
+
+// recordViolations replaces the violations stored for an expense
+func recordViolations(tx *gorm.DB, expenseID uint, violations []models.PolicyViolation) error {
+	if err := tx.Where("expense_id = ?", expenseID).Delete(&models.PolicyViolation{}).Error; err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	for i := range violations {
+		violations[i].ExpenseID = expenseID
+	}
+	return tx.Create(&violations).Error
+}
+
+// applyRules returns the violations of the rules that apply to the expense's
+// category, or to the category of one of its lines, and whether any of them
+// blocks. With deferAttachments, blocking rules about attachments are
+// reported without blocking.
+func applyRules(rules []compiledRule, expense *models.Expense, attachments int, deferAttachments bool) ([]models.PolicyViolation, bool) {
+	facts := policyFacts(expense, attachments)
+	categories := []string{strings.ToLower(expense.Category)}
+	for _, line := range expense.Allocations {
+		categories = append(categories, strings.ToLower(line.Category))
+	}
+	violations := []models.PolicyViolation{}
+	blocked := false
+	now := time.Now()
+	for _, r := range rules {
+		if r.rule.Category != "" && !r.covers(categories) {
+			continue
+		}
+		if !r.condition.Matches(facts) {
+			continue
+		}
+		message := r.rule.Message
+		if message == "" {
+			message = r.rule.Name
+		}
+		violations = append(violations, models.PolicyViolation{
+			RuleID:    r.rule.ID,
+			RuleName:  r.rule.Name,
+			Severity:  r.rule.Severity,
+			Message:   message,
+			CreatedAt: now,
+		})
+		if r.rule.Severity == models.PolicyBlock && !(deferAttachments && r.condition.Uses("attachments")) {
+			blocked = true
+		}
+	}
+	return violations, blocked
+}
+
+// policyFacts describes an expense in the terms of the policy language.
+// Amounts are in the organization's base currency.
+func policyFacts(expense *models.Expense, attachments int) policy.Facts {
+	amount, ok := new(big.Rat).SetString(string(money.FromMinor(expense.BaseAmountMinor, expense.BaseCurrency)))
+	if !ok {
+		amount = new(big.Rat)
+	}
+	attendees := expense.Attendees
+	if attendees < 1 {
+		attendees = 1
+	}
+
+	// Days from when the expense was recorded to the day it is dated, e.g. a flight booked ahead
+	created := expense.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+	createdDay := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+	expenseDay := time.Date(expense.Date.Year(), expense.Date.Month(), expense.Date.Day(), 0, 0, 0, 0, time.UTC)
+	ahead := int64(expenseDay.Sub(createdDay) / (24 * time.Hour))
+
+	return policy.Facts{
+		"amount":            amount,
+		"amount_per_person": new(big.Rat).Quo(amount, big.NewRat(int64(attendees), 1)),
+		"attendees":         big.NewRat(int64(attendees), 1),
+		"attachments":       big.NewRat(int64(attachments), 1),
+		"days_booked_ahead": big.NewRat(ahead, 1),
+		"type":              string(expense.Type),
+		"currency":          expense.Currency,
+		"category":          expense.Category,
+		"description":       expense.Description,
+		"client_notes":      expense.ClientNotes,
+		"text":              expense.Description + "\n" + expense.ClientNotes,
+	}
+}
+
+// activeRules loads and parses the organization's active policy rules
+func activeRules(db *gorm.DB) ([]compiledRule, error) {
+	var rules []models.PolicyRule
+	if err := db.Where("active = ?", true).Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		condition, err := policy.Parse(rule.Condition)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, compiledRule{rule: rule, condition: condition})
+	}
+	if err := resolveRuleCategories(db, compiled); err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// resolveRuleCategories fills in the categories each rule applies to, so a
+// rule for a category also applies to its subcategories
+func resolveRuleCategories(db *gorm.DB, rules []compiledRule) error {
+	for i := range rules {
+		if rules[i].rule.Category == "" {
+			continue
+		}
+		names, err := withSubcategories(db, []string{rules[i].rule.Category})
+		if err != nil {
+			return err
+		}
+		rules[i].categories = make(map[string]bool, len(names))
+		for _, name := range names {
+			rules[i].categories[strings.ToLower(name)] = true
+		}
+	}
+	return nil
+}
+
+// covers tells whether the rule applies to any of the lower-cased categories
+func (r compiledRule) covers(categories []string) bool {
+	for _, category := range categories {
+		if r.categories[category] {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRule validates a rule request and parses its condition
+func compileRule(req models.PolicyRuleRequest) (compiledRule, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return compiledRule{}, errors.New("policy name is required")
+	}
+	severity := req.Severity
+	if severity == "" {
+		severity = models.PolicyWarn
+	}
+	if severity != models.PolicyWarn && severity != models.PolicyBlock {
+		return compiledRule{}, errors.New("invalid severity")
+	}
+	if len(req.Condition) == 0 {
+		return compiledRule{}, errors.New("invalid condition: condition is required")
+	}
+	condition, err := policy.Parse(req.Condition)
+	if err != nil {
+		return compiledRule{}, err
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	return compiledRule{
+		rule: models.PolicyRule{
+			Name:      name,
+			Message:   strings.TrimSpace(req.Message),
+			Category:  strings.TrimSpace(req.Category),
+			Severity:  severity,
+			Condition: req.Condition,
+			Active:    active,
+		},
+		condition: condition,
+	}, nil
+}
+
+// attachmentCount returns how many live attachments an expense has
+func attachmentCount(db *gorm.DB, expenseID uint) (int, error) {
+	var count int64
+	if err := db.Model(&models.Attachment{}).Where("expense_id = ?", expenseID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+type ProjectService struct {
+	db *gorm.DB
+}
+
+func NewProjectService() *ProjectService {
+	return &ProjectService{
+		db: database.GetDB(),
+	}
+}
+
+// ListProjects returns the organization's projects by name with what has been
+// spent on each, optionally only those in one status
+func (s *ProjectService) ListProjects(p *auth.Principal, status models.ProjectStatus) ([]models.Project, error) {
+	db := scoped(s.db, p)
+	query := db.Order("name")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	projects := []models.Project{}
+	if err := query.Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	if err := fillSpent(db, projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// GetProject returns a project with what has been spent on it
+func (s *ProjectService) GetProject(p *auth.Principal, id uint) (*models.Project, error) {
+	db := scoped(s.db, p)
+	var project models.Project
+	if err := db.First(&project, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("project not found")
+		}
+		return nil, err
+	}
+	projects := []models.Project{project}
+	if err := fillSpent(db, projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+// CreateProject adds an active project to the organization
+func (s *ProjectService) CreateProject(p *auth.Principal, req models.CreateProjectRequest) (*models.Project, error) {
+	db := scoped(s.db, p)
+	currency, err := baseCurrency(db, p.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &models.Project{
+		Code:     strings.TrimSpace(req.Code),
+		Client:   strings.TrimSpace(req.Client),
+		Status:   models.ProjectActive,
+		Currency: currency,
+	}
+	if err := setBudget(project, req.Budget); err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := setProjectName(tx, project, req.Name); err != nil {
+			return err
+		}
+		if err := tx.Create(project).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "project.create", EntityType: "project", EntityID: project.ID,
+			After: project,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	project.Spent = money.FromMinor(0, project.Currency)
+	return project, nil
+}
+
+// UpdateProject changes a project's details, budget or status. Closing a
+// project stops new expenses from being booked to it.
+func (s *ProjectService) UpdateProject(p *auth.Principal, id uint, req models.UpdateProjectRequest) (*models.Project, error) {
+	db := scoped(s.db, p)
+	var project models.Project
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&project, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("project not found")
+			}
+			return err
+		}
+		before := project
+
+		if req.Name != nil {
+			if err := setProjectName(tx, &project, *req.Name); err != nil {
+				return err
+			}
+		}
+		if req.Code != nil {
+			project.Code = strings.TrimSpace(*req.Code)
+		}
+		if req.Client != nil {
+			project.Client = strings.TrimSpace(*req.Client)
+		}
+		if req.Budget != nil {
+			currency, err := baseCurrency(tx, p.OrganizationID)
+			if err != nil {
+				return err
+			}
+			project.Currency = currency
+			if err := setBudget(&project, *req.Budget); err != nil {
+				return err
+			}
+		}
+		if req.Status != nil {
+			status := models.ProjectStatus(strings.ToLower(strings.TrimSpace(string(*req.Status))))
+			if status != models.ProjectActive && status != models.ProjectClosed {
+				return errors.New("invalid project status")
+			}
+			project.Status = status
+		}
+
+		if err := tx.Save(&project).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "project.update", EntityType: "project", EntityID: project.ID,
+			Before: before, After: project,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []models.Project{project}
+	if err := fillSpent(db, projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+// DeleteProject removes a project no expense, trashed or not, is booked to.
+// Projects in use can be closed instead.
+func (s *ProjectService) DeleteProject(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		if err := tx.First(&project, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("project not found")
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&models.Expense{}).Where("project_id = ?", project.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("project is in use")
+		}
+
+		if err := tx.Delete(&project).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "project.delete", EntityType: "project", EntityID: project.ID,
+			Before: project,
+		})
+	})
+}
+
+// openProject checks that a project exists and takes new expenses
+func openProject(db *gorm.DB, id uint) error {
+	var project models.Project
+	if err := db.Select("id", "status").First(&project, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("project not found")
+		}
+		return err
+	}
+	if project.Status == models.ProjectClosed {
+		return errors.New("project is closed")
+	}
+	return nil
+}
+
+// fillSpent totals the base amounts of each project's live expenses
+func fillSpent(db *gorm.DB, projects []models.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(projects))
+	for i, project := range projects {
+		ids[i] = project.ID
+	}
+
+	var rows []struct {
+		ProjectID uint
+		Total     int64
+	}
+	err := db.Model(&models.Expense{}).Select("project_id, SUM(base_amount_minor) AS total").
+		Where("project_id IN ?", ids).Group("project_id").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	spent := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		spent[row.ProjectID] = row.Total
+	}
+	for i := range projects {
+		projects[i].Spent = money.FromMinor(spent[projects[i].ID], projects[i].Currency)
+	}
+	return nil
+}
+
+// rebaseProjectBudgets re-expresses the organization's project budgets in a
+// new base currency, keeping their values
+func rebaseProjectBudgets(tx *gorm.DB, organizationID uint, currency string) error {
+	db := database.WithTenant(tx, organizationID)
+	var projects []models.Project
+	if err := db.Find(&projects).Error; err != nil {
+		return err
+	}
+	for _, project := range projects {
+		budget := project.Budget
+		project.Currency = currency
+		if err := setBudget(&project, budget); err != nil {
+			return err
+		}
+		if err := db.Save(&project).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setProjectName validates and sets a project's name, which must be unique
+// within the organization regardless of case
+func setProjectName(tx *gorm.DB, project *models.Project, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("project name is required")
+	}
+	var count int64
+	err := tx.Model(&models.Project{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, project.ID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("project already exists")
+	}
+	project.Name = name
+	return nil
+}
+
+// setBudget sets a project's budget in its currency; empty means no budget
+func setBudget(project *models.Project, budget money.Decimal) error {
+	if strings.TrimSpace(string(budget)) == "" {
+		budget = "0"
+	}
+	minor, err := budget.Minor(project.Currency)
+	if err != nil || minor < 0 {
+		return errors.New("invalid budget")
+	}
+	project.BudgetMinor = minor
+	project.Budget = money.FromMinor(minor, project.Currency)
+	return nil
+}
@@ -0,0 +1,518 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/matching"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// matchDateSlack widens the window of expenses considered for a batch of receipts
+const matchDateSlack = 14 * 24 * time.Hour
+
+// suggestionsPerReceipt bounds how many candidate expenses are queued for one receipt
+const suggestionsPerReceipt = 3
+
+type ReceiptService struct {
+	db          *gorm.DB
+	attachments *AttachmentService
+}
+
+func NewReceiptService() *ReceiptService {
+	return &ReceiptService{
+		db:          database.GetDB(),
+		attachments: NewAttachmentService(),
+	}
+}
+
+// UploadReceipt stores a receipt that belongs to no expense yet and tries to
+// match it, along with the principal's other unmatched receipts
+func (s *ReceiptService) UploadReceipt(p *auth.Principal, file *multipart.FileHeader, details models.ReceiptDetails) (*models.ReceiptUploadResponse, error) {
+	db := scoped(s.db, p)
+
+	receipt := &models.Attachment{
+		UserID:      p.UserID,
+		Filename:    file.Filename,
+		ContentType: file.Header.Get("Content-Type"),
+		FileSize:    file.Size,
+		UploadedAt:  time.Now(),
+		Merchant:    strings.TrimSpace(details.Merchant),
+		ReceiptDate: details.Date,
+	}
+
+	if details.Amount != "" {
+		currency := money.Normalize(details.Currency)
+		if currency == "" {
+			var err error
+			if currency, err = baseCurrency(db, p.OrganizationID); err != nil {
+				return nil, err
+			}
+		}
+		if !money.Valid(currency) {
+			return nil, errors.New("unsupported currency")
+		}
+		minor, err := details.Amount.Minor(currency)
+		if err != nil {
+			return nil, errors.New("invalid amount")
+		}
+		if minor <= 0 {
+			return nil, errors.New("amount must be greater than 0")
+		}
+		receipt.ReceiptAmountMinor = minor
+		receipt.ReceiptCurrency = currency
+	}
+
+	var err error
+	if receipt.ContentHash, err = contentHash(file); err != nil {
+		return nil, err
+	}
+	if receipt.FilePath, receipt.StorageType, err = s.attachments.storeFile(file, "receipts"); err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(receipt).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "receipt.upload", EntityType: "attachment", EntityID: receipt.ID,
+			After: receipt,
+		})
+	})
+	if err != nil {
+		s.attachments.cleanupFile(receipt.FilePath, receipt.StorageType)
+		return nil, err
+	}
+
+	result, err := s.MatchReceipts(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// The run may have linked the new receipt already
+	if err := db.First(receipt, receipt.ID).Error; err != nil {
+		return nil, err
+	}
+	return &models.ReceiptUploadResponse{Receipt: *receipt, Match: *result}, nil
+}
+
+// ListReceipts returns the unmatched receipts visible to the principal, newest first
+func (s *ReceiptService) ListReceipts(p *auth.Principal) ([]models.Attachment, error) {
+	receipts := []models.Attachment{}
+	err := scoped(s.db, p).Scopes(unmatchedReceipts(p, authz.AttachmentRead)).
+		Order("uploaded_at DESC, id DESC").Find(&receipts).Error
+	if err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// GetReceiptFile returns the file data of an unmatched receipt
+func (s *ReceiptService) GetReceiptFile(p *auth.Principal, id uint) (io.ReadCloser, string, int64, error) {
+	var receipt models.Attachment
+	if err := scoped(s.db, p).Scopes(unmatchedReceipts(p, authz.AttachmentRead)).First(&receipt, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", 0, errors.New("receipt not found")
+		}
+		return nil, "", 0, err
+	}
+	return s.attachments.openFile(&receipt)
+}
+
+// MatchReceipts scores the unmatched receipts the principal may attach against
+// the expenses without a receipt belonging to the same uploader. Unambiguous
+// high-confidence pairs are linked; other plausible pairs replace the pending
+// suggestions in the review queue. Rejected pairs are not suggested again.
+func (s *ReceiptService) MatchReceipts(p *auth.Principal) (*models.ReceiptMatchResult, error) {
+	result := &models.ReceiptMatchResult{}
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var receipts []models.Attachment
+		if err := tx.Scopes(unmatchedReceipts(p, authz.AttachmentUpload)).Find(&receipts).Error; err != nil {
+			return err
+		}
+		result.Receipts = len(receipts)
+		if len(receipts) == 0 {
+			return nil
+		}
+
+		receiptIDs := make([]uint, len(receipts))
+		users := make(map[uint]bool)
+		var first, last time.Time
+		for i, r := range receipts {
+			receiptIDs[i] = r.ID
+			users[r.UserID] = true
+			date := receiptDate(r)
+			if first.IsZero() || date.Before(first) {
+				first = date
+			}
+			if date.After(last) {
+				last = date
+			}
+		}
+		userIDs := make([]uint, 0, len(users))
+		for id := range users {
+			userIDs = append(userIDs, id)
+		}
+
+		// Only expenses that can still be edited may gain a receipt
+		var expenses []models.Expense
+		err := tx.Where("expenses.user_id IN ?", userIDs).
+			Where("expenses.status IN ?", []models.ExpenseStatus{models.StatusDraft, models.StatusRejected}).
+			Where("expenses.date BETWEEN ? AND ?", first.Add(-matchDateSlack), last.Add(matchDateSlack)).
+			Where("NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.expense_id = expenses.id AND attachments.deleted_at IS NULL)").
+			Find(&expenses).Error
+		if err != nil {
+			return err
+		}
+
+		var rejected []models.ReceiptMatch
+		if err := tx.Where("attachment_id IN ? AND status = ?", receiptIDs, models.MatchRejected).Find(&rejected).Error; err != nil {
+			return err
+		}
+		skip := make(map[[2]uint]bool, len(rejected))
+		for _, m := range rejected {
+			skip[[2]uint{m.AttachmentID, m.ExpenseID}] = true
+		}
+
+		var pairs []models.ReceiptMatch
+		for _, r := range receipts {
+			for _, e := range expenses {
+				if e.UserID != r.UserID || skip[[2]uint{r.ID, e.ID}] {
+					continue
+				}
+				score, err := scorePair(tx, r, e)
+				if err != nil {
+					return err
+				}
+				if score.Total < matching.ReviewScore {
+					continue
+				}
+				pairs = append(pairs, models.ReceiptMatch{
+					OrganizationID: p.OrganizationID, AttachmentID: r.ID, ExpenseID: e.ID,
+					Score: score.Total, AmountScore: score.Amount, DateScore: score.Date, TextScore: score.Text,
+				})
+			}
+		}
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Score > pairs[j].Score })
+
+		// Suggestions still standing keep their IDs so clients can act on them
+		var pending []models.ReceiptMatch
+		if err := tx.Where("attachment_id IN ? AND status = ?", receiptIDs, models.MatchPending).Find(&pending).Error; err != nil {
+			return err
+		}
+		existing := make(map[[2]uint]models.ReceiptMatch, len(pending))
+		for _, m := range pending {
+			existing[[2]uint{m.AttachmentID, m.ExpenseID}] = m
+		}
+
+		linkedReceipts, linkedExpenses := make(map[uint]bool), make(map[uint]bool)
+		for _, pair := range autoLinks(pairs) {
+			if m, ok := existing[[2]uint{pair.AttachmentID, pair.ExpenseID}]; ok {
+				pair.ID, pair.CreatedAt = m.ID, m.CreatedAt
+			}
+			pair.Status = models.MatchAuto
+			if err := s.link(tx, p, pair); err != nil {
+				return err
+			}
+			linkedReceipts[pair.AttachmentID] = true
+			linkedExpenses[pair.ExpenseID] = true
+			result.AutoLinked++
+		}
+
+		kept := []uint{0}
+		queued := make(map[uint]int)
+		for _, pair := range pairs {
+			if linkedReceipts[pair.AttachmentID] || linkedExpenses[pair.ExpenseID] || queued[pair.AttachmentID] >= suggestionsPerReceipt {
+				continue
+			}
+			if m, ok := existing[[2]uint{pair.AttachmentID, pair.ExpenseID}]; ok {
+				pair.ID, pair.CreatedAt = m.ID, m.CreatedAt
+			}
+			pair.Status = models.MatchPending
+			if err := tx.Save(&pair).Error; err != nil {
+				return err
+			}
+			kept = append(kept, pair.ID)
+			queued[pair.AttachmentID]++
+			result.Queued++
+		}
+
+		return tx.Where("attachment_id IN ? AND status = ? AND id NOT IN ?", receiptIDs, models.MatchPending, kept).
+			Delete(&models.ReceiptMatch{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReviewQueue returns the pending suggestions on expenses the principal may
+// change and the visible expenses flagged for lacking a required receipt
+func (s *ReceiptService) ReviewQueue(p *auth.Principal) (*models.ReceiptReviewResponse, error) {
+	db := scoped(s.db, p)
+	response := &models.ReceiptReviewResponse{
+		Matches:         []models.ReceiptMatch{},
+		MissingReceipts: []models.Expense{},
+	}
+
+	err := db.Joins("JOIN expenses ON expenses.id = receipt_matches.expense_id AND expenses.deleted_at IS NULL").
+		Scopes(visibleTo(p, authz.AttachmentUpload)).
+		Where("receipt_matches.status = ?", models.MatchPending).
+		Preload("Attachment").Preload("Expense").
+		Order("receipt_matches.score DESC, receipt_matches.id").
+		Find(&response.Matches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := receiptThreshold(db, p.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Scopes(visibleTo(p, authz.ExpenseRead), missingReceipt(threshold), withDetails).
+		Order("expenses.date DESC, expenses.id DESC").
+		Find(&response.MissingReceipts).Error
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// ConfirmMatch links a suggested receipt to its expense
+func (s *ReceiptService) ConfirmMatch(p *auth.Principal, matchID uint) (*models.ReceiptMatch, error) {
+	return s.resolve(p, matchID, models.MatchConfirmed)
+}
+
+// RejectMatch dismisses a suggestion so the pair is not suggested again
+func (s *ReceiptService) RejectMatch(p *auth.Principal, matchID uint) (*models.ReceiptMatch, error) {
+	return s.resolve(p, matchID, models.MatchRejected)
+}
+
+func (s *ReceiptService) resolve(p *auth.Principal, matchID uint, status models.ReceiptMatchStatus) (*models.ReceiptMatch, error) {
+	db := scoped(s.db, p)
+	var match models.ReceiptMatch
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&match, matchID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("match not found")
+			}
+			return err
+		}
+
+		var expense models.Expense
+		if err := tx.Scopes(visibleTo(p, authz.ExpenseRead)).First(&expense, match.ExpenseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("match not found")
+			}
+			return err
+		}
+		if !authz.AllowedOn(p, authz.AttachmentUpload, expense.UserID) {
+			return errors.New("permission denied")
+		}
+		if match.Status != models.MatchPending {
+			return errors.New("match already resolved")
+		}
+
+		if status == models.MatchConfirmed {
+			if !expense.Editable() {
+				return errors.New("expense is locked")
+			}
+			match.Status = status
+			return s.link(tx, p, match)
+		}
+
+		now := time.Now()
+		before := match
+		match.Status = status
+		match.ResolvedByID = p.UserID
+		match.ResolvedAt = &now
+		if err := tx.Save(&match).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "receipt_match.reject", EntityType: "receipt_match", EntityID: match.ID, ExpenseID: match.ExpenseID,
+			Before: before, After: match,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Preload("Attachment").Preload("Expense").First(&match, match.ID).Error; err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// link attaches the receipt to the expense and records the decision. Other
+// pending suggestions for either side are dropped since both are now matched.
+func (s *ReceiptService) link(tx *gorm.DB, p *auth.Principal, match models.ReceiptMatch) error {
+	var receipt models.Attachment
+	if err := tx.Where("expense_id = 0").First(&receipt, match.AttachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("receipt already matched")
+		}
+		return err
+	}
+	before := receipt
+
+	if err := tx.Model(&receipt).Update("expense_id", match.ExpenseID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	match.ResolvedByID = p.UserID
+	match.ResolvedAt = &now
+	if match.ID == 0 {
+		if err := tx.Create(&match).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Save(&match).Error; err != nil {
+		return err
+	}
+
+	err := tx.Where("status = ? AND (attachment_id = ? OR expense_id = ?)", models.MatchPending, match.AttachmentID, match.ExpenseID).
+		Delete(&models.ReceiptMatch{}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := recordChange(tx, p, audit.Change{
+		Action: "receipt.match", EntityType: "attachment", EntityID: receipt.ID, ExpenseID: match.ExpenseID,
+		Before: before, After: receipt,
+	}); err != nil {
+		return err
+	}
+	if err := touchExpense(tx, match.ExpenseID); err != nil {
+		return err
+	}
+	if err := refreshDuplicates(tx, p.OrganizationID, match.ExpenseID); err != nil {
+		return err
+	}
+	return indexExpense(tx, match.ExpenseID)
+}
+
+// autoLinks picks the pairs confident enough to link without review: the
+// score clears the bar and leads every other pair involving the same receipt
+// or the same expense by the margin. pairs must be sorted best first.
+func autoLinks(pairs []models.ReceiptMatch) []models.ReceiptMatch {
+	var links []models.ReceiptMatch
+	for i, pair := range pairs {
+		if pair.Score < matching.AutoLinkScore {
+			break
+		}
+		clear := true
+		for j, other := range pairs {
+			if i == j || (other.AttachmentID != pair.AttachmentID && other.ExpenseID != pair.ExpenseID) {
+				continue
+			}
+			if other.Score > pair.Score-matching.AutoLinkMargin {
+				clear = false
+				break
+			}
+		}
+		if clear {
+			links = append(links, pair)
+		}
+	}
+	return links
+}
+
+// scorePair compares a receipt with an expense. A receipt in another currency
+// is converted into the expense's currency at the rate on the receipt date;
+// without a rate its amount cannot count towards the score.
+func scorePair(db *gorm.DB, receipt models.Attachment, expense models.Expense) (matching.Score, error) {
+	text := receipt.Merchant
+	if text == "" {
+		text = strings.TrimSuffix(receipt.Filename, filepath.Ext(receipt.Filename))
+	}
+	r := matching.Candidate{Date: receiptDate(receipt), Text: text}
+
+	if receipt.ReceiptAmountMinor > 0 {
+		r.AmountMinor = receipt.ReceiptAmountMinor
+		if receipt.ReceiptCurrency != expense.Currency {
+			rate, _, err := lookupRate(db, r.Date, receipt.ReceiptCurrency, expense.Currency)
+			switch {
+			case err == nil:
+				if r.AmountMinor, err = money.Convert(r.AmountMinor, receipt.ReceiptCurrency, expense.Currency, rate); err != nil {
+					r.AmountMinor = 0
+				}
+			case strings.HasPrefix(err.Error(), "no exchange rate"):
+				r.AmountMinor = 0
+			default:
+				return matching.Score{}, err
+			}
+		}
+	}
+
+	return matching.Compare(r, matching.Candidate{
+		AmountMinor: expense.AmountMinor,
+		Currency:    expense.Currency,
+		Date:        expense.Date,
+		Text:        expense.Description,
+	}), nil
+}
+
+// receiptDate is the date printed on a receipt, or its upload date when unknown
+func receiptDate(receipt models.Attachment) time.Time {
+	if receipt.ReceiptDate != nil {
+		return *receipt.ReceiptDate
+	}
+	return receipt.UploadedAt
+}
+
+// unmatchedReceipts restricts attachment queries to receipts not yet matched
+// to an expense that the principal may perform action on
+func unmatchedReceipts(p *auth.Principal, action authz.Action) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("attachments.expense_id = 0")
+		switch authz.ScopeOf(p, action) {
+		case authz.Organization:
+			return db
+		case authz.Own:
+			return db.Where("attachments.user_id = ?", p.UserID)
+		default:
+			return db.Where("1 = 0")
+		}
+	}
+}
+
+// missingReceipt restricts expense queries to expenses without an attachment
+// whose base amount exceeds their category's receipt threshold or, when the
+// category sets none, the organization's threshold. A zero organization
+// threshold requires no receipts.
+func missingReceipt(threshold int64) func(*gorm.DB) *gorm.DB {
+	fallback := interface{}(threshold)
+	if threshold == 0 {
+		fallback = gorm.Expr("NULL")
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`expenses.base_amount_minor > COALESCE((SELECT categories.receipt_required_above_minor FROM categories
+			WHERE categories.organization_id = expenses.organization_id AND categories.name = expenses.category), ?)`, fallback).
+			Where("NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.expense_id = expenses.id AND attachments.deleted_at IS NULL)")
+	}
+}
+
+// receiptThreshold returns the organization's receipt threshold in base currency minor units, zero when unset
+func receiptThreshold(db *gorm.DB, organizationID uint) (int64, error) {
+	var org models.Organization
+	if err := db.Select("id", "receipt_required_above_minor").First(&org, organizationID).Error; err != nil {
+		return 0, err
+	}
+	return org.ReceiptRequiredAboveMinor, nil
+}
@@ -0,0 +1,716 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/matching"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/recurrence"
+)
+
+// maxCatchUp bounds how many overdue dates of one template a scheduler run
+// creates expenses for; the rest follow on the next run
+const maxCatchUp = 366
+
+// maxUpcoming bounds the upcoming occurrences listed at once
+const maxUpcoming = 1000
+
+// suggestionHistory is how far back the detector looks; yearly charges need
+// three years' worth of dates
+const suggestionHistory = 25 * 31 * 24 * time.Hour
+
+// amountTolerance is how far a charge may stray from the usual amount and
+// still count as the same subscription
+const amountTolerance = 0.1
+
+// RecurringExpenseService manages recurring expense templates and creates
+// the expenses they schedule
+type RecurringExpenseService struct {
+	db *gorm.DB
+}
+
+func NewRecurringExpenseService() *RecurringExpenseService {
+	return &RecurringExpenseService{
+		db: database.GetDB(),
+	}
+}
+
+// ListRecurringExpenses returns the recurring expenses visible to the principal, next due first
+func (s *RecurringExpenseService) ListRecurringExpenses(p *auth.Principal) ([]models.RecurringExpense, error) {
+	templates := []models.RecurringExpense{}
+	err := scoped(s.db, p).Scopes(templatesVisibleTo(p, authz.ExpenseRead)).
+		Order("next_occurrence IS NULL, next_occurrence, id").Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetRecurringExpense returns one recurring expense
+func (s *RecurringExpenseService) GetRecurringExpense(p *auth.Principal, id uint) (*models.RecurringExpense, error) {
+	var template models.RecurringExpense
+	if err := scoped(s.db, p).Scopes(templatesVisibleTo(p, authz.ExpenseRead)).First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("recurring expense not found")
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateRecurringExpense creates a recurring expense owned by the principal.
+// Dates from the start date up to today get their expenses right away.
+func (s *RecurringExpenseService) CreateRecurringExpense(p *auth.Principal, req models.CreateRecurringExpenseRequest) (*models.RecurringExpense, error) {
+	template := &models.RecurringExpense{
+		UserID:      p.UserID,
+		Description: strings.TrimSpace(req.Description),
+		Currency:    req.Currency,
+		ClientNotes: req.ClientNotes,
+		Rule:        req.Rule,
+		Status:      models.RecurringActive,
+	}
+	if template.Description == "" {
+		return nil, errors.New("description is required")
+	}
+
+	db := scoped(s.db, p)
+	if err := setTemplateAmount(db, p.OrganizationID, template, req.Amount); err != nil {
+		return nil, err
+	}
+	category, err := resolveCategory(db, req.Category)
+	if err != nil {
+		return nil, err
+	}
+	template.Category = category
+	if req.ProjectID != nil && *req.ProjectID != 0 {
+		if err := openProject(db, *req.ProjectID); err != nil {
+			return nil, err
+		}
+		template.ProjectID = req.ProjectID
+	}
+	if req.CostCenterID != nil && *req.CostCenterID != 0 {
+		if err := activeCostCenter(db, *req.CostCenterID); err != nil {
+			return nil, err
+		}
+		template.CostCenterID = req.CostCenterID
+	}
+
+	if template.StartDate, err = parseRateDate(req.StartDate); err != nil {
+		return nil, errors.New("invalid start date")
+	}
+	if strings.TrimSpace(req.EndDate) != "" {
+		end, err := parseRateDate(req.EndDate)
+		if err != nil {
+			return nil, errors.New("invalid end date")
+		}
+		template.EndDate = &end
+	}
+	if err := schedule(template, template.StartDate); err != nil {
+		return nil, err
+	}
+	if template.NextOccurrence == nil {
+		return nil, errors.New("schedule has no dates")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "recurring_expense.create", EntityType: "recurring_expense", EntityID: template.ID,
+			After: template,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.materialize(template, recurrence.Day(time.Now())); err != nil {
+		log.Printf("Recurring expense %d: %v", template.ID, err)
+	}
+	return template, nil
+}
+
+// UpdateRecurringExpense changes a recurring expense. Expenses already
+// created keep their values; a changed schedule continues after the last of
+// them, and resuming a paused template skips the dates it missed.
+func (s *RecurringExpenseService) UpdateRecurringExpense(p *auth.Principal, id uint, req models.UpdateRecurringExpenseRequest) (*models.RecurringExpense, error) {
+	db := scoped(s.db, p)
+	var template models.RecurringExpense
+	if err := db.Scopes(templatesVisibleTo(p, authz.ExpenseRead)).First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("recurring expense not found")
+		}
+		return nil, err
+	}
+	if !authz.AllowedOn(p, authz.ExpenseUpdate, template.UserID) {
+		return nil, errors.New("permission denied")
+	}
+	before := template
+
+	if req.Description != nil {
+		template.Description = strings.TrimSpace(*req.Description)
+		if template.Description == "" {
+			return nil, errors.New("description is required")
+		}
+	}
+	if req.Amount != nil || req.Currency != nil {
+		amount := money.FromMinor(template.AmountMinor, template.Currency)
+		if req.Amount != nil {
+			amount = *req.Amount
+		}
+		if req.Currency != nil {
+			template.Currency = *req.Currency
+		}
+		if err := setTemplateAmount(db, p.OrganizationID, &template, amount); err != nil {
+			return nil, err
+		}
+	}
+	if req.Category != nil && !strings.EqualFold(strings.TrimSpace(*req.Category), template.Category) {
+		category, err := resolveCategory(db, *req.Category)
+		if err != nil {
+			return nil, err
+		}
+		template.Category = category
+	}
+	if req.ClientNotes != nil {
+		template.ClientNotes = *req.ClientNotes
+	}
+	if req.ProjectID != nil {
+		if *req.ProjectID == 0 {
+			template.ProjectID = nil
+		} else if template.ProjectID == nil || *template.ProjectID != *req.ProjectID {
+			if err := openProject(db, *req.ProjectID); err != nil {
+				return nil, err
+			}
+			template.ProjectID = req.ProjectID
+		}
+	}
+	if req.CostCenterID != nil {
+		if *req.CostCenterID == 0 {
+			template.CostCenterID = nil
+		} else if template.CostCenterID == nil || *template.CostCenterID != *req.CostCenterID {
+			if err := activeCostCenter(db, *req.CostCenterID); err != nil {
+				return nil, err
+			}
+			template.CostCenterID = req.CostCenterID
+		}
+	}
+
+	rescheduled := false
+	if req.Rule != nil {
+		template.Rule, rescheduled = *req.Rule, true
+	}
+	if req.StartDate != nil {
+		start, err := parseRateDate(*req.StartDate)
+		if err != nil {
+			return nil, errors.New("invalid start date")
+		}
+		template.StartDate, rescheduled = start, true
+	}
+	if req.EndDate != nil {
+		template.EndDate, rescheduled = nil, true
+		if strings.TrimSpace(*req.EndDate) != "" {
+			end, err := parseRateDate(*req.EndDate)
+			if err != nil {
+				return nil, errors.New("invalid end date")
+			}
+			template.EndDate = &end
+		}
+	}
+
+	today := recurrence.Day(time.Now())
+	resumed := false
+	if req.Status != nil {
+		switch *req.Status {
+		case models.RecurringActive:
+			resumed = template.Status == models.RecurringPaused
+			if template.Status == models.RecurringPaused {
+				template.Status = models.RecurringActive
+			}
+		case models.RecurringPaused:
+			if template.Status == models.RecurringEnded {
+				return nil, errors.New("recurring expense has ended")
+			}
+			template.Status = models.RecurringPaused
+		default:
+			return nil, errors.New("invalid recurring status")
+		}
+	}
+
+	if rescheduled || resumed {
+		from := template.StartDate
+		if template.LastOccurrence != nil && !template.LastOccurrence.Before(from) {
+			from = template.LastOccurrence.AddDate(0, 0, 1)
+		}
+		if resumed && from.Before(today) {
+			from = today
+		}
+		if err := schedule(&template, from); err != nil {
+			return nil, err
+		}
+		switch {
+		case template.NextOccurrence == nil:
+			template.Status = models.RecurringEnded
+		case template.Status == models.RecurringEnded:
+			template.Status = models.RecurringActive
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&template).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "recurring_expense.update", EntityType: "recurring_expense", EntityID: template.ID,
+			Before: before, After: template,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.materialize(&template, today); err != nil {
+		log.Printf("Recurring expense %d: %v", template.ID, err)
+	}
+	return &template, nil
+}
+
+// DeleteRecurringExpense stops a recurring expense. The expenses it created are kept.
+func (s *RecurringExpenseService) DeleteRecurringExpense(p *auth.Principal, id uint) error {
+	db := scoped(s.db, p)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var template models.RecurringExpense
+		if err := tx.Scopes(templatesVisibleTo(p, authz.ExpenseRead)).First(&template, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("recurring expense not found")
+			}
+			return err
+		}
+		if !authz.AllowedOn(p, authz.ExpenseDelete, template.UserID) {
+			return errors.New("permission denied")
+		}
+		if err := tx.Delete(&template).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "recurring_expense.delete", EntityType: "recurring_expense", EntityID: template.ID,
+			Before: template,
+		})
+	})
+}
+
+// UpcomingOccurrences lists the dates the principal's visible active
+// recurring expenses will create expenses on over the next days, including
+// any due but not yet created, earliest first
+func (s *RecurringExpenseService) UpcomingOccurrences(p *auth.Principal, days int) ([]models.UpcomingOccurrence, error) {
+	var templates []models.RecurringExpense
+	err := scoped(s.db, p).Scopes(templatesVisibleTo(p, authz.ExpenseRead)).
+		Where("status = ? AND next_occurrence IS NOT NULL", models.RecurringActive).Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	until := recurrence.Day(time.Now()).AddDate(0, 0, days)
+	upcoming := []models.UpcomingOccurrence{}
+	for _, t := range templates {
+		rule, err := recurrence.Parse(t.Rule)
+		if err != nil {
+			return nil, err
+		}
+		to := until
+		if t.EndDate != nil && t.EndDate.Before(to) {
+			to = *t.EndDate
+		}
+		for _, date := range rule.Between(t.StartDate, *t.NextOccurrence, to, maxUpcoming) {
+			upcoming = append(upcoming, models.UpcomingOccurrence{
+				RecurringExpenseID: t.ID,
+				UserID:             t.UserID,
+				Date:               date,
+				Description:        t.Description,
+				Amount:             t.Amount,
+				AmountMinor:        t.AmountMinor,
+				Currency:           t.Currency,
+				Category:           t.Category,
+			})
+		}
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		if !upcoming[i].Date.Equal(upcoming[j].Date) {
+			return upcoming[i].Date.Before(upcoming[j].Date)
+		}
+		return upcoming[i].RecurringExpenseID < upcoming[j].RecurringExpenseID
+	})
+	if len(upcoming) > maxUpcoming {
+		upcoming = upcoming[:maxUpcoming]
+	}
+	return upcoming, nil
+}
+
+// SuggestRecurringExpenses proposes recurring expenses for charges the
+// principal can see that were entered by hand on a regular schedule with a
+// steady amount, such as a monthly subscription. Charges already covered by
+// a recurring expense are left out. The most regular come first.
+func (s *RecurringExpenseService) SuggestRecurringExpenses(p *auth.Principal) ([]models.RecurringSuggestion, error) {
+	db := scoped(s.db, p)
+	now := time.Now()
+
+	var expenses []models.Expense
+	err := db.Scopes(visibleTo(p, authz.ExpenseRead)).
+		Select("id", "user_id", "description", "amount_minor", "currency", "category", "date").
+		Where("recurring_expense_id IS NULL AND type = ? AND date >= ?", models.ExpenseStandard, now.Add(-suggestionHistory)).
+		Order("date, id").Find(&expenses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []models.RecurringExpense
+	if err := db.Scopes(templatesVisibleTo(p, authz.ExpenseRead)).Select("user_id", "description", "currency").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	covered := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		covered[chargeKey(t.UserID, t.Currency, t.Description)] = true
+	}
+
+	groups := make(map[string][]models.Expense)
+	var keys []string
+	for _, e := range expenses {
+		if matching.Normalize(e.Description) == "" {
+			continue
+		}
+		key := chargeKey(e.UserID, e.Currency, e.Description)
+		if covered[key] {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], e)
+	}
+
+	suggestions := []models.RecurringSuggestion{}
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < recurrence.MinOccurrences || !steadyAmounts(group) {
+			continue
+		}
+		dates := make([]time.Time, len(group))
+		for i, e := range group {
+			dates[i] = e.Date
+		}
+		pattern, ok := recurrence.Detect(dates, now)
+		if !ok {
+			continue
+		}
+
+		latest := group[len(group)-1]
+		suggestion := models.RecurringSuggestion{
+			UserID:      latest.UserID,
+			Description: latest.Description,
+			Amount:      money.FromMinor(latest.AmountMinor, latest.Currency),
+			AmountMinor: latest.AmountMinor,
+			Currency:    latest.Currency,
+			Rule:        pattern.Rule.String(),
+			StartDate:   pattern.Next.Format("2006-01-02"),
+			LastDate:    latest.Date,
+			Confidence:  pattern.Confidence,
+			ExpenseIDs:  make([]uint, len(group)),
+		}
+		for i, e := range group {
+			suggestion.ExpenseIDs[i] = e.ID
+			if e.AmountMinor != latest.AmountMinor {
+				suggestion.AmountVaries = true
+			}
+			if e.Category != "" {
+				suggestion.Category = e.Category
+			}
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].LastDate.After(suggestions[j].LastDate)
+	})
+	return suggestions, nil
+}
+
+// Materialize creates the expenses that active recurring expenses of every
+// organization are due for up to today. Each date's expense carries an
+// external ID made from the template and the date, so a date is never
+// created twice, whether runs overlap or the API was down for a while.
+func (s *RecurringExpenseService) Materialize() (int, error) {
+	today := recurrence.Day(time.Now())
+	var due []models.RecurringExpense
+	err := database.AcrossTenants(s.db).
+		Where("status = ? AND next_occurrence <= ?", models.RecurringActive, today).
+		Order("id").Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i := range due {
+		n, err := s.materialize(&due[i], today)
+		created += n
+		if err != nil {
+			log.Printf("Recurring expense %d: %v", due[i].ID, err)
+		}
+	}
+	return created, nil
+}
+
+// RunMaterialize creates due recurring expenses now and then every interval; it blocks, so run it in a goroutine
+func (s *RecurringExpenseService) RunMaterialize(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, err := s.Materialize()
+		if err != nil {
+			log.Printf("Recurring expense run failed: %v", err)
+		} else if created > 0 {
+			log.Printf("Recurring expense run created %d expenses", created)
+		}
+		<-ticker.C
+	}
+}
+
+// materialize creates the template's expenses for its dates up to today, one
+// transaction per date. A template whose project has closed or whose cost
+// center was archived is paused instead.
+func (s *RecurringExpenseService) materialize(t *models.RecurringExpense, today time.Time) (int, error) {
+	if t.Status != models.RecurringActive {
+		return 0, nil
+	}
+	rule, err := recurrence.Parse(t.Rule)
+	if err != nil {
+		return 0, err
+	}
+	db := database.WithTenant(s.db, t.OrganizationID)
+
+	created := 0
+	for n := 0; n < maxCatchUp && t.NextOccurrence != nil && !t.NextOccurrence.After(today); n++ {
+		date := *t.NextOccurrence
+		next := nextOccurrence(rule, t, date.AddDate(0, 0, 1))
+
+		var inserted bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if t.ProjectID != nil {
+				if err := openProject(tx, *t.ProjectID); err != nil {
+					return err
+				}
+			}
+			if t.CostCenterID != nil {
+				if err := activeCostCenter(tx, *t.CostCenterID); err != nil {
+					return err
+				}
+			}
+
+			expense := scheduledExpense(t, date)
+			if err := setAmount(tx, t.OrganizationID, expense, t.Amount); err != nil {
+				return err
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(expense)
+			if result.Error != nil {
+				return result.Error
+			}
+			if inserted = result.RowsAffected > 0; inserted {
+				if err := audit.Record(tx, audit.Change{
+					OrganizationID: t.OrganizationID,
+					Action:         "expense.recur", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+					After: expense,
+				}); err != nil {
+					return err
+				}
+				if err := indexExpense(tx, expense.ID); err != nil {
+					return err
+				}
+			}
+
+			updates := map[string]interface{}{
+				"next_occurrence": next,
+				"last_occurrence": date,
+				"occurrences":     gorm.Expr("occurrences + 1"),
+				"updated_at":      time.Now(),
+			}
+			if next == nil {
+				updates["status"] = models.RecurringEnded
+			}
+			// Another run that got here first has already moved the template on
+			result = tx.Model(&models.RecurringExpense{}).
+				Where("id = ? AND status = ? AND next_occurrence = ?", t.ID, models.RecurringActive, date).Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("recurring expense was changed concurrently")
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+		case err.Error() == "project is closed", err.Error() == "project not found",
+			err.Error() == "cost center is archived", err.Error() == "cost center not found":
+			return created, s.pause(db, t, err.Error())
+		default:
+			return created, err
+		}
+
+		if inserted {
+			created++
+		}
+		t.NextOccurrence, t.LastOccurrence = next, &date
+		t.Occurrences++
+		if next == nil {
+			t.Status = models.RecurringEnded
+		}
+	}
+	return created, nil
+}
+
+// pause stops a template that can no longer create expenses as it stands
+func (s *RecurringExpenseService) pause(db *gorm.DB, t *models.RecurringExpense, reason string) error {
+	log.Printf("Pausing recurring expense %d: %s", t.ID, reason)
+	return db.Transaction(func(tx *gorm.DB) error {
+		before := *t
+		t.Status = models.RecurringPaused
+		if err := tx.Model(t).Update("status", t.Status).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{
+			OrganizationID: t.OrganizationID,
+			Action:         "recurring_expense.pause", EntityType: "recurring_expense", EntityID: t.ID,
+			Before: before, After: *t,
+		})
+	})
+}
+
+// scheduledExpense builds the draft expense a template creates for a date
+func scheduledExpense(t *models.RecurringExpense, date time.Time) *models.Expense {
+	externalID := fmt.Sprintf("recurring:%d:%s", t.ID, date.Format("2006-01-02"))
+	now := time.Now()
+	return &models.Expense{
+		OrganizationID:     t.OrganizationID,
+		UserID:             t.UserID,
+		ExternalID:         &externalID,
+		RecurringExpenseID: &t.ID,
+		Status:             models.StatusDraft,
+		Version:            1,
+		Type:               models.ExpenseStandard,
+		Description:        t.Description,
+		Currency:           t.Currency,
+		Date:               date,
+		Category:           t.Category,
+		ClientNotes:        t.ClientNotes,
+		ProjectID:          t.ProjectID,
+		CostCenterID:       t.CostCenterID,
+		Attendees:          1,
+		Reimbursement:      models.ReimbursementDue,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		Attachments:        []models.Attachment{},
+		AISuggestions:      []models.AISuggestion{},
+		PolicyViolations:   []models.PolicyViolation{},
+		Tags:               []models.Tag{},
+		Allocations:        []models.ExpenseAllocation{},
+	}
+}
+
+// schedule checks a template's rule and dates, stores the rule in canonical
+// form and sets its next date on or after from
+func schedule(t *models.RecurringExpense, from time.Time) error {
+	rule, err := recurrence.Parse(t.Rule)
+	if err != nil {
+		return err
+	}
+	t.Rule = rule.String()
+	if t.EndDate != nil && t.EndDate.Before(t.StartDate) {
+		return errors.New("end date is before start date")
+	}
+	t.NextOccurrence = nextOccurrence(rule, t, from)
+	return nil
+}
+
+// nextOccurrence returns the template's first date on or after from, or nil
+// when its schedule has no more dates
+func nextOccurrence(rule *recurrence.Rule, t *models.RecurringExpense, from time.Time) *time.Time {
+	it := rule.Iterate(t.StartDate)
+	for {
+		date, ok := it.Next()
+		if !ok || (t.EndDate != nil && date.After(*t.EndDate)) {
+			return nil
+		}
+		if !date.Before(from) {
+			return &date
+		}
+	}
+}
+
+// setTemplateAmount validates a template's amount in its currency, which
+// defaults to the organization's base currency
+func setTemplateAmount(db *gorm.DB, organizationID uint, t *models.RecurringExpense, amount money.Decimal) error {
+	currency := money.Normalize(t.Currency)
+	if currency == "" {
+		base, err := baseCurrency(db, organizationID)
+		if err != nil {
+			return err
+		}
+		currency = base
+	}
+	if !money.Valid(currency) {
+		return errors.New("unsupported currency")
+	}
+	minor, err := amount.Minor(currency)
+	if err != nil {
+		return errors.New("invalid amount")
+	}
+	if minor <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+	t.Currency = currency
+	t.AmountMinor = minor
+	t.Amount = money.FromMinor(minor, currency)
+	return nil
+}
+
+// steadyAmounts reports whether nearly all of a group's charges are close to
+// their median amount, as a subscription's are and taxi rides are not
+func steadyAmounts(group []models.Expense) bool {
+	amounts := make([]int64, len(group))
+	for i, e := range group {
+		amounts[i] = e.AmountMinor
+	}
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i] < amounts[j] })
+	median := float64(amounts[len(amounts)/2])
+
+	steady := 0
+	for _, amount := range amounts {
+		if diff := float64(amount) - median; diff <= amountTolerance*median && -diff <= amountTolerance*median {
+			steady++
+		}
+	}
+	return float64(steady) >= 0.8*float64(len(amounts))
+}
+
+// chargeKey groups charges by who paid, in which currency and to whom
+func chargeKey(userID uint, currency, description string) string {
+	return fmt.Sprintf("%d|%s|%s", userID, currency, matching.Normalize(description))
+}
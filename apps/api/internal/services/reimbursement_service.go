@@ -0,0 +1,553 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/payout"
+)
+
+// maxBatchReference is the longest reference a payout file carries
+const maxBatchReference = 35
+
+type ReimbursementService struct {
+	db *gorm.DB
+}
+
+func NewReimbursementService() *ReimbursementService {
+	return &ReimbursementService{
+		db: database.GetDB(),
+	}
+}
+
+// ListDue returns what each employee is owed for approved, reimbursable
+// expenses not yet in a batch, in the organization's base currency
+func (s *ReimbursementService) ListDue(p *auth.Principal) ([]models.DueReimbursement, error) {
+	db := scoped(s.db, p)
+	currency, err := baseCurrency(db, p.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		UserID uint
+		Total  int64
+		Count  int
+	}
+	if err := dueExpenses(db).
+		Select("user_id, SUM(base_amount_minor) AS total, COUNT(*) AS count").
+		Group("user_id").Order("user_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var withAccount []uint
+	if err := db.Model(&models.BankAccount{}).Where("user_id IS NOT NULL").Pluck("user_id", &withAccount).Error; err != nil {
+		return nil, err
+	}
+	hasAccount := make(map[uint]bool, len(withAccount))
+	for _, id := range withAccount {
+		hasAccount[id] = true
+	}
+
+	due := make([]models.DueReimbursement, 0, len(rows))
+	for _, row := range rows {
+		due = append(due, models.DueReimbursement{
+			UserID:         row.UserID,
+			Amount:         money.FromMinor(row.Total, currency),
+			AmountMinor:    row.Total,
+			Currency:       currency,
+			ExpenseCount:   row.Count,
+			HasBankAccount: hasAccount[row.UserID],
+		})
+	}
+	return due, nil
+}
+
+// ListBatches returns the organization's reimbursement batches, newest first,
+// optionally only those with the given status
+func (s *ReimbursementService) ListBatches(p *auth.Principal, status models.BatchStatus) ([]models.ReimbursementBatch, error) {
+	query := scoped(s.db, p).Order("created_at DESC, id DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	batches := []models.ReimbursementBatch{}
+	if err := query.Find(&batches).Error; err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// GetBatch returns a reimbursement batch with its payments and the expenses each pays
+func (s *ReimbursementService) GetBatch(p *auth.Principal, id uint) (*models.ReimbursementBatch, error) {
+	db := scoped(s.db, p)
+	batch, err := findBatch(db.Preload("Payments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("user_id")
+	}), id)
+	if err != nil {
+		return nil, err
+	}
+
+	var expenses []models.Expense
+	if err := db.Select("id", "user_id").Where("reimbursement_batch_id = ?", id).Order("id").Find(&expenses).Error; err != nil {
+		return nil, err
+	}
+	byUser := make(map[uint][]uint)
+	for _, e := range expenses {
+		byUser[e.UserID] = append(byUser[e.UserID], e.ID)
+	}
+	for i := range batch.Payments {
+		batch.Payments[i].ExpenseIDs = byUser[batch.Payments[i].UserID]
+		if batch.Payments[i].ExpenseIDs == nil {
+			batch.Payments[i].ExpenseIDs = []uint{}
+		}
+	}
+	return batch, nil
+}
+
+// CreateBatch gathers the approved, reimbursable expenses no batch pays yet
+// into a new draft batch with one payment per employee. Employees without a
+// bank account on file are left out and listed in MissingBankDetails.
+func (s *ReimbursementService) CreateBatch(p *auth.Principal, req models.CreateReimbursementBatchRequest) (*models.ReimbursementBatch, error) {
+	reference := strings.TrimSpace(req.Reference)
+	if len(reference) > maxBatchReference {
+		return nil, errors.New("reference is too long")
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	executionDate := today
+	if strings.TrimSpace(req.ExecutionDate) != "" {
+		day, err := parseRateDate(req.ExecutionDate)
+		if err != nil {
+			return nil, errors.New("invalid execution date")
+		}
+		if day.Before(today) {
+			return nil, errors.New("execution date is in the past")
+		}
+		executionDate = day
+	}
+
+	batch := &models.ReimbursementBatch{
+		Reference:     reference,
+		Status:        models.BatchDraft,
+		ExecutionDate: executionDate,
+		CreatedByID:   p.UserID,
+	}
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		currency, err := baseCurrency(tx, p.OrganizationID)
+		if err != nil {
+			return err
+		}
+		batch.Currency = currency
+
+		query := dueExpenses(tx)
+		if len(req.UserIDs) > 0 {
+			query = query.Where("user_id IN ?", req.UserIDs)
+		}
+		var expenses []models.Expense
+		if err := query.Select("id", "user_id", "base_amount_minor").Order("user_id, id").Find(&expenses).Error; err != nil {
+			return err
+		}
+		if len(expenses) == 0 {
+			return errors.New("no reimbursements are due")
+		}
+
+		userIDs := make([]uint, 0)
+		for _, e := range expenses {
+			if len(userIDs) == 0 || userIDs[len(userIDs)-1] != e.UserID {
+				userIDs = append(userIDs, e.UserID)
+			}
+		}
+		var accounts []models.BankAccount
+		if err := tx.Where("user_id IN ?", userIDs).Find(&accounts).Error; err != nil {
+			return err
+		}
+		accountOf := make(map[uint]models.BankAccount, len(accounts))
+		for _, a := range accounts {
+			accountOf[*a.UserID] = a
+		}
+
+		var payments []*models.ReimbursementPayment
+		paymentOf := make(map[uint]*models.ReimbursementPayment)
+		for _, e := range expenses {
+			account, ok := accountOf[e.UserID]
+			if !ok {
+				if n := len(batch.MissingBankDetails); n == 0 || batch.MissingBankDetails[n-1] != e.UserID {
+					batch.MissingBankDetails = append(batch.MissingBankDetails, e.UserID)
+				}
+				continue
+			}
+			payment := paymentOf[e.UserID]
+			if payment == nil {
+				// The payment keeps the details as they are now, still sealed to their owner
+				payment = &models.ReimbursementPayment{
+					UserID:           e.UserID,
+					Currency:         currency,
+					AccountLast4:     account.AccountLast4,
+					EncryptedDetails: account.EncryptedDetails,
+				}
+				paymentOf[e.UserID] = payment
+				payments = append(payments, payment)
+			}
+			payment.AmountMinor += e.BaseAmountMinor
+			payment.ExpenseIDs = append(payment.ExpenseIDs, e.ID)
+		}
+
+		// Credits can outweigh an employee's expenses; nothing is paid to them until they no longer do
+		kept := payments[:0]
+		for _, payment := range payments {
+			if payment.AmountMinor > 0 {
+				kept = append(kept, payment)
+				batch.TotalMinor += payment.AmountMinor
+			}
+		}
+		payments = kept
+		if len(payments) == 0 {
+			if len(batch.MissingBankDetails) > 0 {
+				return errors.New("no employee with reimbursements due has a bank account")
+			}
+			return errors.New("no reimbursements are due")
+		}
+
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		if batch.Reference == "" {
+			batch.Reference = fmt.Sprintf("REIMB-%d-%d", p.OrganizationID, batch.ID)
+			if err := tx.Model(batch).Update("reference", batch.Reference).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, payment := range payments {
+			payment.BatchID = batch.ID
+			payment.EndToEndID = fmt.Sprintf("REIMB-%d-%d", batch.ID, payment.UserID)
+			if err := tx.Create(payment).Error; err != nil {
+				return err
+			}
+			result := tx.Model(&models.Expense{}).
+				Where("id IN ? AND status = ? AND reimbursement_batch_id IS NULL", payment.ExpenseIDs, models.StatusApproved).
+				Updates(map[string]interface{}{
+					"reimbursement_batch_id": batch.ID,
+					"updated_at":             time.Now(),
+					"version":                gorm.Expr("version + 1"),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != int64(len(payment.ExpenseIDs)) {
+				return errors.New("expense was modified concurrently")
+			}
+			batch.Payments = append(batch.Payments, *payment)
+		}
+
+		return recordChange(tx, p, audit.Change{
+			Action: "reimbursement_batch.create", EntityType: "reimbursement_batch", EntityID: batch.ID,
+			After: batch,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// MarkBatchPaid records that a draft batch was sent to the bank, marking its expenses paid
+func (s *ReimbursementService) MarkBatchPaid(p *auth.Principal, id uint) (*models.ReimbursementBatch, error) {
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := advanceBatch(tx, p, id, models.BatchDraft, models.BatchPaid, map[string]interface{}{"paid_at": now}); err != nil {
+			return err
+		}
+		return tx.Model(&models.Expense{}).
+			Where("reimbursement_batch_id = ?", id).
+			Updates(map[string]interface{}{
+				"reimbursement": models.ReimbursementPaid,
+				"paid_at":       now,
+				"updated_at":    now,
+				"version":       gorm.Expr("version + 1"),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBatch(p, id)
+}
+
+// ReconcileBatch records that a paid batch's transfers arrived, moving each of
+// its expenses, and each expense report they complete, to reimbursed
+func (s *ReimbursementService) ReconcileBatch(p *auth.Principal, id uint) (*models.ReimbursementBatch, error) {
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		batch, err := findBatch(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := advanceBatch(tx, p, id, models.BatchPaid, models.BatchReconciled, map[string]interface{}{"reconciled_at": time.Now()}); err != nil {
+			return err
+		}
+
+		var expenses []models.Expense
+		if err := tx.Where("reimbursement_batch_id = ?", id).Order("id").Find(&expenses).Error; err != nil {
+			return err
+		}
+		comment := "Reconciled in reimbursement batch " + batch.Reference
+		reportIDs := make(map[uint]bool)
+		for _, expense := range expenses {
+			if currentStatus(expense) != models.StatusApproved {
+				return errors.New("expense was modified concurrently")
+			}
+			if err := advance(tx, p, expense, models.ActionReimburse, models.StatusReimbursed, 0, map[string]interface{}{}, comment); err != nil {
+				return err
+			}
+			if expense.ReportID != nil {
+				reportIDs[*expense.ReportID] = true
+			}
+		}
+		return settleReports(tx, p, reportIDs, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBatch(p, id)
+}
+
+// CancelBatch deletes a draft batch, so its expenses are due again
+func (s *ReimbursementService) CancelBatch(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		batch, err := findBatch(tx, id)
+		if err != nil {
+			return err
+		}
+		if batch.Status != models.BatchDraft {
+			return errors.New("reimbursement batch is not a draft")
+		}
+		if err := tx.Model(&models.Expense{}).
+			Where("reimbursement_batch_id = ?", id).
+			Updates(map[string]interface{}{
+				"reimbursement_batch_id": nil,
+				"updated_at":             time.Now(),
+				"version":                gorm.Expr("version + 1"),
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("batch_id = ?", id).Delete(&models.ReimbursementPayment{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("status = ?", models.BatchDraft).Delete(&models.ReimbursementBatch{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("reimbursement batch was modified concurrently")
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "reimbursement_batch.delete", EntityType: "reimbursement_batch", EntityID: batch.ID,
+			Before: batch,
+		})
+	})
+}
+
+// WriteBatchFile writes the payment file of a batch in format, paying from
+// the organization's bank account to the details each payment was created with
+func (s *ReimbursementService) WriteBatchFile(p *auth.Principal, id uint, format payout.Format, w io.Writer) error {
+	batch, err := s.GetBatch(p, id)
+	if err != nil {
+		return err
+	}
+
+	db := scoped(s.db, p)
+	account, err := findBankAccount(db, nil)
+	if err != nil {
+		if err.Error() == "bank account not found" {
+			return errors.New("organization bank account not found")
+		}
+		return err
+	}
+	payer, err := openBankDetails(account.EncryptedDetails, account.OrganizationID, nil)
+	if err != nil {
+		return err
+	}
+	var org models.Organization
+	if err := s.db.Select("id", "name").First(&org, p.OrganizationID).Error; err != nil {
+		return err
+	}
+	if payer.HolderName == "" {
+		payer.HolderName = org.Name
+	}
+
+	file := payout.Batch{
+		MessageID:     batch.Reference,
+		CreatedAt:     time.Now(),
+		ExecutionDate: batch.ExecutionDate,
+		Currency:      batch.Currency,
+		Payer:         payoutAccount(payer),
+	}
+	for _, payment := range batch.Payments {
+		payee, err := openBankDetails(payment.EncryptedDetails, payment.OrganizationID, &payment.UserID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case format == payout.FormatSEPA && payee.IBAN == "":
+			return fmt.Errorf("cannot export batch: user %d has no IBAN on file", payment.UserID)
+		case format == payout.FormatNACHA && payee.RoutingNumber == "":
+			return fmt.Errorf("cannot export batch: user %d has no routing number on file", payment.UserID)
+		}
+		file.Transfers = append(file.Transfers, payout.Transfer{
+			Payee:       payoutAccount(payee),
+			AmountMinor: payment.AmountMinor,
+			EndToEndID:  payment.EndToEndID,
+			PayeeID:     strconv.FormatUint(uint64(payment.UserID), 10),
+			Remittance:  "Expense reimbursement " + batch.Reference,
+		})
+	}
+
+	switch format {
+	case payout.FormatSEPA:
+		err = payout.WriteSEPA(w, file)
+	case payout.FormatNACHA:
+		err = payout.WriteNACHA(w, file)
+	default:
+		return errors.New("unknown payout format")
+	}
+	if err != nil {
+		return fmt.Errorf("cannot export batch: %w", err)
+	}
+	return nil
+}
+
+// dueExpenses selects approved, reimbursable expenses that no batch pays yet
+func dueExpenses(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Expense{}).
+		Where("status = ? AND reimbursement = ? AND reimbursement_batch_id IS NULL", models.StatusApproved, models.ReimbursementDue)
+}
+
+func findBatch(db *gorm.DB, id uint) (*models.ReimbursementBatch, error) {
+	var batch models.ReimbursementBatch
+	if err := db.First(&batch, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("reimbursement batch not found")
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// advanceBatch moves a batch from one status to the next and records it. The
+// update is guarded on the status read, so a batch cannot be paid or
+// reconciled twice.
+func advanceBatch(tx *gorm.DB, p *auth.Principal, id uint, from, to models.BatchStatus, updates map[string]interface{}) error {
+	batch, err := findBatch(tx, id)
+	if err != nil {
+		return err
+	}
+	if batch.Status != from {
+		if from == models.BatchDraft {
+			return errors.New("reimbursement batch is not a draft")
+		}
+		return errors.New("reimbursement batch is not " + string(from))
+	}
+
+	updates["status"] = to
+	updates["updated_at"] = time.Now()
+	result := tx.Model(&models.ReimbursementBatch{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("reimbursement batch was modified concurrently")
+	}
+
+	after, err := findBatch(tx, id)
+	if err != nil {
+		return err
+	}
+	return recordChange(tx, p, audit.Change{
+		Action: "reimbursement_batch." + string(to), EntityType: "reimbursement_batch", EntityID: id,
+		Before: batch, After: after,
+	})
+}
+
+// settleReports moves approved expense reports whose expenses have all been
+// reimbursed to reimbursed as well
+func settleReports(tx *gorm.DB, p *auth.Principal, reportIDs map[uint]bool, comment string) error {
+	ids := make([]uint, 0, len(reportIDs))
+	for id := range reportIDs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		var report models.ExpenseReport
+		if err := tx.First(&report, id).Error; err != nil {
+			return err
+		}
+		if report.Status != models.StatusApproved {
+			continue
+		}
+		var outstanding int64
+		if err := tx.Model(&models.Expense{}).Where("report_id = ? AND status <> ?", id, models.StatusReimbursed).Count(&outstanding).Error; err != nil {
+			return err
+		}
+		if outstanding > 0 {
+			continue
+		}
+
+		result := tx.Model(&models.ExpenseReport{}).
+			Where("id = ? AND status = ?", id, models.StatusApproved).
+			Updates(map[string]interface{}{"status": models.StatusReimbursed, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("expense report was modified concurrently")
+		}
+		if err := tx.Create(&models.ExpenseReportTransition{
+			ReportID:   id,
+			Action:     models.ActionReimburse,
+			FromStatus: models.StatusApproved,
+			ToStatus:   models.StatusReimbursed,
+			ActorID:    p.UserID,
+			Comment:    comment,
+		}).Error; err != nil {
+			return err
+		}
+
+		var after models.ExpenseReport
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		if err := recordChange(tx, p, audit.Change{
+			Action: "expense_report.reimburse", EntityType: "expense_report", EntityID: id,
+			Before: report, After: after,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func payoutAccount(details models.BankDetails) payout.Account {
+	return payout.Account{
+		Name:          details.HolderName,
+		IBAN:          details.IBAN,
+		BIC:           details.BIC,
+		RoutingNumber: details.RoutingNumber,
+		AccountNumber: details.AccountNumber,
+		Savings:       details.AccountType == "savings",
+		CompanyID:     details.CompanyID,
+	}
+}
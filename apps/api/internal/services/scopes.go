@@ -0,0 +1,58 @@
+package services
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+)
+
+// scoped returns a session restricted to the principal's organization
+func scoped(db *gorm.DB, p *auth.Principal) *gorm.DB {
+	return database.WithTenant(db, p.OrganizationID)
+}
+
+// visibleTo restricts expense queries to rows the principal may perform action on
+func visibleTo(p *auth.Principal, action authz.Action) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch authz.ScopeOf(p, action) {
+		case authz.Organization:
+			return db
+		case authz.Own:
+			return db.Where("expenses.user_id = ?", p.UserID)
+		default:
+			return db.Where("1 = 0")
+		}
+	}
+}
+
+// templatesVisibleTo restricts recurring expense queries to templates the
+// principal may perform action on, as visibleTo does for expenses
+func templatesVisibleTo(p *auth.Principal, action authz.Action) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch authz.ScopeOf(p, action) {
+		case authz.Organization:
+			return db
+		case authz.Own:
+			return db.Where("recurring_expenses.user_id = ?", p.UserID)
+		default:
+			return db.Where("1 = 0")
+		}
+	}
+}
+
+// reportsVisibleTo restricts expense report queries to reports the principal
+// may perform action on, as visibleTo does for expenses
+func reportsVisibleTo(p *auth.Principal, action authz.Action) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch authz.ScopeOf(p, action) {
+		case authz.Organization:
+			return db
+		case authz.Own:
+			return db.Where("expense_reports.user_id = ?", p.UserID)
+		default:
+			return db.Where("1 = 0")
+		}
+	}
+}
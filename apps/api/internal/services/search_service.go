@@ -0,0 +1,88 @@
+package services
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/search"
+)
+
+type SearchService struct {
+	db *gorm.DB
+}
+
+func NewSearchService() *SearchService {
+	return &SearchService{
+		db: database.GetDB(),
+	}
+}
+
+// SearchExpenses ranks the expenses visible to the principal against free text
+func (s *SearchService) SearchExpenses(p *auth.Principal, text string, skip, limit int) (*models.ExpenseSearchResponse, error) {
+	backend := search.GetBackend()
+	response := &models.ExpenseSearchResponse{
+		Query:   text,
+		Backend: backend.Name(),
+		Results: []models.ExpenseSearchResult{},
+		Skip:    skip,
+		Limit:   limit,
+	}
+
+	db := scoped(s.db, p)
+	query := db.Model(&models.Expense{}).Scopes(visibleTo(p, authz.ExpenseRead))
+	hits, total, err := backend.Search(query, search.Terms(text), limit, skip)
+	if err != nil {
+		return nil, err
+	}
+	response.Total = total
+	if len(hits) == 0 {
+		return response, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ExpenseID
+	}
+	var expenses []models.Expense
+	if err := db.Scopes(withDetails).Where("id IN ?", ids).Find(&expenses).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Expense, len(expenses))
+	for _, e := range expenses {
+		byID[e.ID] = e
+	}
+
+	for _, hit := range hits {
+		if expense, ok := byID[hit.ExpenseID]; ok {
+			response.Results = append(response.Results, models.ExpenseSearchResult{
+				Expense: expense,
+				Score:   hit.Score,
+				Snippet: hit.Snippet,
+			})
+		}
+	}
+	return response, nil
+}
+
+// indexExpense refreshes an expense's entry in the search index from its
+// current text and attachment filenames
+func indexExpense(db *gorm.DB, expenseID uint) error {
+	var expense models.Expense
+	if err := db.Preload("Attachments").First(&expense, expenseID).Error; err != nil {
+		return err
+	}
+
+	doc := search.Document{
+		ExpenseID:   expense.ID,
+		Description: expense.Description,
+		ClientNotes: expense.ClientNotes,
+		Category:    expense.Category,
+	}
+	for _, a := range expense.Attachments {
+		doc.Filenames = append(doc.Filenames, a.Filename)
+	}
+	return search.GetBackend().Index(db, doc)
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/importing"
+	"github.com/example/next-go-monorepo/apps/api/internal/matching"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+)
+
+// externalIDBatch bounds the IN lists used to look up already imported transactions
+const externalIDBatch = 500
+
+// statementLine is a debit from a statement waiting to be saved as an expense
+type statementLine struct {
+	expense    *models.Expense
+	suggestion *models.AISuggestion
+	// merchant is unsaved when the descriptor names one the organization does not know yet
+	merchant *models.Merchant
+}
+
+// ImportStatement creates draft expenses owned by the principal from the
+// debits on a bank or card statement. Each expense keeps the transaction's
+// external ID, so lines already imported, including from an overlapping
+// statement, are skipped. Descriptors are normalized to merchants, creating
+// the ones not known yet. Categories come from the AI categorizer, whose
+// suggestion is stored for review.
+func (s *ImportService) ImportStatement(p *auth.Principal, filename string, data []byte, opts models.StatementImportOptions) (*models.StatementImportResponse, error) {
+	format := importing.Format(strings.ToLower(strings.TrimSpace(opts.Format)))
+	switch format {
+	case "":
+		var err error
+		if format, err = importing.DetectStatementFormat(filename, data); err != nil {
+			return nil, err
+		}
+	case "qfx":
+		format = importing.FormatOFX
+	case importing.FormatOFX, importing.FormatCAMT, importing.FormatMT940:
+	default:
+		return nil, errors.New("invalid statement format")
+	}
+
+	transactions, err := importing.ParseStatement(format, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) > maxImportRows {
+		return nil, fmt.Errorf("too many transactions, at most %d per import", maxImportRows)
+	}
+
+	response := &models.StatementImportResponse{
+		DryRun:       opts.DryRun,
+		Format:       string(format),
+		Transactions: len(transactions),
+		Errors:       []models.ImportRowError{},
+		Expenses:     []models.Expense{},
+	}
+
+	ids := importing.ExternalIDs(transactions)
+
+	db := scoped(s.db, p)
+	imported, err := existingExternalIDs(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []statementLine
+	for i, t := range transactions {
+		if !t.Debit {
+			response.Skipped++
+			continue
+		}
+		if imported[ids[i]] {
+			response.Duplicates++
+			continue
+		}
+		imported[ids[i]] = true
+
+		line, rowError, err := s.statementExpense(db, p, t, ids[i], i+1)
+		if err != nil {
+			return nil, err
+		}
+		if rowError != nil {
+			response.Errors = append(response.Errors, *rowError)
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if !opts.DryRun && len(lines) > 0 {
+		var saved []statementLine
+		err := db.Transaction(func(tx *gorm.DB) error {
+			saved = saved[:0]
+			for _, line := range lines {
+				ok, err := saveStatementLine(tx, p, line)
+				if err != nil {
+					return err
+				}
+				if ok {
+					saved = append(saved, line)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Lines imported concurrently by another request are duplicates too
+		response.Duplicates += len(lines) - len(saved)
+		response.Imported = len(saved)
+		lines = saved
+	}
+
+	// Receipts uploaded ahead of the statement can now find their transactions
+	if response.Imported > 0 {
+		result, err := s.receipts.MatchReceipts(p)
+		if err != nil {
+			log.Printf("Receipt matching after statement import failed: %v", err)
+		} else {
+			response.ReceiptsLinked = result.AutoLinked
+		}
+	}
+
+	for _, line := range lines {
+		line.expense.Merchant = line.merchant
+		response.Expenses = append(response.Expenses, *line.expense)
+		if line.expense.Category != "" {
+			response.Categorized++
+		}
+	}
+	return response, nil
+}
+
+// statementExpense builds the draft expense for a debit. Problems with the
+// line's values are returned as a row error; err is reserved for failures
+// that should abort the import.
+func (s *ImportService) statementExpense(db *gorm.DB, p *auth.Principal, t importing.Transaction, externalID string, row int) (statementLine, *models.ImportRowError, error) {
+	description := t.Description
+	if description == "" {
+		description = "Statement transaction"
+	}
+
+	now := time.Now()
+	expense := &models.Expense{
+		OrganizationID:   p.OrganizationID,
+		UserID:           p.UserID,
+		ExternalID:       &externalID,
+		Status:           models.StatusDraft,
+		Version:          1,
+		Type:             models.ExpenseStandard,
+		Description:      description,
+		Currency:         t.Currency,
+		Date:             t.Date,
+		Attendees:        1,
+		Reimbursement:    models.ReimbursementDue,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Attachments:      []models.Attachment{},
+		AISuggestions:    []models.AISuggestion{},
+		PolicyViolations: []models.PolicyViolation{},
+		Tags:             []models.Tag{},
+		Allocations:      []models.ExpenseAllocation{},
+	}
+
+	if err := setAmount(db, p.OrganizationID, expense, t.Amount); err != nil {
+		field, message, ok := amountProblem(err, string(t.Amount), t.Currency)
+		if !ok {
+			return statementLine{}, nil, err
+		}
+		return statementLine{}, &models.ImportRowError{Row: row, Field: field, Message: message}, nil
+	}
+
+	merchant, err := matchMerchant(db, t.Description)
+	if err != nil {
+		return statementLine{}, nil, err
+	}
+	if merchant == nil {
+		if name := matching.MerchantName(t.Description); name != "" {
+			if merchant, err = namedMerchant(db, name); err != nil {
+				return statementLine{}, nil, err
+			}
+		}
+	}
+	request := models.AISuggestRequest{
+		Description: description,
+		Amount:      money.Float(expense.AmountMinor, expense.Currency),
+	}
+	if merchant != nil && merchant.ID != 0 {
+		expense.MerchantID = &merchant.ID
+		request.MerchantID = &merchant.ID
+	}
+
+	suggestion, err := s.ai.GetAISuggestion(p, request)
+	if err != nil {
+		return statementLine{}, nil, err
+	}
+	expense.Category = suggestion.Category
+	if err := applyMerchantDefaults(db, expense, merchant); err != nil {
+		return statementLine{}, nil, err
+	}
+
+	return statementLine{
+		expense: expense,
+		suggestion: &models.AISuggestion{
+			OrganizationID:    p.OrganizationID,
+			SuggestedCategory: suggestion.Category,
+			SuggestedNotes:    suggestion.ClientNotes,
+			CreatedAt:         now,
+			ModelUsed:         "rule-based-v1",
+		},
+		merchant: merchant,
+	}, nil, nil
+}
+
+// saveStatementLine creates the expense and its suggestion. It reports false,
+// saving nothing, when an expense with the same external ID already exists.
+func saveStatementLine(tx *gorm.DB, p *auth.Principal, line statementLine) (bool, error) {
+	if line.merchant != nil {
+		if err := saveMerchant(tx, p, line.merchant); err != nil {
+			return false, err
+		}
+		line.expense.MerchantID = &line.merchant.ID
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(line.expense)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	line.suggestion.ExpenseID = line.expense.ID
+	if err := tx.Create(line.suggestion).Error; err != nil {
+		return false, err
+	}
+	line.expense.AISuggestions = []models.AISuggestion{*line.suggestion}
+
+	if err := recordChange(tx, p, audit.Change{
+		Action: "expense.import", EntityType: "expense", EntityID: line.expense.ID, ExpenseID: line.expense.ID,
+		After: line.expense,
+	}); err != nil {
+		return false, err
+	}
+	return true, indexExpense(tx, line.expense.ID)
+}
+
+// existingExternalIDs returns which of ids already belong to expenses of the
+// organization, including deleted ones
+func existingExternalIDs(db *gorm.DB, ids []string) (map[string]bool, error) {
+	found := make(map[string]bool, len(ids))
+	for start := 0; start < len(ids); start += externalIDBatch {
+		end := start + externalIDBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		var existing []string
+		err := db.Unscoped().Model(&models.Expense{}).
+			Where("external_id IN ?", ids[start:end]).
+			Pluck("external_id", &existing).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range existing {
+			found[id] = true
+		}
+	}
+	return found, nil
+}
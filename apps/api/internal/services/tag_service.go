@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// maxTagLength bounds tag names so they stay usable as labels
+const maxTagLength = 50
+
+type TagService struct {
+	db *gorm.DB
+}
+
+func NewTagService() *TagService {
+	return &TagService{
+		db: database.GetDB(),
+	}
+}
+
+// ListTags returns the organization's tags by name
+func (s *TagService) ListTags(p *auth.Principal) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if err := scoped(s.db, p).Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// RenameTag renames a tag on every expense carrying it
+func (s *TagService) RenameTag(p *auth.Principal, id uint, name string) (*models.Tag, error) {
+	var tag models.Tag
+
+	err := scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tag, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("tag not found")
+			}
+			return err
+		}
+		before := tag
+
+		name, err := tagName(name)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Tag{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, tag.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("tag already exists")
+		}
+		tag.Name = name
+
+		if err := tx.Save(&tag).Error; err != nil {
+			return err
+		}
+		if err := reindexTagged(tx, tag.ID); err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "tag.update", EntityType: "tag", EntityID: tag.ID,
+			Before: before, After: tag,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// DeleteTag removes a tag from every expense and deletes it
+func (s *TagService) DeleteTag(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.First(&tag, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("tag not found")
+			}
+			return err
+		}
+
+		var ids []uint
+		if err := tx.Table("expense_tags").Where("tag_id = ?", tag.ID).Pluck("expense_id", &ids).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM expense_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&tag).Error; err != nil {
+			return err
+		}
+		for _, expenseID := range ids {
+			if err := touchTagged(tx, expenseID); err != nil {
+				return err
+			}
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "tag.delete", EntityType: "tag", EntityID: tag.ID,
+			Before: tag,
+		})
+	})
+}
+
+// resolveTags finds or creates the named tags. Names are trimmed and matched
+// ignoring case, so repeats collapse to one tag.
+func resolveTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	seen := make(map[string]bool, len(names))
+	for _, raw := range names {
+		name, err := tagName(raw)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var tag models.Tag
+		err = tx.Where("LOWER(name) = ?", key).First(&tag).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tag = models.Tag{Name: name}
+			err = tx.Create(&tag).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// tagName validates a tag name
+func tagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxTagLength {
+		return "", errors.New("invalid tag")
+	}
+	return name, nil
+}
+
+// reindexTagged bumps and reindexes the live expenses carrying a tag
+func reindexTagged(tx *gorm.DB, tagID uint) error {
+	var ids []uint
+	if err := tx.Table("expense_tags").Where("tag_id = ?", tagID).Pluck("expense_id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := touchTagged(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchTagged bumps an expense's version after its tags change under it and
+// reindexes it unless it is in the trash
+func touchTagged(tx *gorm.DB, expenseID uint) error {
+	err := tx.Unscoped().Model(&models.Expense{}).Where("id = ?", expenseID).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error
+	if err != nil {
+		return err
+	}
+	var count int64
+	if err := tx.Model(&models.Expense{}).Where("id = ?", expenseID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return indexExpense(tx, expenseID)
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+	"github.com/example/next-go-monorepo/apps/api/internal/money"
+	"github.com/example/next-go-monorepo/apps/api/internal/reporting"
+)
+
+// defaultTaxCode is charged when neither the expense nor its category names a tax code
+const defaultTaxCode = "standard"
+
+// TaxService manages the tax rate tables expenses' tax is computed from and
+// reports the tax that can be reclaimed
+type TaxService struct {
+	db *gorm.DB
+}
+
+func NewTaxService() *TaxService {
+	return &TaxService{
+		db: database.GetDB(),
+	}
+}
+
+// ListTaxRates returns the organization's tax rates, newest first
+func (s *TaxService) ListTaxRates(p *auth.Principal) ([]models.TaxRate, error) {
+	rates := []models.TaxRate{}
+	if err := scoped(s.db, p).Order("effective_from DESC, country, code").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// CreateTaxRate records a tax rate, replacing any existing rate for the same
+// country, code and effective date
+func (s *TaxService) CreateTaxRate(p *auth.Principal, req models.CreateTaxRateRequest) (*models.TaxRate, error) {
+	effectiveFrom, err := parseRateDate(req.EffectiveFrom)
+	if err != nil {
+		return nil, errors.New("invalid effective date")
+	}
+	country, err := normalizeCountry(req.Country)
+	if err != nil || country == "" {
+		return nil, errors.New("invalid country")
+	}
+	code := normalizeTaxCode(req.Code)
+	if code == "" {
+		code = defaultTaxCode
+	}
+	rate, err := parseTaxRate(req.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	row := models.TaxRate{
+		Country:       country,
+		Code:          code,
+		EffectiveFrom: effectiveFrom,
+		Rate:          money.FormatRate(rate),
+	}
+	err = scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		change := audit.Change{Action: "tax_rate.create", EntityType: "tax_rate"}
+		var existing models.TaxRate
+		err := tx.Where("country = ? AND code = ? AND effective_from = ?", country, code, effectiveFrom).First(&existing).Error
+		if err == nil {
+			change.Action, change.Before = "tax_rate.update", existing
+			row.ID, row.CreatedAt = existing.ID, existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Save(&row).Error; err != nil {
+			return err
+		}
+		change.EntityID, change.After = row.ID, row
+		return recordChange(tx, p, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// DeleteTaxRate removes a tax rate. Expenses already taxed with it keep their
+// rate and tax amount.
+func (s *TaxService) DeleteTaxRate(p *auth.Principal, id uint) error {
+	return scoped(s.db, p).Transaction(func(tx *gorm.DB) error {
+		var rate models.TaxRate
+		if err := tx.First(&rate, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("rate not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&rate).Error; err != nil {
+			return err
+		}
+		return recordChange(tx, p, audit.Change{
+			Action: "tax_rate.delete", EntityType: "tax_rate", EntityID: rate.ID,
+			Before: rate,
+		})
+	})
+}
+
+// WriteVATReclaimReport writes the tax included in the organization's approved
+// and reimbursed expenses dated within from and to, either of which may be
+// nil, grouped by country and rate
+func (s *TaxService) WriteVATReclaimReport(p *auth.Principal, from, to *time.Time, format reporting.ExportFormat, w io.Writer) error {
+	query := scoped(s.db, p).
+		Where("tax_amount_minor > 0 AND tax_jurisdiction <> ''").
+		Where("status IN ?", []models.ExpenseStatus{models.StatusApproved, models.StatusReimbursed})
+	if from != nil {
+		query = query.Where("date >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("date <= ?", *to)
+	}
+	var expenses []models.Expense
+	if err := query.Order("date, id").Find(&expenses).Error; err != nil {
+		return err
+	}
+
+	report := reporting.VATReclaimReport{Title: "VAT Reclaim Report"}
+	if from != nil {
+		report.StartDate = *from
+	}
+	if to != nil {
+		report.EndDate = *to
+	}
+	for _, e := range expenses {
+		report.Lines = append(report.Lines, reporting.VATLine{
+			Date:        e.Date,
+			ExpenseID:   e.ID,
+			Description: e.Description,
+			Category:    e.Category,
+			Country:     e.TaxJurisdiction,
+			Rate:        e.TaxRate,
+			Currency:    e.Currency,
+			NetMinor:    e.NetAmountMinor,
+			TaxMinor:    e.TaxAmountMinor,
+			VATNumber:   e.MerchantVATNumber,
+		})
+	}
+	return reporting.WriteVATReclaimReport(w, report, format)
+}
+
+// taxDetails are what an expense's tax is worked out from. An empty rate is
+// looked up in the tax rate table and an empty amount computed from the rate.
+type taxDetails struct {
+	jurisdiction string
+	code         string
+	rate         money.Decimal
+	amount       money.Decimal
+}
+
+// applyTax sets the tax included in the expense's amount. Without a
+// jurisdiction the expense carries no tax.
+func applyTax(db *gorm.DB, expense *models.Expense, t taxDetails) error {
+	country, err := normalizeCountry(t.jurisdiction)
+	if err != nil {
+		return errors.New("invalid tax jurisdiction")
+	}
+	if country == "" {
+		if t.rate != "" || t.amount != "" {
+			return errors.New("tax jurisdiction is required")
+		}
+		expense.TaxJurisdiction, expense.TaxCode, expense.TaxRate, expense.TaxRateID = "", "", "", nil
+		expense.TaxAmountMinor, expense.TaxAmount = 0, money.FromMinor(0, expense.Currency)
+		expense.NetAmountMinor, expense.NetAmount = expense.AmountMinor, expense.Amount
+		return nil
+	}
+
+	code := normalizeTaxCode(t.code)
+	if code == "" && expense.Category != "" {
+		var category models.Category
+		err := db.Select("tax_code").Where("name = ?", expense.Category).First(&category).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		code = normalizeTaxCode(category.TaxCode)
+	}
+	if code == "" {
+		code = defaultTaxCode
+	}
+
+	var rate *big.Rat
+	var rateID *uint
+	if t.rate != "" {
+		if rate, err = parseTaxRate(t.rate); err != nil {
+			return err
+		}
+	} else {
+		var row models.TaxRate
+		err := db.Where("country = ? AND code = ? AND effective_from <= ?", country, code, rateDate(expense.Date)).
+			Order("effective_from DESC").First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("no tax rate")
+		}
+		if err != nil {
+			return err
+		}
+		if rate, err = parseTaxRate(money.Decimal(row.Rate)); err != nil {
+			return err
+		}
+		rateID = &row.ID
+	}
+
+	// The amount is gross, so the tax is rate/(100+rate) of it
+	var tax int64
+	if t.amount != "" {
+		tax, err = t.amount.Minor(expense.Currency)
+		if err != nil || tax < 0 || tax >= expense.AmountMinor {
+			return errors.New("invalid tax amount")
+		}
+	} else {
+		share := new(big.Rat).Quo(rate, new(big.Rat).Add(rate, big.NewRat(100, 1)))
+		if tax, err = money.Convert(expense.AmountMinor, expense.Currency, expense.Currency, share); err != nil {
+			return err
+		}
+	}
+
+	expense.TaxJurisdiction = country
+	expense.TaxCode = code
+	expense.TaxRate = money.FormatRate(rate)
+	expense.TaxRateID = rateID
+	expense.TaxAmountMinor = tax
+	expense.TaxAmount = money.FromMinor(tax, expense.Currency)
+	expense.NetAmountMinor = expense.AmountMinor - tax
+	expense.NetAmount = money.FromMinor(expense.NetAmountMinor, expense.Currency)
+	return nil
+}
+
+// parseTaxRate parses a percent such as "19" or "5.5"; zero-rated supplies
+// have a rate of 0
+func parseTaxRate(value money.Decimal) (*big.Rat, error) {
+	text := strings.TrimSpace(string(value))
+	rate, ok := new(big.Rat).SetString(text)
+	if !ok || strings.ContainsAny(text, "eE/") || rate.Sign() < 0 || rate.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, errors.New("invalid tax rate")
+	}
+	return rate, nil
+}
+
+// normalizeTaxCode lower-cases a tax code so "Reduced" and "reduced" are the same rate
+func normalizeTaxCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// normalizeVATNumber upper-cases a VAT registration number and drops the
+// spaces, dots and dashes it is often written with
+func normalizeVATNumber(number string) (string, error) {
+	number = strings.ToUpper(strings.NewReplacer(" ", "", ".", "", "-", "").Replace(number))
+	if len(number) > 32 {
+		return "", errors.New("invalid VAT number")
+	}
+	for _, r := range number {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return "", errors.New("invalid VAT number")
+		}
+	}
+	return number, nil
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/audit"
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/authz"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+// DefaultTrashRetention is how long deleted expenses and attachments stay restorable
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// purgeBatchSize bounds how many expenses one purge transaction removes
+const purgeBatchSize = 100
+
+var trashRetention = DefaultTrashRetention
+
+// SetTrashRetention sets how long deleted records are kept before the purge
+// job removes them for good. It must be called before serving requests.
+func SetTrashRetention(retention time.Duration) {
+	if retention > 0 {
+		trashRetention = retention
+	}
+}
+
+type TrashService struct {
+	db          *gorm.DB
+	attachments *AttachmentService
+}
+
+func NewTrashService() *TrashService {
+	return &TrashService{
+		db:          database.GetDB(),
+		attachments: NewAttachmentService(),
+	}
+}
+
+// ListTrash returns the most recently deleted expenses and attachments visible to the principal
+func (s *TrashService) ListTrash(p *auth.Principal, limit int) (*models.TrashResponse, error) {
+	db := scoped(s.db, p)
+	response := &models.TrashResponse{
+		Expenses:      []models.Expense{},
+		Attachments:   []models.Attachment{},
+		RetentionDays: int(trashRetention / (24 * time.Hour)),
+	}
+
+	err := db.Unscoped().Scopes(visibleTo(p, authz.TrashRead)).
+		Where("expenses.deleted_at IS NOT NULL").
+		Preload("Attachments", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Preload("AISuggestions").Preload("PolicyViolations").
+		Preload("Project").Preload("CostCenter").Preload("Merchant").Preload("Tags").Scopes(withAllocations).
+		Order("expenses.deleted_at DESC, expenses.id DESC").
+		Limit(limit).
+		Find(&response.Expenses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Attachments removed from expenses that are still live
+	err = db.Unscoped().Model(&models.Attachment{}).
+		Joins("JOIN expenses ON expenses.id = attachments.expense_id AND expenses.deleted_at IS NULL").
+		Scopes(visibleTo(p, authz.TrashRead)).
+		Where("attachments.deleted_at IS NOT NULL").
+		Order("attachments.deleted_at DESC, attachments.id DESC").
+		Limit(limit).
+		Find(&response.Attachments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// RestoreExpense brings a deleted expense back along with the attachments deleted with it
+func (s *TrashService) RestoreExpense(p *auth.Principal, id uint) (*models.Expense, error) {
+	db := scoped(s.db, p)
+
+	var expense models.Expense
+	err := db.Unscoped().Scopes(visibleTo(p, authz.TrashRead)).
+		Where("expenses.deleted_at IS NOT NULL").
+		First(&expense, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("expense not found")
+		}
+		return nil, err
+	}
+
+	if !authz.AllowedOn(p, authz.ExpenseDelete, expense.UserID) {
+		return nil, errors.New("permission denied")
+	}
+
+	// Attachments deleted on their own before the expense stay in the trash
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&models.Attachment{}).
+			Where("expense_id = ? AND deleted_at >= ?", expense.ID, expense.DeletedAt.Time).
+			UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&expense).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		expense.DeletedAt = gorm.DeletedAt{}
+		if err := recordChange(tx, p, audit.Change{
+			Action: "expense.restore", EntityType: "expense", EntityID: expense.ID, ExpenseID: expense.ID,
+			After: expense,
+		}); err != nil {
+			return err
+		}
+		if err := touchExpense(tx, expense.ID); err != nil {
+			return err
+		}
+		return indexExpense(tx, expense.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Scopes(withDetails).First(&expense, expense.ID).Error; err != nil {
+		return nil, err
+	}
+	return &expense, nil
+}
+
+// RestoreAttachment brings back an attachment deleted from a live expense
+func (s *TrashService) RestoreAttachment(p *auth.Principal, expenseID, attachmentID uint) (*models.Attachment, error) {
+	db := scoped(s.db, p)
+
+	var attachment models.Attachment
+	err := db.Unscoped().Model(&models.Attachment{}).
+		Joins("JOIN expenses ON expenses.id = attachments.expense_id AND expenses.deleted_at IS NULL").
+		Scopes(visibleTo(p, authz.TrashRead)).
+		Where("attachments.expense_id = ? AND attachments.id = ? AND attachments.deleted_at IS NOT NULL", expenseID, attachmentID).
+		First(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("attachment not found")
+		}
+		return nil, err
+	}
+
+	var expense models.Expense
+	if err := db.First(&expense, attachment.ExpenseID).Error; err != nil {
+		return nil, err
+	}
+	if !authz.AllowedOn(p, authz.AttachmentDelete, expense.UserID) {
+		return nil, errors.New("permission denied")
+	}
+	if !expense.Editable() {
+		return nil, errors.New("expense is locked")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&attachment).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		attachment.DeletedAt = gorm.DeletedAt{}
+		if err := recordChange(tx, p, audit.Change{
+			Action: "attachment.restore", EntityType: "attachment", EntityID: attachment.ID, ExpenseID: expense.ID,
+			After: attachment,
+		}); err != nil {
+			return err
+		}
+		if err := touchExpense(tx, expense.ID); err != nil {
+			return err
+		}
+		return indexExpense(tx, expense.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// Purge permanently deletes expenses and attachments that have been in the
+// trash longer than the retention period, including their stored files
+func (s *TrashService) Purge() (expenses, attachments int, err error) {
+	cutoff := time.Now().Add(-trashRetention)
+	db := database.AcrossTenants(s.db)
+
+	for {
+		var batch []models.Expense
+		err := db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Preload("Attachments", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+			Order("id").
+			Limit(purgeBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return expenses, attachments, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		ids := make([]uint, len(batch))
+		for i, e := range batch {
+			ids[i] = e.ID
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&models.Attachment{}, &models.AISuggestion{}, &models.ExpenseTransition{}, &models.ExpenseAllocation{}, &models.PolicyViolation{}} {
+				if err := tx.Unscoped().Where("expense_id IN ?", ids).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("DELETE FROM expense_tags WHERE expense_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("expense_id IN ?", ids).Delete(&models.ReceiptMatch{}).Error; err != nil {
+				return err
+			}
+			// A pair goes when either of its expenses does
+			if err := tx.Where("expense_id IN ? OR duplicate_of_id IN ?", ids, ids).Delete(&models.DuplicatePair{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Expense{}).Error; err != nil {
+				return err
+			}
+			for i := range batch {
+				if err := audit.Record(tx, audit.Change{
+					OrganizationID: batch[i].OrganizationID,
+					Action:         "expense.purge", EntityType: "expense", EntityID: batch[i].ID, ExpenseID: batch[i].ID,
+					Before: batch[i],
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return expenses, attachments, err
+		}
+
+		// Files go only once their rows are gone, so a failed purge never leaves dangling rows
+		for _, e := range batch {
+			for _, a := range e.Attachments {
+				s.attachments.cleanupFile(a.FilePath, a.StorageType)
+				attachments++
+			}
+		}
+		expenses += len(batch)
+
+		if len(batch) < purgeBatchSize {
+			break
+		}
+	}
+
+	var batch []models.Attachment
+	if err := db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&batch).Error; err != nil {
+		return expenses, attachments, err
+	}
+	for _, a := range batch {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("attachment_id = ?", a.ID).Delete(&models.ReceiptMatch{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&a).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, audit.Change{
+				OrganizationID: a.OrganizationID,
+				Action:         "attachment.purge", EntityType: "attachment", EntityID: a.ID, ExpenseID: a.ExpenseID,
+				Before: a,
+			})
+		})
+		if err != nil {
+			return expenses, attachments, err
+		}
+		s.attachments.cleanupFile(a.FilePath, a.StorageType)
+		attachments++
+	}
+
+	return expenses, attachments, nil
+}
+
+// RunPurge purges expired trash now and then every interval; it blocks, so run it in a goroutine
+func (s *TrashService) RunPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expenses, attachments, err := s.Purge()
+		if err != nil {
+			log.Printf("Trash purge failed: %v", err)
+		} else if expenses > 0 || attachments > 0 {
+			log.Printf("Trash purge removed %d expenses and %d attachments", expenses, attachments)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/example/next-go-monorepo/apps/api/internal/auth"
+	"github.com/example/next-go-monorepo/apps/api/internal/database"
+	"github.com/example/next-go-monorepo/apps/api/internal/models"
+)
+
+type UserService struct {
+	db *gorm.DB
+}
+
+func NewUserService() *UserService {
+	return &UserService{
+		db: database.GetDB(),
+	}
+}
+
+// ResolvePrincipal finds or provisions the user for verified token claims and
+// resolves their membership in the requested organization. An organizationID
+// of zero selects the user's oldest membership.
+func (s *UserService) ResolvePrincipal(claims *auth.Claims, organizationID uint) (*auth.Principal, error) {
+	var principal *auth.Principal
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user, err := s.syncUser(tx, claims)
+		if err != nil {
+			return err
+		}
+
+		membership, err := s.membership(tx, user, organizationID)
+		if err != nil {
+			return err
+		}
+
+		principal = &auth.Principal{
+			UserID:         user.ID,
+			Subject:        user.Subject,
+			Email:          user.Email,
+			OrganizationID: membership.OrganizationID,
+			Role:           membership.Role,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+// syncUser loads the user for the token subject, creating or refreshing it as needed
+func (s *UserService) syncUser(tx *gorm.DB, claims *auth.Claims) (*models.User, error) {
+	var user models.User
+
+	err := tx.Where("subject = ?", claims.Subject).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user = models.User{
+			Subject: claims.Subject,
+			Email:   claims.Email,
+			Name:    claims.Name,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		// Keep profile fields in sync with the identity provider
+		changed := false
+		if claims.Email != "" && claims.Email != user.Email {
+			user.Email = claims.Email
+			changed = true
+		}
+		if claims.Name != "" && claims.Name != user.Name {
+			user.Name = claims.Name
+			changed = true
+		}
+		if changed {
+			if err := tx.Save(&user).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &user, nil
+}
+
+// membership picks the membership the request acts under
+func (s *UserService) membership(tx *gorm.DB, user *models.User, organizationID uint) (*models.Membership, error) {
+	var membership models.Membership
+
+	query := tx.Where("user_id = ?", user.ID)
+	if organizationID != 0 {
+		query = query.Where("organization_id = ?", organizationID)
+	}
+
+	err := query.Order("created_at, id").First(&membership).Error
+	switch {
+	case err == nil:
+		return &membership, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	case organizationID != 0:
+		return nil, auth.ErrNotMember
+	}
+
+	// Users without any organization get a personal one so they can start right away
+	return provisionPersonalOrganization(tx, user)
+}
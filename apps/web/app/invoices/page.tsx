@@ -1,7 +1,7 @@
 "use client";
 
 import { useState, useEffect, useMemo } from "react";
-import { getExpenses, ApiClientError, type Expense as ApiExpense } from "@/lib/api";
+import { getExpenses, ApiClientError, type Expense } from "@/lib/api";
 import {
   Card,
   CardContent,
@@ -21,10 +21,6 @@ import {
 import { createTranslator } from "@/i18n";
 import { useToast } from "@/hooks/useToast";
 
-type Expense = ApiExpense & {
-  status?: "paid" | "pending" | "overdue";
-};
-
 type SortOption = "date-desc" | "date-asc" | "amount-desc" | "amount-asc" | "description";
 
 export default function InvoicesPage() {
@@ -46,12 +42,7 @@ export default function InvoicesPage() {
       try {
         setLoading(true);
         const data = await getExpenses({ limit: 100 });
-        // Add mock status for demo purposes
-        const expensesWithStatus = data.map((expense: Expense) => ({
-          ...expense,
-          status: expense.status || (Math.random() > 0.5 ? "paid" : "pending"),
-        }));
-        setExpenses(expensesWithStatus);
+        setExpenses(data);
         setError(null);
       } catch (err) {
         const errorMessage =
@@ -397,7 +388,10 @@ export default function InvoicesPage() {
                     <div className="flex items-center justify-between">
                       <span className="text-sm text-muted-foreground">{t("invoices.amount")}</span>
                       <span className="text-lg font-semibold text-primary">
-                        ${expense.amount.toFixed(2)}
+                        {new Intl.NumberFormat("en", {
+                          style: "currency",
+                          currency: expense.currency,
+                        }).format(expense.amount)}
                       </span>
                     </div>
                     <div className="flex items-center justify-between gap-2">
@@ -409,11 +403,13 @@ export default function InvoicesPage() {
                       {expense.status && (
                         <Badge
                           variant={
-                            expense.status === "paid"
+                            expense.status === "approved" || expense.status === "reimbursed"
                               ? "success"
-                              : expense.status === "overdue"
+                              : expense.status === "rejected"
                                 ? "error"
-                                : "warning"
+                                : expense.status === "draft"
+                                  ? "default"
+                                  : "warning"
                           }
                           size="sm"
                         >
@@ -3,6 +3,7 @@ import type {
   HealthResponse,
   HelloResponse,
   Expense,
+  ExpenseListResponse,
   CreateExpenseRequest,
   UpdateExpenseRequest,
   CategoriesResponse,
@@ -30,6 +31,65 @@ export class ApiClientError extends Error {
   }
 }
 
+/**
+ * Credentials sent with every API request. The API requires a bearer token;
+ * organizationId picks the organization to act in and defaults to the user's
+ * first one when left out.
+ */
+export interface ApiCredentials {
+  token: string;
+  organizationId?: number;
+}
+
+const CREDENTIALS_STORAGE_KEY = "api-credentials";
+
+let credentials: ApiCredentials | null = null;
+
+/**
+ * Set or clear the credentials used by the API client. In the browser they are
+ * kept in local storage so they survive a page reload.
+ */
+export function setApiCredentials(value: ApiCredentials | null): void {
+  credentials = value;
+  if (typeof window === "undefined") return;
+  if (value) {
+    window.localStorage.setItem(CREDENTIALS_STORAGE_KEY, JSON.stringify(value));
+  } else {
+    window.localStorage.removeItem(CREDENTIALS_STORAGE_KEY);
+  }
+}
+
+/**
+ * Get the credentials used by the API client, if any
+ */
+export function getApiCredentials(): ApiCredentials | null {
+  if (credentials || typeof window === "undefined") return credentials;
+  const stored = window.localStorage.getItem(CREDENTIALS_STORAGE_KEY);
+  if (stored) {
+    try {
+      credentials = JSON.parse(stored) as ApiCredentials;
+    } catch {
+      window.localStorage.removeItem(CREDENTIALS_STORAGE_KEY);
+    }
+  }
+  return credentials;
+}
+
+/**
+ * Authorization and organization headers for the current credentials
+ */
+function authHeaders(): Record<string, string> {
+  const current = getApiCredentials();
+  const headers: Record<string, string> = {};
+  if (current?.token) {
+    headers.Authorization = `Bearer ${current.token}`;
+  }
+  if (current?.organizationId) {
+    headers["X-Organization-ID"] = current.organizationId.toString();
+  }
+  return headers;
+}
+
 /**
  * Generic fetch wrapper with error handling
  */
@@ -41,6 +101,7 @@ async function fetchApi<T>(endpoint: string, options?: RequestInit): Promise<T>
       ...options,
       headers: {
         "Content-Type": "application/json",
+        ...authHeaders(),
         ...options?.headers,
       },
     });
@@ -98,7 +159,8 @@ export async function getExpenses(params?: { skip?: number; limit?: number }): P
   const query = searchParams.toString();
   const endpoint = query ? `/api/expenses?${query}` : "/api/expenses";
 
-  return fetchApi<Expense[]>(endpoint);
+  const data = await fetchApi<ExpenseListResponse>(endpoint);
+  return data.expenses;
 }
 
 /**
@@ -119,21 +181,28 @@ export async function createExpense(data: CreateExpenseRequest): Promise<Expense
 }
 
 /**
- * Update an expense
+ * Update an expense. Passing the version it was read at makes the update fail
+ * with 412 if someone changed it since.
  */
-export async function updateExpense(id: number, data: UpdateExpenseRequest): Promise<Expense> {
+export async function updateExpense(
+  id: number,
+  data: UpdateExpenseRequest,
+  version?: number
+): Promise<Expense> {
   return fetchApi<Expense>(`/api/expenses/${id}`, {
     method: "PUT",
     body: JSON.stringify(data),
+    headers: version !== undefined ? { "If-Match": `"${version}"` } : undefined,
   });
 }
 
 /**
- * Delete an expense
+ * Delete an expense, optionally only if it is still at the given version
  */
-export async function deleteExpense(id: number): Promise<void> {
+export async function deleteExpense(id: number, version?: number): Promise<void> {
   return fetchApi<void>(`/api/expenses/${id}`, {
     method: "DELETE",
+    headers: version !== undefined ? { "If-Match": `"${version}"` } : undefined,
   });
 }
 
@@ -11,31 +11,111 @@ export interface HelloResponse {
   timestamp: string;
 }
 
+export type ExpenseStatus = "draft" | "submitted" | "approved" | "rejected" | "reimbursed";
+
+export type ExpenseType = "standard" | "mileage" | "per_diem";
+
+export type ReimbursementStatus = "none" | "reimbursable" | "paid";
+
+/**
+ * Amounts are decimals in the expense's currency; the *_minor fields hold the
+ * same amounts in minor units (e.g. cents). Base amounts are in the
+ * organization's base currency.
+ */
 export interface Expense {
   id: number;
+  organization_id: number;
+  user_id: number;
+  external_id?: string;
+  recurring_expense_id?: number;
+  report_id?: number;
   description: string;
   amount: number;
+  amount_minor: number;
+  currency: string;
+  base_amount: number;
+  base_amount_minor: number;
+  base_currency: string;
+  exchange_rate: string;
+  exchange_rate_date: string | null;
   date: string;
   category: string;
   client_notes?: string;
+  project_id: number | null;
+  cost_center_id: number | null;
+  merchant_id: number | null;
+  attendees: number;
+  type: ExpenseType;
+  mileage?: MileageDetails;
+  per_diem?: PerDiemDetails;
+  tax_jurisdiction: string;
+  tax_code: string;
+  tax_rate: string;
+  tax_rate_id?: number;
+  tax_amount: number;
+  tax_amount_minor: number;
+  net_amount: number;
+  net_amount_minor: number;
+  merchant_vat_number: string;
+  status: ExpenseStatus;
+  approval_count: number;
+  required_approvals: number;
+  submitted_at: string | null;
+  reimbursement: ReimbursementStatus;
+  reimbursement_batch_id?: number;
+  paid_at?: string;
+  /** Increases with every change; send it back in If-Match to update safely */
+  version: number;
   created_at: string;
   updated_at: string;
+  deleted_at: string | null;
   attachments: Attachment[];
   ai_suggestions: AISuggestion[];
+  policy_violations: PolicyViolation[];
+  project?: Project;
+  cost_center?: CostCenter;
+  merchant?: Merchant;
+  tags: Tag[];
+  allocations: ExpenseAllocation[];
+  /** Only on create and update responses */
+  possible_duplicates?: PossibleDuplicate[];
+}
+
+export interface ExpenseListResponse {
+  expenses: Expense[];
+  total: number;
+  skip: number;
+  limit: number;
+  sort: string;
+  filters: Record<string, unknown>;
+  next_cursor?: string;
+  prev_cursor?: string;
 }
 
 export interface Attachment {
   id: number;
+  organization_id: number;
   expense_id: number;
+  user_id: number;
   filename: string;
   content_type: string;
   file_size: number;
   uploaded_at: string;
+  storage_type: string;
+  content_hash?: string;
+  merchant?: string;
+  receipt_amount?: number;
+  receipt_amount_minor?: number;
+  receipt_currency?: string;
+  receipt_date?: string;
+  deleted_at: string | null;
 }
 
 export interface AISuggestion {
   id: number;
+  organization_id: number;
   expense_id: number;
+  allocation_id?: number;
   suggested_category: string;
   suggested_notes: string;
   was_accepted: boolean;
@@ -46,21 +126,164 @@ export interface AISuggestion {
   model_used: string;
 }
 
-export interface CreateExpenseRequest {
+export interface PolicyViolation {
+  id: number;
+  expense_id: number;
+  rule_id: number;
+  rule_name: string;
+  severity: "warn" | "block";
+  message: string;
+  created_at: string;
+}
+
+export interface Tag {
+  id: number;
+  name: string;
+  created_at: string;
+}
+
+export interface Project {
+  id: number;
+  name: string;
+  code: string;
+  client: string;
+  status: string;
+  budget: number;
+  budget_minor: number;
+  currency: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CostCenter {
+  id: number;
+  code: string;
+  name: string;
+  archived: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface Merchant {
+  id: number;
+  name: string;
+  key: string;
+  default_category: string;
+  tax_id: string;
+  created_at: string;
+  updated_at: string;
+}
+
+/**
+ * One line of a split expense
+ */
+export interface ExpenseAllocation {
+  id: number;
+  expense_id: number;
+  position: number;
   description: string;
+  category: string;
+  project_id: number | null;
+  cost_center_id: number | null;
+  user_id: number | null;
+  percent?: number;
   amount: number;
+  amount_minor: number;
+  currency: string;
+  base_amount: number;
+  base_amount_minor: number;
+  base_currency: string;
+  project?: Project;
+  cost_center?: CostCenter;
+  created_at: string;
+}
+
+export interface MileageDetails {
+  distance: number;
+  unit: string;
+  vehicle_type: string;
+  country?: string;
+  rate_id: number;
+  rate: string;
+  rate_unit: string;
+  rate_effective_from: string;
+}
+
+export interface PerDiemDetails {
+  country: string;
+  start: string;
+  end: string;
+  full_days: number;
+  partial_days: number;
+  breakfasts_provided: number;
+  lunches_provided: number;
+  dinners_provided: number;
+  rate_id: number;
+  daily_rate: number;
+  partial_day_percent: number;
+  breakfast_percent: number;
+  lunch_percent: number;
+  dinner_percent: number;
+  rate_effective_from: string;
+  allowance: number;
+  deductions: number;
+}
+
+export interface PossibleDuplicate {
+  pair_id?: number;
+  expense: Expense;
+  score: number;
+  amount_score: number;
+  date_score: number;
+  text_score: number;
+  same_attachment: boolean;
+}
+
+export interface CreateExpenseRequest {
+  description: string;
+  /** Required for standard expenses; mileage and per-diem amounts are computed */
+  amount?: number;
+  /** Defaults to the organization's base currency */
+  currency?: string;
+  type?: ExpenseType;
   date: string;
   category: string;
   client_notes?: string;
+  project_id?: number;
+  cost_center_id?: number;
+  merchant_id?: number;
+  merchant?: string;
+  tags?: string[];
+  attendees?: number;
+  reimbursable?: boolean;
+  tax_jurisdiction?: string;
+  tax_code?: string;
+  tax_rate?: number;
+  tax_amount?: number;
+  merchant_vat_number?: string;
   request_ai_suggestion?: boolean;
 }
 
 export interface UpdateExpenseRequest {
   description?: string;
   amount?: number;
+  currency?: string;
   date?: string;
   category?: string;
   client_notes?: string;
+  /** 0 clears the project, cost center or merchant */
+  project_id?: number;
+  cost_center_id?: number;
+  merchant_id?: number;
+  merchant?: string;
+  tags?: string[];
+  attendees?: number;
+  reimbursable?: boolean;
+  tax_jurisdiction?: string;
+  tax_code?: string;
+  tax_rate?: number;
+  tax_amount?: number;
+  merchant_vat_number?: string;
 }
 
 export interface CategoriesResponse {